@@ -1,54 +1,299 @@
 package config
 
 import (
-	"log"
+	"flag"
+	"fmt"
+	"net/url"
 	"os"
-	"strconv"
+	"strings"
+	"time"
+)
 
-	"github.com/joho/godotenv"
+// Профили задают значения по умолчанию и строгость проверок
+const (
+	ProfileDev  = "dev"
+	ProfileTest = "test"
+	ProfileProd = "prod"
 )
 
+// Config — настройки сервиса. Источники в порядке приоритета (каждый следующий перекрывает предыдущий):
+// значения профиля по умолчанию, файл (env или YAML), переменные окружения, флаги командной строки.
+// Тег env — имя переменной окружения; для любой из них можно задать VAR_FILE с путём к файлу секрета.
+// Тег yaml — ключ в YAML-файле; путь из yaml-ключей через точку — имя флага (-postgres.host).
 type Config struct {
-	DBUser        string
-	DBPassword    string
-	DBHost        string
-	DBPort        string
-	DBName        string
-	MongoURI      string
-	MongoDB       string
-	RedisAddr     string // Адрес Redis
-	RedisPassword string // Пароль Redis
-	RedisDB       int    // Номер базы данных Redis
-	ServerAddr    string // Адрес сервера
-}
-
-func LoadConfig() *Config {
-	err := godotenv.Load("config/config.env")
-	if err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+	Profile string `yaml:"-"`
+
+	Server    ServerConfig    `yaml:"server"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	GraphQL   GraphQLConfig   `yaml:"graphql"`
+	Postgres  PostgresConfig  `yaml:"postgres"`
+	Mongo     MongoConfig     `yaml:"mongo"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Auth      AuthConfig      `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Retention RetentionConfig `yaml:"retention"`
+	Webhooks  WebhookConfig   `yaml:"webhooks"`
+	Orders    OrdersConfig    `yaml:"orders"`
+	Inventory InventoryConfig `yaml:"inventory"`
+	Invoice   InvoiceConfig   `yaml:"invoice"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr" env:"SERVER_ADDR" validate:"required"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" validate:"gt=0"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" validate:"gt=0"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" validate:"gt=0"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" validate:"gt=0"`
+	// Сколько ждать завершения текущих запросов и фоновых задач при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" validate:"gt=0"`
+	// Адреса/подсети прокси через запятую, которым доверяется X-Forwarded-For; пусто — никому
+	TrustedProxies string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// GRPCConfig — gRPC API; использует те же сервисы, лимиты и JWT, что и REST
+type GRPCConfig struct {
+	Enabled bool   `yaml:"enabled" env:"GRPC_ENABLED"`
+	Addr    string `yaml:"addr" env:"GRPC_ADDR" validate:"required"`
+	// Reflection позволяет grpcurl и другим клиентам получать схему без .proto-файлов
+	Reflection bool `yaml:"reflection" env:"GRPC_REFLECTION"`
+}
+
+// GraphQLConfig — ограничения запросов к /graphql; 0 — без ограничения
+type GraphQLConfig struct {
+	MaxDepth      int `yaml:"max_depth" env:"GRAPHQL_MAX_DEPTH" validate:"min=0"`
+	MaxComplexity int `yaml:"max_complexity" env:"GRAPHQL_MAX_COMPLEXITY" validate:"min=0"`
+	// Introspection разрешает запросы схемы (__schema, __type) для GraphiQL и генераторов клиентов
+	Introspection bool `yaml:"introspection" env:"GRAPHQL_INTROSPECTION"`
+}
+
+type PostgresConfig struct {
+	Host           string        `yaml:"host" env:"DB_HOST" validate:"required"`
+	Port           int           `yaml:"port" env:"DB_PORT" validate:"min=1,max=65535"`
+	User           string        `yaml:"user" env:"DB_USER" validate:"required"`
+	Password       string        `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name           string        `yaml:"name" env:"DB_NAME" validate:"required"`
+	SSLMode        string        `yaml:"sslmode" env:"DB_SSLMODE" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	MaxConns       int32         `yaml:"max_conns" env:"DB_MAX_CONNS" validate:"min=1"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" validate:"gt=0"`
+}
+
+// DSN возвращает строку подключения; пароль экранируется
+func (c PostgresConfig) DSN() string {
+	dsn := url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   fmt.Sprintf("%s:%d", c.Host, c.Port),
+		Path:   "/" + c.Name,
 	}
-	return &Config{
-		DBUser:        os.Getenv("DB_USER"),
-		DBPassword:    os.Getenv("DB_PASSWORD"),
-		DBHost:        os.Getenv("DB_HOST"),
-		DBPort:        os.Getenv("DB_PORT"),
-		DBName:        os.Getenv("DB_NAME"),
-		MongoURI:      os.Getenv("MONGO_URI"),
-		MongoDB:       os.Getenv("MONGO_DB"),
-		RedisAddr:     os.Getenv("REDIS_ADDR"),
-		RedisPassword: os.Getenv("REDIS_PASSWORD"),
-		RedisDB:       atoi(os.Getenv("REDIS_DB")),
-		ServerAddr:    os.Getenv("SERVER_ADDR"),
+	query := url.Values{}
+	query.Set("sslmode", c.SSLMode)
+	query.Set("connect_timeout", fmt.Sprint(int(c.ConnectTimeout.Seconds())))
+	dsn.RawQuery = query.Encode()
+	return dsn.String()
+}
+
+type MongoConfig struct {
+	URI      string        `yaml:"uri" env:"MONGO_URI" secret:"true" validate:"required,startswith=mongodb"`
+	Database string        `yaml:"database" env:"MONGO_DB" validate:"required"`
+	Timeout  time.Duration `yaml:"timeout" env:"MONGO_TIMEOUT" validate:"gt=0"`
+}
+
+type RedisConfig struct {
+	Addr         string        `yaml:"addr" env:"REDIS_ADDR" validate:"required,hostname_port"`
+	Password     string        `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB           int           `yaml:"db" env:"REDIS_DB" validate:"min=0,max=15"`
+	DialTimeout  time.Duration `yaml:"dial_timeout" env:"REDIS_DIAL_TIMEOUT" validate:"gt=0"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"REDIS_READ_TIMEOUT" validate:"gt=0"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"REDIS_WRITE_TIMEOUT" validate:"gt=0"`
+}
+
+// CacheConfig — кэш продуктов
+type CacheConfig struct {
+	// Сколько экземпляр хранит продукты в памяти поверх Redis; 0 — не хранить
+	LocalTTL time.Duration `yaml:"local_ttl" env:"CACHE_LOCAL_TTL" validate:"gte=0"`
+	// Сбрасывать кэш по потоку изменений коллекции products (MongoDB должен быть replica set)
+	WatchProducts bool `yaml:"watch_products" env:"CACHE_WATCH_PRODUCTS"`
+	// Пауза перед повторным открытием потока изменений после ошибки
+	WatchRetry time.Duration `yaml:"watch_retry" env:"CACHE_WATCH_RETRY" validate:"gt=0"`
+}
+
+type AuthConfig struct {
+	JWTSecret string        `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true" validate:"required,min=16"`
+	TokenTTL  time.Duration `yaml:"token_ttl" env:"JWT_TTL" validate:"gt=0"`
+}
+
+// RateLimitConfig — лимиты запросов по маршрутам и блокировка входа после неудачных попыток
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// redis — общие лимиты для всех экземпляров (при недоступности Redis — в памяти), memory — только в памяти
+	Store string `yaml:"store" env:"RATE_LIMIT_STORE" validate:"oneof=redis memory"`
+	// Политики маршрутов: "POST /login=ip:20/1m,account:10/15m,lockout;POST /register=ip:5/1h"
+	Policies         string        `yaml:"policies" env:"RATE_LIMIT_POLICIES"`
+	LockoutThreshold int           `yaml:"lockout_threshold" env:"LOGIN_LOCKOUT_THRESHOLD" validate:"min=1"`
+	LockoutBase      time.Duration `yaml:"lockout_base" env:"LOGIN_LOCKOUT_BASE" validate:"gt=0"`
+	LockoutMax       time.Duration `yaml:"lockout_max" env:"LOGIN_LOCKOUT_MAX" validate:"gtefield=LockoutBase"`
+	// Сколько помнить неудачные входы
+	LockoutWindow time.Duration `yaml:"lockout_window" env:"LOGIN_LOCKOUT_WINDOW" validate:"gt=0"`
+}
+
+// RetentionConfig — сроки хранения персональных данных
+type RetentionConfig struct {
+	// Как часто запускается задача хранения
+	Interval time.Duration `yaml:"interval" env:"RETENTION_INTERVAL" validate:"gt=0"`
+	// Учётные записи покупателей без входа дольше этого срока обезличиваются; 0 — не удалять
+	InactiveAccounts time.Duration `yaml:"inactive_accounts" env:"RETENTION_INACTIVE_ACCOUNTS" validate:"gte=0"`
+	// IP клиентов в журнале аудита старше этого срока удаляются; 0 — хранить
+	AuditIP time.Duration `yaml:"audit_ip" env:"RETENTION_AUDIT_IP" validate:"gte=0"`
+}
+
+// WebhookConfig — доставка вебхуков о событиях заказов
+type WebhookConfig struct {
+	// Как часто проверять очередь доставок
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL" validate:"gt=0"`
+	// Таймаут одного запроса к получателю
+	Timeout time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT" validate:"gt=0"`
+	// После стольких неудачных попыток доставка помечается failed
+	MaxAttempts int `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" validate:"gte=1"`
+	// Пауза после первой неудачи, дальше удваивается до BackoffMax
+	BackoffBase time.Duration `yaml:"backoff_base" env:"WEBHOOK_BACKOFF_BASE" validate:"gt=0"`
+	BackoffMax  time.Duration `yaml:"backoff_max" env:"WEBHOOK_BACKOFF_MAX" validate:"gtefield=BackoffBase"`
+	// Сколько доставок отправляется параллельно
+	BatchSize int `yaml:"batch_size" env:"WEBHOOK_BATCH_SIZE" validate:"gte=1,lte=500"`
+}
+
+// OrdersConfig — журнал событий заказов
+type OrdersConfig struct {
+	// Снимок заказа сохраняется через столько событий после предыдущего
+	SnapshotInterval int `yaml:"snapshot_interval" env:"ORDER_SNAPSHOT_INTERVAL" validate:"gte=1"`
+}
+
+// InventoryConfig — склады и резервирование остатков
+type InventoryConfig struct {
+	// Как выбирается склад при резервировании: priority, single_warehouse или most_available
+	Allocation string `yaml:"allocation" env:"INVENTORY_ALLOCATION" validate:"oneof=priority single_warehouse most_available"`
+	// Код склада, на который приходуются начальные остатки каталога и возвраты без известного склада отгрузки
+	DefaultWarehouse string `yaml:"default_warehouse" env:"INVENTORY_DEFAULT_WAREHOUSE" validate:"required"`
+}
+
+// InvoiceConfig — реквизиты продавца и налог для счетов
+type InvoiceConfig struct {
+	SellerName    string  `yaml:"seller_name" env:"SELLER_NAME"`
+	SellerAddress string  `yaml:"seller_address" env:"SELLER_ADDRESS"`
+	SellerTaxID   string  `yaml:"seller_tax_id" env:"SELLER_TAX_ID"`
+	TaxRate       float64 `yaml:"tax_rate" env:"TAX_RATE" validate:"gte=0,lt=1"` // Ставка НДС, цены включают налог (0.2 = 20%)
+	Currency      string  `yaml:"currency" env:"CURRENCY" validate:"iso4217"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL" validate:"oneof=debug info warn error DEBUG INFO WARN ERROR"`
+	// Уровни отдельных пакетов ("repositories=debug,http=warn")
+	Levels string `yaml:"levels" env:"LOG_LEVELS"`
+}
+
+type TracingConfig struct {
+	Exporter     string `yaml:"exporter" env:"TRACING_EXPORTER" validate:"oneof=otlp stdout none"`
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"OTLP_ENDPOINT"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" env:"OTLP_INSECURE"`
+}
+
+// Переменные окружения, которые выбирают профиль и файл настроек
+const (
+	ProfileEnv = "APP_PROFILE"
+	FileEnv    = "CONFIG_FILE"
+)
+
+// Flags — флаги командной строки для настроек; создаются до flag.Parse
+type Flags struct {
+	profile string
+	file    string
+	values  map[string]string // заданные флаги: переменная окружения поля -> значение
+}
+
+// RegisterFlags добавляет в набор флаги -profile, -config и по флагу на каждое поле Config
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	flags := &Flags{values: map[string]string{}}
+	fs.StringVar(&flags.profile, "profile", "", "configuration profile: dev, test or prod (env "+ProfileEnv+")")
+	fs.StringVar(&flags.file, "config", "", "path to a .env or .yaml configuration file (env "+FileEnv+")")
+	for _, f := range fields(&Config{}) {
+		env := f.env
+		fs.Func(f.path, "overrides "+env, func(value string) error {
+			flags.values[env] = value
+			return nil
+		})
 	}
+	return flags
 }
 
-func atoi(str string) int {
-	if str == "" {
-		return 0
+// Load собирает настройки из всех источников и проверяет их. Все найденные проблемы
+// возвращаются разом в *Error. flags может быть nil — тогда учитываются профиль, файл и окружение.
+func Load(flags *Flags) (*Config, error) {
+	if flags == nil {
+		flags = &Flags{}
 	}
-	i, err := strconv.Atoi(str)
+
+	profile := firstNonEmpty(flags.profile, os.Getenv(ProfileEnv), ProfileDev)
+	cfg, err := defaults(profile)
 	if err != nil {
-		log.Fatalf("Error converting string to int: %v", err)
+		return nil, err
+	}
+
+	var problems []string
+	problems = append(problems, loadFile(cfg, firstNonEmpty(flags.file, os.Getenv(FileEnv)))...)
+	problems = append(problems, applyLookup(cfg, "environment", os.LookupEnv)...)
+	problems = append(problems, applyLookup(cfg, "flag", flags.lookup)...)
+	if len(problems) == 0 {
+		problems = cfg.validate()
+	}
+	if len(problems) > 0 {
+		return nil, &Error{Profile: profile, Problems: problems}
+	}
+	return cfg, nil
+}
+
+// Error — отчёт о неверных настройках, по строке на проблему
+type Error struct {
+	Profile  string
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (profile %s):", e.Profile)
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem)
+	}
+	return b.String()
+}
+
+// lookup ищет флаг по имени переменной окружения поля
+func (f *Flags) lookup(env string) (string, bool) {
+	value, ok := f.values[env]
+	return value, ok
+}
+
+// loadFile читает явно указанный файл или, если он не задан, первый существующий
+// из config/config.<профиль>.yaml и config/config.env
+func loadFile(cfg *Config, path string) []string {
+	if path != "" {
+		return readFile(cfg, path)
+	}
+	for _, candidate := range []string{"config/config." + cfg.Profile + ".yaml", "config/config.env"} {
+		if _, err := os.Stat(candidate); err == nil {
+			return readFile(cfg, candidate)
+		}
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
 	}
-	return i
+	return ""
 }
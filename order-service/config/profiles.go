@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// devJWTSecret — ключ подписи для локальной разработки и тестов; в prod запрещён
+const devJWTSecret = "dev-secret-change-me"
+
+// defaults возвращает значения по умолчанию для профиля
+func defaults(profile string) (*Config, error) {
+	cfg := &Config{
+		Profile: profile,
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   15 * time.Second,
+		},
+		GRPC:    GRPCConfig{Enabled: true, Addr: ":9090", Reflection: true},
+		GraphQL: GraphQLConfig{MaxDepth: 10, MaxComplexity: 1000, Introspection: true},
+		Postgres: PostgresConfig{
+			Host:           "localhost",
+			Port:           5432,
+			SSLMode:        "disable",
+			MaxConns:       10,
+			ConnectTimeout: 5 * time.Second,
+		},
+		Mongo: MongoConfig{
+			URI:     "mongodb://localhost:27017",
+			Timeout: 10 * time.Second,
+		},
+		Redis: RedisConfig{
+			Addr:         "localhost:6379",
+			DialTimeout:  5 * time.Second,
+			ReadTimeout:  3 * time.Second,
+			WriteTimeout: 3 * time.Second,
+		},
+		// Поток изменений требует replica set, а MongoDB в docker-compose.yml одиночный
+		Cache: CacheConfig{LocalTTL: 30 * time.Second, WatchRetry: 5 * time.Second},
+		Auth:  AuthConfig{TokenTTL: 24 * time.Hour},
+		RateLimit: RateLimitConfig{
+			Enabled:          true,
+			Store:            "redis",
+			Policies:         "POST /login=ip:20/1m,account:10/15m,lockout;POST /register=ip:5/1h",
+			LockoutThreshold: 5,
+			LockoutBase:      time.Minute,
+			LockoutMax:       time.Hour,
+			LockoutWindow:    24 * time.Hour,
+		},
+		// Три года без входа; IP в журнале аудита — 90 дней
+		Retention: RetentionConfig{Interval: 24 * time.Hour, InactiveAccounts: 3 * 365 * 24 * time.Hour, AuditIP: 90 * 24 * time.Hour},
+		Webhooks: WebhookConfig{
+			PollInterval: 2 * time.Second,
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			BackoffBase:  30 * time.Second,
+			BackoffMax:   6 * time.Hour,
+			BatchSize:    20,
+		},
+		Orders:    OrdersConfig{SnapshotInterval: 20},
+		Inventory: InventoryConfig{Allocation: "priority", DefaultWarehouse: "main"},
+		Invoice:   InvoiceConfig{Currency: "USD"},
+		Log:       LogConfig{Level: "info"},
+		Tracing:   TracingConfig{Exporter: "none"},
+	}
+
+	switch profile {
+	case ProfileDev:
+		// Совпадает с docker-compose.yml
+		cfg.Postgres.User, cfg.Postgres.Password, cfg.Postgres.Name = "user", "password", "orders_db"
+		cfg.Mongo.Database = "orders_db"
+		cfg.Auth.JWTSecret = devJWTSecret
+		cfg.Log.Level = "debug"
+	case ProfileTest:
+		cfg.Postgres.User, cfg.Postgres.Password, cfg.Postgres.Name = "user", "password", "orders_test"
+		cfg.Mongo.Database = "orders_test"
+		cfg.Redis.DB = 1
+		cfg.RateLimit.Store = "memory"
+		cfg.Retention.InactiveAccounts = 0
+		cfg.Retention.AuditIP = 0
+		cfg.Auth.JWTSecret = devJWTSecret
+		cfg.Server.ShutdownTimeout = time.Second
+		cfg.Log.Level = "warn"
+	case ProfileProd:
+		// Учётные данные и ключи задаются только явно
+		cfg.GRPC.Reflection = false
+		cfg.GraphQL.Introspection = false
+	default:
+		return nil, fmt.Errorf("unknown configuration profile %q: expected %s, %s or %s", profile, ProfileDev, ProfileTest, ProfileProd)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// field — поле настроек с тегами env и yaml
+type field struct {
+	path   string // yaml-путь через точку: postgres.host
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields возвращает все поля настроек, у которых есть тег env
+func fields(cfg *Config) []field {
+	var result []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			path := prefix + name
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), path+".")
+				continue
+			}
+			if env := sf.Tag.Get("env"); env != "" {
+				result = append(result, field{path: path, env: env, secret: sf.Tag.Get("secret") == "true", value: v.Field(i)})
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return result
+}
+
+// readFile применяет файл настроек: .yaml/.yml — вложенные ключи, иначе — формат .env
+func readFile(cfg *Config, path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{fmt.Sprintf("config file: %v", err)}
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return []string{fmt.Sprintf("%s: %v", path, err)}
+		}
+		return nil
+	default:
+		values, err := godotenv.Parse(bytes.NewReader(data))
+		if err != nil {
+			return []string{fmt.Sprintf("%s: %v", path, err)}
+		}
+		return applyLookup(cfg, path, func(key string) (string, bool) {
+			value, ok := values[key]
+			return value, ok
+		})
+	}
+}
+
+// applyLookup применяет значения из источника source (окружение, .env-файл, флаги).
+// VAR_FILE указывает на файл, содержимое которого становится значением VAR.
+func applyLookup(cfg *Config, source string, lookup func(string) (string, bool)) []string {
+	var problems []string
+	for _, f := range fields(cfg) {
+		value, ok := lookup(f.env)
+		if file, fileOK := lookup(f.env + "_FILE"); fileOK && file != "" {
+			if ok && value != "" {
+				problems = append(problems, fmt.Sprintf("%s: both %s and %s_FILE are set", source, f.env, f.env))
+				continue
+			}
+			content, err := os.ReadFile(file)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s_FILE: %v", source, f.env, err))
+				continue
+			}
+			value, ok = strings.TrimRight(string(content), "\r\n"), true
+		}
+		if !ok {
+			continue
+		}
+		if err := setValue(f.value, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s: %v", source, f.env, err))
+		}
+	}
+	return problems
+}
+
+// setValue разбирает строку в значение поля. Пустая строка сбрасывает только строковые поля,
+// чтобы "REDIS_DB=" не ломал запуск.
+func setValue(v reflect.Value, value string) error {
+	if value == "" && v.Kind() != reflect.String {
+		return nil
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// LogValue выводит настройки в лог группами по разделам; значения секретов скрываются
+func (c *Config) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("profile", c.Profile)}
+	sections := map[string][]slog.Attr{}
+	var order []string
+	for _, f := range fields(c) {
+		section, name, _ := strings.Cut(f.path, ".")
+		if _, ok := sections[section]; !ok {
+			order = append(order, section)
+		}
+		value := slog.AnyValue(f.value.Interface())
+		if d, ok := f.value.Interface().(time.Duration); ok {
+			value = slog.StringValue(d.String())
+		}
+		if f.secret {
+			value = slog.BoolValue(!f.value.IsZero())
+			name += "_set"
+		}
+		sections[section] = append(sections[section], slog.Attr{Key: name, Value: value})
+	}
+	for _, section := range order {
+		attrs = append(attrs, slog.Attr{Key: section, Value: slog.GroupValue(sections[section]...)})
+	}
+	return slog.GroupValue(attrs...)
+}
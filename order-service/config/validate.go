@@ -0,0 +1,117 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"order-service/logging"
+	"order-service/ratelimit"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// В отчёте поле называется yaml-путём и переменной окружения: postgres.host (DB_HOST)
+	v.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if env := sf.Tag.Get("env"); env != "" {
+			return name + " (" + env + ")"
+		}
+		return name
+	})
+	return v
+}
+
+// Validate проверяет настройки; ошибка — *Error со всеми найденными проблемами
+func (c *Config) Validate() error {
+	if problems := c.validate(); len(problems) > 0 {
+		return &Error{Profile: c.Profile, Problems: problems}
+	}
+	return nil
+}
+
+func (c *Config) validate() []string {
+	var problems []string
+
+	var errs validator.ValidationErrors
+	if err := validate.Struct(c); errors.As(err, &errs) {
+		for _, e := range errs {
+			// Namespace начинается с имени типа: Config.postgres.host (DB_HOST)
+			_, name, _ := strings.Cut(e.Namespace(), ".")
+			problems = append(problems, fmt.Sprintf("%s: %s", name, describe(e)))
+		}
+	} else if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if _, err := logging.ParseLevels("", c.Log.Levels); err != nil {
+		problems = append(problems, fmt.Sprintf("log.levels (LOG_LEVELS): %v", err))
+	}
+
+	if _, err := ratelimit.ParsePolicies(c.RateLimit.Policies); err != nil {
+		problems = append(problems, fmt.Sprintf("rate_limit.policies (RATE_LIMIT_POLICIES): %v", err))
+	}
+
+	if c.Profile == ProfileProd {
+		if c.Auth.JWTSecret != "" && (c.Auth.JWTSecret == devJWTSecret || len(c.Auth.JWTSecret) < 32) {
+			problems = append(problems, "auth.jwt_secret (JWT_SECRET): must be a unique value of at least 32 characters in prod")
+		}
+		if c.Postgres.Password == "" {
+			problems = append(problems, "postgres.password (DB_PASSWORD): is required in prod")
+		}
+		if c.Log.Level == "debug" {
+			problems = append(problems, "log.level (LOG_LEVEL): debug is not allowed in prod")
+		}
+	}
+	return problems
+}
+
+// describe переводит нарушенное правило в понятное сообщение
+func describe(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s (got %q)", e.Param(), fmt.Sprint(e.Value()))
+	case "min", "gte":
+		if e.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", e.Param())
+		}
+		return fmt.Sprintf("must be greater than or equal to %s", e.Param())
+	case "max", "lte":
+		return fmt.Sprintf("must be less than or equal to %s", e.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", e.Param())
+	case "gtefield":
+		return "must not be less than " + snakeCase(e.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", e.Param())
+	case "startswith":
+		return fmt.Sprintf("must start with %q", e.Param())
+	case "hostname_port":
+		return "must be host:port"
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	}
+	return fmt.Sprintf("failed %q check", e.Tag())
+}
+
+// snakeCase переводит имя поля Go в имя ключа: LockoutBase -> lockout_base
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -3,20 +3,31 @@ package db
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"order-service/config"
+	"order-service/logging"
 
 	"github.com/jackc/pgx/v5"
 )
 
+var logger = logging.For("db")
+
+// legacyMigrations применялись до появления таблицы schema_migrations.
+// Если база уже содержит все базовые таблицы, эти файлы считаются применёнными,
+// иначе повторный запуск 0002 удалил бы пользователей.
+var legacyMigrations = []string{
+	"0001_create_tables.sql",
+	"0002_recreate_users_table.sql",
+}
+
 // Migrate выполняет миграции базы данных
 func Migrate(db *pgx.Conn) error {
+	ctx := context.Background()
+
 	// Определяем путь к директории с миграциями
 	// Сначала проверяем абсолютный путь для Docker
 	migrationsDir := "/app/db/migrations"
@@ -25,43 +36,45 @@ func Migrate(db *pgx.Conn) error {
 		migrationsDir = "db/migrations"
 	}
 
-	log.Printf("Используется директория миграций: %s", migrationsDir)
+	logger.Info("Using migrations directory", "dir", migrationsDir)
 
-	// Проверяем существование всех необходимых таблиц
-	requiredTables := []string{"users", "products", "orders", "payments"}
-	var missingTables []string
+	// Таблица с уже применёнными миграциями
+	_, err := db.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version VARCHAR(255) PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
 
-	for _, table := range requiredTables {
-		var exists bool
-		err := db.QueryRow(context.Background(), `
-			SELECT EXISTS (
-				SELECT FROM information_schema.tables 
-				WHERE table_schema = 'public' 
-				AND table_name = $1
-			);
-		`, table).Scan(&exists)
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
 
+	// База создана до появления schema_migrations: отмечаем старые миграции как применённые
+	if len(applied) == 0 {
+		legacy, err := hasLegacySchema(db)
 		if err != nil {
-			return fmt.Errorf("ошибка при проверке существования таблицы %s: %v", table, err)
+			return err
 		}
-
-		if !exists {
-			missingTables = append(missingTables, table)
+		if legacy {
+			logger.Info("Existing schema found, marking baseline migrations as applied")
+			for _, file := range legacyMigrations {
+				if _, err := db.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT DO NOTHING`, file); err != nil {
+					return fmt.Errorf("failed to record migration %s: %w", file, err)
+				}
+				applied[file] = true
+			}
 		}
 	}
 
-	// Если все таблицы существуют, пропускаем миграции
-	if len(missingTables) == 0 {
-		log.Println("Все необходимые таблицы уже существуют, пропускаем миграции")
-		return nil
-	}
-
-	log.Printf("Отсутствуют таблицы: %v", missingTables)
-
 	// Получаем список файлов миграций
-	files, err := ioutil.ReadDir(migrationsDir)
+	files, err := os.ReadDir(migrationsDir)
 	if err != nil {
-		return fmt.Errorf("ошибка при чтении директории миграций: %v", err)
+		return fmt.Errorf("failed to read migrations directory: %w", err)
 	}
 
 	// Фильтруем только .sql файлы и сортируем их
@@ -73,42 +86,93 @@ func Migrate(db *pgx.Conn) error {
 	}
 	sort.Strings(migrationFiles)
 
-	log.Printf("Найдено файлов миграций: %d", len(migrationFiles))
+	logger.Info("Migration files found", "count", len(migrationFiles))
 
-	// Применяем каждую миграцию
+	// Применяем каждую ещё не применённую миграцию в отдельной транзакции
 	for _, file := range migrationFiles {
-		log.Printf("Применение миграции: %s", file)
-		content, err := ioutil.ReadFile(filepath.Join(migrationsDir, file))
+		if applied[file] {
+			continue
+		}
+
+		logger.Info("Applying migration", "file", file)
+		content, err := os.ReadFile(filepath.Join(migrationsDir, file))
 		if err != nil {
-			return fmt.Errorf("ошибка при чтении файла миграции %s: %v", file, err)
+			return fmt.Errorf("failed to read migration file %s: %w", file, err)
 		}
 
-		// Выполняем SQL-запросы из файла
-		_, err = db.Exec(context.Background(), string(content))
+		tx, err := db.Begin(ctx)
 		if err != nil {
-			return fmt.Errorf("ошибка при выполнении миграции %s: %v", file, err)
+			return fmt.Errorf("failed to begin transaction for %s: %w", file, err)
+		}
+
+		// Выполняем SQL-запросы из файла
+		if _, err := tx.Exec(ctx, string(content)); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("migration %s failed: %w", file, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, file); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("failed to record migration %s: %w", file, err)
 		}
-		log.Printf("Миграция %s успешно применена", file)
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", file, err)
+		}
+		logger.Info("Migration applied", "file", file)
 	}
 
-	log.Println("Все миграции успешно применены")
+	logger.Info("All migrations applied")
 	return nil
 }
 
-func MigrateConfig(cfg *config.Config) error {
-	log.Println("Starting database migration...")
+// appliedMigrations возвращает множество уже применённых миграций
+func appliedMigrations(db *pgx.Conn) (map[string]bool, error) {
+	rows, err := db.Query(context.Background(), `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// hasLegacySchema проверяет, существуют ли все таблицы из базовых миграций
+func hasLegacySchema(db *pgx.Conn) (bool, error) {
+	requiredTables := []string{"users", "products", "orders", "payments"}
 
-	// Формируем строку подключения к базе данных
-	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.DBUser,
-		cfg.DBPassword,
-		cfg.DBHost,
-		cfg.DBPort,
-		cfg.DBName,
-	)
+	for _, table := range requiredTables {
+		var exists bool
+		err := db.QueryRow(context.Background(), `
+			SELECT EXISTS (
+				SELECT FROM information_schema.tables
+				WHERE table_schema = 'public'
+				AND table_name = $1
+			);
+		`, table).Scan(&exists)
+
+		if err != nil {
+			return false, fmt.Errorf("failed to check whether table %s exists: %w", table, err)
+		}
+
+		if !exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func MigrateConfig(cfg *config.Config) error {
+	logger.Info("Starting database migration")
 
 	// Подключаемся к базе данных
-	conn, err := pgx.Connect(context.Background(), dbURL)
+	conn, err := pgx.Connect(context.Background(), cfg.Postgres.DSN())
 	if err != nil {
 		return fmt.Errorf("unable to connect to database: %v", err)
 	}
@@ -119,6 +183,6 @@ func MigrateConfig(cfg *config.Config) error {
 		return fmt.Errorf("error during migration: %v", err)
 	}
 
-	log.Println("Database migration completed successfully")
+	logger.Info("Database migration completed successfully")
 	return nil
 }
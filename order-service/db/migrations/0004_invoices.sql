@@ -0,0 +1,28 @@
+-- Счётчики номеров документов. Номер выдаётся в той же транзакции,
+-- что и запись документа, поэтому при откате пропусков не возникает
+-- (в отличие от SEQUENCE).
+CREATE TABLE IF NOT EXISTS document_sequences (
+    doc_type VARCHAR(20) PRIMARY KEY,
+    last_number BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO document_sequences (doc_type) VALUES ('invoice'), ('credit_note')
+ON CONFLICT (doc_type) DO NOTHING;
+
+-- Выпущенные счета и кредит-ноты: снимок данных и хэш отрисованного PDF
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    doc_type VARCHAR(20) NOT NULL,
+    sequence BIGINT NOT NULL,
+    number VARCHAR(32) NOT NULL UNIQUE,
+    order_id UUID NOT NULL REFERENCES orders(id),
+    refund_id UUID REFERENCES refunds(id),
+    data JSONB NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    issued_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (doc_type, sequence)
+);
+
+-- Один счёт на заказ и одна кредит-нота на возврат средств
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_order_invoice ON invoices(order_id) WHERE doc_type = 'invoice';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_refund ON invoices(refund_id) WHERE refund_id IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Позиции заказов и возвратов ссылаются на SKU варианта продукта.
+-- У перенесённых продуктов SKU единственного варианта совпадает с ID продукта.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+UPDATE order_items SET sku = product_id WHERE sku IS NULL;
+ALTER TABLE order_items ALTER COLUMN sku SET NOT NULL;
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_order_id_product_id_key;
+ALTER TABLE order_items ADD CONSTRAINT order_items_order_id_sku_key UNIQUE (order_id, sku);
+
+ALTER TABLE order_return_items ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+UPDATE order_return_items SET sku = product_id WHERE sku IS NULL;
+ALTER TABLE order_return_items ALTER COLUMN sku SET NOT NULL;
+ALTER TABLE order_return_items DROP CONSTRAINT IF EXISTS order_return_items_pkey;
+ALTER TABLE order_return_items ADD PRIMARY KEY (return_id, sku);
@@ -0,0 +1,4 @@
+-- Номер версии для оптимистичной блокировки: увеличивается при каждом изменении строки
+-- и проверяется в условии UPDATE. Клиенты получают его в ETag и передают в If-Match.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
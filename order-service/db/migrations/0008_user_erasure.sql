@@ -0,0 +1,15 @@
+-- Пользователи больше не удаляются физически: по запросу на удаление персональные данные
+-- обезличиваются (erased_at), а заказы и финансовые документы остаются для бухгалтерии.
+-- Каскадное удаление заказов вместе с пользователем заменено запретом удаления.
+-- NOT VALID: старые заказы без пользователя не мешают миграции, новые проверяются.
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_user_id_fkey;
+ALTER TABLE orders ADD CONSTRAINT orders_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT NOT VALID;
+
+-- Время последнего входа — для удаления неактивных учётных записей по сроку хранения
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS erased_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_last_activity ON users ((COALESCE(last_login_at, created_at)))
+    WHERE erased_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_order_returns_user_id ON order_returns(user_id);
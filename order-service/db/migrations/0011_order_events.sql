@@ -0,0 +1,80 @@
+-- Журнал событий заказов — источник истины для агрегата заказа.
+-- Версия события совпадает с версией заказа после него (ETag); пара (order_id, version) уникальна,
+-- поэтому из двух одновременных изменений одной версии сохраняется только первое.
+CREATE TABLE IF NOT EXISTS order_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_id UUID NOT NULL,
+    version BIGINT NOT NULL CHECK (version > 0),
+    type TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    actor_id UUID,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (order_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_events_occurred_at ON order_events(occurred_at);
+
+-- Журнал только дополняется; проекции при необходимости пересобираются из него (-replay-orders)
+CREATE OR REPLACE FUNCTION order_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'order_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS order_events_no_update ON order_events;
+CREATE TRIGGER order_events_no_update BEFORE UPDATE OR DELETE ON order_events
+    FOR EACH ROW EXECUTE FUNCTION order_events_append_only();
+
+DROP TRIGGER IF EXISTS order_events_no_truncate ON order_events;
+CREATE TRIGGER order_events_no_truncate BEFORE TRUNCATE ON order_events
+    FOR EACH STATEMENT EXECUTE FUNCTION order_events_append_only();
+
+-- Снимок состояния заказа на версии version; загрузка начинается с него и дочитывает следующие события
+CREATE TABLE IF NOT EXISTS order_snapshots (
+    order_id UUID PRIMARY KEY,
+    version BIGINT NOT NULL,
+    state JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Сводка по заказам пользователя; удалённые заказы не учитываются, отменённые не входят в сумму
+CREATE TABLE IF NOT EXISTS order_user_summaries (
+    user_id UUID PRIMARY KEY,
+    orders_count INT NOT NULL DEFAULT 0,
+    cancelled_count INT NOT NULL DEFAULT 0,
+    total_spent DECIMAL(12,2) NOT NULL DEFAULT 0,
+    last_order_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Существующие заказы начинают поток с события order.imported с их текущим состоянием
+INSERT INTO order_events (order_id, version, type, data, occurred_at)
+SELECT o.id, o.version, 'order.imported',
+       jsonb_build_object(
+           'id', o.id,
+           'user_id', o.user_id,
+           'items', COALESCE((
+               SELECT jsonb_agg(jsonb_build_object(
+                          'product_id', i.product_id, 'sku', i.sku, 'quantity', i.quantity, 'price', i.price)
+                      ORDER BY i.sku)
+               FROM order_items i WHERE i.order_id = o.id), '[]'::jsonb),
+           'total_price', o.total_price,
+           'status', o.status,
+           'version', o.version,
+           'created_at', o.created_at,
+           'updated_at', o.updated_at,
+           'deleted_at', o.deleted_at),
+       o.updated_at
+FROM orders o
+ON CONFLICT (order_id, version) DO NOTHING;
+
+INSERT INTO order_user_summaries (user_id, orders_count, cancelled_count, total_spent, last_order_at)
+SELECT user_id,
+       COUNT(*),
+       COUNT(*) FILTER (WHERE status = 'cancelled'),
+       COALESCE(SUM(total_price) FILTER (WHERE status <> 'cancelled'), 0),
+       MAX(created_at)
+FROM orders
+WHERE deleted_at IS NULL AND user_id IS NOT NULL
+GROUP BY user_id
+ON CONFLICT (user_id) DO NOTHING;
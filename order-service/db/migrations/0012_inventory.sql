@@ -0,0 +1,76 @@
+-- Склады, с которых отгружаются заказы. priority — порядок выбора при резервировании:
+-- склад с меньшим значением предпочтительнее. Неактивный склад не участвует в резервировании.
+CREATE TABLE IF NOT EXISTS warehouses (
+    id UUID PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    priority INT NOT NULL DEFAULT 100,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    version BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Склад по умолчанию (inventory.default_warehouse): на него приходуются начальные остатки каталога
+INSERT INTO warehouses (id, code, name, priority)
+VALUES (gen_random_uuid(), 'main', 'Main warehouse', 1)
+ON CONFLICT (code) DO NOTHING;
+
+-- Журнал движений остатков — источник истины для складских остатков.
+-- quantity положительно у всех движений, кроме корректировки, где знак задаёт направление.
+-- receipt и adjustment меняют физический остаток, reservation и release — резерв,
+-- shipment списывает зарезервированный товар со склада.
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id),
+    sku TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('receipt', 'reservation', 'release', 'shipment', 'adjustment')),
+    quantity INT NOT NULL CHECK (quantity > 0 OR (type = 'adjustment' AND quantity <> 0)),
+    order_id UUID,
+    reason TEXT NOT NULL,
+    actor_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_sku ON stock_movements(sku, warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_order ON stock_movements(order_id) WHERE order_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS stock_movements_no_update ON stock_movements;
+CREATE TRIGGER stock_movements_no_update BEFORE UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only();
+
+DROP TRIGGER IF EXISTS stock_movements_no_truncate ON stock_movements;
+CREATE TRIGGER stock_movements_no_truncate BEFORE TRUNCATE ON stock_movements
+    FOR EACH STATEMENT EXECUTE FUNCTION stock_movements_append_only();
+
+-- Остатки SKU на складах, вычисленные по журналу; available — сколько можно пообещать покупателю
+CREATE OR REPLACE VIEW stock_levels AS
+SELECT warehouse_id,
+       sku,
+       on_hand,
+       reserved,
+       on_hand - reserved AS available,
+       updated_at
+FROM (
+    SELECT warehouse_id,
+           sku,
+           COALESCE(SUM(CASE type
+               WHEN 'receipt' THEN quantity
+               WHEN 'adjustment' THEN quantity
+               WHEN 'shipment' THEN -quantity
+           END), 0)::INT AS on_hand,
+           COALESCE(SUM(CASE type
+               WHEN 'reservation' THEN quantity
+               WHEN 'release' THEN -quantity
+               WHEN 'shipment' THEN -quantity
+           END), 0)::INT AS reserved,
+           MAX(created_at) AS updated_at
+    FROM stock_movements
+    GROUP BY warehouse_id, sku
+) totals;
@@ -0,0 +1,2 @@
+-- По одной заявке на возврат — не больше одного возврата средств
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refunds_return_id ON refunds(return_id);
@@ -0,0 +1,10 @@
+-- IP клиента для записей журнала аудита. Он хранится вне цепочки хэшей: запись audit_log
+-- неизменна, а IP — персональные данные, которые удаляются по сроку хранения (retention.audit_ip)
+-- и при удалении пользователя. У записей до этой миграции IP остаётся в audit_log.ip.
+CREATE TABLE IF NOT EXISTS audit_log_ips (
+    entry_id BIGINT PRIMARY KEY REFERENCES audit_log(id),
+    ip VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_ips_created_at ON audit_log_ips(created_at);
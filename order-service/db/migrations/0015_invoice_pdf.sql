@@ -0,0 +1,4 @@
+-- Выпущенный PDF хранится целиком и отдаётся как есть: обновление библиотеки отрисовки
+-- или шаблона не меняет уже выпущенные документы. У документов, выпущенных раньше,
+-- файл сохраняется при первой выгрузке, если повторная отрисовка совпала с хэшем.
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS pdf BYTEA;
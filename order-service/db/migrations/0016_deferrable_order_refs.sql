@@ -0,0 +1,23 @@
+-- Пересборка проекций (-replay-orders) очищает orders и заполняет её заново из журнала событий
+-- в одной транзакции. Ссылки на заказы проверяются при её фиксации: каждая ссылающаяся строка
+-- должна указывать на восстановленный заказ. Заказы удаляются только мягко (deleted_at),
+-- поэтому каскадное удаление платежей и истории статусов не нужно.
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_order_id_fkey;
+ALTER TABLE payments ADD CONSTRAINT payments_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders(id) DEFERRABLE INITIALLY IMMEDIATE;
+
+ALTER TABLE order_status_history DROP CONSTRAINT IF EXISTS order_status_history_order_id_fkey;
+ALTER TABLE order_status_history ADD CONSTRAINT order_status_history_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders(id) DEFERRABLE INITIALLY IMMEDIATE;
+
+ALTER TABLE order_returns DROP CONSTRAINT IF EXISTS order_returns_order_id_fkey;
+ALTER TABLE order_returns ADD CONSTRAINT order_returns_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders(id) DEFERRABLE INITIALLY IMMEDIATE;
+
+ALTER TABLE refunds DROP CONSTRAINT IF EXISTS refunds_order_id_fkey;
+ALTER TABLE refunds ADD CONSTRAINT refunds_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders(id) DEFERRABLE INITIALLY IMMEDIATE;
+
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_order_id_fkey;
+ALTER TABLE invoices ADD CONSTRAINT invoices_order_id_fkey
+    FOREIGN KEY (order_id) REFERENCES orders(id) DEFERRABLE INITIALLY IMMEDIATE;
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongoMigration — миграция документов MongoDB
+type mongoMigration struct {
+	Version string
+	Up      func(ctx context.Context, db *mongo.Database) error
+}
+
+// mongoMigrations применяются по порядку; применённые версии хранятся в коллекции schema_migrations
+var mongoMigrations = []mongoMigration{
+	{Version: "0001_product_default_variants", Up: migrateProductDefaultVariants},
+	{Version: "0002_product_versions", Up: migrateProductVersions},
+}
+
+// MigrateMongo применяет ещё не применённые миграции MongoDB
+func MigrateMongo(db *mongo.Database) error {
+	ctx := context.Background()
+	migrations := db.Collection("schema_migrations")
+
+	for _, migration := range mongoMigrations {
+		count, err := migrations.CountDocuments(ctx, bson.M{"_id": migration.Version})
+		if err != nil {
+			return fmt.Errorf("failed to read mongo migrations: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		logger.Info("Applying mongo migration", "version", migration.Version)
+		if err := migration.Up(ctx, db); err != nil {
+			return fmt.Errorf("mongo migration %s failed: %w", migration.Version, err)
+		}
+		if _, err := migrations.InsertOne(ctx, bson.M{"_id": migration.Version, "applied_at": time.Now()}); err != nil {
+			return fmt.Errorf("failed to record mongo migration %s: %w", migration.Version, err)
+		}
+		logger.Info("Mongo migration applied", "version", migration.Version)
+	}
+	return nil
+}
+
+// migrateProductDefaultVariants превращает плоские продукты в продукты с одним вариантом.
+// SKU варианта совпадает с ID продукта, поэтому старые корзины и позиции заказов
+// (в них хранился ID продукта) продолжают указывать на тот же товар.
+func migrateProductDefaultVariants(ctx context.Context, db *mongo.Database) error {
+	products := db.Collection("products")
+	filter := bson.M{"$or": bson.A{
+		bson.M{"variants": bson.M{"$exists": false}},
+		bson.M{"variants": bson.M{"$size": 0}},
+	}}
+
+	cursor, err := products.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	const batchSize = 500
+	var batch []mongo.WriteModel
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := products.BulkWrite(ctx, batch)
+		batch = batch[:0]
+		return err
+	}
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+
+		sku, _ := doc["idString"].(string)
+		if sku == "" {
+			if oid, ok := doc["_id"].(primitive.ObjectID); ok {
+				sku = oid.Hex()
+			} else {
+				sku = fmt.Sprint(doc["_id"])
+			}
+		}
+
+		set := bson.M{"variants": bson.A{bson.M{
+			"sku":   sku,
+			"price": valueOrZero(doc["price"]),
+			"stock": valueOrZero(doc["stock"]),
+		}}}
+		if _, ok := doc["idString"]; !ok {
+			set["idString"] = sku
+		}
+
+		batch = append(batch, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": doc["_id"]}).
+			SetUpdate(bson.M{"$set": set}))
+		migrated++
+
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	logger.Info("Migrated products to default variants", "count", migrated)
+	return nil
+}
+
+// migrateProductVersions проставляет начальную версию продуктам, созданным до появления ETag
+func migrateProductVersions(ctx context.Context, db *mongo.Database) error {
+	result, err := db.Collection("products").UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": int64(1)}})
+	if err != nil {
+		return err
+	}
+	logger.Info("Set initial version on products", "count", result.ModifiedCount)
+	return nil
+}
+
+func valueOrZero(value interface{}) interface{} {
+	if value == nil {
+		return 0
+	}
+	return value
+}
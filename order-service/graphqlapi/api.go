@@ -0,0 +1,115 @@
+// Package graphqlapi — GraphQL API витрины: пользователи, каталог, корзины и заказы со связями
+// между ними поверх тех же сервисов, что и REST. Схема — schema.graphql. Связанные продукты
+// и пользователи загружаются пакетами (loader), глубина и сложность запроса ограничены до выполнения.
+package graphqlapi
+
+import (
+	"context"
+	_ "embed"
+	"order-service/logging"
+	"order-service/models"
+	"order-service/services"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+var logger = logging.For("graphql")
+
+// Settings — ограничения запросов; 0 — без ограничения
+type Settings struct {
+	MaxDepth      int
+	MaxComplexity int
+	// Introspection разрешает запросы __schema и __type
+	Introspection bool
+}
+
+// maxParallelism — сколько резолверов одного запроса выполняется параллельно
+const maxParallelism = 10
+
+// API выполняет GraphQL-запросы
+type API struct {
+	schema   *graphql.Schema
+	limits   *queryLimits
+	users    *services.UserService
+	products *services.ProductService
+}
+
+func NewAPI(users *services.UserService, products *services.ProductService, cart *services.CartService, orders *services.OrderService, settings Settings) (*API, error) {
+	limits, err := newQueryLimits(schemaSDL, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []graphql.SchemaOpt{
+		graphql.UseStringDescriptions(),
+		graphql.MaxParallelism(maxParallelism),
+		graphql.Logger(panicHandler{}),
+		graphql.PanicHandler(panicHandler{}),
+	}
+	if !settings.Introspection {
+		opts = append(opts, graphql.DisableIntrospection())
+	}
+	schema, err := graphql.ParseSchema(schemaSDL, &queryResolver{users: users, products: products, cart: cart, orders: orders}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &API{schema: schema, limits: limits, users: users, products: products}, nil
+}
+
+// Exec выполняет запрос от имени claims; nil — анонимный запрос
+func (a *API) Exec(ctx context.Context, claims *services.TokenClaims, query, operationName string, variables map[string]any) *graphql.Response {
+	if errs := a.limits.check(query, operationName, variables); errs != nil {
+		return &graphql.Response{Errors: errs}
+	}
+
+	ctx = context.WithValue(ctx, claimsKey{}, claims)
+	ctx = context.WithValue(ctx, loadersKey{}, &loaders{
+		products: newLoader(ctx, a.products.GetProductsByIDs),
+		users:    newLoader(ctx, a.users.GetUsersByIDs),
+	})
+	response := a.schema.Exec(ctx, query, operationName, variables)
+	withErrorCodes(ctx, response.Errors)
+	return response
+}
+
+type claimsKey struct{}
+
+// claimsFromContext возвращает данные токена; nil — анонимный запрос
+func claimsFromContext(ctx context.Context) *services.TokenClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*services.TokenClaims)
+	return claims
+}
+
+// requireClaims — как для защищённых маршрутов REST: без токена доступа нет
+func requireClaims(ctx context.Context) (*services.TokenClaims, error) {
+	if claims := claimsFromContext(ctx); claims != nil {
+		return claims, nil
+	}
+	return nil, services.ErrTokenRequired
+}
+
+// checkIncludeDeleted — удалённые записи видит только администратор
+func checkIncludeDeleted(ctx context.Context, includeDeleted bool) error {
+	if !includeDeleted {
+		return nil
+	}
+	if claims := claimsFromContext(ctx); claims == nil || !claims.IsAdmin() {
+		return services.ErrDeletedAdminOnly
+	}
+	return nil
+}
+
+// loaders — пакетная загрузка связанных записей в пределах одного запроса
+type loaders struct {
+	products *loader[string, *models.Product]
+	users    *loader[string, *models.User]
+}
+
+type loadersKey struct{}
+
+func loadersFromContext(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,77 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"runtime/debug"
+
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// codeInternal — код ошибок без категории, как в REST
+const codeInternal = "internal_error"
+
+// errorKinds сопоставляет категории ошибок с кодами по умолчанию — так же, как problemKinds в REST
+var errorKinds = []struct {
+	kind error
+	code string
+}{
+	{models.ErrNotFound, "not_found"},
+	{models.ErrConflict, "conflict"},
+	{models.ErrValidation, "validation_failed"},
+	{models.ErrForbidden, "forbidden"},
+	{models.ErrUnauthorized, "unauthorized"},
+	{models.ErrPreconditionFailed, "precondition_failed"},
+	{models.ErrPreconditionRequired, "precondition_required"},
+	{models.ErrUnsupportedMediaType, "unsupported_media_type"},
+	{models.ErrTooManyRequests, "too_many_requests"},
+}
+
+// withErrorCodes дополняет ошибки резолверов стабильным кодом (extensions.code) и ошибками
+// полей (extensions.fields). Ошибки без категории логируются, а клиент получает internal_error
+// без подробностей.
+func withErrorCodes(ctx context.Context, errs []*gqlerrors.QueryError) {
+	for _, e := range errs {
+		if e.ResolverError == nil {
+			continue
+		}
+		e.Extensions = errorExtensions(e.ResolverError)
+		if e.Extensions == nil {
+			logger.ErrorContext(ctx, "graphql resolver failed", "path", e.Path, "error", e.ResolverError)
+			e.Message = "internal error"
+			e.Extensions = map[string]any{"code": codeInternal}
+		}
+	}
+}
+
+func errorExtensions(err error) map[string]any {
+	for _, k := range errorKinds {
+		if !errors.Is(err, k.kind) {
+			continue
+		}
+		extensions := map[string]any{"code": k.code}
+		var typed *models.Error
+		if errors.As(err, &typed) {
+			extensions["code"] = typed.Code
+			if len(typed.Fields) > 0 {
+				extensions["fields"] = typed.Fields
+			}
+		}
+		return extensions
+	}
+	return nil
+}
+
+// panicHandler логирует панику резолвера со стеком и возвращает клиенту internal_error.
+// Реализует и Logger, и PanicHandler graphql-go: первый вызывается до второго, но только
+// второй может задать ответ, поэтому логирование тоже здесь.
+type panicHandler struct{}
+
+func (panicHandler) LogPanic(context.Context, any) {}
+
+func (panicHandler) MakePanicError(ctx context.Context, value any) *gqlerrors.QueryError {
+	logger.ErrorContext(ctx, "graphql resolver panicked", "panic", fmt.Sprint(value), "stack", string(debug.Stack()))
+	return &gqlerrors.QueryError{Message: "internal error", Extensions: map[string]any{"code": codeInternal}}
+}
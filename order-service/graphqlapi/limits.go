@@ -0,0 +1,195 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Коды ошибок проверки запроса
+const (
+	CodeQueryInvalid          = "query_invalid"
+	CodeQueryTooDeep          = "query_too_deep"
+	CodeQueryTooComplex       = "query_too_complex"
+	CodeIntrospectionDisabled = "introspection_disabled"
+)
+
+const (
+	// defaultListSize — ожидаемый размер списка без pageSize
+	defaultListSize     = 10
+	pageSizeArgument    = "pageSize"
+	introspectionPrefix = "__"
+)
+
+// fetchFields — поля, резолверы которых обращаются к сервисам (все поля Query и перечисленные здесь).
+// Остальные поля берутся из уже загруженной записи и в сложность не входят.
+var fetchFields = map[string]bool{
+	"User.orders":       true,
+	"User.cart":         true,
+	"Cart.user":         true,
+	"CartLine.product":  true,
+	"Order.user":        true,
+	"Order.history":     true,
+	"OrderItem.product": true,
+}
+
+// queryLimits проверяет глубину и сложность запроса до выполнения.
+// Сложность — число обращений к сервисам (fetchFields): каждое стоит 1, а внутри списка
+// умножается на его ожидаемый размер — pageSize ближайшего родителя или defaultListSize.
+// Поля интроспекции (__schema, __type) не учитываются; если интроспекция выключена, запрос с ними отклоняется.
+type queryLimits struct {
+	schema        *ast.Schema
+	maxDepth      int
+	maxComplexity int
+	introspection bool
+}
+
+func newQueryLimits(sdl string, settings Settings) (*queryLimits, error) {
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: "schema.graphql", Input: sdl})
+	if err != nil {
+		return nil, err
+	}
+	return &queryLimits{schema: schema, maxDepth: settings.MaxDepth, maxComplexity: settings.MaxComplexity, introspection: settings.Introspection}, nil
+}
+
+// check возвращает ошибки разбора или превышения лимитов; nil — запрос можно выполнять.
+// Если операция не найдена, проверять нечего: ошибку вернёт исполнитель запроса.
+func (l *queryLimits) check(query, operationName string, variables map[string]any) []*gqlerrors.QueryError {
+	doc, errs := gqlparser.LoadQuery(l.schema, query)
+	if len(errs) > 0 {
+		result := make([]*gqlerrors.QueryError, 0, len(errs))
+		for _, e := range errs {
+			qe := &gqlerrors.QueryError{Message: e.Message, Extensions: map[string]any{"code": CodeQueryInvalid}}
+			for _, loc := range e.Locations {
+				qe.Locations = append(qe.Locations, gqlerrors.Location{Line: loc.Line, Column: loc.Column})
+			}
+			result = append(result, qe)
+		}
+		return result
+	}
+	op := doc.Operations.ForName(operationName)
+	if op == nil {
+		return nil
+	}
+
+	w := walker{doc: doc, variables: variables}
+	complexity := w.cost(op.SelectionSet, 1, 0)
+	if w.introspection && !l.introspection {
+		return []*gqlerrors.QueryError{{
+			Message:    "introspection is disabled",
+			Extensions: map[string]any{"code": CodeIntrospectionDisabled},
+		}}
+	}
+	if l.maxDepth > 0 && w.depth > l.maxDepth {
+		return []*gqlerrors.QueryError{{
+			Message:    fmt.Sprintf("query depth %d exceeds the maximum of %d", w.depth, l.maxDepth),
+			Extensions: map[string]any{"code": CodeQueryTooDeep, "depth": w.depth, "maxDepth": l.maxDepth},
+		}}
+	}
+	if l.maxComplexity > 0 && complexity > l.maxComplexity {
+		return []*gqlerrors.QueryError{{
+			Message:    fmt.Sprintf("query complexity %d exceeds the maximum of %d", complexity, l.maxComplexity),
+			Extensions: map[string]any{"code": CodeQueryTooComplex, "complexity": complexity, "maxComplexity": l.maxComplexity},
+		}}
+	}
+	return nil
+}
+
+type walker struct {
+	doc           *ast.QueryDocument
+	variables     map[string]any
+	depth         int
+	introspection bool // в запросе есть __schema или __type
+}
+
+// cost считает сложность набора полей на глубине depth. pageSize — размер страницы,
+// заданный родителем и ещё не применённый ни к одному списку (0 — не задан).
+// Сложность ограничена сверху, чтобы вложенные списки не переполнили int.
+func (w *walker) cost(set ast.SelectionSet, depth, pageSize int) int {
+	total := 0
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			total = saturatingAdd(total, w.fieldCost(s, depth, pageSize))
+		case *ast.InlineFragment:
+			total = saturatingAdd(total, w.cost(s.SelectionSet, depth, pageSize))
+		case *ast.FragmentSpread:
+			if fragment := w.doc.Fragments.ForName(s.Name); fragment != nil {
+				total = saturatingAdd(total, w.cost(fragment.SelectionSet, depth, pageSize))
+			}
+		}
+	}
+	return total
+}
+
+func (w *walker) fieldCost(field *ast.Field, depth, pageSize int) int {
+	if strings.HasPrefix(field.Name, introspectionPrefix) || field.Definition == nil {
+		// __typename — имя типа, а не интроспекция схемы
+		if field.Name != "__typename" {
+			w.introspection = true
+		}
+		return 0
+	}
+	if depth > w.depth {
+		w.depth = depth
+	}
+	if size := w.pageSize(field); size > 0 {
+		pageSize = size
+	}
+	cost := 0
+	if field.ObjectDefinition.Name == "Query" || fetchFields[field.ObjectDefinition.Name+"."+field.Name] {
+		cost = 1
+	}
+	if len(field.SelectionSet) == 0 {
+		return cost
+	}
+
+	multiplier := 1
+	if field.Definition.Type.Elem != nil {
+		multiplier = defaultListSize
+		if pageSize > 0 {
+			multiplier, pageSize = pageSize, 0
+		}
+	}
+	return saturatingAdd(cost, saturatingMul(multiplier, w.cost(field.SelectionSet, depth+1, pageSize)))
+}
+
+// pageSize возвращает аргумент pageSize поля (с учётом значения по умолчанию) или 0
+func (w *walker) pageSize(field *ast.Field) int {
+	if field.Definition.Arguments.ForName(pageSizeArgument) == nil {
+		return 0
+	}
+	switch v := field.ArgumentMap(w.variables)[pageSizeArgument].(type) {
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	}
+	return 0
+}
+
+// complexityCap — предел, после которого сложность дальше не считается
+const complexityCap = 1 << 30
+
+func saturatingAdd(a, b int) int {
+	if a+b > complexityCap {
+		return complexityCap
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int) int {
+	if a > 0 && b > complexityCap/a {
+		return complexityCap
+	}
+	return a * b
+}
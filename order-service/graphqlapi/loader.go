@@ -0,0 +1,112 @@
+package graphqlapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Параметры пакетной загрузки: ключи, запрошенные резолверами в течение loaderWait,
+// загружаются одним запросом, но не больше loaderMaxBatch ключей за раз
+const (
+	loaderWait     = 2 * time.Millisecond
+	loaderMaxBatch = 100
+)
+
+// loader собирает ключи, запрошенные параллельно работающими резолверами, и загружает
+// их пакетом, чтобы список из N заказов не порождал N запросов за продуктами (N+1).
+// Загруженные значения кэшируются до конца запроса: loader создаётся на каждый запрос.
+type loader[K comparable, V any] struct {
+	// ctx — контекст GraphQL-запроса: пакет загружается от его имени, а не от первого резолвера
+	ctx   context.Context
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu    sync.Mutex
+	cache map[K]*loaderResult[V]
+	batch *loaderBatch[K, V]
+}
+
+type loaderResult[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+type loaderBatch[K comparable, V any] struct {
+	keys    []K
+	results []*loaderResult[V]
+}
+
+func newLoader[K comparable, V any](ctx context.Context, fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{ctx: ctx, fetch: fetch, cache: make(map[K]*loaderResult[V])}
+}
+
+// Load возвращает значение по ключу; found = false, если его нет
+func (l *loader[K, V]) Load(ctx context.Context, key K) (value V, found bool, err error) {
+	result := l.enqueue(key)
+	select {
+	case <-result.done:
+		return result.value, result.found, result.err
+	case <-ctx.Done():
+		return value, false, ctx.Err()
+	}
+}
+
+// Prime ставит ключи в очередь, не дожидаясь загрузки. Так родительский резолвер
+// (например, позиции заказа) сразу собирает все ключи в один пакет: иначе дочерние
+// резолверы, которых параллельно выполняется не больше MaxParallelism, разбили бы их на несколько.
+func (l *loader[K, V]) Prime(keys ...K) {
+	for _, key := range keys {
+		l.enqueue(key)
+	}
+}
+
+func (l *loader[K, V]) enqueue(key K) *loaderResult[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if result, ok := l.cache[key]; ok {
+		return result
+	}
+	result := &loaderResult[V]{done: make(chan struct{})}
+	l.cache[key] = result
+
+	if l.batch == nil {
+		batch := &loaderBatch[K, V]{}
+		l.batch = batch
+		time.AfterFunc(loaderWait, func() { l.dispatch(batch) })
+	}
+	l.batch.keys = append(l.batch.keys, key)
+	l.batch.results = append(l.batch.results, result)
+	if len(l.batch.keys) >= loaderMaxBatch {
+		batch := l.batch
+		l.batch = nil
+		go l.run(batch)
+	}
+	return result
+}
+
+// dispatch загружает пакет по истечении loaderWait, если он не был отправлен раньше заполненным
+func (l *loader[K, V]) dispatch(batch *loaderBatch[K, V]) {
+	l.mu.Lock()
+	if l.batch != batch {
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+	l.run(batch)
+}
+
+func (l *loader[K, V]) run(batch *loaderBatch[K, V]) {
+	values, err := l.fetch(l.ctx, batch.keys)
+	for i, key := range batch.keys {
+		result := batch.results[i]
+		result.err = err
+		if err == nil {
+			result.value, result.found = values[key]
+		}
+		close(result.done)
+	}
+}
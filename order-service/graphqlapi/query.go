@@ -0,0 +1,235 @@
+package graphqlapi
+
+import (
+	"context"
+	"order-service/models"
+	"order-service/services"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+// maxSearchPageSize — как в поиске REST API: больше сервис всё равно не вернёт
+const maxSearchPageSize = 100
+
+// queryResolver — корневой тип Query. Права те же, что в REST и gRPC: каталог открыт всем,
+// пользователь видит себя, свою корзину и свои заказы, списки — только администратор.
+type queryResolver struct {
+	users    *services.UserService
+	products *services.ProductService
+	cart     *services.CartService
+	orders   *services.OrderService
+}
+
+func (q *queryResolver) Me(ctx context.Context) (*userResolver, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := q.users.GetUserByID(ctx, claims.UserID, false)
+	if err != nil {
+		return nil, err
+	}
+	return q.user(user), nil
+}
+
+func (q *queryResolver) User(ctx context.Context, args struct {
+	ID             graphql.ID
+	IncludeDeleted bool
+}) (*userResolver, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIncludeDeleted(ctx, args.IncludeDeleted); err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(string(args.ID)) {
+		return nil, services.ErrForbidden
+	}
+
+	user, err := q.users.GetUserByID(ctx, string(args.ID), args.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return q.user(user), nil
+}
+
+func (q *queryResolver) Users(ctx context.Context, args struct{ IncludeDeleted bool }) ([]*userResolver, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.IsAdmin() {
+		return nil, services.ErrAdminRequired
+	}
+
+	users, err := q.users.GetAllUsers(ctx, args.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*userResolver, 0, len(users))
+	for i := range users {
+		result = append(result, q.user(&users[i]))
+	}
+	return result, nil
+}
+
+func (q *queryResolver) Product(ctx context.Context, args struct {
+	ID             graphql.ID
+	IncludeDeleted bool
+}) (*productResolver, error) {
+	if err := checkIncludeDeleted(ctx, args.IncludeDeleted); err != nil {
+		return nil, err
+	}
+
+	product, err := q.products.GetProductById(ctx, string(args.ID), args.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	response := product.ToResponse()
+	return &productResolver{p: &response}, nil
+}
+
+func (q *queryResolver) Products(ctx context.Context, args struct{ IncludeDeleted bool }) ([]*productResolver, error) {
+	if err := checkIncludeDeleted(ctx, args.IncludeDeleted); err != nil {
+		return nil, err
+	}
+
+	products, err := q.products.GetAllProducts(ctx, args.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*productResolver, 0, len(products))
+	for i := range products {
+		result = append(result, &productResolver{p: &products[i]})
+	}
+	return result, nil
+}
+
+func (q *queryResolver) SearchProducts(ctx context.Context, args struct {
+	Query    string
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  *bool
+	Category string
+	Page     int32
+	PageSize int32
+}) (*searchResultResolver, error) {
+	params := models.ProductSearchParams{
+		Query:    args.Query,
+		MinPrice: args.MinPrice,
+		MaxPrice: args.MaxPrice,
+		InStock:  args.InStock,
+		Category: args.Category,
+		Page:     int(args.Page),
+		PageSize: int(args.PageSize),
+	}
+
+	var fields models.FieldErrors
+	if params.MinPrice != nil && *params.MinPrice < 0 {
+		fields.Add("minPrice", "must be greater than or equal to 0")
+	}
+	if params.MaxPrice != nil && *params.MaxPrice < 0 {
+		fields.Add("maxPrice", "must be greater than or equal to 0")
+	}
+	if params.Page < 1 || params.Page > models.MaxSearchPage {
+		fields.Add("page", "must be between 1 and 1000")
+	}
+	if params.PageSize < 1 || params.PageSize > maxSearchPageSize {
+		fields.Add("pageSize", "must be between 1 and 100")
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+
+	result, err := q.products.SearchProducts(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return &searchResultResolver{r: result}, nil
+}
+
+func (q *queryResolver) Cart(ctx context.Context, args struct{ UserID *graphql.ID }) (*cartResolver, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID := claims.UserID
+	if args.UserID != nil {
+		userID = string(*args.UserID)
+	}
+	if !claims.CanAccess(userID) {
+		return nil, services.ErrForbidden
+	}
+	return q.userCart(ctx, userID)
+}
+
+func (q *queryResolver) Order(ctx context.Context, args struct {
+	ID             graphql.ID
+	IncludeDeleted bool
+}) (*orderResolver, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIncludeDeleted(ctx, args.IncludeDeleted); err != nil {
+		return nil, err
+	}
+
+	order, err := q.orders.GetOrderById(ctx, string(args.ID), args.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, services.ErrForbidden
+	}
+	return q.order(order), nil
+}
+
+// Orders возвращает заказы userId; без него — свои заказы, а администратору — все
+func (q *queryResolver) Orders(ctx context.Context, args struct{ UserID *graphql.ID }) ([]*orderResolver, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []models.Order
+	switch {
+	case args.UserID == nil && claims.IsAdmin():
+		orders, err = q.orders.GetAllOrders(ctx, false)
+	case args.UserID == nil:
+		orders, err = q.orders.GetUserOrders(ctx, claims.UserID)
+	case claims.CanAccess(string(*args.UserID)):
+		orders, err = q.orders.GetUserOrders(ctx, string(*args.UserID))
+	default:
+		return nil, services.ErrForbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q.orderList(orders), nil
+}
+
+func (q *queryResolver) userCart(ctx context.Context, userID string) (*cartResolver, error) {
+	lines, err := q.cart.GetCartLines(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &cartResolver{userID: userID, lines: lines, q: q}, nil
+}
+
+func (q *queryResolver) user(u *models.User) *userResolver {
+	return &userResolver{u: u, q: q}
+}
+
+func (q *queryResolver) order(o *models.Order) *orderResolver {
+	return &orderResolver{o: o, q: q}
+}
+
+func (q *queryResolver) orderList(orders []models.Order) []*orderResolver {
+	result := make([]*orderResolver, 0, len(orders))
+	for i := range orders {
+		result = append(result, q.order(&orders[i]))
+	}
+	return result
+}
@@ -0,0 +1,387 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"order-service/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+// timestamp переводит необязательное время; nil остаётся nil
+func timestamp(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+// optional возвращает nil для пустой строки: необязательные поля приходят как null
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// jsonValue — скаляр JSON для значений атрибутов продукта
+type jsonValue struct {
+	value any
+}
+
+func (jsonValue) ImplementsGraphQLType(name string) bool {
+	return name == "JSON"
+}
+
+func (v *jsonValue) UnmarshalGraphQL(input any) error {
+	v.value = input
+	return nil
+}
+
+func (v jsonValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.value)
+}
+
+type userResolver struct {
+	u *models.User
+	q *queryResolver
+}
+
+func (r *userResolver) ID() graphql.ID             { return graphql.ID(r.u.ID) }
+func (r *userResolver) Username() string           { return r.u.Username }
+func (r *userResolver) Email() string              { return r.u.Email }
+func (r *userResolver) Role() string               { return r.u.Role }
+func (r *userResolver) Version() int32             { return int32(r.u.Version) }
+func (r *userResolver) CreatedAt() graphql.Time    { return graphql.Time{Time: r.u.CreatedAt} }
+func (r *userResolver) UpdatedAt() graphql.Time    { return graphql.Time{Time: r.u.UpdatedAt} }
+func (r *userResolver) LastLoginAt() *graphql.Time { return timestamp(r.u.LastLoginAt) }
+func (r *userResolver) DeletedAt() *graphql.Time   { return timestamp(r.u.DeletedAt) }
+
+// Orders — заказы пользователя; к пользователю уже есть доступ, иначе его бы не вернули
+func (r *userResolver) Orders(ctx context.Context) ([]*orderResolver, error) {
+	orders, err := r.q.orders.GetUserOrders(ctx, r.u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.q.orderList(orders), nil
+}
+
+func (r *userResolver) Cart(ctx context.Context) (*cartResolver, error) {
+	return r.q.userCart(ctx, r.u.ID)
+}
+
+type productResolver struct {
+	p *models.ProductResponse
+}
+
+func (r *productResolver) ID() graphql.ID           { return graphql.ID(r.p.ID) }
+func (r *productResolver) Name() string             { return r.p.Name }
+func (r *productResolver) Description() string      { return r.p.Description }
+func (r *productResolver) Price() float64           { return r.p.Price }
+func (r *productResolver) Stock() int32             { return int32(r.p.Stock) }
+func (r *productResolver) Category() *string        { return optional(r.p.Category) }
+func (r *productResolver) Version() int32           { return int32(r.p.Version) }
+func (r *productResolver) DeletedAt() *graphql.Time { return timestamp(r.p.DeletedAt) }
+
+func (r *productResolver) Variants() []*variantResolver {
+	result := make([]*variantResolver, 0, len(r.p.Variants))
+	for i := range r.p.Variants {
+		result = append(result, &variantResolver{v: &r.p.Variants[i]})
+	}
+	return result
+}
+
+func (r *productResolver) Attributes() []*attributeResolver {
+	result := make([]*attributeResolver, 0, len(r.p.Attributes))
+	for i := range r.p.Attributes {
+		result = append(result, &attributeResolver{a: &r.p.Attributes[i]})
+	}
+	return result
+}
+
+func (r *productResolver) Images() []*imageResolver {
+	result := make([]*imageResolver, 0, len(r.p.Images))
+	for i := range r.p.Images {
+		result = append(result, &imageResolver{i: &r.p.Images[i]})
+	}
+	return result
+}
+
+type variantResolver struct {
+	v *models.ProductVariant
+}
+
+func (r *variantResolver) SKU() string    { return r.v.SKU }
+func (r *variantResolver) Name() *string  { return optional(r.v.Name) }
+func (r *variantResolver) Price() float64 { return r.v.Price }
+func (r *variantResolver) Stock() int32   { return int32(r.v.Stock) }
+
+// Options — характеристики варианта в порядке имён, чтобы ответ не зависел от порядка map
+func (r *variantResolver) Options() []*optionResolver {
+	result := make([]*optionResolver, 0, len(r.v.Options))
+	for name, value := range r.v.Options {
+		result = append(result, &optionResolver{name: name, value: value})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result
+}
+
+type optionResolver struct {
+	name, value string
+}
+
+func (r *optionResolver) Name() string  { return r.name }
+func (r *optionResolver) Value() string { return r.value }
+
+type attributeResolver struct {
+	a *models.ProductAttribute
+}
+
+func (r *attributeResolver) Name() string { return r.a.Name }
+func (r *attributeResolver) Type() string { return r.a.Type }
+
+func (r *attributeResolver) Value() *jsonValue {
+	if r.a.Value == nil {
+		return nil
+	}
+	return &jsonValue{value: r.a.Value}
+}
+
+type imageResolver struct {
+	i *models.ProductImage
+}
+
+func (r *imageResolver) URL() string         { return r.i.URL }
+func (r *imageResolver) Alt() *string        { return optional(r.i.Alt) }
+func (r *imageResolver) Position() int32     { return int32(r.i.Position) }
+func (r *imageResolver) VariantSKU() *string { return optional(r.i.VariantSKU) }
+
+type searchResultResolver struct {
+	r *models.ProductSearchResult
+}
+
+func (r *searchResultResolver) Total() int32    { return int32(r.r.Total) }
+func (r *searchResultResolver) Page() int32     { return int32(r.r.Page) }
+func (r *searchResultResolver) PageSize() int32 { return int32(r.r.PageSize) }
+
+func (r *searchResultResolver) Items() []*searchHitResolver {
+	result := make([]*searchHitResolver, 0, len(r.r.Items))
+	for i := range r.r.Items {
+		result = append(result, &searchHitResolver{p: &r.r.Items[i]})
+	}
+	return result
+}
+
+func (r *searchResultResolver) Facets() *facetsResolver {
+	return &facetsResolver{f: &r.r.Facets}
+}
+
+type searchHitResolver struct {
+	p *models.ProductResponse
+}
+
+func (r *searchHitResolver) Product() *productResolver { return &productResolver{p: r.p} }
+func (r *searchHitResolver) Score() float64            { return r.p.Score }
+
+// Highlights — подсветка в порядке имён полей
+func (r *searchHitResolver) Highlights() []*highlightResolver {
+	result := make([]*highlightResolver, 0, len(r.p.Highlights))
+	for field, fragment := range r.p.Highlights {
+		result = append(result, &highlightResolver{field: field, fragment: fragment})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].field < result[j].field })
+	return result
+}
+
+type highlightResolver struct {
+	field, fragment string
+}
+
+func (r *highlightResolver) Field() string    { return r.field }
+func (r *highlightResolver) Fragment() string { return r.fragment }
+
+type facetsResolver struct {
+	f *models.ProductFacets
+}
+
+func (r *facetsResolver) Price() []*facetBucketResolver        { return facetBuckets(r.f.Price) }
+func (r *facetsResolver) Category() []*facetBucketResolver     { return facetBuckets(r.f.Category) }
+func (r *facetsResolver) Availability() []*facetBucketResolver { return facetBuckets(r.f.Availability) }
+
+type facetBucketResolver struct {
+	b models.FacetBucket
+}
+
+func (r *facetBucketResolver) Value() string { return r.b.Value }
+func (r *facetBucketResolver) Count() int32  { return int32(r.b.Count) }
+
+func facetBuckets(buckets []models.FacetBucket) []*facetBucketResolver {
+	result := make([]*facetBucketResolver, 0, len(buckets))
+	for _, b := range buckets {
+		result = append(result, &facetBucketResolver{b: b})
+	}
+	return result
+}
+
+type cartResolver struct {
+	userID string
+	lines  []models.CartLine
+	q      *queryResolver
+}
+
+// User — владелец корзины; null, если такого пользователя нет
+func (r *cartResolver) User(ctx context.Context) (*userResolver, error) {
+	return r.q.loadUser(ctx, r.userID)
+}
+
+func (r *cartResolver) Items(ctx context.Context) []*cartLineResolver {
+	products := loadersFromContext(ctx).products
+	result := make([]*cartLineResolver, 0, len(r.lines))
+	for i := range r.lines {
+		line := &r.lines[i]
+		if line.ProductID != "" {
+			products.Prime(line.ProductID)
+		}
+		result = append(result, &cartLineResolver{l: line})
+	}
+	return result
+}
+
+func (r *cartResolver) Total() float64 {
+	total := 0.0
+	for _, line := range r.lines {
+		if line.Unavailable == "" {
+			total += line.Price * float64(line.Quantity)
+		}
+	}
+	return total
+}
+
+type cartLineResolver struct {
+	l *models.CartLine
+}
+
+func (r *cartLineResolver) SKU() string          { return r.l.SKU }
+func (r *cartLineResolver) Quantity() int32      { return int32(r.l.Quantity) }
+func (r *cartLineResolver) Price() float64       { return r.l.Price }
+func (r *cartLineResolver) Name() *string        { return optional(r.l.Name) }
+func (r *cartLineResolver) Unavailable() *string { return optional(r.l.Unavailable) }
+
+func (r *cartLineResolver) Product(ctx context.Context) (*productResolver, error) {
+	return loadProduct(ctx, r.l.ProductID)
+}
+
+type orderResolver struct {
+	o *models.Order
+	q *queryResolver
+}
+
+func (r *orderResolver) ID() graphql.ID           { return graphql.ID(r.o.ID) }
+func (r *orderResolver) TotalPrice() float64      { return r.o.TotalPrice }
+func (r *orderResolver) Status() string           { return orderStatus(r.o.Status) }
+func (r *orderResolver) Version() int32           { return int32(r.o.Version) }
+func (r *orderResolver) CreatedAt() graphql.Time  { return graphql.Time{Time: r.o.CreatedAt} }
+func (r *orderResolver) UpdatedAt() graphql.Time  { return graphql.Time{Time: r.o.UpdatedAt} }
+func (r *orderResolver) DeletedAt() *graphql.Time { return timestamp(r.o.DeletedAt) }
+
+// User — владелец заказа, в том числе удалённый; null, если такого пользователя нет
+func (r *orderResolver) User(ctx context.Context) (*userResolver, error) {
+	return r.q.loadUser(ctx, r.o.UserID)
+}
+
+// Items ставит продукты всех позиций в очередь загрузки, чтобы они загрузились одним пакетом
+func (r *orderResolver) Items(ctx context.Context) []*orderItemResolver {
+	products := loadersFromContext(ctx).products
+	result := make([]*orderItemResolver, 0, len(r.o.Items))
+	for i := range r.o.Items {
+		item := &r.o.Items[i]
+		if item.ProductID != "" {
+			products.Prime(item.ProductID)
+		}
+		result = append(result, &orderItemResolver{i: item})
+	}
+	return result
+}
+
+func (r *orderResolver) History(ctx context.Context) ([]*statusChangeResolver, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	history, err := r.q.orders.GetOrderHistory(ctx, r.o.ID, claims)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*statusChangeResolver, 0, len(history))
+	for i := range history {
+		result = append(result, &statusChangeResolver{c: &history[i]})
+	}
+	return result, nil
+}
+
+type orderItemResolver struct {
+	i *models.CartItem
+}
+
+func (r *orderItemResolver) SKU() string     { return r.i.SKU }
+func (r *orderItemResolver) Quantity() int32 { return int32(r.i.Quantity) }
+func (r *orderItemResolver) Price() float64  { return r.i.Price }
+
+func (r *orderItemResolver) Product(ctx context.Context) (*productResolver, error) {
+	return loadProduct(ctx, r.i.ProductID)
+}
+
+type statusChangeResolver struct {
+	c *models.OrderStatusChange
+}
+
+func (r *statusChangeResolver) ToStatus() string        { return orderStatus(r.c.ToStatus) }
+func (r *statusChangeResolver) Reason() *string         { return optional(r.c.Reason) }
+func (r *statusChangeResolver) ChangedAt() graphql.Time { return graphql.Time{Time: r.c.CreatedAt} }
+
+// FromStatus — null для первой записи истории (создание заказа)
+func (r *statusChangeResolver) FromStatus() *string {
+	if r.c.FromStatus == "" {
+		return nil
+	}
+	status := orderStatus(r.c.FromStatus)
+	return &status
+}
+
+func (r *statusChangeResolver) ChangedBy() *graphql.ID {
+	if r.c.ChangedBy == nil {
+		return nil
+	}
+	id := graphql.ID(*r.c.ChangedBy)
+	return &id
+}
+
+// orderStatus переводит статус заказа в значение перечисления OrderStatus
+func orderStatus(s string) string {
+	return strings.ToUpper(s)
+}
+
+// loadProduct загружает продукт через loader запроса; nil — продукта нет
+func loadProduct(ctx context.Context, id string) (*productResolver, error) {
+	if id == "" {
+		return nil, nil
+	}
+	product, found, err := loadersFromContext(ctx).products.Load(ctx, id)
+	if err != nil || !found {
+		return nil, err
+	}
+	response := product.ToResponse()
+	return &productResolver{p: &response}, nil
+}
+
+// loadUser загружает пользователя через loader запроса; nil — пользователя нет
+func (q *queryResolver) loadUser(ctx context.Context, id string) (*userResolver, error) {
+	user, found, err := loadersFromContext(ctx).users.Load(ctx, id)
+	if err != nil || !found {
+		return nil, err
+	}
+	return q.user(user), nil
+}
@@ -0,0 +1,103 @@
+package grpcapi
+
+import (
+	"context"
+	"order-service/models"
+	"order-service/services"
+
+	pb "order-service/gen/orderservice/v1"
+)
+
+// maxSKULength — как в AddToCartRequest REST API
+const maxSKULength = 64
+
+type cartServer struct {
+	pb.UnimplementedCartServiceServer
+	cart *services.CartService
+}
+
+// cartOwner возвращает владельца корзины: user_id из запроса или, если он пуст, владельца токена
+func cartOwner(ctx context.Context, userID string) (string, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return claims.UserID, nil
+	}
+	if !claims.CanAccess(userID) {
+		return "", services.ErrForbidden
+	}
+	return userID, nil
+}
+
+func (s *cartServer) AddItem(ctx context.Context, req *pb.AddCartItemRequest) (*pb.Cart, error) {
+	userID, err := cartOwner(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	var fields models.FieldErrors
+	if req.GetSku() == "" && req.GetProductId() == "" {
+		fields.Add("sku", "is required when product_id is not set")
+	}
+	if len(req.GetSku()) > maxSKULength {
+		fields.Add("sku", "must be at most 64 characters long")
+	}
+	if req.GetQuantity() < 1 {
+		fields.Add("quantity", "must be greater than or equal to 1")
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.cart.AddToCart(ctx, userID, req.GetSku(), req.GetProductId(), int(req.GetQuantity())); err != nil {
+		return nil, err
+	}
+	return s.cartResponse(ctx, userID)
+}
+
+func (s *cartServer) RemoveItem(ctx context.Context, req *pb.RemoveCartItemRequest) (*pb.Cart, error) {
+	userID, err := cartOwner(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetSku() == "" {
+		return nil, models.NewFieldErrors(models.FieldError{Field: "sku", Message: "is required"})
+	}
+
+	if err := s.cart.RemoveFromCart(ctx, userID, req.GetSku()); err != nil {
+		return nil, err
+	}
+	return s.cartResponse(ctx, userID)
+}
+
+func (s *cartServer) GetCart(ctx context.Context, req *pb.GetCartRequest) (*pb.Cart, error) {
+	userID, err := cartOwner(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	return s.cartResponse(ctx, userID)
+}
+
+// Checkout оформляет заказ; позиции удалённых продуктов остаются в корзине и возвращаются в skipped
+func (s *cartServer) Checkout(ctx context.Context, req *pb.CheckoutRequest) (*pb.CheckoutResponse, error) {
+	userID, err := cartOwner(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	order, skipped, err := s.cart.CheckoutCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CheckoutResponse{Order: toOrder(order), Skipped: toCartLines(skipped)}, nil
+}
+
+func (s *cartServer) cartResponse(ctx context.Context, userID string) (*pb.Cart, error) {
+	lines, err := s.cart.GetCartLines(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Cart{UserId: userID, Items: toCartLines(lines)}, nil
+}
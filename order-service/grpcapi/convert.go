@@ -0,0 +1,148 @@
+package grpcapi
+
+import (
+	"order-service/models"
+	"strings"
+	"time"
+
+	pb "order-service/gen/orderservice/v1"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// timestamp переводит необязательное время; nil остаётся nil
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func toUser(u *models.User) *pb.User {
+	return &pb.User{
+		Id:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		Role:        u.Role,
+		Version:     u.Version,
+		CreatedAt:   timestamppb.New(u.CreatedAt),
+		UpdatedAt:   timestamppb.New(u.UpdatedAt),
+		LastLoginAt: timestamp(u.LastLoginAt),
+		DeletedAt:   timestamp(u.DeletedAt),
+	}
+}
+
+func toProduct(p *models.ProductResponse) *pb.Product {
+	product := &pb.Product{
+		Id:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Stock:       int32(p.Stock),
+		Category:    p.Category,
+		Version:     p.Version,
+		DeletedAt:   timestamp(p.DeletedAt),
+	}
+	for _, v := range p.Variants {
+		product.Variants = append(product.Variants, &pb.ProductVariant{
+			Sku:     v.SKU,
+			Name:    v.Name,
+			Price:   v.Price,
+			Stock:   int32(v.Stock),
+			Options: v.Options,
+		})
+	}
+	for _, a := range p.Attributes {
+		// Значение атрибута проверено при сохранении; непредставимое передаётся как null
+		value, err := structpb.NewValue(a.Value)
+		if err != nil {
+			value = structpb.NewNullValue()
+		}
+		product.Attributes = append(product.Attributes, &pb.ProductAttribute{Name: a.Name, Type: a.Type, Value: value})
+	}
+	for _, img := range p.Images {
+		product.Images = append(product.Images, &pb.ProductImage{
+			Url:        img.URL,
+			Alt:        img.Alt,
+			Position:   int32(img.Position),
+			VariantSku: img.VariantSKU,
+		})
+	}
+	return product
+}
+
+func toFacetBuckets(buckets []models.FacetBucket) []*pb.FacetBucket {
+	result := make([]*pb.FacetBucket, 0, len(buckets))
+	for _, b := range buckets {
+		result = append(result, &pb.FacetBucket{Value: b.Value, Count: b.Count})
+	}
+	return result
+}
+
+func toCartLines(lines []models.CartLine) []*pb.CartLine {
+	result := make([]*pb.CartLine, 0, len(lines))
+	for _, l := range lines {
+		result = append(result, &pb.CartLine{
+			Sku:         l.SKU,
+			ProductId:   l.ProductID,
+			Name:        l.Name,
+			Quantity:    int32(l.Quantity),
+			Price:       l.Price,
+			Unavailable: l.Unavailable,
+		})
+	}
+	return result
+}
+
+// toOrderStatus переводит статус заказа в значение перечисления; неизвестный — UNSPECIFIED
+func toOrderStatus(s string) pb.OrderStatus {
+	if s == "" {
+		return pb.OrderStatus_ORDER_STATUS_UNSPECIFIED
+	}
+	return pb.OrderStatus(pb.OrderStatus_value["ORDER_STATUS_"+strings.ToUpper(s)])
+}
+
+func toOrder(o *models.Order) *pb.Order {
+	order := &pb.Order{
+		Id:         o.ID,
+		UserId:     o.UserID,
+		Items:      make([]*pb.OrderItem, 0, len(o.Items)),
+		TotalPrice: o.TotalPrice,
+		Status:     toOrderStatus(o.Status),
+		Version:    o.Version,
+		CreatedAt:  timestamppb.New(o.CreatedAt),
+		UpdatedAt:  timestamppb.New(o.UpdatedAt),
+		DeletedAt:  timestamp(o.DeletedAt),
+	}
+	for _, item := range o.Items {
+		order.Items = append(order.Items, &pb.OrderItem{
+			ProductId: item.ProductID,
+			Sku:       item.SKU,
+			Quantity:  int32(item.Quantity),
+			Price:     item.Price,
+		})
+	}
+	return order
+}
+
+func toOrders(orders []models.Order) []*pb.Order {
+	result := make([]*pb.Order, 0, len(orders))
+	for i := range orders {
+		result = append(result, toOrder(&orders[i]))
+	}
+	return result
+}
+
+func toStatusChange(c *models.OrderStatusChange) *pb.OrderStatusChange {
+	change := &pb.OrderStatusChange{
+		FromStatus: toOrderStatus(c.FromStatus),
+		ToStatus:   toOrderStatus(c.ToStatus),
+		Reason:     c.Reason,
+		ChangedAt:  timestamppb.New(c.CreatedAt),
+	}
+	if c.ChangedBy != nil {
+		change.ChangedBy = *c.ChangedBy
+	}
+	return change
+}
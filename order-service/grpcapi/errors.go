@@ -0,0 +1,71 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"order-service/models"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain — домен кодов ошибок в google.rpc.ErrorInfo
+const errorDomain = "order-service"
+
+// statusKinds сопоставляет категории ошибок с кодами gRPC — так же, как problemKinds в REST
+// сопоставляет их с HTTP-статусами
+var statusKinds = []struct {
+	kind error
+	code codes.Code
+}{
+	{models.ErrNotFound, codes.NotFound},
+	{models.ErrConflict, codes.FailedPrecondition},
+	{models.ErrValidation, codes.InvalidArgument},
+	{models.ErrForbidden, codes.PermissionDenied},
+	{models.ErrUnauthorized, codes.Unauthenticated},
+	{models.ErrPreconditionFailed, codes.Aborted},
+	{models.ErrPreconditionRequired, codes.FailedPrecondition},
+	{models.ErrUnsupportedMediaType, codes.InvalidArgument},
+	{models.ErrTooManyRequests, codes.ResourceExhausted},
+}
+
+// errShuttingDown завершает потоки при остановке сервера; клиенту стоит переподключиться
+var errShuttingDown = status.Error(codes.Unavailable, "server is shutting down")
+
+// toStatus переводит ошибку сервисов в статус gRPC. Стабильный код ошибки передаётся
+// в google.rpc.ErrorInfo.reason, ошибки полей — в google.rpc.BadRequest.
+// Ошибки без категории считаются внутренними: клиент получает Internal без подробностей.
+func toStatus(err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err)
+	}
+
+	for _, k := range statusKinds {
+		if !errors.Is(err, k.kind) {
+			continue
+		}
+		st := status.New(k.code, err.Error())
+		var typed *models.Error
+		if !errors.As(err, &typed) {
+			return st
+		}
+		details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: typed.Code, Domain: errorDomain}}
+		if len(typed.Fields) > 0 {
+			violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(typed.Fields))
+			for _, f := range typed.Fields {
+				violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+			}
+			details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+		}
+		if withDetails, err := st.WithDetails(details...); err == nil {
+			return withDetails
+		}
+		return st
+	}
+	return status.New(codes.Internal, "internal error")
+}
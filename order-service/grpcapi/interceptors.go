@@ -0,0 +1,307 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"order-service/logging"
+	"order-service/metrics"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/ratelimit"
+	"order-service/services"
+	"order-service/tracing"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	pb "order-service/gen/orderservice/v1"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var logger = logging.For("grpc")
+
+// requestIDKey — ключ метаданных с ID запроса; в ответе возвращается в заголовках
+const requestIDKey = "x-request-id"
+
+// publicMethods — вызовы, доступные без токена
+var publicMethods = map[string]bool{
+	pb.UserService_Register_FullMethodName: true,
+	pb.UserService_Login_FullMethodName:    true,
+}
+
+// publicPrefixes — службы, все вызовы которых доступны без токена: каталог,
+// проверка здоровья и reflection
+var publicPrefixes = []string{
+	"/" + pb.ProductService_ServiceDesc.ServiceName + "/",
+	"/grpc.health.v1.",
+	"/grpc.reflection.",
+}
+
+func isPublic(method string) bool {
+	if publicMethods[method] {
+		return true
+	}
+	for _, prefix := range publicPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimitRoutes сопоставляет вызовы с маршрутами REST, политики которых к ним применяются:
+// лимиты и блокировка входа общие для обоих API
+var rateLimitRoutes = map[string]struct{ method, route string }{
+	pb.UserService_Login_FullMethodName:    {"POST", "/login"},
+	pb.UserService_Register_FullMethodName: {"POST", "/register"},
+}
+
+// serverStream подменяет контекст потока контекстом с данными перехватчиков
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// observeUnary — ID запроса, спан, журнал, метрики и восстановление после паники.
+// Стоит первым в цепочке: ошибки всех следующих перехватчиков переводятся в статусы gRPC здесь.
+func observeUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	ctx, finish := observe(ctx, info.FullMethod)
+	defer func() { err = finish(recover(), err) }()
+	return handler(ctx, req)
+}
+
+func observeStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx, finish := observe(ss.Context(), info.FullMethod)
+	defer func() { err = finish(recover(), err) }()
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// observe начинает наблюдение за вызовом; возвращённая функция завершает его
+// и возвращает ошибку, переведённую в статус gRPC
+func observe(ctx context.Context, method string) (context.Context, func(panicked any, err error) error) {
+	start := time.Now()
+	requestID := middleware.ResolveRequestID(firstMetadata(ctx, requestIDKey))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID))
+	ctx = logging.WithRequestID(ctx, requestID)
+	ctx, span := tracing.StartGRPCSpan(ctx, method)
+
+	return ctx, func(panicked any, err error) error {
+		if panicked != nil {
+			logger.ErrorContext(ctx, "panic in grpc handler", "method", method, "panic", panicked, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+		if err != nil {
+			st := toStatus(err)
+			if _, isStatus := status.FromError(err); !isStatus && st.Code() == codes.Internal {
+				logger.ErrorContext(ctx, "rpc failed", "method", method, "error", err)
+			}
+			err = st.Err()
+		}
+
+		code := status.Code(err)
+		duration := time.Since(start)
+		tracing.EndGRPCSpan(span, code, err)
+		metrics.ObserveGRPCRequest(method, code.String(), duration)
+
+		level := slog.LevelInfo
+		if tracing.IsGRPCServerError(code) {
+			level = slog.LevelError
+		}
+		logger.Log(ctx, level, "rpc completed",
+			"method", method,
+			"code", code.String(),
+			"duration_ms", float64(duration.Microseconds())/1000,
+			"client_ip", clientIP(ctx),
+		)
+		return err
+	}
+}
+
+type claimsKey struct{}
+
+// authUnary проверяет токен из метаданных authorization ("Bearer {token}").
+// Без токена доступны только публичные вызовы; переданный токен проверяется всегда.
+func authUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func authStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate кладёт в контекст данные токена и автора вызова для журнала аудита
+func authenticate(ctx context.Context, method string) (context.Context, error) {
+	actor := services.Actor{IP: clientIP(ctx)}
+	if header := firstMetadata(ctx, "authorization"); header != "" {
+		claims, err := services.Authenticate(header)
+		if err != nil {
+			return nil, err
+		}
+		ctx = context.WithValue(ctx, claimsKey{}, claims)
+		actor.UserID = claims.UserID
+		actor.Role = claims.Role
+	} else if !isPublic(method) {
+		return nil, services.ErrTokenRequired
+	}
+	return services.WithActor(ctx, actor), nil
+}
+
+// claimsFromContext возвращает данные токена вызова или nil
+func claimsFromContext(ctx context.Context) *services.TokenClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*services.TokenClaims)
+	return claims
+}
+
+// requireClaims возвращает данные токена; нужен в вызовах, доступных и без токена
+func requireClaims(ctx context.Context) (*services.TokenClaims, error) {
+	claims := claimsFromContext(ctx)
+	if claims == nil {
+		return nil, services.ErrTokenRequired
+	}
+	return claims, nil
+}
+
+// requireAdmin пропускает только администраторов
+func requireAdmin(ctx context.Context) (*services.TokenClaims, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.IsAdmin() {
+		return nil, services.ErrAdminRequired
+	}
+	return claims, nil
+}
+
+// rateLimitUnary применяет политики REST-маршрутов из rateLimitRoutes, как middleware.RateLimit.
+// Время до повтора передаётся в google.rpc.RetryInfo.
+func rateLimitUnary(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		route, ok := rateLimitRoutes[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		policy, ok := limiter.Policy(route.method, route.route)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		var account string
+		if policy.HasScope(ratelimit.ScopeAccount) {
+			account = requestAccount(ctx, req)
+		}
+
+		if policy.Lockout && account != "" {
+			locked, err := limiter.LockedFor(ctx, account)
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to check login lockout", "error", err)
+			} else if locked > 0 {
+				metrics.RateLimited(policy.Route, metrics.RateLimitLockout)
+				return nil, withRetryDelay(middleware.ErrAccountLocked, locked)
+			}
+		}
+
+		result, rule, err := limiter.Allow(ctx, policy, clientIP(ctx), account)
+		if err != nil {
+			// Лимиты не должны ломать сервис: без хранилища вызов пропускается
+			logger.ErrorContext(ctx, "rate limit check failed", "error", err)
+			return handler(ctx, req)
+		}
+		if !result.Allowed {
+			metrics.RateLimited(policy.Route, rule.Scope)
+			return nil, withRetryDelay(middleware.ErrRateLimited, result.Reset)
+		}
+
+		resp, err := handler(ctx, req)
+		if policy.Lockout && account != "" {
+			recordLogin(ctx, limiter, account, err)
+		}
+		return resp, err
+	}
+}
+
+// recordLogin учитывает результат входа: Unauthenticated — неудача, успех сбрасывает счётчик
+func recordLogin(ctx context.Context, limiter *ratelimit.Limiter, account string, err error) {
+	switch {
+	case errors.Is(err, models.ErrUnauthorized):
+		locked, err := limiter.LoginFailed(ctx, account)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to record failed login", "error", err)
+		} else if locked > 0 {
+			logger.WarnContext(ctx, "login locked after failed attempts", "account", account, "ip", clientIP(ctx), "locked_for", locked.String())
+		}
+	case err == nil:
+		if err := limiter.LoginSucceeded(ctx, account); err != nil {
+			logger.ErrorContext(ctx, "failed to reset failed logins", "error", err)
+		}
+	}
+}
+
+// requestAccount возвращает пользователя из токена или email из запроса
+func requestAccount(ctx context.Context, req any) string {
+	if claims := claimsFromContext(ctx); claims != nil {
+		return claims.UserID
+	}
+	if r, ok := req.(interface{ GetEmail() string }); ok {
+		return r.GetEmail()
+	}
+	return ""
+}
+
+// withRetryDelay добавляет к статусу ошибки время до повтора, округлённое вверх до секунды
+func withRetryDelay(err error, d time.Duration) error {
+	st := toStatus(err)
+	delay := time.Duration(math.Ceil(d.Seconds())) * time.Second
+	if withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}); err == nil {
+		return withDetails.Err()
+	}
+	return st.Err()
+}
+
+// firstMetadata возвращает первое значение ключа входящих метаданных
+func firstMetadata(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// clientIP возвращает адрес клиента из соединения
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,186 @@
+package grpcapi
+
+import (
+	"context"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/repositories"
+	"order-service/services"
+	"unicode/utf8"
+
+	pb "order-service/gen/orderservice/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxCancelReasonLength — как в CancelOrderRequest REST API
+const maxCancelReasonLength = 500
+
+type orderServer struct {
+	pb.UnimplementedOrderServiceServer
+	orders  *services.OrderService
+	updates *services.OrderUpdates
+	// Закрывается при остановке сервера: открытые потоки WatchOrder завершаются
+	done <-chan struct{}
+}
+
+func (s *orderServer) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest) (*pb.Order, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	request := models.CreateOrderRequest{UserID: req.GetUserId(), TotalPrice: req.GetTotalPrice()}
+	if err := middleware.Validate(&request); err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(request.UserID) {
+		return nil, services.ErrForbidden
+	}
+
+	order, err := s.orders.CreateOrder(ctx, request.UserID, request.TotalPrice)
+	if err != nil {
+		return nil, err
+	}
+	return toOrder(order), nil
+}
+
+func (s *orderServer) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.Order, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIncludeDeleted(ctx, req.GetIncludeDeleted()); err != nil {
+		return nil, err
+	}
+
+	order, err := s.orders.GetOrderById(ctx, req.GetId(), req.GetIncludeDeleted())
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, services.ErrForbidden
+	}
+	return toOrder(order), nil
+}
+
+// ListOrders возвращает заказы user_id; без него — свои заказы, а администратору — все
+func (s *orderServer) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []models.Order
+	switch userID := req.GetUserId(); {
+	case userID == "" && claims.IsAdmin():
+		orders, err = s.orders.GetAllOrders(ctx, false)
+	case userID == "":
+		orders, err = s.orders.GetUserOrders(ctx, claims.UserID)
+	case claims.CanAccess(userID):
+		orders, err = s.orders.GetUserOrders(ctx, userID)
+	default:
+		return nil, services.ErrForbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ListOrdersResponse{Orders: toOrders(orders)}, nil
+}
+
+func (s *orderServer) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.Order, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(req.GetReason()) > maxCancelReasonLength {
+		return nil, models.NewFieldErrors(models.FieldError{Field: "reason", Message: "must be at most 500 characters long"})
+	}
+
+	order, err := s.orders.CancelOrder(ctx, req.GetId(), claims, req.GetReason())
+	if err != nil {
+		return nil, err
+	}
+	return toOrder(order), nil
+}
+
+func (s *orderServer) GetOrderHistory(ctx context.Context, req *pb.GetOrderHistoryRequest) (*pb.GetOrderHistoryResponse, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.orders.GetOrderHistory(ctx, req.GetId(), claims)
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.GetOrderHistoryResponse{Changes: make([]*pb.OrderStatusChange, 0, len(history))}
+	for i := range history {
+		response.Changes = append(response.Changes, toStatusChange(&history[i]))
+	}
+	return response, nil
+}
+
+// WatchOrder отправляет текущее состояние заказа, затем каждое его изменение, пока заказ
+// не придёт в конечный статус или не будет удалён. Сообщения Pub/Sub служат только сигналом:
+// заказ перечитывается из базы, а состояния не новее уже отправленного пропускаются.
+func (s *orderServer) WatchOrder(req *pb.WatchOrderRequest, stream grpc.ServerStreamingServer[pb.OrderUpdate]) error {
+	ctx := stream.Context()
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Подписка раньше чтения: изменение между чтением и подпиской не потеряется
+	sub, err := s.updates.Subscribe(ctx, req.GetId())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to subscribe to order updates", "order_id", req.GetId(), "error", err)
+		return status.Error(codes.Unavailable, "order updates are unavailable")
+	}
+	defer sub.Close()
+
+	order, err := s.orders.GetOrderById(ctx, req.GetId(), true)
+	if err != nil {
+		return err
+	}
+	if order.DeletedAt != nil && !claims.IsAdmin() {
+		return repositories.ErrOrderNotFound
+	}
+	if !claims.CanAccess(order.UserID) {
+		return services.ErrForbidden
+	}
+	if err := stream.Send(&pb.OrderUpdate{Order: toOrder(order)}); err != nil {
+		return err
+	}
+
+	for !order.IsFinal() && order.DeletedAt == nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.done:
+			return errShuttingDown
+		case _, ok := <-sub.Updates():
+			if !ok {
+				return status.Error(codes.Unavailable, "order updates subscription closed")
+			}
+		}
+
+		current, err := s.orders.GetOrderById(ctx, order.ID, true)
+		if err != nil {
+			return err
+		}
+		if current.Version <= order.Version {
+			continue
+		}
+		update := &pb.OrderUpdate{Order: toOrder(current)}
+		if current.Status != order.Status {
+			update.PreviousStatus = toOrderStatus(order.Status)
+		}
+		if err := stream.Send(update); err != nil {
+			return err
+		}
+		order = current
+	}
+	return nil
+}
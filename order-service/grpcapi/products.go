@@ -0,0 +1,110 @@
+package grpcapi
+
+import (
+	"context"
+	"order-service/models"
+	"order-service/services"
+
+	pb "order-service/gen/orderservice/v1"
+)
+
+type productServer struct {
+	pb.UnimplementedProductServiceServer
+	products *services.ProductService
+}
+
+func (s *productServer) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	if err := checkIncludeDeleted(ctx, req.GetIncludeDeleted()); err != nil {
+		return nil, err
+	}
+
+	product, err := s.products.GetProductById(ctx, req.GetId(), req.GetIncludeDeleted())
+	if err != nil {
+		return nil, err
+	}
+	response := product.ToResponse()
+	return toProduct(&response), nil
+}
+
+func (s *productServer) ListProducts(ctx context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	if err := checkIncludeDeleted(ctx, req.GetIncludeDeleted()); err != nil {
+		return nil, err
+	}
+
+	products, err := s.products.GetAllProducts(ctx, req.GetIncludeDeleted())
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.ListProductsResponse{Products: make([]*pb.Product, 0, len(products))}
+	for i := range products {
+		response.Products = append(response.Products, toProduct(&products[i]))
+	}
+	return response, nil
+}
+
+// SearchProducts — полнотекстовый поиск с фасетами; page и page_size = 0 — значения по умолчанию
+func (s *productServer) SearchProducts(ctx context.Context, req *pb.SearchProductsRequest) (*pb.SearchProductsResponse, error) {
+	params := models.ProductSearchParams{
+		Query:    req.GetQuery(),
+		MinPrice: req.MinPrice,
+		MaxPrice: req.MaxPrice,
+		InStock:  req.InStock,
+		Category: req.GetCategory(),
+		Page:     int(req.GetPage()),
+		PageSize: int(req.GetPageSize()),
+	}
+
+	var fields models.FieldErrors
+	if params.MinPrice != nil && *params.MinPrice < 0 {
+		fields.Add("min_price", "must be greater than or equal to 0")
+	}
+	if params.MaxPrice != nil && *params.MaxPrice < 0 {
+		fields.Add("max_price", "must be greater than or equal to 0")
+	}
+	if params.Page < 0 || params.Page > models.MaxSearchPage {
+		fields.Add("page", "must be between 0 and 1000")
+	}
+	if params.PageSize < 0 {
+		fields.Add("page_size", "must be greater than or equal to 0")
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+
+	result, err := s.products.SearchProducts(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.SearchProductsResponse{
+		Items:    make([]*pb.ProductSearchHit, 0, len(result.Items)),
+		Total:    result.Total,
+		Page:     int32(result.Page),
+		PageSize: int32(result.PageSize),
+		Facets: &pb.ProductFacets{
+			Price:        toFacetBuckets(result.Facets.Price),
+			Category:     toFacetBuckets(result.Facets.Category),
+			Availability: toFacetBuckets(result.Facets.Availability),
+		},
+	}
+	for i := range result.Items {
+		item := &result.Items[i]
+		response.Items = append(response.Items, &pb.ProductSearchHit{
+			Product:    toProduct(item),
+			Score:      item.Score,
+			Highlights: item.Highlights,
+		})
+	}
+	return response, nil
+}
+
+// checkIncludeDeleted — удалённые записи видит только администратор
+func checkIncludeDeleted(ctx context.Context, includeDeleted bool) error {
+	if !includeDeleted {
+		return nil
+	}
+	if claims := claimsFromContext(ctx); claims == nil || !claims.IsAdmin() {
+		return services.ErrDeletedAdminOnly
+	}
+	return nil
+}
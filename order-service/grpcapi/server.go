@@ -0,0 +1,82 @@
+// Package grpcapi — gRPC API сервиса: пользователи, каталог, корзина и заказы поверх тех же
+// сервисов, что и REST. Контракт описан в proto/orderservice/v1, код в gen/ генерируется buf.
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"order-service/ratelimit"
+	"order-service/services"
+
+	pb "order-service/gen/orderservice/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server — gRPC-сервер с проверкой здоровья (grpc.health.v1) и, по настройке, reflection
+type Server struct {
+	GRPC   *grpc.Server
+	health *health.Server
+	done   chan struct{}
+}
+
+// NewServer регистрирует службы API. limiter — nil, если лимиты запросов выключены.
+// Валидаторы (middleware.RegisterValidators) должны быть зарегистрированы до первого вызова.
+func NewServer(users *services.UserService, products *services.ProductService, cart *services.CartService, orders *services.OrderService, updates *services.OrderUpdates, limiter *ratelimit.Limiter, enableReflection bool) *Server {
+	unary := []grpc.UnaryServerInterceptor{observeUnary, authUnary}
+	if limiter != nil {
+		unary = append(unary, rateLimitUnary(limiter))
+	}
+
+	s := &Server{
+		GRPC: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(unary...),
+			grpc.ChainStreamInterceptor(observeStream, authStream),
+		),
+		health: health.NewServer(),
+		done:   make(chan struct{}),
+	}
+
+	pb.RegisterUserServiceServer(s.GRPC, &userServer{users: users})
+	pb.RegisterProductServiceServer(s.GRPC, &productServer{products: products})
+	pb.RegisterCartServiceServer(s.GRPC, &cartServer{cart: cart})
+	pb.RegisterOrderServiceServer(s.GRPC, &orderServer{orders: orders, updates: updates, done: s.done})
+	healthpb.RegisterHealthServer(s.GRPC, s.health)
+	if enableReflection {
+		reflection.Register(s.GRPC)
+	}
+
+	// Пустое имя — состояние сервера в целом
+	for _, service := range []string{"", pb.UserService_ServiceDesc.ServiceName, pb.ProductService_ServiceDesc.ServiceName, pb.CartService_ServiceDesc.ServiceName, pb.OrderService_ServiceDesc.ServiceName} {
+		s.health.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+	return s
+}
+
+// Serve принимает соединения, пока сервер не остановлен
+func (s *Server) Serve(lis net.Listener) error {
+	return s.GRPC.Serve(lis)
+}
+
+// Shutdown переводит проверку здоровья в NOT_SERVING, завершает потоки WatchOrder
+// и дожидается текущих вызовов; по истечении ctx закрывает соединения сразу
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	close(s.done)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GRPC.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.GRPC.Stop()
+		return ctx.Err()
+	}
+}
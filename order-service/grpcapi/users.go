@@ -0,0 +1,77 @@
+package grpcapi
+
+import (
+	"context"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/services"
+
+	pb "order-service/gen/orderservice/v1"
+)
+
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	users *services.UserService
+}
+
+func (s *userServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.User, error) {
+	request := models.RegisterRequest{Username: req.GetUsername(), Email: req.GetEmail(), Password: req.GetPassword()}
+	if err := middleware.Validate(&request); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.Register(ctx, request.Username, request.Email, request.Password)
+	if err != nil {
+		return nil, err
+	}
+	return toUser(user), nil
+}
+
+func (s *userServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	request := models.LoginRequest{Email: req.GetEmail(), Password: req.GetPassword()}
+	if err := middleware.Validate(&request); err != nil {
+		return nil, err
+	}
+
+	token, err := s.users.Login(ctx, request.Email, request.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.LoginResponse{Token: token}, nil
+}
+
+// GetUser возвращает пользователя ему самому или администратору
+func (s *userServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	claims, err := requireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(req.GetId()) {
+		return nil, services.ErrForbidden
+	}
+	if req.GetIncludeDeleted() && !claims.IsAdmin() {
+		return nil, services.ErrDeletedAdminOnly
+	}
+
+	user, err := s.users.GetUserByID(ctx, req.GetId(), req.GetIncludeDeleted())
+	if err != nil {
+		return nil, err
+	}
+	return toUser(user), nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	users, err := s.users.GetAllUsers(ctx, req.GetIncludeDeleted())
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.ListUsersResponse{Users: make([]*pb.User, 0, len(users))}
+	for i := range users {
+		response.Users = append(response.Users, toUser(&users[i]))
+	}
+	return response, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	Service *services.AuditService
+}
+
+func NewAuditHandler(service *services.AuditService) *AuditHandler {
+	return &AuditHandler{Service: service}
+}
+
+// ListAudit возвращает журнал аудита от новых записей к старым.
+// Фильтры: actor_id, action, entity_type, entity_id, request_id, from, to (RFC 3339);
+// страницы — limit и before_id.
+func (h *AuditHandler) ListAudit(c *gin.Context) {
+	filter := models.AuditFilter{
+		ActorID:    c.Query("actor_id"),
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		RequestID:  c.Query("request_id"),
+	}
+
+	var fields models.FieldErrors
+	filter.From = optionalTime(c, "from", &fields)
+	filter.To = optionalTime(c, "to", &fields)
+	filter.Limit = optionalInt(c, "limit", &fields)
+	if value := c.Query("before_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			fields.Add("before_id", "must be a positive integer")
+		}
+		filter.BeforeID = id
+	}
+	if err := fields.Err(); err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	page, err := h.Service.List(c.Request.Context(), filter)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// VerifyAudit пересчитывает цепочку хэшей журнала и сообщает, где она нарушена
+func (h *AuditHandler) VerifyAudit(c *gin.Context) {
+	result, err := h.Service.Verify(c.Request.Context())
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// optionalTime читает необязательный параметр запроса со временем в формате RFC 3339
+func optionalTime(c *gin.Context, name string, fields *models.FieldErrors) *time.Time {
+	value := c.Query(name)
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		fields.Add(name, "must be an RFC 3339 timestamp")
+		return nil
+	}
+	return &t
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"net/http"
+	"order-service/middleware"
+	"order-service/models"
 	"order-service/services"
 
 	"github.com/gin-gonic/gin"
@@ -15,9 +17,11 @@ func NewCartHandler(cartService *services.CartService) *CartHandler {
 	return &CartHandler{CartService: cartService}
 }
 
-// AddToCartRequest представляет структуру запроса для добавления товара в корзину
+// AddToCartRequest представляет структуру запроса для добавления товара в корзину.
+// Достаточно указать sku; productId подходит только для продуктов с одним вариантом.
 type AddToCartRequest struct {
-	ProductID string `json:"productId" binding:"required"`
+	SKU       string `json:"sku" binding:"required_without=ProductID,max=64"`
+	ProductID string `json:"productId"`
 	Quantity  int    `json:"quantity" binding:"required,min=1"`
 }
 
@@ -28,53 +32,59 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	// Получаем данные из тела запроса
 	var request AddToCartRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		middleware.RespondBindingError(c, err)
 		return
 	}
 
 	// Добавляем товар в корзину
-	err := h.CartService.AddToCart(userID, request.ProductID, request.Quantity)
+	err := h.CartService.AddToCart(c.Request.Context(), userID, request.SKU, request.ProductID, request.Quantity)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product added to cart"})
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Product added to cart"})
 }
 func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 	userID := c.Param("userID")
-	productID := c.Param("productID")
+	sku := c.Param("sku")
 
 	// Удаляем товар из корзины
-	err := h.CartService.RemoveFromCart(userID, productID)
+	err := h.CartService.RemoveFromCart(c.Request.Context(), userID, sku)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product removed from cart"})
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Product removed from cart"})
 }
 func (h *CartHandler) GetCart(c *gin.Context) {
 	userID := c.Param("userID")
 
-	// Получаем корзину
-	cart, err := h.CartService.GetCart(userID)
+	// Получаем позиции корзины с данными продуктов; недоступные помечены полем unavailable
+	items, err := h.CartService.GetCartLines(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(c, err)
 		return
 	}
+	// cart — прежний формат ответа {sku: количество}
+	cart := make(map[string]int, len(items))
+	for _, item := range items {
+		cart[item.SKU] = item.Quantity
+	}
 
-	c.JSON(http.StatusOK, gin.H{"cart": cart})
+	c.JSON(http.StatusOK, models.CartResponse{Cart: cart, Items: items})
 }
 func (h *CartHandler) CheckoutCart(c *gin.Context) {
 	userID := c.Param("userID")
 
 	// Оформляем заказ
-	order, err := h.CartService.CheckoutCart(userID)
+	order, skipped, err := h.CartService.CheckoutCart(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"order": order})
+	// skipped — позиции удалённых продуктов, оставшиеся в корзине
+	c.JSON(http.StatusOK, models.CheckoutResponse{Order: order.ToResponse(), Skipped: skipped})
 }
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	Service *services.CategoryService
+}
+
+func NewCategoryHandler(service *services.CategoryService) *CategoryHandler {
+	return &CategoryHandler{Service: service}
+}
+
+// CategoryRequest — тело запроса на создание или изменение категории
+type CategoryRequest struct {
+	Name   string `json:"name" binding:"max=100"`
+	Slug   string `json:"slug" binding:"max=100"`
+	Parent string `json:"parent" binding:"max=100"`
+}
+
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	category, err := h.Service.CreateCategory(c.Request.Context(), req.Name, req.Slug, req.Parent)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, category)
+}
+
+// GetCategoryTree возвращает дерево категорий
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	tree, err := h.Service.GetCategoryTree(c.Request.Context())
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	category, err := h.Service.GetCategory(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory переименовывает категорию или переносит её под другого родителя
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	category, err := h.Service.UpdateCategory(c.Request.Context(), c.Param("slug"), req.Name, req.Parent)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	if err := h.Service.DeleteCategory(c.Request.Context(), c.Param("slug")); err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Category deleted successfully"})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"order-service/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DocsHandler struct {
+	// Spec — спецификация OpenAPI, сериализованная один раз при запуске
+	Spec []byte
+}
+
+func NewDocsHandler(doc *openapi.Document) (*DocsHandler, error) {
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &DocsHandler{Spec: spec}, nil
+}
+
+// OpenAPI отдаёт спецификацию API
+func (h *DocsHandler) OpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", h.Spec)
+}
+
+// SwaggerUI отдаёт страницу Swagger UI для спецификации из /openapi.json
+func (h *DocsHandler) SwaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.SwaggerUI)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"order-service/graphqlapi"
+	"order-service/middleware"
+	"order-service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GraphQLHandler struct {
+	API *graphqlapi.API
+}
+
+func NewGraphQLHandler(api *graphqlapi.API) *GraphQLHandler {
+	return &GraphQLHandler{API: api}
+}
+
+// Query выполняет GraphQL-запрос из тела POST или параметров GET.
+// Ошибки самого запроса и резолверов возвращаются в поле errors со статусом 200, как принято
+// в GraphQL; problem+json — только если запрос не удалось разобрать или токен недействителен.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var request models.GraphQLRequest
+	if c.Request.Method == http.MethodGet {
+		if err := c.ShouldBindQuery(&request); err != nil {
+			middleware.RespondBindingError(c, err)
+			return
+		}
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				middleware.RespondError(c, models.NewFieldErrors(models.FieldError{Field: "variables", Message: "must be a JSON object"}))
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	response := h.API.Exec(c.Request.Context(), middleware.Claims(c), request.Query, request.OperationName, request.Variables)
+	c.JSON(http.StatusOK, response)
+}
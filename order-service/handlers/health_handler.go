@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"order-service/models"
+	"order-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	Service *services.HealthService
+}
+
+func NewHealthHandler(service *services.HealthService) *HealthHandler {
+	return &HealthHandler{Service: service}
+}
+
+// Liveness отвечает, пока процесс способен обрабатывать запросы; зависимости не проверяются
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Liveness{Status: "ok"})
+}
+
+// Readiness отвечает 200, если доступны Postgres, MongoDB и Redis, иначе 503
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	readiness := h.Service.Readiness(c.Request.Context())
+	status := http.StatusOK
+	if readiness.Status != models.ReadinessReady {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, readiness)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InventoryHandler struct {
+	Service *services.InventoryService
+}
+
+func NewInventoryHandler(service *services.InventoryService) *InventoryHandler {
+	return &InventoryHandler{Service: service}
+}
+
+func (h *InventoryHandler) CreateWarehouse(c *gin.Context) {
+	var request models.WarehouseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	warehouse, err := h.Service.CreateWarehouse(c.Request.Context(), &request)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	middleware.SetETag(c, warehouse.Version)
+	c.JSON(http.StatusCreated, warehouse)
+}
+
+func (h *InventoryHandler) ListWarehouses(c *gin.Context) {
+	warehouses, err := h.Service.ListWarehouses(c.Request.Context())
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, warehouses)
+}
+
+func (h *InventoryHandler) GetWarehouse(c *gin.Context) {
+	warehouse, err := h.Service.GetWarehouse(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	middleware.SetETag(c, warehouse.Version)
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// UpdateWarehouse заменяет склад; If-Match должен содержать текущий ETag
+func (h *InventoryHandler) UpdateWarehouse(c *gin.Context) {
+	version, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	var request models.WarehouseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	warehouse, err := h.Service.UpdateWarehouse(c.Request.Context(), c.Param("id"), &request, version)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	middleware.SetETag(c, warehouse.Version)
+	c.JSON(http.StatusOK, warehouse)
+}
+
+// GetAvailability возвращает остатки SKU по складам
+func (h *InventoryHandler) GetAvailability(c *gin.Context) {
+	availability, err := h.Service.GetAvailability(c.Request.Context(), c.Param("sku"))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, availability)
+}
+
+// ListStockMovements возвращает журнал движений от новых к старым.
+// Фильтры — sku, warehouse_id, order_id, type; страницы — limit и before_id.
+func (h *InventoryHandler) ListStockMovements(c *gin.Context) {
+	filter := models.StockMovementFilter{
+		SKU:         c.Query("sku"),
+		WarehouseID: c.Query("warehouse_id"),
+		OrderID:     c.Query("order_id"),
+		Type:        c.Query("type"),
+	}
+
+	var fields models.FieldErrors
+	filter.Limit = optionalInt(c, "limit", &fields)
+	if value := c.Query("before_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			fields.Add("before_id", "must be a positive integer")
+		}
+		filter.BeforeID = id
+	}
+	if err := fields.Err(); err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	page, err := h.Service.ListMovements(c.Request.Context(), filter)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// RecordStockMovement записывает поступление или корректировку остатка
+func (h *InventoryHandler) RecordStockMovement(c *gin.Context) {
+	var request models.StockMovementRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	movement, err := h.Service.RecordMovement(c.Request.Context(), &request)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, movement)
+}
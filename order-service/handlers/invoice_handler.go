@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InvoiceHandler struct {
+	Service *services.InvoiceService
+}
+
+func NewInvoiceHandler(service *services.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{Service: service}
+}
+
+// GetInvoice отдаёт PDF счёта по заказу
+func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
+	orderID := c.Param("id")
+
+	invoice, pdf, err := h.Service.GetInvoicePDF(c.Request.Context(), orderID, middleware.Claims(c))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	writePDF(c, invoice, pdf)
+}
+
+// GetCreditNote отдаёт PDF кредит-ноты по возврату средств
+func (h *InvoiceHandler) GetCreditNote(c *gin.Context) {
+	refundID := c.Param("id")
+
+	creditNote, pdf, err := h.Service.GetCreditNotePDF(c.Request.Context(), refundID, middleware.Claims(c))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	writePDF(c, creditNote, pdf)
+}
+
+func writePDF(c *gin.Context, document *models.Invoice, pdf []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, document.Number))
+	c.Header("ETag", `"`+document.SHA256+`"`)
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
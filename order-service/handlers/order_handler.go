@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"order-service/middleware"
 	"order-service/models"
 	"order-service/services"
 )
@@ -17,73 +17,216 @@ func NewOrderHandler(service *services.OrderService) *OrderHandler {
 }
 
 func (h *OrderHandler) CreateOrder(ctx *gin.Context) {
-	var request struct {
-		UserID     string  `json:"user_id"`
-		TotalPrice float64 `json:"total_price"`
-	}
+	var request models.CreateOrderRequest
 	if err := ctx.ShouldBindJSON(&request); err != nil { // Используем ShouldBindJSON вместо ShouldBind
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.RespondBindingError(ctx, err)
+		return
+	}
+	// Заказ оформляет сам покупатель или администратор — как в gRPC и GraphQL
+	if !middleware.Claims(ctx).CanAccess(request.UserID) {
+		middleware.RespondError(ctx, services.ErrForbidden)
 		return
 	}
 
-	order, err := h.Service.CreateOrder(request.UserID, request.TotalPrice)
+	order, err := h.Service.CreateOrder(ctx.Request.Context(), request.UserID, request.TotalPrice)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusCreated, order)
+	middleware.SetETag(ctx, order.Version)
+	ctx.JSON(http.StatusCreated, order.ToResponse())
 }
 
 func (h *OrderHandler) GetOrderById(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	order, err := h.Service.GetOrderById(id) // Исправлено на `h.Service`
+	withDeleted, err := includeDeleted(ctx)
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	order, err := h.Service.GetOrderById(ctx.Request.Context(), id, withDeleted) // Исправлено на `h.Service`
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Order with id %s not found", id)})
+		middleware.RespondError(ctx, err)
+		return
+	}
+	if !middleware.Claims(ctx).CanAccess(order.UserID) {
+		middleware.RespondError(ctx, services.ErrForbidden)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, order)
+	middleware.SetETag(ctx, order.Version)
+	ctx.JSON(http.StatusOK, order.ToResponse())
 }
 
 func (h *OrderHandler) GetAllOrders(ctx *gin.Context) {
-	orders, err := h.Service.GetAllOrders() // Исправлено на `h.Service`
+	withDeleted, err := includeDeleted(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		middleware.RespondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, orders)
+	orders, err := h.Service.GetAllOrders(ctx.Request.Context(), withDeleted) // Исправлено на `h.Service`
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	response := make([]models.OrderResponse, 0, len(orders))
+	for i := range orders {
+		response = append(response, orders[i].ToResponse())
+	}
+	ctx.JSON(http.StatusOK, response)
 }
 
+// UpdateOrder заменяет заказ; If-Match должен содержать текущий ETag
 func (h *OrderHandler) UpdateOrder(ctx *gin.Context) {
 	id := ctx.Param("id")
-	var updatedOrder models.Order
+	version, err := middleware.IfMatchVersion(ctx)
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	var request models.UpdateOrderRequest
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(ctx, err)
+		return
+	}
+
+	updatedOrder, err := h.Service.UpdateOrder(ctx.Request.Context(), id, &models.Order{
+		UserID: request.UserID,
+		Status: request.Status,
+	}, version)
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	middleware.SetETag(ctx, updatedOrder.Version)
+	ctx.JSON(http.StatusOK, updatedOrder.ToResponse())
+}
+
+// PatchOrder частично обновляет заказ (JSON Merge Patch или JSON Patch)
+func (h *OrderHandler) PatchOrder(ctx *gin.Context) {
+	id := ctx.Param("id")
+	version, err := middleware.IfMatchVersion(ctx)
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
 
-	if err := ctx.ShouldBindJSON(&updatedOrder); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	order, err := h.Service.GetOrderById(ctx.Request.Context(), id, false)
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+	request := models.UpdateOrderRequest{UserID: order.UserID, Status: order.Status}
+	fields, err := middleware.BindPatch(ctx, &request, models.OrderPatchFields)
+	if err != nil {
+		middleware.RespondBindingError(ctx, err)
 		return
 	}
 
-	// Правильное присваивание с указателем
-	updatedOrderPtr, err := h.Service.UpdateOrder(id, &updatedOrder)
+	order, err = h.Service.PatchOrder(ctx.Request.Context(), id, &request, fields, version)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+		middleware.RespondError(ctx, err)
 		return
 	}
 
-	// Разыменовываем указатель перед отправкой в JSON
-	ctx.JSON(http.StatusOK, *updatedOrderPtr)
+	middleware.SetETag(ctx, order.Version)
+	ctx.JSON(http.StatusOK, order.ToResponse())
 }
 
+// DeleteOrder мягко удаляет заказ (администратор)
 func (h *OrderHandler) DeleteOrder(ctx *gin.Context) {
+	if err := h.Service.DeleteOrder(ctx.Request.Context(), ctx.Param("id")); err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.MessageResponse{Message: "Order deleted successfully"})
+}
+
+// RestoreOrder восстанавливает мягко удалённый заказ (администратор)
+func (h *OrderHandler) RestoreOrder(ctx *gin.Context) {
+	order, err := h.Service.RestoreOrder(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	middleware.SetETag(ctx, order.Version)
+	ctx.JSON(http.StatusOK, order.ToResponse())
+}
+
+// CancelOrder отменяет заказ до отгрузки
+func (h *OrderHandler) CancelOrder(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var request models.CancelOrderRequest
+	// Тело запроса необязательно
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			middleware.RespondBindingError(ctx, err)
+			return
+		}
+	}
+
+	order, err := h.Service.CancelOrder(ctx.Request.Context(), id, middleware.Claims(ctx), request.Reason)
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order.ToResponse())
+}
+
+// GetOrderHistory возвращает историю статусов заказа
+func (h *OrderHandler) GetOrderHistory(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	history, err := h.Service.GetOrderHistory(ctx.Request.Context(), id, middleware.Claims(ctx))
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
+
+// GetOrderRefunds возвращает возвраты средств по заказу
+func (h *OrderHandler) GetOrderRefunds(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	err := h.Service.DeleteOrder(id) // Исправлено на `h.Service`
+	refunds, err := h.Service.GetOrderRefunds(ctx.Request.Context(), id, middleware.Claims(ctx))
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, refunds)
+}
+
+// GetOrderEvents возвращает журнал событий заказа (администратор)
+func (h *OrderHandler) GetOrderEvents(ctx *gin.Context) {
+	events, err := h.Service.GetOrderEvents(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		middleware.RespondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
+
+// GetUserOrderSummary возвращает сводку по заказам пользователя
+func (h *OrderHandler) GetUserOrderSummary(ctx *gin.Context) {
+	summary, err := h.Service.GetUserOrderSummary(ctx.Request.Context(), ctx.Param("id"), middleware.Claims(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Order with id %s not found", id)})
+		middleware.RespondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
+	ctx.JSON(http.StatusOK, summary)
 }
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrivacyHandler struct {
+	Service *services.PrivacyService
+}
+
+func NewPrivacyHandler(service *services.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{Service: service}
+}
+
+// ExportUser отдаёт ZIP-архив с персональными данными пользователя
+func (h *PrivacyHandler) ExportUser(c *gin.Context) {
+	id := c.Param("id")
+
+	archive, err := h.Service.ExportUser(c.Request.Context(), id, middleware.Claims(c))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"user-%s-export.zip\"", id))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// EraseUser удаляет персональные данные пользователя; заказы и документы остаются обезличенными
+func (h *PrivacyHandler) EraseUser(c *gin.Context) {
+	if err := h.Service.EraseUser(c.Request.Context(), c.Param("id"), middleware.Claims(c)); err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "User deleted successfully"})
+}
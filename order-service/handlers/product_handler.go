@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"order-service/middleware"
 	"order-service/models"
 	"order-service/services"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type ProductHandler struct {
@@ -17,64 +22,241 @@ func NewProductHandler(service *services.ProductService) *ProductHandler {
 }
 
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
-	var product models.Product
-	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var request models.ProductRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
 		return
 	}
 
-	// Если IDString не задан в запросе, он будет сгенерирован в репозитории
-	err := h.Service.CreateProduct(&product)
+	// Если ID не задан в запросе, канонический UUID будет сгенерирован в репозитории
+	product := request.ToProduct()
+	err := h.Service.CreateProduct(c.Request.Context(), product)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		middleware.RespondError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, product)
+	middleware.SetETag(c, product.Version)
+	c.JSON(http.StatusOK, product.ToResponse())
 }
 
 func (h *ProductHandler) GetProductById(c *gin.Context) {
 	id := c.Param("id")
-	product, err := h.Service.GetProductById(id)
+	withDeleted, err := includeDeleted(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		middleware.RespondError(c, err)
 		return
 	}
+	product, err := h.Service.GetProductById(c.Request.Context(), id, withDeleted)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	// Продукт найден по прежнему ID: сообщаем клиенту канонический адрес
+	if id != product.IDString {
+		c.Header("Link", fmt.Sprintf("</products/%s>; rel=\"canonical\"", product.IDString))
+	}
+	middleware.SetETag(c, product.Version)
+	c.JSON(http.StatusOK, product.ToResponse())
+}
 
-	c.JSON(http.StatusOK, product)
+// ResolveProductID сообщает канонический ID продукта по любому его ID
+func (h *ProductHandler) ResolveProductID(c *gin.Context) {
+	resolution, err := h.Service.ResolveProductID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resolution)
 }
 func (h *ProductHandler) GetAllProducts(c *gin.Context) {
-	products, err := h.Service.GetAllProducts()
+	withDeleted, err := includeDeleted(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(c, err)
+		return
+	}
+	products, err := h.Service.GetAllProducts(c.Request.Context(), withDeleted)
+	if err != nil {
+		middleware.RespondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, products)
 }
+
+// SearchProducts выполняет полнотекстовый поиск продуктов с фасетами
+func (h *ProductHandler) SearchProducts(c *gin.Context) {
+	params := models.ProductSearchParams{
+		Query:    c.Query("q"),
+		Category: c.Query("category"),
+	}
+
+	var fields models.FieldErrors
+	params.MinPrice = optionalFloat(c, "min_price", &fields)
+	params.MaxPrice = optionalFloat(c, "max_price", &fields)
+	if value := c.Query("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			fields.Add("in_stock", "must be true or false")
+		}
+		params.InStock = &inStock
+	}
+	params.Page = optionalInt(c, "page", &fields)
+	if params.Page > models.MaxSearchPage {
+		fields.Add("page", "must be less than or equal to 1000")
+	}
+	params.PageSize = optionalInt(c, "page_size", &fields)
+	if err := fields.Err(); err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	result, err := h.Service.SearchProducts(c.Request.Context(), params)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// optionalFloat читает необязательный числовой параметр запроса
+func optionalFloat(c *gin.Context, name string, fields *models.FieldErrors) *float64 {
+	value := c.Query(name)
+	if value == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		fields.Add(name, "must be a number")
+		return nil
+	}
+	if f < 0 {
+		fields.Add(name, "must be greater than or equal to 0")
+	}
+	return &f
+}
+
+// includeDeleted читает параметр include_deleted (?include_deleted или ?include_deleted=true).
+// Удалённые записи видит только администратор.
+func includeDeleted(c *gin.Context) (bool, error) {
+	value, ok := c.GetQuery("include_deleted")
+	if !ok {
+		return false, nil
+	}
+	include := true
+	if value != "" {
+		var err error
+		if include, err = strconv.ParseBool(value); err != nil {
+			return false, models.NewFieldErrors(models.FieldError{Field: "include_deleted", Message: "must be true or false"})
+		}
+	}
+	if include && services.ActorFromContext(c.Request.Context()).Role != models.RoleAdmin {
+		return false, services.ErrDeletedAdminOnly
+	}
+	return include, nil
+}
+
+// optionalInt читает необязательный целочисленный параметр запроса
+func optionalInt(c *gin.Context, name string, fields *models.FieldErrors) int {
+	value := c.Query(name)
+	if value == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		fields.Add(name, "must be an integer")
+		return 0
+	}
+	if i < 1 {
+		fields.Add(name, "must be greater than or equal to 1")
+	}
+	return i
+}
+
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	id := c.Param("id")
-	var updatedProduct models.Product
+	version, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
 
-	if err := c.ShouldBindJSON(&updatedProduct); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+	var request models.ProductRequest
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+	// Остаток меняется только движениями склада — как в PATCH, поле stock отклоняется
+	var fields map[string]json.RawMessage
+	if err := c.ShouldBindBodyWith(&fields, binding.JSON); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+	if _, ok := fields["stock"]; ok {
+		middleware.RespondError(c, services.ErrStockManaged)
 		return
 	}
 
-	updated, err := h.Service.UpdateProduct(id, &updatedProduct)
+	updated, err := h.Service.UpdateProduct(c.Request.Context(), id, request.ToProduct(), version)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, updated)
+	middleware.SetETag(c, updated.Version)
+	c.JSON(http.StatusOK, updated.ToResponse())
 }
+
+// PatchProduct частично обновляет продукт (JSON Merge Patch или JSON Patch)
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	id := c.Param("id")
+	version, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	product, err := h.Service.GetProductById(c.Request.Context(), id, false)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	request := product.ToRequest()
+	fields, err := middleware.BindPatch(c, &request, models.ProductPatchFields)
+	if err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	product, err = h.Service.PatchProduct(c.Request.Context(), id, &request, fields, version)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	middleware.SetETag(c, product.Version)
+	c.JSON(http.StatusOK, product.ToResponse())
+}
+
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
 
-	err := h.Service.DeleteProduct(id)
+	err := h.Service.DeleteProduct(c.Request.Context(), id)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Product deleted successfully"})
+}
+
+// RestoreProduct возвращает мягко удалённый продукт в каталог
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	product, err := h.Service.RestoreProduct(c.Request.Context(), c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+	middleware.SetETag(c, product.Version)
+	c.JSON(http.StatusOK, product.ToResponse())
 }
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReturnHandler struct {
+	Service *services.ReturnService
+}
+
+func NewReturnHandler(service *services.ReturnService) *ReturnHandler {
+	return &ReturnHandler{Service: service}
+}
+
+// CreateReturnRequest — заявка покупателя на возврат позиций заказа
+type CreateReturnRequest struct {
+	Reason string              `json:"reason" binding:"required,max=500"`
+	Items  []models.ReturnItem `json:"items" binding:"required,min=1,dive"`
+}
+
+// ResolveReturnRequest — решение администратора по заявке
+type ResolveReturnRequest struct {
+	Comment string `json:"comment" binding:"max=1000"`
+}
+
+// CreateReturn оформляет заявку на возврат доставленного заказа
+func (h *ReturnHandler) CreateReturn(c *gin.Context) {
+	orderID := c.Param("id")
+
+	var request CreateReturnRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	ret, err := h.Service.RequestReturn(c.Request.Context(), orderID, middleware.Claims(c), request.Reason, request.Items)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ret)
+}
+
+// GetOrderReturns возвращает заявки на возврат по заказу
+func (h *ReturnHandler) GetOrderReturns(c *gin.Context) {
+	orderID := c.Param("id")
+
+	returns, err := h.Service.GetOrderReturns(c.Request.Context(), orderID, middleware.Claims(c))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+// GetReturn возвращает заявку на возврат по ID
+func (h *ReturnHandler) GetReturn(c *gin.Context) {
+	id := c.Param("id")
+
+	ret, err := h.Service.GetReturn(c.Request.Context(), id, middleware.Claims(c))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// ListReturns возвращает заявки для администратора, ?status= фильтрует по статусу
+func (h *ReturnHandler) ListReturns(c *gin.Context) {
+	returns, err := h.Service.ListReturns(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+// ApproveReturn одобряет заявку, возвращает деньги и пополняет склад
+func (h *ReturnHandler) ApproveReturn(c *gin.Context) {
+	h.resolve(c, h.Service.ApproveReturn)
+}
+
+// RejectReturn отклоняет заявку
+func (h *ReturnHandler) RejectReturn(c *gin.Context) {
+	h.resolve(c, h.Service.RejectReturn)
+}
+
+func (h *ReturnHandler) resolve(c *gin.Context, action func(ctx context.Context, id, adminID, comment string) (*models.Return, error)) {
+	id := c.Param("id")
+
+	var request ResolveReturnRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			middleware.RespondBindingError(c, err)
+			return
+		}
+	}
+
+	ret, err := action(c.Request.Context(), id, middleware.Claims(c).UserID, request.Comment)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
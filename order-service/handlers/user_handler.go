@@ -3,6 +3,7 @@ package handlers
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"order-service/middleware"
 	"order-service/models"
 	"order-service/services"
 )
@@ -17,96 +18,150 @@ func NewUserHandler(service *services.UserService) *UserHandler {
 
 // Регистрация пользователя
 func (h *UserHandler) Register(c *gin.Context) {
-	var request struct {
-		Username string `json:"username"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
+	var request models.RegisterRequest
 	if err := c.ShouldBind(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.RespondBindingError(c, err)
 		return
 	}
 
-	user, err := h.Service.Register(request.Username, request.Email, request.Password)
+	user, err := h.Service.Register(c.Request.Context(), request.Username, request.Email, request.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, user.ToResponse())
 }
 
 // Вход в систему
 func (h *UserHandler) Login(c *gin.Context) {
-	var request struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
+	var request models.LoginRequest
 	if err := c.ShouldBind(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.RespondBindingError(c, err)
 		return
 	}
 
-	token, err := h.Service.Login(request.Email, request.Password)
+	token, err := h.Service.Login(c.Request.Context(), request.Email, request.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, models.TokenResponse{Token: token})
 }
 
 // Получение всех пользователей
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
-	users, err := h.Service.GetAllUsers()
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	users, err := h.Service.GetAllUsers(c.Request.Context(), withDeleted)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	response := make([]models.UserResponse, 0, len(users))
+	for i := range users {
+		response = append(response, users[i].ToResponse())
+	}
+	c.JSON(http.StatusOK, response)
 }
 func (h *UserHandler) GetUserByID(c *gin.Context) {
 	id := c.Param("id")
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
 
-	user, err := h.Service.GetUserByID(id)
+	user, err := h.Service.GetUserByID(c.Request.Context(), id, withDeleted)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	middleware.SetETag(c, user.Version)
+	c.JSON(http.StatusOK, user.ToResponse())
 }
 
-// Обновление пользователя
+// Обновление пользователя; If-Match должен содержать текущий ETag
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id := c.Param("id")
-	var updatedUser models.User
-	if err := c.ShouldBindJSON(&updatedUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	version, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	var request models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
 		return
 	}
 
-	user, err := h.Service.UpdateUser(id, &updatedUser)
+	user, err := h.Service.UpdateUser(c.Request.Context(), id, middleware.Claims(c), &request, version)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	middleware.SetETag(c, user.Version)
+	c.JSON(http.StatusOK, user.ToResponse())
 }
 
-// Удаление пользователя
-func (h *UserHandler) DeleteUser(c *gin.Context) {
+// PatchUser частично обновляет пользователя (JSON Merge Patch или JSON Patch)
+func (h *UserHandler) PatchUser(c *gin.Context) {
 	id := c.Param("id")
+	version, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	user, err := h.Service.GetUserByID(c.Request.Context(), id, false)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	request := models.UpdateUserRequest{Username: user.Username, Email: user.Email}
+	fields, err := middleware.BindPatch(c, &request, models.UserPatchFields)
+	if err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	user, err = h.Service.PatchUser(c.Request.Context(), id, middleware.Claims(c), &request, fields, version)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	middleware.SetETag(c, user.Version)
+	c.JSON(http.StatusOK, user.ToResponse())
+}
+
+// DeleteUser мягко удаляет пользователя (администратор); персональные данные удаляет DELETE /users/:id
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	if err := h.Service.DeleteUser(c.Request.Context(), c.Param("id")); err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "User deactivated successfully"})
+}
 
-	err := h.Service.DeleteUser(id)
+// RestoreUser восстанавливает мягко удалённого пользователя (администратор)
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	user, err := h.Service.RestoreUser(c.Request.Context(), c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		middleware.RespondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	middleware.SetETag(c, user.Version)
+	c.JSON(http.StatusOK, user.ToResponse())
 }
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/repositories"
+	"order-service/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	Service *services.WebhookService
+}
+
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{Service: service}
+}
+
+// CreateWebhook создаёт подписку; секрет подписи возвращается только в этом ответе
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var request models.WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	sub, err := h.Service.CreateSubscription(c.Request.Context(), &request)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	middleware.SetETag(c, sub.Version)
+	c.JSON(http.StatusCreated, sub)
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	subs, err := h.Service.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	sub, err := h.Service.GetSubscription(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	middleware.SetETag(c, sub.Version)
+	c.JSON(http.StatusOK, sub)
+}
+
+// UpdateWebhook заменяет подписку; If-Match должен содержать текущий ETag
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	version, err := middleware.IfMatchVersion(c)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	var request models.WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		middleware.RespondBindingError(c, err)
+		return
+	}
+
+	sub, err := h.Service.UpdateSubscription(c.Request.Context(), c.Param("id"), &request, version)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	middleware.SetETag(c, sub.Version)
+	c.JSON(http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if err := h.Service.DeleteSubscription(c.Request.Context(), c.Param("id")); err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Webhook deleted successfully"})
+}
+
+// ListWebhookDeliveries возвращает журнал доставок подписки от новых к старым.
+// Фильтр — status; страницы — limit и before_id.
+func (h *WebhookHandler) ListWebhookDeliveries(c *gin.Context) {
+	filter := models.WebhookDeliveryFilter{Status: c.Query("status")}
+
+	var fields models.FieldErrors
+	filter.Limit = optionalInt(c, "limit", &fields)
+	if value := c.Query("before_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			fields.Add("before_id", "must be a positive integer")
+		}
+		filter.BeforeID = id
+	}
+	if err := fields.Err(); err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+
+	page, err := h.Service.ListDeliveries(c.Request.Context(), c.Param("id"), filter)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// RedeliverWebhook ставит событие доставки в очередь повторно; отправка идёт в фоне
+func (h *WebhookHandler) RedeliverWebhook(c *gin.Context) {
+	deliveryID, err := strconv.ParseInt(c.Param("deliveryID"), 10, 64)
+	if err != nil {
+		middleware.RespondError(c, repositories.ErrDeliveryNotFound)
+		return
+	}
+
+	delivery, err := h.Service.Redeliver(c.Request.Context(), c.Param("id"), deliveryID)
+	if err != nil {
+		middleware.RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config — настройки логирования
+type Config struct {
+	Level slog.Level // уровень по умолчанию
+	// Уровни отдельных пакетов: {"repositories": slog.LevelDebug}
+	PackageLevels map[string]slog.Level
+}
+
+// ParseLevels разбирает уровень по умолчанию ("info") и уровни пакетов
+// ("repositories=debug,services=warn")
+func ParseLevels(level, packageLevels string) (Config, error) {
+	cfg := Config{Level: slog.LevelInfo, PackageLevels: map[string]slog.Level{}}
+	if level != "" {
+		if err := cfg.Level.UnmarshalText([]byte(level)); err != nil {
+			return cfg, fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+	}
+	for _, entry := range strings.Split(packageLevels, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pkg, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return cfg, fmt.Errorf("invalid package log level %q: expected package=level", entry)
+		}
+		var pkgLevel slog.Level
+		if err := pkgLevel.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+			return cfg, fmt.Errorf("invalid log level for package %s: %w", pkg, err)
+		}
+		cfg.PackageLevels[strings.TrimSpace(pkg)] = pkgLevel
+	}
+	return cfg, nil
+}
+
+// state — текущие обработчик и уровни; логгеры пакетов создаются до Setup и читают его при каждой записи.
+// До Setup записи уровня info и выше пишутся в stderr.
+type state struct {
+	handler slog.Handler
+	cfg     Config
+}
+
+var current atomic.Pointer[state]
+
+func init() {
+	current.Store(&state{
+		handler: newRedactHandler(slog.NewJSONHandler(os.Stderr, nil)),
+		cfg:     Config{Level: slog.LevelInfo},
+	})
+}
+
+// Setup включает JSON-логи в w и делает их логгером по умолчанию,
+// в том числе для стандартного пакета log
+func Setup(w io.Writer, cfg Config) {
+	json := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	current.Store(&state{handler: newRedactHandler(json), cfg: cfg})
+	slog.SetDefault(For("main"))
+}
+
+// For возвращает логгер пакета: записи помечаются атрибутом package,
+// уровень берётся из PackageLevels, если он там задан
+func For(pkg string) *slog.Logger {
+	return slog.New(&packageHandler{pkg: pkg})
+}
+
+// packageHandler фильтрует записи по уровню пакета, добавляет request_id и trace_id
+// из контекста и передаёт запись текущему обработчику
+type packageHandler struct {
+	pkg string
+	// WithAttrs и WithGroup в порядке вызова; применяются к обработчику при записи
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h *packageHandler) Enabled(_ context.Context, level slog.Level) bool {
+	cfg := current.Load().cfg
+	if pkgLevel, ok := cfg.PackageLevels[h.pkg]; ok {
+		return level >= pkgLevel
+	}
+	return level >= cfg.Level
+}
+
+func (h *packageHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := []slog.Attr{slog.String("package", h.pkg)}
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", span.TraceID().String()))
+	}
+	handler := current.Load().handler.WithAttrs(attrs)
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+func (h *packageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *packageHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *packageHandler) with(op func(slog.Handler) slog.Handler) *packageHandler {
+	ops := append(append([]func(slog.Handler) slog.Handler{}, h.ops...), op)
+	return &packageHandler{pkg: h.pkg, ops: ops}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID сохраняет ID запроса в контексте; все записи с этим контекстом получают request_id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает ID запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
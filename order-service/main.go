@@ -2,86 +2,469 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"order-service/config"
 	"order-service/db"
+	"order-service/graphqlapi"
+	"order-service/grpcapi"
 	"order-service/handlers"
+	"order-service/logging"
+	"order-service/metrics"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/ratelimit"
 	"order-service/repositories"
 	"order-service/routes"
 	"order-service/services"
+	"order-service/tracing"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// commands — однократные команды вместо запуска сервера
+type commands struct {
+	migrateOnly        bool
+	backfillProductIDs bool
+	replayOrders       bool
+	importStock        bool
+}
+
 func main() {
+	var cmd commands
 	// Определяем флаг для запуска только миграций
-	migrateOnly := flag.Bool("migrate", false, "Run database migrations only")
+	flag.BoolVar(&cmd.migrateOnly, "migrate", false, "Run database migrations only")
+	flag.BoolVar(&cmd.backfillProductIDs, "backfill-product-ids", false, "Normalize product IDs to the canonical form and exit")
+	flag.BoolVar(&cmd.replayOrders, "replay-orders", false, "Rebuild order projections and snapshots from the order event log and exit")
+	flag.BoolVar(&cmd.importStock, "import-stock", false, "Record catalog stock of SKUs without stock movements as receipts to the default warehouse and exit")
+	configFlags := config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
-	// Загружаем конфигурацию
-	cfg := config.LoadConfig()
+	// Загружаем конфигурацию: профиль, файл, окружение, флаги; при ошибках выводим отчёт и выходим
+	cfg, err := config.Load(configFlags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Процесс завершается только здесь, когда отложенные вызовы run уже закрыли соединения
+	// и выгрузили трассы
+	if err := run(cfg, cmd); err != nil {
+		slog.Error("Service failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run запускает сервис или однократную команду и возвращает ошибку запуска или работы
+func run(cfg *config.Config, cmd commands) error {
+	// JSON-логи в stdout; пароли, токены и email вырезаются из атрибутов
+	logConfig, err := logging.ParseLevels(cfg.Log.Level, cfg.Log.Levels)
+	if err != nil {
+		return fmt.Errorf("invalid log configuration: %w", err)
+	}
+	logging.Setup(os.Stdout, logConfig)
+	slog.Info("Configuration loaded", "config", cfg)
+
+	services.JWTSecret = []byte(cfg.Auth.JWTSecret)
+	services.TokenTTL = cfg.Auth.TokenTTL
+	repositories.OrderSnapshotInterval = cfg.Orders.SnapshotInterval
+
+	// Трассировка; спаны, не отправленные к моменту остановки, выгружаются в конце
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
 
 	// Подключение к PostgreSQL для заказов и пользователей
 	dbConn, err := repositories.ConnectDB(cfg)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer func() {
-		log.Println("Closing database connection")
-		dbConn.Close(context.Background())
+		slog.Info("Closing database connection")
+		dbConn.Close()
 	}()
 
+	if err := metrics.RegisterPgxPool(dbConn); err != nil {
+		return fmt.Errorf("failed to register database metrics: %w", err)
+	}
+
 	// Применяем миграции
 	if err := db.MigrateConfig(cfg); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	// Если указан флаг -migrate, завершаем работу после миграций
-	if *migrateOnly {
-		log.Println("Migrations completed successfully. Exiting.")
-		return
+	// Пересборка проекций заказов из журнала событий
+	if cmd.replayOrders {
+		result, err := repositories.NewOrderRepository(dbConn).ReplayOrders(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to replay order events: %w", err)
+		}
+		slog.Info("Order projections rebuilt", "orders", result.Orders, "events", result.Events, "snapshots", result.Snapshots)
+		return nil
 	}
 
 	// Подключение к MongoDB для продуктов
-	mongoRepo, err := repositories.NewMongoDBRepository(cfg.MongoURI, cfg.MongoDB)
+	mongoRepo, err := repositories.NewMongoDBRepository(cfg.Mongo)
 	if err != nil {
-		log.Fatal("Failed to connect to MongoDB", err)
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	defer func() {
+		slog.Info("Closing MongoDB connection")
+		mongoRepo.Client.Disconnect(context.Background())
+	}()
+
+	// Миграции документов MongoDB
+	if err := db.MigrateMongo(mongoRepo.DB); err != nil {
+		return fmt.Errorf("failed to migrate MongoDB: %w", err)
+	}
+
+	// Однократная нормализация ID продуктов; выполняется до создания уникальных индексов
+	if cmd.backfillProductIDs {
+		result, err := repositories.NewProductRepository(mongoRepo.DB).BackfillProductIDs(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to backfill product IDs: %w", err)
+		}
+		slog.Info("Product IDs backfilled", "scanned", result.Scanned, "updated", result.Updated, "reinserted", result.Reinserted)
+		return nil
+	}
+
+	inventorySettings := services.InventorySettings{
+		Allocation:       cfg.Inventory.Allocation,
+		DefaultWarehouse: cfg.Inventory.DefaultWarehouse,
+	}
+
+	// Однократный перенос остатков каталога в журнал движений склада
+	if cmd.importStock {
+		inventory, err := services.NewInventoryService(repositories.NewInventoryRepository(dbConn), repositories.NewProductRepository(mongoRepo.DB), nil, nil, inventorySettings)
+		if err != nil {
+			return fmt.Errorf("invalid inventory configuration: %w", err)
+		}
+		result, err := inventory.ImportCatalogStock(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to import catalog stock: %w", err)
+		}
+		slog.Info("Catalog stock imported", "skus", result.SKUs, "units", result.Units, "skipped", result.Skipped)
+		return nil
+	}
+
+	// Если указан флаг -migrate, завершаем работу после миграций
+	if cmd.migrateOnly {
+		slog.Info("Migrations completed successfully. Exiting.")
+		return nil
 	}
 
 	// Подключение к Redis для корзины
 	redisClient := redis.NewClient(&redis.Options{
-		Addr: cfg.RedisAddr,
+		Addr:         cfg.Redis.Addr,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		DialTimeout:  cfg.Redis.DialTimeout,
+		ReadTimeout:  cfg.Redis.ReadTimeout,
+		WriteTimeout: cfg.Redis.WriteTimeout,
 	})
+	redisClient.AddHook(metrics.RedisHook{})
+	if err := redisotel.InstrumentTracing(redisClient); err != nil {
+		return fmt.Errorf("failed to instrument Redis tracing: %w", err)
+	}
+	defer func() {
+		slog.Info("Closing Redis connection")
+		redisClient.Close()
+	}()
 
 	// Репозитории
 	orderRepo := repositories.NewOrderRepository(dbConn)
 	userRepo := repositories.NewUserRepository(dbConn)
 	productRepo := repositories.NewProductRepository(mongoRepo.DB)
+	if err := productRepo.EnsureIndexes(context.Background()); err != nil {
+		return fmt.Errorf("failed to create product indexes: %w", err)
+	}
+	categoryRepo := repositories.NewCategoryRepository(mongoRepo.DB)
+	if err := categoryRepo.EnsureIndexes(context.Background()); err != nil {
+		return fmt.Errorf("failed to create category indexes: %w", err)
+	}
+	paymentRepo := repositories.NewPaymentRepository(dbConn)
+	returnRepo := repositories.NewReturnRepository(dbConn)
+	invoiceRepo := repositories.NewInvoiceRepository(dbConn)
+	auditRepo := repositories.NewAuditRepository(dbConn)
+	webhookRepo := repositories.NewWebhookRepository(dbConn)
+	inventoryRepo := repositories.NewInventoryRepository(dbConn)
 
 	// Сервисы
-	orderService := services.NewOrderService(orderRepo, redisClient)
-	userService := services.NewUserService(userRepo, redisClient)
-	productService := services.NewProductService(productRepo, redisClient)
-	cartService := services.NewCartService(redisClient, productRepo, orderRepo, userRepo)
+	auditService := services.NewAuditService(auditRepo)
+	paymentService := services.NewPaymentService(paymentRepo, auditService)
+	webhookService := services.NewWebhookService(webhookRepo, auditService, services.WebhookSettings{
+		PollInterval: cfg.Webhooks.PollInterval,
+		Timeout:      cfg.Webhooks.Timeout,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		BackoffBase:  cfg.Webhooks.BackoffBase,
+		BackoffMax:   cfg.Webhooks.BackoffMax,
+		BatchSize:    cfg.Webhooks.BatchSize,
+	})
+	productCache := services.NewProductCache(redisClient, cfg.Cache.LocalTTL)
+	inventoryService, err := services.NewInventoryService(inventoryRepo, productRepo, productCache, auditService, inventorySettings)
+	if err != nil {
+		return fmt.Errorf("invalid inventory configuration: %w", err)
+	}
+	orderUpdates := services.NewOrderUpdates(redisClient)
+	orderService := services.NewOrderService(orderRepo, paymentService, inventoryService, redisClient, auditService, webhookService, orderUpdates)
+	userService := services.NewUserService(userRepo, redisClient, auditService)
+	productService := services.NewProductService(productRepo, categoryRepo, inventoryService, productCache, auditService)
+	categoryService := services.NewCategoryService(categoryRepo, productRepo, productCache, auditService)
+	cartService := services.NewCartService(redisClient, productRepo, orderRepo, userRepo, inventoryService, auditService, webhookService)
+	returnService := services.NewReturnService(returnRepo, orderRepo, inventoryService, paymentService, redisClient, auditService, webhookService, orderUpdates)
+	invoiceService := services.NewInvoiceService(invoiceRepo, orderRepo, userRepo, productRepo, paymentRepo, returnRepo, services.InvoiceSettings{
+		Seller: models.InvoiceParty{
+			Name:    cfg.Invoice.SellerName,
+			Address: cfg.Invoice.SellerAddress,
+			TaxID:   cfg.Invoice.SellerTaxID,
+		},
+		TaxRate:  cfg.Invoice.TaxRate,
+		Currency: cfg.Invoice.Currency,
+	}, auditService)
+	privacyService := services.NewPrivacyService(userRepo, orderRepo, returnRepo, paymentRepo, invoiceRepo, auditRepo, redisClient, auditService, services.RetentionSettings{
+		InactiveAccounts: cfg.Retention.InactiveAccounts,
+		AuditIP:          cfg.Retention.AuditIP,
+	})
+
+	// Лимиты запросов и блокировка входа; политики проверены при загрузке настроек
+	policies, err := ratelimit.ParsePolicies(cfg.RateLimit.Policies)
+	if err != nil {
+		return fmt.Errorf("invalid rate limit policies: %w", err)
+	}
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == "redis" {
+		limitStore = ratelimit.NewFallbackStore(ratelimit.NewRedisStore(redisClient), limitStore)
+	}
+	limiter := ratelimit.NewLimiter(limitStore, policies, ratelimit.LockoutConfig{
+		Threshold: cfg.RateLimit.LockoutThreshold,
+		Base:      cfg.RateLimit.LockoutBase,
+		Max:       cfg.RateLimit.LockoutMax,
+		Window:    cfg.RateLimit.LockoutWindow,
+	})
+
+	healthService := services.NewHealthService(dbConn, mongoRepo.Client, redisClient)
 
 	// Хендлеры
 	orderHandler := handlers.NewOrderHandler(orderService)
 	userHandler := handlers.NewUserHandler(userService)
 	productHandler := handlers.NewProductHandler(productService)
+	categoryHandler := handlers.NewCategoryHandler(categoryService)
 	cartHandler := handlers.NewCartHandler(cartService)
+	returnHandler := handlers.NewReturnHandler(returnService)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
+	healthHandler := handlers.NewHealthHandler(healthService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	inventoryHandler := handlers.NewInventoryHandler(inventoryService)
+
+	graphqlAPI, err := graphqlapi.NewAPI(userService, productService, cartService, orderService, graphqlapi.Settings{
+		MaxDepth:      cfg.GraphQL.MaxDepth,
+		MaxComplexity: cfg.GraphQL.MaxComplexity,
+		Introspection: cfg.GraphQL.Introspection,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+	graphqlHandler := handlers.NewGraphQLHandler(graphqlAPI)
+
+	spec := routes.OpenAPI()
+	docsHandler, err := handlers.NewDocsHandler(spec)
+	if err != nil {
+		return fmt.Errorf("failed to build OpenAPI spec: %w", err)
+	}
 
 	// Создание и настройка Gin
-	r := gin.Default()
+	r := gin.New()
+	// Адрес клиента (и лимиты по IP) берётся из X-Forwarded-For только от доверенных прокси
+	if err := r.SetTrustedProxies(splitList(cfg.Server.TrustedProxies)); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	r.Use(middleware.RequestID())
+	r.Use(otelgin.Middleware(tracing.ServiceName))
+	r.Use(middleware.AccessLog())
+	r.Use(middleware.Metrics())
+	r.Use(gin.Recovery())
+	r.Use(middleware.Actor())
+	if cfg.RateLimit.Enabled {
+		r.Use(middleware.RateLimit(limiter))
+	}
+	if err := middleware.RegisterValidators(); err != nil {
+		return fmt.Errorf("failed to register validators: %w", err)
+	}
 
 	// Регистрация маршрутов
-	routes.RegisterRoutes(r, userHandler, orderHandler, productHandler, cartHandler)
+	routes.RegisterRoutes(r, userHandler, orderHandler, productHandler, cartHandler, returnHandler, invoiceHandler, categoryHandler, healthHandler, auditHandler, privacyHandler, webhookHandler, inventoryHandler, graphqlHandler, docsHandler)
 
 	// Запуск сервера
-	serverAddr := cfg.ServerAddr
-	if serverAddr == "" {
-		serverAddr = ":8080" // Default address if not configured
+	server := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	// Порты открываются до запуска серверов и фоновых задач: если порт занят, запускать
+	// и потом останавливать ещё нечего
+	httpListener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for HTTP: %w", err)
+	}
+	defer httpListener.Close()
+
+	// gRPC API на отдельном порту; лимиты и проверки токена те же, что у REST
+	var grpcServer *grpcapi.Server
+	var grpcListener net.Listener
+	if cfg.GRPC.Enabled {
+		var grpcLimiter *ratelimit.Limiter
+		if cfg.RateLimit.Enabled {
+			grpcLimiter = limiter
+		}
+		grpcServer = grpcapi.NewServer(userService, productService, cartService, orderService, orderUpdates, grpcLimiter, cfg.GRPC.Reflection)
+		if grpcListener, err = net.Listen("tcp", cfg.GRPC.Addr); err != nil {
+			return fmt.Errorf("failed to listen for gRPC: %w", err)
+		}
+		defer grpcListener.Close()
+	}
+
+	// Фоновые задачи получают контекст, который отменяется при остановке сервиса
+	workers := newWorkerGroup()
+	if cfg.Retention.InactiveAccounts > 0 || cfg.Retention.AuditIP > 0 {
+		workers.Go("retention", func(ctx context.Context) {
+			privacyService.RunRetention(ctx, cfg.Retention.Interval)
+		})
+	}
+	workers.Go("webhooks", webhookService.RunDeliveries)
+	// Копии продуктов в памяти сбрасываются по сообщениям других экземпляров
+	if cfg.Cache.LocalTTL > 0 {
+		workers.Go("product-cache", productCache.RunInvalidations)
+	}
+	if cfg.Cache.WatchProducts {
+		workers.Go("product-changes", func(ctx context.Context) {
+			productService.WatchChanges(ctx, cfg.Cache.WatchRetry)
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 2)
+	go func() {
+		slog.Info("Server listening", "addr", server.Addr)
+		serverErr <- server.Serve(httpListener)
+	}()
+	if grpcServer != nil {
+		go func() {
+			slog.Info("gRPC server listening", "addr", cfg.GRPC.Addr)
+			serverErr <- grpcServer.Serve(grpcListener)
+		}()
+	}
+
+	// Ошибка сервера возвращается после штатной остановки остальных компонентов
+	var runErr error
+	select {
+	case err := <-serverErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			runErr = fmt.Errorf("server failed: %w", err)
+		}
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received, draining requests")
+	}
+	// Повторный сигнал завершает процесс сразу
+	stop()
+
+	// Сначала перестаём быть готовыми и дожидаемся текущих запросов, затем фоновых задач;
+	// соединения с базами закрываются отложенными вызовами выше
+	healthService.Drain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown did not complete", "error", err)
+	}
+	if grpcServer != nil {
+		if err := grpcServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("gRPC server shutdown did not complete", "error", err)
+		}
+	}
+	if err := workers.Stop(shutdownCtx); err != nil {
+		slog.Error("Background workers did not stop", "error", err)
+	}
+	slog.Info("Server stopped")
+	return runErr
+}
+
+// splitList разбирает список через запятую; пустая строка — nil
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// workerGroup запускает фоновые задачи и дожидается их завершения при остановке сервиса
+type workerGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkerGroup() *workerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workerGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go запускает задачу; задача должна завершиться после отмены переданного ей контекста
+func (g *workerGroup) Go(name string, run func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		run(g.ctx)
+		slog.Info("Worker stopped", "worker", name)
+	}()
+}
+
+// Stop отменяет контекст задач и ждёт их завершения не дольше, чем позволяет ctx
+func (g *workerGroup) Stop(ctx context.Context) error {
+	g.cancel()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	r.Run(serverAddr)
 }
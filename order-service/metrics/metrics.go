@@ -0,0 +1,203 @@
+// Package metrics содержит метрики Prometheus сервиса. Набор меток у каждой метрики
+// ограничен значениями с малой кардинальностью: шаблон маршрута вместо пути,
+// имя команды вместо ключа, префикс ключа кэша вместо самого ключа.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "order_service"
+
+// commandBuckets — границы гистограмм для команд баз данных: от 0.5 мс до ~4 с
+var commandBuckets = prometheus.ExponentialBuckets(0.0005, 2, 14)
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	grpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "gRPC call latency by full method name and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	mongoCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "MongoDB command latency by command name and outcome.",
+		Buckets:   commandBuckets,
+	}, []string{"command", "status"})
+
+	redisCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Redis command latency by command name and outcome.",
+		Buckets:   commandBuckets,
+	}, []string{"command", "status"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by key prefix and result (hit or miss).",
+	}, []string{"prefix", "result"})
+
+	cacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_invalidations_total",
+		Help:      "Product cache invalidations by source (change_stream or peer).",
+	}, []string{"source"})
+
+	ordersCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_created_total",
+		Help:      "Orders created, by source (api or checkout).",
+	}, []string{"source"})
+
+	checkoutFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checkout_failures_total",
+		Help:      "Failed cart checkouts by reason.",
+	}, []string{"reason"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by rate limits or login lockout.",
+	}, []string{"route", "reason"})
+
+	webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by event type and result (success, retry or failed).",
+	}, []string{"event", "result"})
+
+	revenue = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Total price of created orders, in the invoice currency.",
+	})
+)
+
+// Источники заказов
+const (
+	OrderSourceAPI      = "api"
+	OrderSourceCheckout = "checkout"
+)
+
+// Источники сброса кэша продуктов: поток изменений MongoDB или сообщение другого экземпляра
+const (
+	CacheInvalidationChangeStream = "change_stream"
+	CacheInvalidationPeer         = "peer"
+)
+
+// Причины неудачного оформления корзины
+const (
+	CheckoutEmptyCart  = "empty_cart"
+	CheckoutUnknownSKU = "unknown_sku"
+	CheckoutOutOfStock = "out_of_stock"
+	CheckoutInternal   = "internal"
+)
+
+// Причины отказа по лимиту: область нарушенного правила или блокировка входа
+const (
+	RateLimitIP      = "ip"
+	RateLimitAccount = "account"
+	RateLimitLockout = "lockout"
+)
+
+// Результаты попытки доставки вебхука
+const (
+	WebhookSuccess = "success"
+	WebhookRetry   = "retry"
+	WebhookFailed  = "failed"
+)
+
+// cachePrefixes — префиксы ключей кэша, которые попадают в метки; остальные считаются как "other"
+var cachePrefixes = map[string]bool{
+	"order":       true,
+	"user_orders": true,
+	"product":     true,
+	"products":    true,
+	"user":        true,
+}
+
+// Handler отдаёт метрики в формате Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveHTTPRequest учитывает обработанный HTTP-запрос. route — шаблон маршрута ("/orders/:id").
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveGRPCRequest учитывает завершённый вызов gRPC. method — полное имя
+// ("/orderservice.v1.OrderService/GetOrder"), code — код статуса ("OK", "NotFound").
+func ObserveGRPCRequest(method, code string, duration time.Duration) {
+	grpcRequestDuration.WithLabelValues(method, code).Observe(duration.Seconds())
+}
+
+// ObserveCache учитывает обращение к кэшу по ключу key ("product:<id>")
+func ObserveCache(key string, hit bool) {
+	prefix, _, _ := strings.Cut(key, ":")
+	if !cachePrefixes[prefix] {
+		prefix = "other"
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(prefix, result).Inc()
+}
+
+// CacheInvalidated учитывает сброс кэша продуктов из источника source
+func CacheInvalidated(source string) {
+	cacheInvalidations.WithLabelValues(source).Inc()
+}
+
+// OrderCreated учитывает созданный заказ и его сумму в выручке
+func OrderCreated(source string, total float64) {
+	ordersCreated.WithLabelValues(source).Inc()
+	if total > 0 {
+		revenue.Add(total)
+	}
+}
+
+// CheckoutFailed учитывает неудачное оформление корзины
+func CheckoutFailed(reason string) {
+	checkoutFailures.WithLabelValues(reason).Inc()
+}
+
+// RateLimited учитывает отклонённый запрос; route — метод и шаблон пути ("POST /login")
+func RateLimited(route, reason string) {
+	rateLimited.WithLabelValues(route, reason).Inc()
+}
+
+// WebhookDelivered учитывает попытку доставки вебхука с событием event
+func WebhookDelivered(event, result string) {
+	webhookDeliveries.WithLabelValues(event, result).Inc()
+}
+
+func observeCommand(vec *prometheus.HistogramVec, command string, err error, duration time.Duration) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	vec.WithLabelValues(command, status).Observe(duration.Seconds())
+}
@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MongoCommandMonitor измеряет длительность команд MongoDB
+func MongoCommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			observeCommand(mongoCommandDuration, e.CommandName, nil, e.Duration)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			observeCommand(mongoCommandDuration, e.CommandName, errors.New(e.Failure), e.Duration)
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"order-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// claimsKey — ключ, под которым данные токена сохраняются в gin.Context
+const claimsKey = "auth_claims"
+
+// AuthRequired проверяет заголовок Authorization: Bearer {token}
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := services.Authenticate(c.GetHeader("Authorization"))
+		if err != nil {
+			RespondError(c, err)
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// OptionalAuth сохраняет данные токена, если заголовок Authorization передан; без него
+// запрос анонимный. Недействительный токен отклоняется так же, как в AuthRequired.
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		claims, err := services.Authenticate(c.GetHeader("Authorization"))
+		if err != nil {
+			RespondError(c, err)
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// AdminOnly пропускает только администраторов; используется после AuthRequired
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := Claims(c)
+		if claims == nil || !claims.IsAdmin() {
+			RespondError(c, services.ErrAdminRequired)
+			return
+		}
+		c.Next()
+	}
+}
+
+// SelfOrAdmin пропускает пользователя, чей ID указан в параметре маршрута param, и администраторов;
+// используется после AuthRequired
+func SelfOrAdmin(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := Claims(c)
+		if claims == nil || !claims.CanAccess(c.Param(param)) {
+			RespondError(c, services.ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Claims возвращает данные токена текущего запроса или nil
+func Claims(c *gin.Context) *services.TokenClaims {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := value.(*services.TokenClaims)
+	return claims
+}
+
+// Actor сохраняет в контексте запроса его автора для журнала аудита: пользователя
+// из действительного токена, если он передан, и адрес клиента. Доступ не ограничивает.
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := services.Actor{IP: c.ClientIP()}
+		if claims, err := services.Authenticate(c.GetHeader("Authorization")); err == nil {
+			actor.UserID = claims.UserID
+			actor.Role = claims.Role
+		}
+		c.Request = c.Request.WithContext(services.WithActor(c.Request.Context(), actor))
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+
+	"order-service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrIfMatchRequired — изменение ресурса без заголовка If-Match
+var ErrIfMatchRequired = models.NewPreconditionRequired("if_match_required", "If-Match header with the current ETag is required")
+
+// AnyVersion — версия, соответствующая If-Match: *
+const AnyVersion int64 = 0
+
+// SetETag отдаёт версию ресурса в заголовке ETag
+func SetETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// IfMatchVersion возвращает версию из заголовка If-Match. Для "*" возвращается AnyVersion.
+// Нераспознанное значение не совпадает ни с одной версией и приводит к 412.
+func IfMatchVersion(c *gin.Context) (int64, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return 0, ErrIfMatchRequired
+	}
+	if header == "*" {
+		return AnyVersion, nil
+	}
+
+	// Слабые ETag для If-Match не подходят (RFC 9110, 13.1.1)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		value, err := strconv.Unquote(tag)
+		if err != nil {
+			continue
+		}
+		if version, err := strconv.ParseInt(value, 10, 64); err == nil && version > 0 {
+			return version, nil
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"order-service/metrics"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics учитывает длительность запросов по шаблону маршрута, а не по фактическому пути,
+// чтобы ID в URL не раздували число временных рядов
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		metrics.ObserveHTTPRequest(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}
+}
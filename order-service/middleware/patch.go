@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"order-service/models"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Форматы тела PATCH-запроса
+const (
+	MergePatchContentType = "application/merge-patch+json" // RFC 7386
+	JSONPatchContentType  = "application/json-patch+json"  // RFC 6902
+)
+
+var (
+	// ErrUnsupportedPatch — тело PATCH-запроса не в одном из поддерживаемых форматов
+	ErrUnsupportedPatch = models.NewUnsupportedMediaType("unsupported_patch_format",
+		"PATCH body must be "+MergePatchContentType+" or "+JSONPatchContentType)
+	// ErrPatchConflict — операцию JSON Patch нельзя применить к текущему состоянию ресурса
+	ErrPatchConflict = models.NewConflict("patch_conflict", "patch cannot be applied to the current state of the resource")
+)
+
+// BindPatch применяет тело PATCH-запроса к target и проверяет результат по binding-тегам,
+// как ShouldBindJSON. До вызова target должен содержать текущее состояние ресурса.
+// Изменять можно только поля верхнего уровня из allowed; возвращаются изменённые поля.
+// Ошибки отдаются через RespondBindingError.
+func BindPatch(c *gin.Context, target interface{}, allowed []string) ([]string, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	current, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	var patched []byte
+	switch c.ContentType() {
+	case MergePatchContentType:
+		if fields, err = mergePatchFields(body, allowed); err != nil {
+			return nil, err
+		}
+		if patched, err = jsonpatch.MergePatch(current, body); err != nil {
+			return nil, invalidPatch(err.Error())
+		}
+	case JSONPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return nil, invalidPatch(err.Error())
+		}
+		if fields, err = jsonPatchFields(patch, allowed); err != nil {
+			return nil, err
+		}
+		if patched, err = patch.Apply(current); err != nil {
+			return nil, jsonPatchError(err)
+		}
+	default:
+		return nil, ErrUnsupportedPatch
+	}
+
+	// Удалённые патчем поля должны стать нулевыми, а не сохранить прежние значения
+	value := reflect.ValueOf(target).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.Unmarshal(patched, target); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(target); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// mergePatchFields возвращает поля верхнего уровня, которые изменяет JSON Merge Patch
+func mergePatchFields(body []byte, allowed []string) ([]string, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, invalidPatch("merge patch must be a JSON object")
+	}
+
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields, checkPatchFields(fields, allowed)
+}
+
+// jsonPatchFields возвращает поля верхнего уровня, которые затрагивают операции JSON Patch.
+// Путь каждой операции (и from у move) должен начинаться с разрешённого поля.
+func jsonPatchFields(patch jsonpatch.Patch, allowed []string) ([]string, error) {
+	changed := make(map[string]bool)
+	var paths []string
+	for _, op := range patch {
+		path, err := op.Path()
+		if err != nil {
+			return nil, invalidPatch(err.Error())
+		}
+		field := topLevelField(path)
+		paths = append(paths, field)
+		if op.Kind() != "test" {
+			changed[field] = true
+		}
+
+		if op.Kind() == "move" {
+			from, err := op.From()
+			if err != nil {
+				return nil, invalidPatch(err.Error())
+			}
+			field = topLevelField(from)
+			paths = append(paths, field)
+			changed[field] = true
+		}
+	}
+	if err := checkPatchFields(paths, allowed); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(changed))
+	for field := range changed {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// checkPatchFields проверяет поля по списку разрешённых
+func checkPatchFields(fields, allowed []string) error {
+	var errs models.FieldErrors
+	reported := make(map[string]bool)
+	for _, field := range fields {
+		if reported[field] || contains(allowed, field) {
+			continue
+		}
+		reported[field] = true
+		if field == "" {
+			errs.Add("/", "the whole document cannot be replaced")
+		} else {
+			errs.Add(field, "cannot be modified")
+		}
+	}
+	return errs.Err()
+}
+
+// topLevelField возвращает первый сегмент JSON Pointer ("/variants/0/stock" -> "variants")
+func topLevelField(pointer string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(pointer, "/"), "/")
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+}
+
+func jsonPatchError(err error) error {
+	if errors.Is(err, jsonpatch.ErrMissing) || errors.Is(err, jsonpatch.ErrTestFailed) || errors.Is(err, jsonpatch.ErrInvalidIndex) {
+		return models.NewConflict(ErrPatchConflict.Code, err.Error())
+	}
+	return invalidPatch(err.Error())
+}
+
+func invalidPatch(detail string) error {
+	return models.NewValidation("invalid_patch", detail)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
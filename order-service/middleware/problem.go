@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"order-service/logging"
+	"order-service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType — тип содержимого ответов с ошибкой (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// Problem — единый формат ответа с ошибкой (RFC 7807).
+// Code — стабильный машиночитаемый код; type строится из него.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Ошибки отдельных полей; фронтенд сопоставляет их с полями формы
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+var logger = logging.For("middleware")
+
+// Коды ошибок, не привязанные к конкретному ресурсу
+const (
+	CodeInvalidRequest = "invalid_request"
+	CodeInternal       = "internal_error"
+)
+
+// problemKinds сопоставляет категории ошибок с HTTP-статусом и кодом по умолчанию
+var problemKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{models.ErrNotFound, http.StatusNotFound, "not_found"},
+	{models.ErrConflict, http.StatusConflict, "conflict"},
+	{models.ErrValidation, http.StatusBadRequest, "validation_failed"},
+	{models.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{models.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{models.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition_failed"},
+	{models.ErrPreconditionRequired, http.StatusPreconditionRequired, "precondition_required"},
+	{models.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	{models.ErrTooManyRequests, http.StatusTooManyRequests, "too_many_requests"},
+}
+
+// RespondError прерывает запрос ответом problem+json, статус определяется категорией ошибки.
+// Ошибки без категории считаются внутренними: они логируются, а клиент получает 500 без подробностей.
+func RespondError(c *gin.Context, err error) {
+	for _, k := range problemKinds {
+		if !errors.Is(err, k.kind) {
+			continue
+		}
+		problem := newProblem(c, k.status, k.code, err.Error())
+		var typed *models.Error
+		if errors.As(err, &typed) {
+			problem.Code = typed.Code
+			problem.Type = "/problems/" + typed.Code
+			problem.Errors = typed.Fields
+		}
+		writeProblem(c, problem)
+		return
+	}
+
+	logger.ErrorContext(c.Request.Context(), "request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+	RespondProblem(c, http.StatusInternalServerError, CodeInternal, "")
+}
+
+// RespondProblem прерывает запрос ответом problem+json с заданным статусом и кодом
+func RespondProblem(c *gin.Context, status int, code, detail string) {
+	writeProblem(c, newProblem(c, status, code, detail))
+}
+
+func newProblem(c *gin.Context, status int, code, detail string) Problem {
+	return Problem{
+		Type:     "/problems/" + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     code,
+	}
+}
+
+func writeProblem(c *gin.Context, problem Problem) {
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// RespondBadRequest отвечает 400 на некорректное тело или параметры запроса
+func RespondBadRequest(c *gin.Context, detail string) {
+	RespondProblem(c, http.StatusBadRequest, CodeInvalidRequest, detail)
+}
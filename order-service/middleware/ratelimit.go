@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"order-service/metrics"
+	"order-service/models"
+	"order-service/ratelimit"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrRateLimited — превышен лимит запросов к маршруту
+	ErrRateLimited = models.NewTooManyRequests("rate_limited", "too many requests, retry later")
+	// ErrAccountLocked — вход временно заблокирован после неудачных попыток
+	ErrAccountLocked = models.NewTooManyRequests("account_locked", "too many failed login attempts, retry later")
+)
+
+// maxAccountBody — сколько тела запроса читается, чтобы найти email аккаунта
+const maxAccountBody = 64 << 10
+
+// RateLimit применяет политику маршрута: лимиты по IP и по аккаунту, а для маршрутов
+// с блокировкой — учёт неудачных входов (ответ 401) и отказ на время блокировки.
+// Подключается глобально: политика выбирается по методу и шаблону пути.
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy, ok := limiter.Policy(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+
+		var account string
+		if policy.HasScope(ratelimit.ScopeAccount) {
+			account = requestAccount(c)
+		}
+
+		if policy.Lockout && account != "" {
+			locked, err := limiter.LockedFor(ctx, account)
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to check login lockout", "error", err)
+			} else if locked > 0 {
+				metrics.RateLimited(policy.Route, metrics.RateLimitLockout)
+				setRetryAfter(c, locked)
+				RespondError(c, ErrAccountLocked)
+				return
+			}
+		}
+
+		result, rule, err := limiter.Allow(ctx, policy, c.ClientIP(), account)
+		if err != nil {
+			// Лимиты не должны ломать сервис: без хранилища запрос пропускается
+			logger.ErrorContext(ctx, "rate limit check failed", "error", err)
+			c.Next()
+			return
+		}
+		if result.Limit > 0 {
+			c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			c.Header("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+			c.Header("RateLimit-Policy", strconv.Itoa(rule.Limit)+";w="+strconv.Itoa(seconds(rule.Window)))
+		}
+		if !result.Allowed {
+			metrics.RateLimited(policy.Route, rule.Scope)
+			setRetryAfter(c, result.Reset)
+			RespondError(c, ErrRateLimited)
+			return
+		}
+
+		c.Next()
+
+		if policy.Lockout && account != "" {
+			recordLogin(c, limiter, account)
+		}
+	}
+}
+
+// recordLogin учитывает результат входа: 401 — неудача, успешный ответ сбрасывает счётчик
+func recordLogin(c *gin.Context, limiter *ratelimit.Limiter, account string) {
+	ctx := c.Request.Context()
+	switch status := c.Writer.Status(); {
+	case status == http.StatusUnauthorized:
+		locked, err := limiter.LoginFailed(ctx, account)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to record failed login", "error", err)
+		} else if locked > 0 {
+			logger.WarnContext(ctx, "login locked after failed attempts", "account", account, "ip", c.ClientIP(), "locked_for", locked.String())
+		}
+	case status < http.StatusBadRequest:
+		if err := limiter.LoginSucceeded(ctx, account); err != nil {
+			logger.ErrorContext(ctx, "failed to reset failed logins", "error", err)
+		}
+	}
+}
+
+// requestAccount возвращает пользователя из токена или email из тела запроса (JSON или форма).
+// Тело возвращается в запрос, чтобы обработчик прочитал его как обычно.
+func requestAccount(c *gin.Context) string {
+	if claims := Claims(c); claims != nil {
+		return claims.UserID
+	}
+	if c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAccountBody))
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+	switch c.ContentType() {
+	case "application/x-www-form-urlencoded":
+		values, _ := url.ParseQuery(string(body))
+		return values.Get("email")
+	default:
+		var request struct {
+			Email string `json:"email"`
+		}
+		_ = json.Unmarshal(body, &request)
+		return request.Email
+	}
+}
+
+func setRetryAfter(c *gin.Context, d time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(seconds(d)))
+}
+
+// seconds округляет вверх: клиент, повторивший запрос через это время, уже не упрётся в лимит
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"order-service/ratelimit"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitStep — запрос к /login после сдвига часов на advance и ожидаемый ответ.
+// Пустое значение в headers означает, что заголовка быть не должно.
+type rateLimitStep struct {
+	advance    time.Duration
+	ip         string
+	email      string
+	password   string
+	wantStatus int
+	headers    map[string]string
+}
+
+func TestRateLimit(t *testing.T) {
+	lockout := ratelimit.LockoutConfig{Threshold: 3, Base: time.Minute, Max: 4 * time.Minute, Window: 15 * time.Minute}
+
+	tests := []struct {
+		name   string
+		policy string
+		steps  []rateLimitStep
+	}{
+		{
+			name:   "sliding window per ip",
+			policy: "POST /login=ip:3/1m",
+			steps: []rateLimitStep{
+				{ip: "192.0.2.1", wantStatus: http.StatusOK, headers: map[string]string{
+					"RateLimit-Limit": "3", "RateLimit-Remaining": "2", "RateLimit-Reset": "60", "RateLimit-Policy": "3;w=60", "Retry-After": "",
+				}},
+				{advance: 10 * time.Second, ip: "192.0.2.1", wantStatus: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "1", "RateLimit-Reset": "50"}},
+				{advance: 10 * time.Second, ip: "192.0.2.1", wantStatus: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "40"}},
+				// Окно заполнено: место освободится, когда из него выйдет первый запрос
+				{advance: 10 * time.Second, ip: "192.0.2.1", wantStatus: http.StatusTooManyRequests, headers: map[string]string{
+					"RateLimit-Remaining": "0", "RateLimit-Reset": "30", "Retry-After": "30",
+				}},
+				// У другого адреса своё окно
+				{ip: "192.0.2.2", wantStatus: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "2"}},
+				// Окно скользящее: первый запрос вышел из него, второй и третий ещё нет
+				{advance: 30 * time.Second, ip: "192.0.2.1", wantStatus: http.StatusOK, headers: map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "10"}},
+				{advance: 5 * time.Second, ip: "192.0.2.1", wantStatus: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "5"}},
+				{advance: 5 * time.Second, ip: "192.0.2.1", wantStatus: http.StatusOK},
+			},
+		},
+		{
+			name:   "account limit across addresses",
+			policy: "POST /login=ip:10/1m,account:2/1h",
+			steps: []rateLimitStep{
+				// Остаток показывается по самому строгому правилу
+				{ip: "192.0.2.1", email: "user@example.com", wantStatus: http.StatusOK, headers: map[string]string{
+					"RateLimit-Limit": "2", "RateLimit-Remaining": "1", "RateLimit-Policy": "2;w=3600",
+				}},
+				{ip: "192.0.2.2", email: "USER@example.com ", wantStatus: http.StatusOK},
+				{ip: "192.0.2.3", email: "user@example.com", wantStatus: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "3600"}},
+				// Без email правило аккаунта не применяется
+				{ip: "192.0.2.3", wantStatus: http.StatusOK, headers: map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "8"}},
+			},
+		},
+		{
+			name:   "exponential lockout after failed logins",
+			policy: "POST /login=ip:100/1m,lockout",
+			steps: []rateLimitStep{
+				{email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				// Третья неудача подряд блокирует вход на Base
+				{email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{email: "user@example.com", password: "secret", wantStatus: http.StatusTooManyRequests, headers: map[string]string{
+					"Retry-After": "60", "RateLimit-Limit": "",
+				}},
+				// Блокировка по аккаунту, а не по адресу
+				{ip: "192.0.2.9", email: "user@example.com", password: "secret", wantStatus: http.StatusTooManyRequests},
+				{email: "other@example.com", password: "secret", wantStatus: http.StatusOK},
+				{advance: 30 * time.Second, email: "user@example.com", password: "secret", wantStatus: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "30"}},
+				// Каждая следующая неудача удваивает блокировку
+				{advance: 30 * time.Second, email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{email: "user@example.com", password: "secret", wantStatus: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "120"}},
+				{advance: 2 * time.Minute, email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{email: "user@example.com", password: "secret", wantStatus: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "240"}},
+				// Дольше Max блокировка не растёт
+				{advance: 4 * time.Minute, email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{email: "user@example.com", password: "secret", wantStatus: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "240"}},
+				// Успешный вход после блокировки сбрасывает счётчик неудач
+				{advance: 4 * time.Minute, email: "user@example.com", password: "secret", wantStatus: http.StatusOK},
+				{email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{email: "user@example.com", password: "secret", wantStatus: http.StatusOK},
+			},
+		},
+		{
+			name:   "failure counter expires after the window",
+			policy: "POST /login=ip:100/1m,lockout",
+			steps: []rateLimitStep{
+				{email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{advance: 16 * time.Minute, email: "user@example.com", password: "wrong", wantStatus: http.StatusUnauthorized},
+				{email: "user@example.com", password: "secret", wantStatus: http.StatusOK},
+			},
+		},
+		{
+			name:   "routes without a policy are not limited",
+			policy: "POST /register=ip:1/1m",
+			steps: []rateLimitStep{
+				{wantStatus: http.StatusOK, headers: map[string]string{"RateLimit-Limit": ""}},
+				{wantStatus: http.StatusOK, headers: map[string]string{"RateLimit-Limit": ""}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := ratelimit.ParsePolicies(tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+			store := ratelimit.NewMemoryStore().WithClock(func() time.Time { return now })
+			engine := newRateLimitEngine(ratelimit.NewLimiter(store, policies, lockout))
+
+			for i, step := range tt.steps {
+				now = now.Add(step.advance)
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, newLoginRequest(step))
+
+				if w.Code != step.wantStatus {
+					t.Fatalf("step %d: status %d, want %d (body %s)", i, w.Code, step.wantStatus, w.Body)
+				}
+				for header, want := range step.headers {
+					if got := w.Header().Get(header); got != want {
+						t.Errorf("step %d: %s = %q, want %q", i, header, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// newRateLimitEngine — /login, отвечающий 401 на пароль "wrong" и 200 на любой другой
+func newRateLimitEngine(limiter *ratelimit.Limiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RateLimit(limiter))
+	engine.POST("/login", func(c *gin.Context) {
+		var request struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.Password == "wrong" {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func newLoginRequest(step rateLimitStep) *http.Request {
+	body := `{"password":"` + step.password + `"}`
+	if step.email != "" {
+		body = `{"email":"` + step.email + `","password":"` + step.password + `"}`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	ip := step.ip
+	if ip == "" {
+		ip = "192.0.2.1"
+	}
+	req.RemoteAddr = ip + ":1234"
+	return req
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log/slog"
+	"order-service/logging"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader — заголовок с ID запроса во входящем запросе и в ответе
+const RequestIDHeader = "X-Request-ID"
+
+// Допустимый ID от клиента или прокси; иначе генерируется новый
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+var accessLogger = logging.For("http")
+
+// RequestID берёт ID запроса из X-Request-ID или генерирует его, возвращает в ответе
+// и кладёт в контекст запроса: логи сервисов и репозиториев получают атрибут request_id
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := ResolveRequestID(c.GetHeader(RequestIDHeader))
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// ResolveRequestID возвращает ID запроса, переданный клиентом, если он допустим, иначе новый
+func ResolveRequestID(id string) string {
+	if !requestIDPattern.MatchString(id) {
+		return uuid.NewString()
+	}
+	return id
+}
+
+// AccessLog пишет по записи на каждый запрос; строка запроса не логируется,
+// потому что в ней бывают email и токены
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		accessLogger.Log(c.Request.Context(), level, "request completed",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Политика паролей: bcrypt учитывает только первые 72 байта
+const (
+	passwordMinLength = 8
+	passwordMaxLength = 72
+)
+
+// RegisterValidators настраивает валидатор gin: имена полей в ошибках берутся
+// из json-тегов, регистрируются собственные правила (password, sku).
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	if err := v.RegisterValidation("password", validatePassword); err != nil {
+		return err
+	}
+	return v.RegisterValidation("sku", validateSKU)
+}
+
+// validatePassword: 8–72 байта, хотя бы одна буква и одна цифра
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < passwordMinLength || len(password) > passwordMaxLength {
+		return false
+	}
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return letter && digit
+}
+
+// validateSKU: латиница, цифры, точка, дефис и подчёркивание
+func validateSKU(fl validator.FieldLevel) bool {
+	sku := fl.Field().String()
+	if sku == "" || len(sku) > 64 {
+		return false
+	}
+	for _, r := range sku {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// RespondBindingError отвечает 400 на ошибку ShouldBind*: нарушения правил валидации
+// и несовпадения типов возвращаются как ошибки отдельных полей.
+func RespondBindingError(c *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		RespondError(c, fieldErrors(validationErrors))
+		return
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		RespondError(c, models.NewFieldErrors(models.FieldError{
+			Field:   typeError.Field,
+			Message: fmt.Sprintf("must be of type %s", jsonTypeName(typeError.Type)),
+		}))
+		return
+	}
+
+	// Ошибки с категорией (например, от BindPatch) отдаём как есть
+	var typed *models.Error
+	if errors.As(err, &typed) {
+		RespondError(c, err)
+		return
+	}
+
+	RespondBadRequest(c, err.Error())
+}
+
+// Validate проверяет структуру по binding-тегам теми же правилами, что и тела REST-запросов.
+// Нарушения возвращаются ошибкой валидации с перечнем полей.
+func Validate(obj any) error {
+	err := binding.Validator.ValidateStruct(obj)
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return fieldErrors(validationErrors)
+	}
+	return err
+}
+
+func fieldErrors(validationErrors validator.ValidationErrors) error {
+	fields := make([]models.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields = append(fields, models.FieldError{Field: fieldPath(fe), Message: fieldMessage(fe)})
+	}
+	return models.NewFieldErrors(fields...)
+}
+
+// fieldPath убирает имя структуры из пути поля: "RegisterRequest.email" -> "email"
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+	isCollection := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not set", fe.Param())
+	case "email":
+		return "must be a valid email address"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "password":
+		return fmt.Sprintf("must be %d-%d characters long and contain a letter and a digit", passwordMinLength, passwordMaxLength)
+	case "sku":
+		return "must contain only latin letters, digits, '.', '-' and '_' (up to 64 characters)"
+	case "min", "gte":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		if isCollection {
+			return fmt.Sprintf("must contain at least %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be greater than or equal to %s", fe.Param())
+	case "max", "lte":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		if isCollection {
+			return fmt.Sprintf("must contain at most %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be less than or equal to %s", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	default:
+		return fmt.Sprintf("is invalid (%s)", fe.Tag())
+	}
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
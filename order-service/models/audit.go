@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Типы сущностей в журнале аудита
+const (
+	AuditEntityUser      = "user"
+	AuditEntityOrder     = "order"
+	AuditEntityProduct   = "product"
+	AuditEntityCategory  = "category"
+	AuditEntityReturn    = "return"
+	AuditEntityRefund    = "refund"
+	AuditEntityInvoice   = "invoice"
+	AuditEntityWebhook   = "webhook"
+	AuditEntityWarehouse = "warehouse"
+)
+
+// AuditEntry — запись журнала аудита. Changes — изменённые поля сущности:
+// {"price": {"before": 10, "after": 12}}; при создании есть только after, при удалении — только before.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    string          `json:"actor_id,omitempty"` // пусто — анонимный запрос
+	ActorRole  string          `json:"actor_role,omitempty"`
+	Action     string          `json:"action"` // <сущность>.<действие>: product.update
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Changes    json.RawMessage `json:"changes"`
+	RequestID  string          `json:"request_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// AuditFilter — условия выборки журнала; пустые поля не фильтруют
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	EntityType string
+	EntityID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	BeforeID   int64 // только записи с id меньше BeforeID (следующая страница)
+	Limit      int
+}
+
+// AuditPage — страница журнала от новых записей к старым
+type AuditPage struct {
+	Items []AuditEntry `json:"items"`
+	// NextBeforeID передаётся в ?before_id= для следующей страницы; нет — записей больше нет
+	NextBeforeID *int64 `json:"next_before_id,omitempty"`
+}
+
+// AuditVerification — результат проверки цепочки хэшей журнала
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`             // сколько записей проверено
+	BrokenAt *int64 `json:"broken_at,omitempty"` // первая запись, на которой цепочка нарушена
+	// Head — хэш последней записи; сохранённый вне базы, он позволяет заметить удаление хвоста журнала
+	Head   string `json:"head,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,116 @@
+package models
+
+import "errors"
+
+// Категории ошибок. По категории обработчики выбирают HTTP-статус,
+// поэтому сервисы и репозитории возвращают ошибки одной из них.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrPreconditionFailed — версия ресурса не совпала с переданной в If-Match
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrPreconditionRequired — изменение без If-Match запрещено
+	ErrPreconditionRequired = errors.New("precondition required")
+	// ErrUnsupportedMediaType — формат тела запроса не поддерживается
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrTooManyRequests — превышен лимит запросов или вход временно заблокирован
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
+// Error — типизированная ошибка со стабильным машиночитаемым кодом.
+// errors.Is(err, ErrNotFound) и т.п. срабатывает по категории Kind,
+// сравнение с самой ошибкой — по указателю, как с обычной sentinel-ошибкой.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []FieldError // ошибки отдельных полей запроса, если есть
+}
+
+// FieldError — ошибка конкретного поля запроса. Field — путь в JSON-теле,
+// например "email" или "variants[1].sku".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// CodeValidationFailed — код ошибки с перечнем некорректных полей
+const CodeValidationFailed = "validation_failed"
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is сопоставляет ошибку с её категорией
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// NewNotFound создаёт ошибку «ресурс не найден»
+func NewNotFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+// NewConflict создаёт ошибку конфликта с текущим состоянием ресурса
+func NewConflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+// NewValidation создаёт ошибку некорректных входных данных
+func NewValidation(code, message string) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message}
+}
+
+// NewForbidden создаёт ошибку отсутствия доступа
+func NewForbidden(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+// NewUnauthorized создаёт ошибку отсутствующей или неверной аутентификации
+func NewUnauthorized(code, message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Code: code, Message: message}
+}
+
+// NewPreconditionFailed создаёт ошибку несовпадения версии ресурса
+func NewPreconditionFailed(code, message string) *Error {
+	return &Error{Kind: ErrPreconditionFailed, Code: code, Message: message}
+}
+
+// NewPreconditionRequired создаёт ошибку отсутствующего условного заголовка
+func NewPreconditionRequired(code, message string) *Error {
+	return &Error{Kind: ErrPreconditionRequired, Code: code, Message: message}
+}
+
+// NewTooManyRequests создаёт ошибку превышения лимита запросов
+func NewTooManyRequests(code, message string) *Error {
+	return &Error{Kind: ErrTooManyRequests, Code: code, Message: message}
+}
+
+// NewUnsupportedMediaType создаёт ошибку неподдерживаемого формата тела запроса
+func NewUnsupportedMediaType(code, message string) *Error {
+	return &Error{Kind: ErrUnsupportedMediaType, Code: code, Message: message}
+}
+
+// NewFieldErrors создаёт ошибку валидации с перечнем некорректных полей
+func NewFieldErrors(fields ...FieldError) *Error {
+	return &Error{Kind: ErrValidation, Code: CodeValidationFailed, Message: "request validation failed", Fields: fields}
+}
+
+// FieldErrors накапливает ошибки полей при проверке запроса
+type FieldErrors []FieldError
+
+// Add добавляет ошибку поля
+func (f *FieldErrors) Add(field, message string) {
+	*f = append(*f, FieldError{Field: field, Message: message})
+}
+
+// Err возвращает ошибку валидации или nil, если ошибок нет
+func (f FieldErrors) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return NewFieldErrors(f...)
+}
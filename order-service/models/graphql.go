@@ -0,0 +1,8 @@
+package models
+
+// GraphQLRequest — запрос к /graphql: тело POST или параметры GET (variables — строка JSON)
+type GraphQLRequest struct {
+	Query         string         `json:"query" form:"query" binding:"required"`
+	OperationName string         `json:"operationName" form:"operationName"`
+	Variables     map[string]any `json:"variables" form:"-"`
+}
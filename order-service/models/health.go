@@ -0,0 +1,28 @@
+package models
+
+// Статусы проверок готовности
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+
+	ReadinessReady    = "ready"
+	ReadinessNotReady = "not_ready"
+)
+
+// Liveness — ответ /healthz
+type Liveness struct {
+	Status string `json:"status"`
+}
+
+// DependencyHealth — результат проверки одной зависимости
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Readiness — ответ /readyz
+type Readiness struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+}
@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// Типы движений остатков
+const (
+	StockReceipt     = "receipt"     // поступление на склад
+	StockReservation = "reservation" // резерв под заказ
+	StockRelease     = "release"     // снятие резерва (отмена заказа)
+	StockShipment    = "shipment"    // отгрузка зарезервированного товара
+	StockAdjustment  = "adjustment"  // корректировка по инвентаризации, со знаком
+)
+
+// Стратегии выбора склада при резервировании
+const (
+	AllocationPriority        = "priority"         // склады по приоритету, позиция может делиться между складами
+	AllocationSingleWarehouse = "single_warehouse" // по возможности весь заказ с одного склада
+	AllocationMostAvailable   = "most_available"   // сначала склад с наибольшим доступным остатком SKU
+)
+
+// Warehouse — склад, с которого отгружаются заказы
+type Warehouse struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// Меньшее значение — склад предпочтительнее при резервировании
+	Priority  int       `json:"priority"`
+	Active    bool      `json:"active"`
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WarehouseRequest — данные для создания или замены склада
+type WarehouseRequest struct {
+	Code     string `json:"code" binding:"required,max=50"`
+	Name     string `json:"name" binding:"required,max=200"`
+	Priority int    `json:"priority" binding:"gte=0"`
+	Active   *bool  `json:"active"`
+}
+
+// StockMovement — запись журнала движений остатков
+type StockMovement struct {
+	ID          int64     `json:"id"`
+	WarehouseID string    `json:"warehouse_id"`
+	SKU         string    `json:"sku"`
+	Type        string    `json:"type"`
+	Quantity    int       `json:"quantity"` // у корректировки со знаком
+	OrderID     string    `json:"order_id,omitempty"`
+	Reason      string    `json:"reason"`
+	ActorID     string    `json:"actor_id,omitempty"` // пусто — движение сделано системой
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// StockMovementRequest — ручное движение: поступление или корректировка
+type StockMovementRequest struct {
+	WarehouseID string `json:"warehouse_id" binding:"required"`
+	SKU         string `json:"sku" binding:"required,sku"`
+	Type        string `json:"type" binding:"required,oneof=receipt adjustment"`
+	Quantity    int    `json:"quantity" binding:"required"`
+	Reason      string `json:"reason" binding:"required,max=500"`
+}
+
+// StockMovementFilter — фильтр журнала движений; пустые поля не фильтруют
+type StockMovementFilter struct {
+	SKU         string
+	WarehouseID string
+	OrderID     string
+	Type        string
+	BeforeID    int64 // курсор: движения с ID меньше этого
+	Limit       int
+}
+
+// StockMovementPage — страница журнала движений, от новых к старым
+type StockMovementPage struct {
+	Items        []StockMovement `json:"items"`
+	NextBeforeID *int64          `json:"next_before_id,omitempty"`
+}
+
+// StockLevel — остаток SKU на складе, вычисленный по журналу движений
+type StockLevel struct {
+	WarehouseID   string `json:"warehouse_id"`
+	WarehouseCode string `json:"warehouse_code"`
+	SKU           string `json:"sku"`
+	OnHand        int    `json:"on_hand"`
+	Reserved      int    `json:"reserved"`
+	Available     int    `json:"available"` // on_hand - reserved
+	// Приоритет и активность склада — для выбора склада при резервировании
+	Priority int  `json:"-"`
+	Active   bool `json:"active"`
+}
+
+// SKUAvailability — остатки SKU по складам; Available — сумма по активным складам
+type SKUAvailability struct {
+	SKU        string       `json:"sku"`
+	OnHand     int          `json:"on_hand"`
+	Reserved   int          `json:"reserved"`
+	Available  int          `json:"available"`
+	Warehouses []StockLevel `json:"warehouses"`
+}
+
+// StockLine — сколько единиц SKU нужно зарезервировать
+type StockLine struct {
+	SKU      string
+	Quantity int
+}
+
+// StockAllocation — часть позиции, зарезервированная на конкретном складе
+type StockAllocation struct {
+	WarehouseID string `json:"warehouse_id"`
+	SKU         string `json:"sku"`
+	Quantity    int    `json:"quantity"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Типы финансовых документов
+const (
+	DocTypeInvoice    = "invoice"
+	DocTypeCreditNote = "credit_note"
+)
+
+// InvoiceParty — реквизиты продавца или покупателя
+type InvoiceParty struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	TaxID   string `json:"tax_id,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+// InvoiceLine — строка документа; суммы в валюте документа
+type InvoiceLine struct {
+	ProductID   string  `json:"product_id,omitempty"`
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	NetAmount   float64 `json:"net_amount"`
+	TaxAmount   float64 `json:"tax_amount"`
+	GrossAmount float64 `json:"gross_amount"`
+}
+
+// InvoiceData — неизменяемый снимок данных документа на момент выпуска.
+// PDF отрисовывается из снимка один раз, при выпуске, и затем хранится как есть.
+type InvoiceData struct {
+	Seller        InvoiceParty  `json:"seller"`
+	Buyer         InvoiceParty  `json:"buyer"`
+	OrderID       string        `json:"order_id"`
+	OrderDate     time.Time     `json:"order_date"`
+	RelatedNumber string        `json:"related_number,omitempty"` // номер исходного счёта для кредит-ноты
+	Reason        string        `json:"reason,omitempty"`
+	Currency      string        `json:"currency"`
+	TaxRate       float64       `json:"tax_rate"`
+	Lines         []InvoiceLine `json:"lines"`
+	NetTotal      float64       `json:"net_total"`
+	TaxTotal      float64       `json:"tax_total"`
+	GrossTotal    float64       `json:"gross_total"`
+}
+
+// Invoice — выпущенный счёт или кредит-нота
+type Invoice struct {
+	ID       string      `json:"id"`
+	DocType  string      `json:"doc_type"`
+	Sequence int64       `json:"sequence"`
+	Number   string      `json:"number"`
+	OrderID  string      `json:"order_id"`
+	RefundID *string     `json:"refund_id,omitempty"`
+	Data     InvoiceData `json:"data"`
+	SHA256   string      `json:"sha256"`
+	IssuedAt time.Time   `json:"issued_at"`
+}
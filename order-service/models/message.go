@@ -0,0 +1,6 @@
+package models
+
+// MessageResponse — ответ операций, после которых нечего вернуть, кроме подтверждения
+type MessageResponse struct {
+	Message string `json:"message"`
+}
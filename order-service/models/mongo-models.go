@@ -1,14 +1,85 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Product struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty"`
 	Name        string             `bson:"name"`
 	Description string             `bson:"description"`
-	Price       float64            `bson:"price"`
-	Stock       int                `bson:"stock"`
-	IDString    string             `bson:"idString,omitempty"` // Добавляем поле для хранения UUID
+	Price       float64            `bson:"price"` // Минимальная цена среди вариантов
+	Stock       int                `bson:"stock"` // Суммарный остаток всех вариантов
+	Category    string             `bson:"category,omitempty"`
+	// Slug-и категории и всех её предков, для поиска по поддереву
+	CategoryPath []string           `bson:"category_path,omitempty"`
+	Variants     []ProductVariant   `bson:"variants"`
+	Attributes   []ProductAttribute `bson:"attributes,omitempty"`
+	Images       []ProductImage     `bson:"images,omitempty"`
+	// Канонический публичный ID продукта (UUID в нижнем регистре)
+	IDString string `bson:"idString,omitempty"`
+	// Прежние идентификаторы продукта (hex ObjectID, старые idString), по которым его ещё можно найти
+	LegacyIDs []string `bson:"legacy_ids,omitempty" json:"-"`
+	// Увеличивается при каждом изменении документа
+	Version int64 `bson:"version"`
+	// Время мягкого удаления; удалённый продукт скрыт из каталога, но остаётся для корзин и документов
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+}
+
+// NewProductID генерирует канонический публичный ID продукта
+func NewProductID() string {
+	return uuid.New().String()
+}
+
+// IsCanonicalProductID проверяет, что id записан в каноническом виде: UUID в нижнем регистре
+func IsCanonicalProductID(id string) bool {
+	parsed, err := uuid.Parse(id)
+	return err == nil && parsed.String() == id
+}
+
+// ProductVariant — вариант продукта (размер, цвет и т.п.) со своим SKU, ценой и остатком
+type ProductVariant struct {
+	SKU     string            `bson:"sku" json:"sku" binding:"required,sku"`
+	Name    string            `bson:"name,omitempty" json:"name,omitempty" binding:"max=200"`
+	Price   float64           `bson:"price" json:"price" binding:"gte=0"`
+	Stock   int               `bson:"stock" json:"stock" binding:"gte=0"`
+	Options map[string]string `bson:"options,omitempty" json:"options,omitempty"` // например {"size": "M", "color": "red"}
+}
+
+// Типы значений атрибутов продукта
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+)
+
+// ProductAttribute — произвольный типизированный атрибут продукта
+type ProductAttribute struct {
+	Name  string      `bson:"name" json:"name" binding:"required,max=100"`
+	Type  string      `bson:"type" json:"type" binding:"required,oneof=string number boolean"`
+	Value interface{} `bson:"value" json:"value"`
+}
+
+// ProductImage — ссылка на изображение продукта или конкретного варианта
+type ProductImage struct {
+	URL        string `bson:"url" json:"url" binding:"required,url"`
+	Alt        string `bson:"alt,omitempty" json:"alt,omitempty" binding:"max=200"`
+	Position   int    `bson:"position" json:"position" binding:"gte=0"`
+	VariantSKU string `bson:"variant_sku,omitempty" json:"variant_sku,omitempty"`
+}
+
+// Category — узел дерева категорий
+type Category struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Slug      string             `bson:"slug" json:"slug"`
+	Name      string             `bson:"name" json:"name"`
+	Parent    string             `bson:"parent,omitempty" json:"parent,omitempty"` // slug родителя
+	Path      []string           `bson:"path" json:"path"`                         // slug-и от корня до текущей категории
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	Children  []*Category        `bson:"-" json:"children,omitempty"`
 }
 
 type ProductResponse struct {
@@ -17,4 +88,119 @@ type ProductResponse struct {
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
 	Stock       int     `json:"stock"`
+	Category    string  `json:"category,omitempty"`
+	Version     int64   `json:"version"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	Variants   []ProductVariant   `json:"variants,omitempty"`
+	Attributes []ProductAttribute `json:"attributes,omitempty"`
+	Images     []ProductImage     `json:"images,omitempty"`
+
+	// Заполняются только в результатах поиска
+	Score      float64           `json:"score,omitempty"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// ProductRequest — данные для создания или изменения продукта
+type ProductRequest struct {
+	ID          string             `json:"id" binding:"omitempty,uuid"` // необязательный канонический ID нового продукта
+	Name        string             `json:"name" binding:"required,max=200"`
+	Description string             `json:"description" binding:"max=5000"`
+	Price       float64            `json:"price" binding:"gte=0"`
+	Stock       int                `json:"stock" binding:"gte=0"`
+	Category    string             `json:"category" binding:"max=100"`
+	Variants    []ProductVariant   `json:"variants" binding:"dive"`
+	Attributes  []ProductAttribute `json:"attributes" binding:"dive"`
+	Images      []ProductImage     `json:"images" binding:"dive"`
+}
+
+// ProductPatchFields — поля продукта, которые можно изменить через PATCH.
+// Вложенные пути ("/variants/0/stock") заменяют поле верхнего уровня целиком.
+var ProductPatchFields = []string{"name", "description", "price", "stock", "category", "variants", "attributes", "images"}
+
+// ToProduct преобразует запрос в документ продукта
+func (r *ProductRequest) ToProduct() *Product {
+	return &Product{
+		IDString:    r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Price:       r.Price,
+		Stock:       r.Stock,
+		Category:    r.Category,
+		Variants:    r.Variants,
+		Attributes:  r.Attributes,
+		Images:      r.Images,
+	}
+}
+
+// ToRequest возвращает изменяемые поля продукта в виде запроса; к нему применяется PATCH
+func (p *Product) ToRequest() ProductRequest {
+	return ProductRequest{
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Stock:       p.Stock,
+		Category:    p.Category,
+		Variants:    p.Variants,
+		Attributes:  p.Attributes,
+		Images:      p.Images,
+	}
+}
+
+// ProductIDResolution — результат разрешения ID продукта в канонический
+type ProductIDResolution struct {
+	ID          string `json:"id"`           // запрошенный ID
+	CanonicalID string `json:"canonical_id"` // канонический ID продукта
+	Legacy      bool   `json:"legacy"`       // запрошенный ID устарел
+}
+
+// ToResponse преобразует документ продукта в ответ API
+func (p *Product) ToResponse() ProductResponse {
+	return ProductResponse{
+		ID:          p.IDString, // Используем IDString вместо ID.Hex()
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Stock:       p.Stock,
+		Category:    p.Category,
+		Version:     p.Version,
+		DeletedAt:   p.DeletedAt,
+		Variants:    p.Variants,
+		Attributes:  p.Attributes,
+		Images:      p.Images,
+	}
+}
+
+// Variant возвращает вариант по SKU
+func (p *Product) Variant(sku string) (*ProductVariant, bool) {
+	for i := range p.Variants {
+		if p.Variants[i].SKU == sku {
+			return &p.Variants[i], true
+		}
+	}
+	return nil, false
+}
+
+// DefaultSKU возвращает SKU единственного варианта; для продуктов с несколькими вариантами — пустую строку
+func (p *Product) DefaultSKU() string {
+	if len(p.Variants) == 1 {
+		return p.Variants[0].SKU
+	}
+	return ""
+}
+
+// SyncTotals пересчитывает цену и остаток продукта по вариантам
+func (p *Product) SyncTotals() {
+	if len(p.Variants) == 0 {
+		return
+	}
+	p.Price = p.Variants[0].Price
+	p.Stock = 0
+	for _, v := range p.Variants {
+		if v.Price < p.Price {
+			p.Price = v.Price
+		}
+		p.Stock += v.Stock
+	}
 }
@@ -2,10 +2,53 @@ package models
 
 import "time"
 
+// Статусы заказа
+const (
+	OrderStatusPending           = "pending"
+	OrderStatusPaid              = "paid"
+	OrderStatusShipped           = "shipped"
+	OrderStatusDelivered         = "delivered"
+	OrderStatusCancelled         = "cancelled"
+	OrderStatusPartiallyReturned = "partially_returned"
+	OrderStatusReturned          = "returned"
+)
+
 type CartItem struct {
 	ProductID string  `json:"product_id"`
+	SKU       string  `json:"sku"` // SKU варианта продукта
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+}
+
+// Причины, по которым позиция корзины не может быть оформлена
+const (
+	CartLineProductDeleted  = "product_deleted"
+	CartLineVariantNotFound = "variant_not_found"
+)
+
+// CartLine — позиция корзины с текущими названием и ценой продукта
+type CartLine struct {
+	SKU       string  `json:"sku"`
+	ProductID string  `json:"product_id,omitempty"`
+	Name      string  `json:"name,omitempty"`
 	Quantity  int     `json:"quantity"`
 	Price     float64 `json:"price"`
+	// Почему позицию нельзя оформить; пусто, если можно
+	Unavailable string `json:"unavailable,omitempty"`
+}
+
+// CartResponse — содержимое корзины
+type CartResponse struct {
+	// Cart — прежний формат ответа {sku: количество}
+	Cart  map[string]int `json:"cart"`
+	Items []CartLine     `json:"items"`
+}
+
+// CheckoutResponse — заказ, оформленный из корзины
+type CheckoutResponse struct {
+	Order OrderResponse `json:"order"`
+	// Skipped — позиции удалённых продуктов, оставшиеся в корзине
+	Skipped []CartLine `json:"skipped"`
 }
 
 type Order struct {
@@ -14,6 +57,114 @@ type Order struct {
 	Items      []CartItem `json:"items"`
 	TotalPrice float64    `json:"total_price"`
 	Status     string     `json:"status"`
+	Version    int64      `json:"version"` // увеличивается при каждом изменении
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // время мягкого удаления
+}
+
+// IsCancellable сообщает, можно ли отменить заказ (только до отгрузки)
+func (o *Order) IsCancellable() bool {
+	return o.Status == OrderStatusPending || o.Status == OrderStatusPaid
+}
+
+// IsReturnable сообщает, можно ли оформить возврат (только после доставки)
+func (o *Order) IsReturnable() bool {
+	return o.Status == OrderStatusDelivered || o.Status == OrderStatusPartiallyReturned
+}
+
+// IsInvoiceable сообщает, можно ли выпустить счёт: заказ оплачен и не отменён
+// (в том числе отгружен, доставлен или возвращён)
+func (o *Order) IsInvoiceable() bool {
+	switch o.Status {
+	case OrderStatusPaid, OrderStatusShipped, OrderStatusDelivered, OrderStatusPartiallyReturned, OrderStatusReturned:
+		return true
+	}
+	return false
+}
+
+// orderTransitions — переходы статуса, доступные через PUT и PATCH заказа.
+// Отмена и возвраты идут через свои операции: они возвращают деньги и записывают причину.
+var orderTransitions = map[string]string{
+	OrderStatusPending: OrderStatusPaid,
+	OrderStatusPaid:    OrderStatusShipped,
+	OrderStatusShipped: OrderStatusDelivered,
+}
+
+// CanTransitionTo сообщает, можно ли перевести заказ в статус status через PUT и PATCH
+func (o *Order) CanTransitionTo(status string) bool {
+	return orderTransitions[o.Status] == status
+}
+
+// CreateOrderRequest — заказ, созданный без корзины
+type CreateOrderRequest struct {
+	UserID     string  `json:"user_id" binding:"required,uuid"`
+	TotalPrice float64 `json:"total_price" binding:"gte=0"`
+}
+
+// UpdateOrderRequest — изменяемые поля заказа. Сумма заказа складывается из позиций и не меняется.
+type UpdateOrderRequest struct {
+	UserID string `json:"user_id" binding:"required,uuid"`
+	Status string `json:"status" binding:"required,oneof=pending paid shipped delivered cancelled partially_returned returned"`
+}
+
+// CancelOrderRequest — необязательная причина отмены заказа
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// OrderPatchFields — поля заказа, которые можно изменить через PATCH
+var OrderPatchFields = []string{"user_id", "status"}
+
+// OrderResponse — заказ в ответах API
+type OrderResponse struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Items      []CartItem `json:"items"`
+	TotalPrice float64    `json:"total_price"`
+	Status     string     `json:"status"`
+	Version    int64      `json:"version"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ToResponse преобразует заказ в ответ API
+func (o *Order) ToResponse() OrderResponse {
+	items := o.Items
+	if items == nil {
+		items = []CartItem{}
+	}
+	return OrderResponse{
+		ID:         o.ID,
+		UserID:     o.UserID,
+		Items:      items,
+		TotalPrice: o.TotalPrice,
+		Status:     o.Status,
+		Version:    o.Version,
+		CreatedAt:  o.CreatedAt,
+		UpdatedAt:  o.UpdatedAt,
+		DeletedAt:  o.DeletedAt,
+	}
+}
+
+// OrderStatusChange — запись истории статусов заказа
+type OrderStatusChange struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason,omitempty"`
+	ChangedBy  *string   `json:"changed_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OrderUpdate — заказ после изменения и его статус до изменения (если статус менялся)
+type OrderUpdate struct {
+	Order          Order  `json:"order"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+}
+
+// IsFinal сообщает, что статус заказа больше не меняется в обычном потоке:
+// заказ отменён, полностью возвращён или доставлен
+func (o *Order) IsFinal() bool {
+	return o.Status == OrderStatusCancelled || o.Status == OrderStatusReturned || o.Status == OrderStatusDelivered
 }
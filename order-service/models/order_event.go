@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Типы событий агрегата заказа
+const (
+	OrderEventCreated       = "order.created"
+	OrderEventImported      = "order.imported" // состояние заказа, созданного до журнала событий
+	OrderEventUpdated       = "order.updated"
+	OrderEventStatusChanged = "order.status_changed"
+	OrderEventDeleted       = "order.deleted"
+	OrderEventRestored      = "order.restored"
+)
+
+// OrderEvent — запись журнала событий заказа. Version — версия заказа после события.
+type OrderEvent struct {
+	ID         int64           `json:"id"`
+	OrderID    string          `json:"order_id"`
+	Version    int64           `json:"version"`
+	Type       string          `json:"type"`
+	Data       json.RawMessage `json:"data"`
+	ActorID    string          `json:"actor_id,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// OrderCreated — данные события order.created
+type OrderCreated struct {
+	UserID     string     `json:"user_id"`
+	Items      []CartItem `json:"items"`
+	TotalPrice float64    `json:"total_price"`
+	Status     string     `json:"status"`
+}
+
+// OrderChanges — данные события order.updated: только изменённые поля
+type OrderChanges struct {
+	UserID     *string  `json:"user_id,omitempty"`
+	TotalPrice *float64 `json:"total_price,omitempty"`
+	Status     *string  `json:"status,omitempty"`
+}
+
+// OrderStatusChanged — данные события order.status_changed
+type OrderStatusChanged struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewOrderEvent создаёт событие с сериализованными данными; версию и время задаёт журнал
+func NewOrderEvent(orderID, eventType string, data any, actorID string) (*OrderEvent, error) {
+	raw := json.RawMessage(`{}`)
+	if data != nil {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return nil, fmt.Errorf("marshal %s event: %w", eventType, err)
+		}
+	}
+	return &OrderEvent{OrderID: orderID, Type: eventType, Data: raw, ActorID: actorID}, nil
+}
+
+// Changes возвращает отличия заказа after от o в формате события order.updated
+func (o *Order) Changes(after *Order) OrderChanges {
+	var changes OrderChanges
+	if after.UserID != o.UserID {
+		changes.UserID = &after.UserID
+	}
+	if after.TotalPrice != o.TotalPrice {
+		changes.TotalPrice = &after.TotalPrice
+	}
+	if after.Status != o.Status {
+		changes.Status = &after.Status
+	}
+	return changes
+}
+
+// Apply применяет событие к заказу. Состояние заказа — результат применения всех его событий по порядку.
+func (o *Order) Apply(event *OrderEvent) error {
+	switch event.Type {
+	case OrderEventCreated:
+		var data OrderCreated
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return fmt.Errorf("decode %s event: %w", event.Type, err)
+		}
+		*o = Order{
+			ID:         event.OrderID,
+			UserID:     data.UserID,
+			Items:      data.Items,
+			TotalPrice: data.TotalPrice,
+			Status:     data.Status,
+			CreatedAt:  event.OccurredAt,
+		}
+	case OrderEventImported:
+		var state Order
+		if err := json.Unmarshal(event.Data, &state); err != nil {
+			return fmt.Errorf("decode %s event: %w", event.Type, err)
+		}
+		*o = state
+	case OrderEventUpdated:
+		var data OrderChanges
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return fmt.Errorf("decode %s event: %w", event.Type, err)
+		}
+		if data.UserID != nil {
+			o.UserID = *data.UserID
+		}
+		if data.TotalPrice != nil {
+			o.TotalPrice = *data.TotalPrice
+		}
+		if data.Status != nil {
+			o.Status = *data.Status
+		}
+	case OrderEventStatusChanged:
+		var data OrderStatusChanged
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return fmt.Errorf("decode %s event: %w", event.Type, err)
+		}
+		o.Status = data.To
+	case OrderEventDeleted:
+		deletedAt := event.OccurredAt
+		o.DeletedAt = &deletedAt
+	case OrderEventRestored:
+		o.DeletedAt = nil
+	default:
+		return fmt.Errorf("unknown order event type %q", event.Type)
+	}
+	o.Version = event.Version
+	o.UpdatedAt = event.OccurredAt
+	return nil
+}
+
+// OrderUserSummary — сводка по заказам пользователя, которую поддерживает проекция журнала событий
+type OrderUserSummary struct {
+	UserID         string     `json:"user_id"`
+	OrdersCount    int        `json:"orders_count"`
+	CancelledCount int        `json:"cancelled_count"`
+	TotalSpent     float64    `json:"total_spent"` // сумма неотменённых заказов
+	LastOrderAt    *time.Time `json:"last_order_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
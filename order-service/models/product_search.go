@@ -0,0 +1,37 @@
+package models
+
+// MaxSearchPage — последняя доступная страница поиска: дальше $skip обходит слишком большую часть выборки
+const MaxSearchPage = 1000
+
+// ProductSearchParams — параметры полнотекстового поиска продуктов
+type ProductSearchParams struct {
+	Query    string
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  *bool
+	Category string
+	Page     int
+	PageSize int
+}
+
+// FacetBucket — значение фасета и количество найденных продуктов
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// ProductFacets — фасеты, посчитанные по всем найденным продуктам (без пагинации)
+type ProductFacets struct {
+	Price        []FacetBucket `json:"price"`
+	Category     []FacetBucket `json:"category"`
+	Availability []FacetBucket `json:"availability"`
+}
+
+// ProductSearchResult — страница результатов поиска
+type ProductSearchResult struct {
+	Items    []ProductResponse `json:"items"`
+	Total    int64             `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+	Facets   ProductFacets     `json:"facets"`
+}
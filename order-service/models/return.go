@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Статусы заявки на возврат
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusRefunded  = "refunded"
+)
+
+// Статусы возврата средств
+const (
+	RefundStatusCompleted = "completed"
+)
+
+// ReturnItem — возвращаемая позиция заказа
+type ReturnItem struct {
+	ProductID string  `json:"product_id" binding:"required_without=SKU"`
+	SKU       string  `json:"sku"`
+	Quantity  int     `json:"quantity" binding:"min=1"`
+	Price     float64 `json:"price"`
+	Reason    string  `json:"reason,omitempty" binding:"max=500"`
+}
+
+// Return — заявка на возврат (RMA)
+type Return struct {
+	ID           string       `json:"id"`
+	OrderID      string       `json:"order_id"`
+	UserID       string       `json:"user_id"`
+	Status       string       `json:"status"`
+	Reason       string       `json:"reason"`
+	AdminComment string       `json:"admin_comment,omitempty"`
+	RefundAmount float64      `json:"refund_amount"`
+	Items        []ReturnItem `json:"items"`
+	ResolvedBy   *string      `json:"resolved_by,omitempty"`
+	ResolvedAt   *time.Time   `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// Refund — возврат денежных средств по заказу
+type Refund struct {
+	ID        string    `json:"id"`
+	OrderID   string    `json:"order_id"`
+	ReturnID  *string   `json:"return_id,omitempty"`
+	Amount    float64   `json:"amount"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
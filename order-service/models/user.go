@@ -2,11 +2,82 @@ package models
 
 import "time"
 
+// Роли пользователей
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
 	ID        string    `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"` // bcrypt-хэш, никогда не сериализуется
+	Role      string    `json:"role"`
+	Version   int64     `json:"version"` // увеличивается при каждом изменении
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// LastLoginAt — время последнего входа; по нему удаляются неактивные учётные записи
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	// ErasedAt — время обезличивания по запросу на удаление; персональных данных после него нет
+	ErasedAt *time.Time `json:"erased_at,omitempty"`
+	// DeletedAt — время мягкого удаления; такую учётную запись можно восстановить
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// RegisterRequest — данные для регистрации пользователя
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,password"`
+}
+
+// LoginRequest — данные для входа
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// TokenResponse — JWT, выданный при входе
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+// UpdateUserRequest — изменяемые поля профиля
+type UpdateUserRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+}
+
+// UserPatchFields — поля пользователя, которые можно изменить через PATCH
+var UserPatchFields = []string{"username", "email"}
+
+// UserResponse — пользователь в ответах API, без хэша пароля
+type UserResponse struct {
+	ID          string     `json:"id"`
+	Username    string     `json:"username"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	Version     int64      `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	ErasedAt    *time.Time `json:"erased_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ToResponse преобразует пользователя в ответ API
+func (u *User) ToResponse() UserResponse {
+	return UserResponse{
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		Role:        u.Role,
+		Version:     u.Version,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		LastLoginAt: u.LastLoginAt,
+		ErasedAt:    u.ErasedAt,
+		DeletedAt:   u.DeletedAt,
+	}
 }
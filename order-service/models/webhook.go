@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// События заказов, на которые можно подписаться
+const (
+	WebhookOrderCreated       = "order.created"
+	WebhookOrderUpdated       = "order.updated"
+	WebhookOrderStatusChanged = "order.status_changed"
+	WebhookOrderCancelled     = "order.cancelled"
+	WebhookOrderDeleted       = "order.deleted"
+	WebhookOrderRestored      = "order.restored"
+)
+
+// WebhookEvents — все события в порядке для документации и проверки подписок
+var WebhookEvents = []string{
+	WebhookOrderCreated,
+	WebhookOrderUpdated,
+	WebhookOrderStatusChanged,
+	WebhookOrderCancelled,
+	WebhookOrderDeleted,
+	WebhookOrderRestored,
+}
+
+// Статусы доставки
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSubscription — подписка внешней системы на события заказов
+type WebhookSubscription struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"-"` // ключ HMAC, в ответах показывается только при создании
+	// Пустой список — все события
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	Description string    `json:"description,omitempty"`
+	Version     int64     `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Subscribed сообщает, подписана ли подписка на событие event
+func (s *WebhookSubscription) Subscribed(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookSubscriptionRequest — данные для создания или замены подписки
+type WebhookSubscriptionRequest struct {
+	URL string `json:"url" binding:"required,url,max=2000"`
+	// Пустой секрет при создании — сгенерировать; при замене — оставить прежний
+	Secret      string   `json:"secret" binding:"omitempty,min=16,max=200"`
+	Events      []string `json:"events" binding:"max=20,dive,oneof=order.created order.updated order.status_changed order.cancelled order.deleted order.restored"`
+	Active      *bool    `json:"active"`
+	Description string   `json:"description" binding:"max=500"`
+}
+
+// WebhookSubscriptionResponse — подписка в ответах API; секрет есть только в ответе на создание
+type WebhookSubscriptionResponse struct {
+	WebhookSubscription
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookEvent — тело доставки
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookOrderData — данные событий заказа
+type WebhookOrderData struct {
+	Order OrderResponse `json:"order"`
+	// Статус до изменения; только для order.status_changed
+	PreviousStatus string `json:"previous_status,omitempty"`
+}
+
+// WebhookDelivery — доставка события одной подписке и её последняя попытка
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"` // только для pending
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   string          `json:"response_body,omitempty"` // начало ответа получателя
+	LastError      string          `json:"last_error,omitempty"`
+	RedeliveryOf   *int64          `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+
+	// Адрес и секрет подписки; заполняются, когда доставка взята в отправку
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookDeliveryFilter — фильтр журнала доставок подписки
+type WebhookDeliveryFilter struct {
+	Status   string
+	BeforeID int64 // курсор: доставки с ID меньше этого
+	Limit    int
+}
+
+// WebhookDeliveryPage — страница журнала доставок, от новых к старым
+type WebhookDeliveryPage struct {
+	Items        []WebhookDelivery `json:"items"`
+	NextBeforeID *int64            `json:"next_before_id,omitempty"`
+}
@@ -0,0 +1,33 @@
+package openapi
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Diff сверяет спецификацию с маршрутами gin: возвращает маршруты, которых нет в спецификации,
+// и описанные маршруты, которые не зарегистрированы. Пустой результат — спецификация полная.
+func (d *Document) Diff(registered gin.RoutesInfo) []string {
+	var problems []string
+	seen := map[string]bool{}
+	for _, route := range registered {
+		path := specPath(route.Path)
+		key := route.Method + " " + path
+		seen[key] = true
+		if _, ok := d.Paths[path][strings.ToLower(route.Method)]; !ok {
+			problems = append(problems, fmt.Sprintf("%s %s: registered but missing from the OpenAPI spec", route.Method, route.Path))
+		}
+	}
+	for path, item := range d.Paths {
+		for method := range item {
+			if key := strings.ToUpper(method) + " " + path; !seen[key] {
+				problems = append(problems, fmt.Sprintf("%s: described in the OpenAPI spec but not registered", key))
+			}
+		}
+	}
+	slices.Sort(problems)
+	return problems
+}
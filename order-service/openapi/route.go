@@ -0,0 +1,258 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Auth — требования маршрута к токену
+type Auth int
+
+const (
+	// AuthNone — маршрут открыт, токен не проверяется
+	AuthNone Auth = iota
+	// AuthOptional — токен необязателен; с ним доступно больше (удалённые записи, свои данные)
+	AuthOptional
+	// AuthBearer — нужен действительный токен
+	AuthBearer
+	// AuthAdmin — нужен токен администратора
+	AuthAdmin
+)
+
+const (
+	bearerScheme       = "bearerAuth"
+	problemContentType = "application/problem+json"
+	jsonContentType    = "application/json"
+	mergePatchType     = "application/merge-patch+json"
+	jsonPatchType      = "application/json-patch+json"
+)
+
+// Param — параметр строки запроса
+type Param struct {
+	Name        string
+	Description string
+	Schema      *Schema
+	Required    bool
+}
+
+// QueryParam описывает необязательный параметр строки запроса с типом string, integer, number или boolean
+func QueryParam(name, typ, description string) Param {
+	return Param{Name: name, Description: description, Schema: &Schema{Type: typ}}
+}
+
+// Route — описание маршрута. Тела запроса и ответа задаются значениями DTO (models.LoginRequest{}),
+// ответы с ошибками problem+json добавляются по авторизации, телу, параметрам и If-Match.
+type Route struct {
+	Method string
+	// Path — путь в формате gin: /users/:id
+	Path        string
+	Summary     string
+	Description string
+	Tag         string
+	Auth        Auth
+	Query       []Param
+	// IfMatch — изменение требует заголовка If-Match с текущим ETag
+	IfMatch bool
+
+	// Body — DTO тела запроса в формате JSON
+	Body         any
+	BodyOptional bool
+	// Patch — DTO изменяемых полей для PATCH: тело в формате JSON Merge Patch или JSON Patch;
+	// PatchFields — поля DTO, которые разрешено менять
+	Patch       any
+	PatchFields []string
+
+	// Status — код успешного ответа, по умолчанию 200
+	Status int
+	// Response — DTO ответа; nil — ответ без тела JSON
+	Response any
+	// ResponseType — тип содержимого ответа, если он не JSON (application/pdf); тело описывается как двоичное
+	ResponseType string
+	// ETag — успешный ответ содержит заголовок ETag
+	ETag bool
+	// Errors — статусы ошибок, которые возвращает сам обработчик (404, 409, ...)
+	Errors []int
+}
+
+var pathParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Add добавляет маршруты в спецификацию; повторное описание маршрута — ошибка в таблице маршрутов
+func (d *Document) Add(routes ...Route) {
+	for _, route := range routes {
+		path := specPath(route.Path)
+		item, ok := d.Paths[path]
+		if !ok {
+			item = PathItem{}
+			d.Paths[path] = item
+		}
+		method := strings.ToLower(route.Method)
+		if _, ok := item[method]; ok {
+			panic(fmt.Sprintf("openapi: route %s %s is described twice", route.Method, route.Path))
+		}
+		item[method] = d.operation(route, path)
+	}
+}
+
+// specPath переводит путь gin в шаблон OpenAPI: /users/:id → /users/{id}
+func specPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+func (d *Document) operation(route Route, path string) *Operation {
+	op := &Operation{
+		OperationID: operationID(route.Method, path),
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]*Response{},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, p := range route.Query {
+		op.Parameters = append(op.Parameters, Parameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: p.Schema})
+	}
+	if route.IfMatch {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name: "If-Match", In: "header", Required: true,
+			Description: "ETag текущей версии ресурса из ответа GET",
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+
+	switch {
+	case route.Body != nil:
+		op.RequestBody = &RequestBody{
+			Required: !route.BodyOptional,
+			Content:  map[string]MediaType{jsonContentType: {Schema: d.SchemaFor(route.Body)}},
+		}
+	case route.Patch != nil:
+		// В merge patch передаются только изменяемые поля, поэтому обязательных полей нет
+		merge := d.structSchema(reflect.TypeOf(route.Patch))
+		merge.Required = nil
+		for name := range merge.Properties {
+			if !slices.Contains(route.PatchFields, name) {
+				delete(merge.Properties, name)
+			}
+		}
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			mergePatchType: {Schema: merge},
+			jsonPatchType:  {Schema: &Schema{Type: "array", Items: d.jsonPatchOperation()}},
+		}}
+	}
+
+	switch route.Auth {
+	case AuthOptional:
+		// Пустое требование означает, что токен можно не передавать
+		op.Security = []map[string][]string{{}, {bearerScheme: {}}}
+	case AuthBearer, AuthAdmin:
+		op.Security = []map[string][]string{{bearerScheme: {}}}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses[strconv.Itoa(status)] = d.successResponse(route, status)
+	for _, code := range errorStatuses(route) {
+		op.Responses[strconv.Itoa(code)] = d.problemResponse(code)
+	}
+	return op
+}
+
+func (d *Document) successResponse(route Route, status int) *Response {
+	response := &Response{Description: http.StatusText(status)}
+	switch {
+	case strings.HasPrefix(route.ResponseType, "text/"):
+		response.Content = map[string]MediaType{route.ResponseType: {Schema: &Schema{Type: "string"}}}
+	case route.ResponseType != "":
+		response.Content = map[string]MediaType{route.ResponseType: {Schema: &Schema{Type: "string", Format: "binary"}}}
+	case route.Response != nil:
+		response.Content = map[string]MediaType{jsonContentType: {Schema: d.SchemaFor(route.Response)}}
+	}
+	if route.ETag {
+		response.Headers = map[string]Header{"ETag": {Description: "Версия ресурса для If-Match", Schema: &Schema{Type: "string"}}}
+	}
+	return response
+}
+
+// errorStatuses — статусы ошибок маршрута: объявленные обработчиком и следующие из его описания
+func errorStatuses(route Route) []int {
+	codes := slices.Clone(route.Errors)
+	if route.Body != nil || route.Patch != nil || len(route.Query) > 0 {
+		codes = append(codes, http.StatusBadRequest)
+	}
+	switch route.Auth {
+	case AuthBearer:
+		codes = append(codes, http.StatusUnauthorized)
+	case AuthAdmin:
+		codes = append(codes, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	if route.IfMatch {
+		codes = append(codes, http.StatusPreconditionFailed, http.StatusPreconditionRequired)
+	}
+	if route.Patch != nil {
+		codes = append(codes, http.StatusUnsupportedMediaType)
+	}
+	codes = append(codes, http.StatusTooManyRequests, http.StatusInternalServerError)
+	slices.Sort(codes)
+	return slices.Compact(codes)
+}
+
+// problemResponse возвращает ссылку на общий ответ problem+json с этим статусом
+func (d *Document) problemResponse(status int) *Response {
+	name := strings.ReplaceAll(http.StatusText(status), " ", "")
+	if _, ok := d.Components.Responses[name]; !ok {
+		d.Components.Responses[name] = &Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{problemContentType: {Schema: d.problem}},
+		}
+	}
+	return &Response{Ref: "#/components/responses/" + name}
+}
+
+// jsonPatchOperation — операция JSON Patch (RFC 6902)
+func (d *Document) jsonPatchOperation() *Schema {
+	const name = "JSONPatchOperation"
+	if _, ok := d.Components.Schemas[name]; !ok {
+		d.Components.Schemas[name] = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"op":    {Type: "string", Enum: []any{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  {Type: "string", Description: "JSON Pointer изменяемого поля: /status"},
+				"from":  {Type: "string", Description: "Источник для move и copy"},
+				"value": {},
+			},
+			Required: []string{"op", "path"},
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// operationID строится из метода и пути: GET /users/{id}/export → getUsersIdExport
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range path {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,256 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaFor возвращает схему значения v; именованные структуры попадают в components
+// и возвращаются ссылкой $ref
+func (d *Document) SchemaFor(v any) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// []byte кодируется encoding/json в base64
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return d.component(t)
+	default:
+		// interface{} и прочее — любое значение JSON
+		return &Schema{}
+	}
+}
+
+// component регистрирует схему именованной структуры и возвращает ссылку на неё
+func (d *Document) component(t reflect.Type) *Schema {
+	name := d.componentName(t)
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if existing, ok := d.types[name]; ok {
+		if existing != t {
+			panic(fmt.Sprintf("openapi: schema name %s is used by both %s and %s", name, existing, t))
+		}
+		return ref
+	}
+	// Тип регистрируется до обхода полей, чтобы рекурсивные структуры ссылались сами на себя
+	d.types[name] = t
+	d.Components.Schemas[name] = d.structSchema(t)
+	return ref
+}
+
+// Name задаёт имя схемы типа v в components вместо имени типа: для типов сторонних пакетов
+// с общими именами (graphql.Response)
+func (d *Document) Name(v any, name string) {
+	d.names[reflect.TypeOf(v)] = name
+}
+
+func (d *Document) componentName(t reflect.Type) string {
+	if name, ok := d.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	// Параметры generic-типов в имени схемы недопустимы
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// structSchema описывает поля структуры. Если у структуры есть binding-теги (это DTO запроса),
+// обязательность берётся из правила required; иначе (DTO ответа) обязательны поля без omitempty.
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(s, t, hasBindingTags(t))
+	return s
+}
+
+func (d *Document) addFields(s *Schema, t reflect.Type, validated bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		// Встроенные структуры без json-имени раскрываются в родителя, как в encoding/json
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				d.addFields(s, ft, validated)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		field := d.schemaOf(f.Type)
+		if opts == "string" || strings.Contains(opts, ",string") {
+			field = &Schema{Type: "string"}
+		}
+		required := d.applyBinding(field, f.Tag.Get("binding"))
+		if !validated {
+			required = !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer
+		}
+
+		s.Properties[name] = field
+		if required && !slices.Contains(s.Required, name) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func hasBindingTags(t reflect.Type) bool {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("binding"); ok {
+			return true
+		}
+		if f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && hasBindingTags(ft) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyBinding переносит правила валидатора в схему поля и сообщает, обязательно ли оно.
+// Правила после dive относятся к элементам массива.
+func (d *Document) applyBinding(s *Schema, binding string) (required bool) {
+	if binding == "" {
+		return false
+	}
+	target := s
+	for _, rule := range strings.Split(binding, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "required":
+			if target == s {
+				required = true
+			}
+			continue
+		case "dive":
+			if s.Items == nil {
+				return required
+			}
+			target = s.Items
+			continue
+		}
+		// Ограничения к ссылке на схему не добавляются: они задаются в самой схеме
+		if target.Ref != "" {
+			continue
+		}
+		applyRule(target, tag, param)
+		if apply, ok := d.rules[tag]; ok {
+			apply(target)
+		}
+	}
+	return required
+}
+
+func applyRule(s *Schema, tag, param string) {
+	switch tag {
+	case "email":
+		s.Format = "email"
+	case "uuid":
+		s.Format = "uuid"
+	case "url":
+		s.Format = "uri"
+	case "oneof":
+		for _, v := range strings.Fields(param) {
+			if n, err := strconv.ParseFloat(v, 64); err == nil && s.Type != "string" {
+				s.Enum = append(s.Enum, n)
+			} else {
+				s.Enum = append(s.Enum, v)
+			}
+		}
+	case "min", "gte":
+		setBound(s, param, &s.MinLength, &s.MinItems, &s.Minimum)
+	case "max", "lte":
+		setBound(s, param, &s.MaxLength, &s.MaxItems, &s.Maximum)
+	case "gt":
+		setBound(s, param, nil, nil, &s.ExclusiveMinimum)
+	case "lt":
+		setBound(s, param, nil, nil, &s.ExclusiveMaximum)
+	}
+}
+
+// setBound задаёт ограничение длины строки, размера массива или значения числа — в зависимости от типа
+func setBound(s *Schema, param string, length, items **int, value **float64) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "string":
+		if length != nil {
+			*length = ptr(int(n))
+		}
+	case "array":
+		if items != nil {
+			*items = ptr(int(n))
+		}
+	case "integer", "number":
+		*value = ptr(n)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,9 @@
+package openapi
+
+import _ "embed"
+
+// SwaggerUI — страница Swagger UI; спецификацию она загружает из openapi.json рядом с собой,
+// сами скрипты и стили — из CDN
+//
+//go:embed swagger.html
+var SwaggerUI []byte
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"order-service/logging"
+	"sync/atomic"
+	"time"
+)
+
+var logger = logging.For("ratelimit")
+
+// FallbackStore переключается на резервное хранилище, когда основное (Redis) недоступно:
+// лимиты продолжают действовать в пределах экземпляра, а не отключаются совсем
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+	degraded atomic.Bool
+}
+
+func NewFallbackStore(primary, fallback Store) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback}
+}
+
+// use сообщает об ошибке основного хранилища один раз за период недоступности
+func (s *FallbackStore) use(ctx context.Context, err error) Store {
+	if err == nil {
+		if s.degraded.CompareAndSwap(true, false) {
+			logger.InfoContext(ctx, "rate limit store recovered")
+		}
+		return nil
+	}
+	if ctx.Err() != nil {
+		// Запрос отменён клиентом — это не признак недоступности Redis
+		return s.fallback
+	}
+	if s.degraded.CompareAndSwap(false, true) {
+		logger.WarnContext(ctx, "rate limit store unavailable, using in-memory fallback", "error", err)
+	}
+	return s.fallback
+}
+
+func (s *FallbackStore) Hit(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	result, err := s.primary.Hit(ctx, key, limit, window)
+	if fallback := s.use(ctx, err); fallback != nil {
+		return fallback.Hit(ctx, key, limit, window)
+	}
+	return result, nil
+}
+
+func (s *FallbackStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	value, err := s.primary.Incr(ctx, key, ttl)
+	if fallback := s.use(ctx, err); fallback != nil {
+		return fallback.Incr(ctx, key, ttl)
+	}
+	return value, nil
+}
+
+func (s *FallbackStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	err := s.primary.Lock(ctx, key, d)
+	if fallback := s.use(ctx, err); fallback != nil {
+		return fallback.Lock(ctx, key, d)
+	}
+	return nil
+}
+
+func (s *FallbackStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	left, err := s.primary.LockedFor(ctx, key)
+	if fallback := s.use(ctx, err); fallback != nil {
+		return fallback.LockedFor(ctx, key)
+	}
+	return left, nil
+}
+
+func (s *FallbackStore) Delete(ctx context.Context, keys ...string) error {
+	err := s.primary.Delete(ctx, keys...)
+	if fallback := s.use(ctx, err); fallback != nil {
+		return fallback.Delete(ctx, keys...)
+	}
+	// Блокировки, поставленные во время недоступности, тоже снимаются
+	return s.fallback.Delete(ctx, keys...)
+}
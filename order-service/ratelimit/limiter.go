@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// LockoutConfig — блокировка аккаунта после Threshold неудачных входов подряд:
+// Base, затем вдвое дольше после каждой следующей неудачи, но не дольше Max.
+// Счётчик неудач сбрасывается успешным входом или через Window без неудач.
+type LockoutConfig struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// Limiter применяет политики маршрутов и блокировку входа
+type Limiter struct {
+	store    Store
+	policies map[string]Policy
+	lockout  LockoutConfig
+}
+
+func NewLimiter(store Store, policies map[string]Policy, lockout LockoutConfig) *Limiter {
+	return &Limiter{store: store, policies: policies, lockout: lockout}
+}
+
+// Policy возвращает политику маршрута (route — шаблон пути, как в c.FullPath())
+func (l *Limiter) Policy(method, route string) (Policy, bool) {
+	policy, ok := l.policies[method+" "+route]
+	return policy, ok
+}
+
+// Allow учитывает запрос во всех правилах политики. Возвращает результат первого
+// нарушенного правила, а если нарушений нет — правила с наименьшим остатком.
+// Правила области account пропускаются, если account пустой.
+func (l *Limiter) Allow(ctx context.Context, policy Policy, ip, account string) (Result, Rule, error) {
+	var tightest Result
+	var tightestRule Rule
+	checked := false
+	for _, rule := range policy.Rules {
+		id := ip
+		if rule.Scope == ScopeAccount {
+			if account == "" {
+				continue
+			}
+			id = accountKey(account)
+		}
+
+		result, err := l.store.Hit(ctx, policy.Route+":"+rule.Scope+":"+id, rule.Limit, rule.Window)
+		if err != nil {
+			return Result{}, rule, err
+		}
+		if !result.Allowed {
+			return result, rule, nil
+		}
+		if !checked || result.Remaining < tightest.Remaining {
+			tightest, tightestRule, checked = result, rule, true
+		}
+	}
+	if !checked {
+		return Result{Allowed: true}, Rule{}, nil
+	}
+	return tightest, tightestRule, nil
+}
+
+// LockedFor возвращает, сколько ещё заблокирован вход в аккаунт
+func (l *Limiter) LockedFor(ctx context.Context, account string) (time.Duration, error) {
+	return l.store.LockedFor(ctx, "lock:"+accountKey(account))
+}
+
+// LoginFailed учитывает неудачный вход; возвращает срок блокировки, если она наступила
+func (l *Limiter) LoginFailed(ctx context.Context, account string) (time.Duration, error) {
+	key := accountKey(account)
+	failures, err := l.store.Incr(ctx, "failures:"+key, l.lockout.Window)
+	if err != nil || failures < int64(l.lockout.Threshold) {
+		return 0, err
+	}
+
+	lock := l.lockout.Base
+	for i := int64(l.lockout.Threshold); i < failures && lock < l.lockout.Max; i++ {
+		lock *= 2
+	}
+	if lock > l.lockout.Max {
+		lock = l.lockout.Max
+	}
+	return lock, l.store.Lock(ctx, "lock:"+key, lock)
+}
+
+// LoginSucceeded сбрасывает счётчик неудачных входов
+func (l *Limiter) LoginSucceeded(ctx context.Context, account string) error {
+	key := accountKey(account)
+	return l.store.Delete(ctx, "failures:"+key, "lock:"+key)
+}
+
+// accountKey — хэш email или ID: персональные данные не попадают в ключи Redis
+func accountKey(account string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(account))))
+	return hex.EncodeToString(sum[:16])
+}
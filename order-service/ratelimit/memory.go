@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore хранит счётчики в памяти процесса: для тестов и запуска без Redis.
+// При нескольких экземплярах сервиса лимиты считаются отдельно в каждом.
+type MemoryStore struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	hits     map[string][]time.Time
+	counters map[string]memoryCounter
+	locks    map[string]time.Time
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:      time.Now,
+		hits:     map[string][]time.Time{},
+		counters: map[string]memoryCounter{},
+		locks:    map[string]time.Time{},
+	}
+}
+
+// WithClock заменяет источник времени: тесты сдвигают окна и блокировки, не дожидаясь их
+func (s *MemoryStore) WithClock(now func() time.Time) *MemoryStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+	return s
+}
+
+func (s *MemoryStore) Hit(_ context.Context, key string, limit int, window time.Duration) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	hits := s.hits[key]
+	// Отбрасываем запросы, вышедшие из окна
+	start := 0
+	for start < len(hits) && !hits[start].After(now.Add(-window)) {
+		start++
+	}
+	hits = hits[start:]
+
+	result := Result{Limit: limit}
+	if len(hits) < limit {
+		hits = append(hits, now)
+		result.Allowed = true
+	}
+	result.Remaining = limit - len(hits)
+	result.Reset = hits[0].Add(window).Sub(now)
+	if len(hits) == 0 {
+		delete(s.hits, key)
+	} else {
+		s.hits[key] = hits
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	counter := s.counters[key]
+	if !counter.expires.After(now) {
+		counter.value = 0
+	}
+	counter.value++
+	counter.expires = now.Add(ttl)
+	s.counters[key] = counter
+	return counter.value, nil
+}
+
+func (s *MemoryStore) Lock(_ context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks[key] = s.now().Add(d)
+	return nil
+}
+
+func (s *MemoryStore) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.locks[key]
+	if !ok {
+		return 0, nil
+	}
+	if left := until.Sub(s.now()); left > 0 {
+		return left, nil
+	}
+	delete(s.locks, key)
+	return 0, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.hits, key)
+		delete(s.counters, key)
+		delete(s.locks, key)
+	}
+	return nil
+}
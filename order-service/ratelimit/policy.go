@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Области, по которым считаются лимиты
+const (
+	ScopeIP      = "ip"      // адрес клиента
+	ScopeAccount = "account" // email из тела запроса или ID пользователя из токена
+)
+
+// Rule — не больше Limit запросов за скользящее окно Window на один ключ области Scope
+type Rule struct {
+	Scope  string
+	Limit  int
+	Window time.Duration
+}
+
+// Policy — правила маршрута; Lockout включает блокировку аккаунта после неудачных входов
+type Policy struct {
+	Route   string // метод и шаблон пути: "POST /login"
+	Rules   []Rule
+	Lockout bool
+}
+
+// HasScope сообщает, нужен ли политике ключ области scope
+func (p Policy) HasScope(scope string) bool {
+	for _, rule := range p.Rules {
+		if rule.Scope == scope {
+			return true
+		}
+	}
+	return p.Lockout && scope == ScopeAccount
+}
+
+// ParsePolicies разбирает политики вида
+// "POST /login=ip:20/1m,account:10/15m,lockout;POST /register=ip:5/1h"
+func ParsePolicies(spec string) (map[string]Policy, error) {
+	policies := map[string]Policy{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, rules, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("policy %q: expected \"METHOD /path=rules\"", entry)
+		}
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return nil, fmt.Errorf("policy %q: route must be \"METHOD /path\"", entry)
+		}
+		policy := Policy{Route: strings.ToUpper(method) + " " + strings.TrimSpace(path)}
+		if _, exists := policies[policy.Route]; exists {
+			return nil, fmt.Errorf("policy for %s is defined twice", policy.Route)
+		}
+
+		for _, item := range strings.Split(rules, ",") {
+			item = strings.TrimSpace(item)
+			if item == "lockout" {
+				policy.Lockout = true
+				continue
+			}
+			rule, err := parseRule(item)
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: %w", policy.Route, err)
+			}
+			policy.Rules = append(policy.Rules, rule)
+		}
+		policies[policy.Route] = policy
+	}
+	return policies, nil
+}
+
+// parseRule разбирает правило "scope:limit/window", например "ip:20/1m"
+func parseRule(s string) (Rule, error) {
+	scope, limitWindow, ok := strings.Cut(s, ":")
+	if !ok {
+		return Rule{}, fmt.Errorf("rule %q: expected scope:limit/window", s)
+	}
+	if scope != ScopeIP && scope != ScopeAccount {
+		return Rule{}, fmt.Errorf("rule %q: scope must be %s or %s", s, ScopeIP, ScopeAccount)
+	}
+	limitStr, windowStr, ok := strings.Cut(limitWindow, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("rule %q: expected scope:limit/window", s)
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return Rule{}, fmt.Errorf("rule %q: limit must be a positive integer", s)
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window < time.Second {
+		return Rule{}, fmt.Errorf("rule %q: window must be a duration of at least 1s", s)
+	}
+	return Rule{Scope: scope, Limit: limit, Window: window}, nil
+}
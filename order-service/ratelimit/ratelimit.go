@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result — итог проверки лимита для одного ключа
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Через сколько освободится место в окне: для RateLimit-Reset и Retry-After
+	Reset time.Duration
+}
+
+// Store хранит счётчики лимитов и блокировок. RedisStore используется в работе,
+// MemoryStore — в тестах и когда Redis не нужен.
+type Store interface {
+	// Hit учитывает запрос в скользящем окне window, если в нём меньше limit запросов
+	Hit(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+	// Incr увеличивает счётчик и продлевает его жизнь до ttl; возвращает новое значение
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Lock ставит блокировку key на время d
+	Lock(ctx context.Context, key string, d time.Duration) error
+	// LockedFor возвращает оставшееся время блокировки или 0
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// Delete удаляет счётчики и блокировки
+	Delete(ctx context.Context, keys ...string) error
+}
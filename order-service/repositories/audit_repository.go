@@ -0,0 +1,286 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// auditGenesisHash — prev_hash первой записи журнала
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// auditLockKey — ключ advisory-блокировки, под которой записи добавляются по одной:
+// иначе две параллельные записи сослались бы на один и тот же prev_hash
+const auditLockKey = 7_041_001
+
+const auditColumns = `id, occurred_at, actor_id, actor_role, action, entity_type, entity_id, changes,
+	request_id, ip, prev_hash, hash`
+
+// auditEntriesQuery выбирает записи с IP клиента: у новых записей он лежит в audit_log_ips,
+// у записей до её появления — в самом audit_log
+const auditEntriesQuery = `SELECT a.id, a.occurred_at, a.actor_id, a.actor_role, a.action, a.entity_type, a.entity_id,
+	a.changes, a.request_id, COALESCE(i.ip, a.ip), a.prev_hash, a.hash
+	FROM audit_log a LEFT JOIN audit_log_ips i ON i.entry_id = a.id`
+
+type AuditRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewAuditRepository(db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+// Append добавляет запись в конец журнала: проставляет время, prev_hash, hash и ID.
+// IP клиента сохраняется в audit_log_ips и в хэш не входит, чтобы его можно было удалить.
+func (r *AuditRepository) Append(ctx context.Context, entry *models.AuditEntry) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&entry.PrevHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		entry.PrevHash = auditGenesisHash
+	} else if err != nil {
+		return err
+	}
+
+	// Postgres хранит время с точностью до микросекунд: хэш считается от того же значения, что будет прочитано
+	entry.OccurredAt = time.Now().UTC().Truncate(time.Microsecond)
+	if len(entry.Changes) == 0 {
+		entry.Changes = json.RawMessage(`{}`)
+	}
+	ip := entry.IP
+	entry.IP = ""
+	if entry.Hash, err = auditHash(entry); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO audit_log (occurred_at, actor_id, actor_role, action, entity_type, entity_id, changes,
+			request_id, ip, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`,
+		entry.OccurredAt, entry.ActorID, entry.ActorRole, entry.Action, entry.EntityType, entry.EntityID,
+		string(entry.Changes), entry.RequestID, entry.IP, entry.PrevHash, entry.Hash).Scan(&entry.ID)
+	if err != nil {
+		logQueryError(ctx, "error inserting audit entry", err)
+		return err
+	}
+	if ip != "" {
+		if _, err := tx.Exec(ctx, `INSERT INTO audit_log_ips (entry_id, ip) VALUES ($1, $2)`, entry.ID, ip); err != nil {
+			logQueryError(ctx, "error inserting audit entry ip", err)
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	entry.IP = ip
+	return nil
+}
+
+// DeleteIPsBefore удаляет IP клиентов записей журнала, сделанных раньше cutoff; возвращает их число
+func (r *AuditRepository) DeleteIPsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := r.DB.Exec(ctx, `DELETE FROM audit_log_ips WHERE created_at < $1`, cutoff)
+	if err != nil {
+		logQueryError(ctx, "error deleting audit entry ips", err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteActorIPs удаляет IP клиента из всех записей журнала, сделанных пользователем
+func (r *AuditRepository) DeleteActorIPs(ctx context.Context, actorID string) error {
+	_, err := r.DB.Exec(ctx, `
+		DELETE FROM audit_log_ips
+		WHERE entry_id IN (SELECT id FROM audit_log WHERE actor_id = $1)`, actorID)
+	if err != nil {
+		logQueryError(ctx, "error deleting audit entry ips", err)
+	}
+	return err
+}
+
+// List возвращает записи по фильтру от новых к старым
+func (r *AuditRepository) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.ActorID != "" {
+		add("a.actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("a.action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		add("a.entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		add("a.entity_id = $%d", filter.EntityID)
+	}
+	if filter.RequestID != "" {
+		add("a.request_id = $%d", filter.RequestID)
+	}
+	if filter.From != nil {
+		add("a.occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("a.occurred_at < $%d", *filter.To)
+	}
+	if filter.BeforeID > 0 {
+		add("a.id < $%d", filter.BeforeID)
+	}
+
+	query := auditEntriesQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY a.id DESC LIMIT $%d", len(args))
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		logQueryError(ctx, "error querying audit log", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// ListBySubject возвращает записи о пользователе: его собственные действия и изменения его учётной записи
+func (r *AuditRepository) ListBySubject(ctx context.Context, userID string) ([]models.AuditEntry, error) {
+	rows, err := r.DB.Query(ctx, auditEntriesQuery+`
+		WHERE a.actor_id = $1 OR (a.entity_type = $2 AND a.entity_id = $1)
+		ORDER BY a.id`, userID, models.AuditEntityUser)
+	if err != nil {
+		logQueryError(ctx, "error querying audit log", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// Verify проходит журнал от первой записи и пересчитывает цепочку хэшей.
+// Проверка находит изменённые и удалённые из середины записи; чтобы заметить
+// удаление последних записей, сверяйте хэш головы с сохранённым ранее.
+func (r *AuditRepository) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	rows, err := r.DB.Query(ctx, "SELECT "+auditColumns+" FROM audit_log ORDER BY id")
+	if err != nil {
+		logQueryError(ctx, "error querying audit log", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &models.AuditVerification{Valid: true}
+	prevHash := auditGenesisHash
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Checked++
+
+		reason := ""
+		if entry.PrevHash != prevHash {
+			reason = "prev_hash does not match the previous entry"
+		} else if hash, err := auditHash(entry); err != nil {
+			return nil, err
+		} else if hash != entry.Hash {
+			reason = "entry content does not match its hash"
+		}
+		if reason != "" {
+			result.Valid = false
+			result.BrokenAt = &entry.ID
+			result.Reason = reason
+			return result, nil
+		}
+		prevHash = entry.Hash
+		result.Head = entry.Hash
+	}
+	return result, rows.Err()
+}
+
+func scanAuditEntry(rows pgx.Rows) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var changes []byte
+	err := rows.Scan(&entry.ID, &entry.OccurredAt, &entry.ActorID, &entry.ActorRole, &entry.Action,
+		&entry.EntityType, &entry.EntityID, &changes, &entry.RequestID, &entry.IP, &entry.PrevHash, &entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	entry.Changes = changes
+	return &entry, nil
+}
+
+// auditHash — SHA-256 от prev_hash и содержимого записи в каноническом JSON.
+// JSONB не сохраняет порядок ключей и пробелы, поэтому changes перед хэшированием
+// приводятся к виду encoding/json: ключи по алфавиту, без пробелов.
+func auditHash(entry *models.AuditEntry) (string, error) {
+	var changes any
+	if err := json.Unmarshal(entry.Changes, &changes); err != nil {
+		return "", fmt.Errorf("audit changes: %w", err)
+	}
+	payload, err := json.Marshal(struct {
+		PrevHash   string `json:"prev_hash"`
+		OccurredAt string `json:"occurred_at"`
+		ActorID    string `json:"actor_id"`
+		ActorRole  string `json:"actor_role"`
+		Action     string `json:"action"`
+		EntityType string `json:"entity_type"`
+		EntityID   string `json:"entity_id"`
+		Changes    any    `json:"changes"`
+		RequestID  string `json:"request_id"`
+		IP         string `json:"ip"`
+	}{
+		PrevHash:   entry.PrevHash,
+		OccurredAt: entry.OccurredAt.UTC().Format(time.RFC3339Nano),
+		ActorID:    entry.ActorID,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   entry.EntityID,
+		Changes:    changes,
+		RequestID:  entry.RequestID,
+		IP:         entry.IP,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"order-service/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrCategoryNotFound — категория с таким slug не существует
+var ErrCategoryNotFound = models.NewNotFound("category_not_found", "category not found")
+
+// ErrCategoryExists — slug категории уже занят
+var ErrCategoryExists = models.NewConflict("category_exists", "category with this slug already exists")
+
+type CategoryRepository struct {
+	db *mongo.Database
+}
+
+func NewCategoryRepository(db *mongo.Database) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// EnsureIndexes создаёт уникальный индекс по slug
+func (r *CategoryRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("categories").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "path", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create category indexes: %w", err)
+	}
+	return nil
+}
+
+func (r *CategoryRepository) CreateCategory(ctx context.Context, category *models.Category) error {
+	category.CreatedAt = time.Now()
+	_, err := r.db.Collection("categories").InsertOne(ctx, category)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCategoryExists
+	}
+	return err
+}
+
+func (r *CategoryRepository) GetCategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	var category models.Category
+	err := r.db.Collection("categories").FindOne(ctx, bson.M{"slug": slug}).Decode(&category)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetAllCategories возвращает все категории, упорядоченные по имени
+func (r *CategoryRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	return r.find(ctx, bson.M{})
+}
+
+// GetDescendants возвращает все подкатегории (на любой глубине)
+func (r *CategoryRepository) GetDescendants(ctx context.Context, slug string) ([]models.Category, error) {
+	return r.find(ctx, bson.M{"path": slug, "slug": bson.M{"$ne": slug}})
+}
+
+// UpdateCategory обновляет имя, родителя и путь категории
+func (r *CategoryRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	_, err := r.db.Collection("categories").UpdateOne(ctx,
+		bson.M{"slug": category.Slug},
+		bson.M{"$set": bson.M{
+			"name":   category.Name,
+			"parent": category.Parent,
+			"path":   category.Path,
+		}})
+	return err
+}
+
+func (r *CategoryRepository) DeleteCategory(ctx context.Context, slug string) error {
+	result, err := r.db.Collection("categories").DeleteOne(ctx, bson.M{"slug": slug})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (r *CategoryRepository) find(ctx context.Context, filter bson.M) ([]models.Category, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := r.db.Collection("categories").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	categories := []models.Category{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
@@ -2,30 +2,51 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/jackc/pgx/v5"
-	_ "github.com/jackc/pgx/v5"
-	"log"
+	"log/slog"
 	"order-service/config"
+	"order-service/logging"
+	"order-service/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func ConnectDB(cfg *config.Config) (*pgx.Conn, error) {
-	// Формируем строку подключения (DSN)
-	dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s",
-		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
-	log.Println("Connecting to database with DSN:", dsn)
+var logger = logging.For("repositories")
 
-	// Создаем соединение с базой данных
-	conn, err := pgx.Connect(context.Background(), dsn)
+func ConnectDB(cfg *config.Config) (*pgxpool.Pool, error) {
+	// Пароль в лог не попадает
+	logger.Info("Connecting to database", "host", cfg.Postgres.Host, "port", cfg.Postgres.Port, "database", cfg.Postgres.Name, "user", cfg.Postgres.User)
+
+	poolConfig, err := pgxpool.ParseConfig(cfg.Postgres.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+	poolConfig.MaxConns = cfg.Postgres.MaxConns
+	poolConfig.ConnConfig.Tracer = tracing.PgxTracer{}
+
+	// Пул соединений: обработчики запросов работают с базой параллельно
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to database: %w", err)
 	}
 
 	// Проверяем подключение
-	if err := conn.Ping(context.Background()); err != nil {
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("unable to ping database: %w", err)
 	}
-	
-	log.Println("Successfully connected to database!")
-	return conn, nil
+
+	logger.Info("Successfully connected to database")
+	return pool, nil
+}
+
+// logQueryError логирует ошибку запроса; отсутствие строки — обычная ситуация (404), а не сбой
+func logQueryError(ctx context.Context, msg string, err error) {
+	level := slog.LevelError
+	if errors.Is(err, pgx.ErrNoRows) {
+		level = slog.LevelDebug
+	}
+	logger.Log(ctx, level, msg, "error", err)
 }
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"errors"
+	"order-service/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Ошибки поиска записей по ID
+var (
+	ErrUserNotFound   = models.NewNotFound("user_not_found", "user not found")
+	ErrOrderNotFound  = models.NewNotFound("order_not_found", "order not found")
+	ErrReturnNotFound = models.NewNotFound("return_not_found", "return not found")
+	ErrRefundNotFound = models.NewNotFound("refund_not_found", "refund not found")
+)
+
+// ErrVersionMismatch — запись изменилась после того, как клиент получил её версию
+var ErrVersionMismatch = models.NewPreconditionFailed("version_mismatch", "resource has been modified; reload it and retry")
+
+// ErrUserExists — email или имя пользователя уже заняты
+var ErrUserExists = models.NewConflict("user_exists", "user with this email or username already exists")
+
+// notFound заменяет ошибку «строка не найдена» на типизированную ошибку target.
+// ID с некорректным для UUID-колонки форматом тоже означают отсутствие записи.
+func notFound(err, target error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return target
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "22P02" { // invalid_text_representation
+		return target
+	}
+	return err
+}
+
+// foreignKeyViolation заменяет нарушение внешнего ключа на ошибку target
+func foreignKeyViolation(err, target error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return target
+	}
+	return err
+}
+
+// uniqueViolation заменяет нарушение уникального ограничения на ошибку target
+func uniqueViolation(err, target error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return target
+	}
+	return err
+}
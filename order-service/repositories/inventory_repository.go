@@ -0,0 +1,495 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrWarehouseNotFound — склада с таким ID или кодом нет
+	ErrWarehouseNotFound = models.NewNotFound("warehouse_not_found", "warehouse not found")
+	// ErrWarehouseExists — код склада уже занят
+	ErrWarehouseExists = models.NewConflict("warehouse_exists", "warehouse with this code already exists")
+	// ErrInsufficientStock — доступного остатка не хватает для резерва или списания
+	ErrInsufficientStock = models.NewConflict("insufficient_stock", "not enough stock available")
+)
+
+const warehouseColumns = `id, code, name, priority, active, version, created_at, updated_at`
+
+const movementColumns = `id, warehouse_id, sku, type, quantity, COALESCE(order_id::text, ''), reason,
+	COALESCE(actor_id::text, ''), created_at`
+
+// StockAllocator распределяет позиции по складам; levels — остатки SKU позиций на активных складах
+// в порядке приоритета складов
+type StockAllocator func(lines []models.StockLine, levels []models.StockLevel) ([]models.StockAllocation, error)
+
+// StockImportResult — итог переноса остатков каталога в журнал движений
+type StockImportResult struct {
+	SKUs    int // сколько SKU получили поступление
+	Units   int // сколько единиц оприходовано
+	Skipped int // у скольких SKU журнал уже вёлся
+}
+
+type InventoryRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewInventoryRepository(db *pgxpool.Pool) *InventoryRepository {
+	return &InventoryRepository{DB: db}
+}
+
+func (r *InventoryRepository) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	warehouse.ID = uuid.New().String()
+	warehouse.Version = 1
+	err := r.DB.QueryRow(ctx, `
+		INSERT INTO warehouses (id, code, name, priority, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, updated_at`,
+		warehouse.ID, warehouse.Code, warehouse.Name, warehouse.Priority, warehouse.Active).
+		Scan(&warehouse.CreatedAt, &warehouse.UpdatedAt)
+	if err != nil {
+		logQueryError(ctx, "error inserting warehouse", err)
+		return uniqueViolation(err, ErrWarehouseExists)
+	}
+	return nil
+}
+
+func (r *InventoryRepository) GetWarehouse(ctx context.Context, id string) (*models.Warehouse, error) {
+	warehouse, err := scanWarehouse(r.DB.QueryRow(ctx, `SELECT `+warehouseColumns+` FROM warehouses WHERE id = $1`, id))
+	if err != nil {
+		logQueryError(ctx, "error getting warehouse", err)
+		return nil, notFound(err, ErrWarehouseNotFound)
+	}
+	return warehouse, nil
+}
+
+func (r *InventoryRepository) GetWarehouseByCode(ctx context.Context, code string) (*models.Warehouse, error) {
+	warehouse, err := scanWarehouse(r.DB.QueryRow(ctx, `SELECT `+warehouseColumns+` FROM warehouses WHERE code = $1`, code))
+	if err != nil {
+		logQueryError(ctx, "error getting warehouse by code", err)
+		return nil, notFound(err, ErrWarehouseNotFound)
+	}
+	return warehouse, nil
+}
+
+// ListWarehouses возвращает склады в порядке приоритета
+func (r *InventoryRepository) ListWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	rows, err := r.DB.Query(ctx, `SELECT `+warehouseColumns+` FROM warehouses ORDER BY priority, code`)
+	if err != nil {
+		logQueryError(ctx, "error listing warehouses", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	warehouses := []models.Warehouse{}
+	for rows.Next() {
+		warehouse, err := scanWarehouse(rows)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, *warehouse)
+	}
+	return warehouses, rows.Err()
+}
+
+// UpdateWarehouse сохраняет склад, если его версия равна warehouse.Version, и увеличивает версию
+func (r *InventoryRepository) UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	err := r.DB.QueryRow(ctx, `
+		UPDATE warehouses
+		SET code = $1, name = $2, priority = $3, active = $4, updated_at = $5, version = version + 1
+		WHERE id = $6 AND version = $7
+		RETURNING version, updated_at`,
+		warehouse.Code, warehouse.Name, warehouse.Priority, warehouse.Active, time.Now(), warehouse.ID, warehouse.Version).
+		Scan(&warehouse.Version, &warehouse.UpdatedAt)
+	if err != nil {
+		logQueryError(ctx, "error updating warehouse", err)
+		return uniqueViolation(notFound(err, ErrVersionMismatch), ErrWarehouseExists)
+	}
+	return nil
+}
+
+// GetStockLevels возвращает остатки SKU из skus на всех складах, где по ним были движения,
+// в порядке приоритета складов
+func (r *InventoryRepository) GetStockLevels(ctx context.Context, skus []string) ([]models.StockLevel, error) {
+	return queryStockLevels(ctx, r.DB, skus, false)
+}
+
+// GetAvailable возвращает доступный остаток SKU из skus, суммарно по активным складам
+func (r *InventoryRepository) GetAvailable(ctx context.Context, skus []string) (map[string]int, error) {
+	levels, err := queryStockLevels(ctx, r.DB, skus, true)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[string]int, len(skus))
+	for _, sku := range skus {
+		available[sku] = 0
+	}
+	for _, level := range levels {
+		available[level.SKU] += level.Available
+	}
+	return available, nil
+}
+
+// GetWarehouseSKUs возвращает SKU, по которым были движения на складе
+func (r *InventoryRepository) GetWarehouseSKUs(ctx context.Context, warehouseID string) ([]string, error) {
+	rows, err := r.DB.Query(ctx, `SELECT DISTINCT sku FROM stock_movements WHERE warehouse_id = $1 ORDER BY sku`, warehouseID)
+	if err != nil {
+		logQueryError(ctx, "error getting warehouse skus", err)
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// ListMovements возвращает движения по фильтру от новых к старым
+func (r *InventoryRepository) ListMovements(ctx context.Context, filter models.StockMovementFilter) ([]models.StockMovement, error) {
+	conditions := []string{"TRUE"}
+	args := []any{}
+	for _, c := range []struct {
+		column, value string
+	}{
+		{"sku", filter.SKU},
+		{"warehouse_id::text", filter.WarehouseID},
+		{"order_id::text", filter.OrderID},
+		{"type", filter.Type},
+	} {
+		if c.value != "" {
+			args = append(args, c.value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", c.column, len(args)))
+		}
+	}
+	if filter.BeforeID > 0 {
+		args = append(args, filter.BeforeID)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`SELECT %s FROM stock_movements WHERE %s ORDER BY id DESC LIMIT $%d`,
+		movementColumns, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		logQueryError(ctx, "error listing stock movements", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var m models.StockMovement
+		if err := rows.Scan(movementFields(&m)...); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+// RecordMovement записывает поступление или корректировку. Корректировка в минус не может
+// опустить остаток склада ниже уже зарезервированного — иначе ErrInsufficientStock.
+func (r *InventoryRepository) RecordMovement(ctx context.Context, movement *models.StockMovement) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockSKUs(ctx, tx, []string{movement.SKU}); err != nil {
+		return err
+	}
+	if movement.Quantity < 0 {
+		var available int
+		err := tx.QueryRow(ctx, `SELECT COALESCE(SUM(available), 0) FROM stock_levels WHERE warehouse_id = $1 AND sku = $2`,
+			movement.WarehouseID, movement.SKU).Scan(&available)
+		if err != nil {
+			logQueryError(ctx, "error getting stock level", err)
+			return err
+		}
+		if available+movement.Quantity < 0 {
+			return fmt.Errorf("%w: %s has %d available", ErrInsufficientStock, movement.SKU, available)
+		}
+	}
+	if err := insertStockMovement(ctx, tx, movement); err != nil {
+		return foreignKeyViolation(err, ErrWarehouseNotFound)
+	}
+	return tx.Commit(ctx)
+}
+
+// Reserve резервирует позиции заказа orderID на складах, выбранных allocate, одной транзакцией.
+// На время выбора остатки SKU позиций заблокированы, поэтому два заказа не займут одни и те же единицы.
+func (r *InventoryRepository) Reserve(ctx context.Context, orderID string, lines []models.StockLine, actorID string, allocate StockAllocator) ([]models.StockAllocation, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	skus := make([]string, 0, len(lines))
+	for _, line := range lines {
+		skus = append(skus, line.SKU)
+	}
+	if err := lockSKUs(ctx, tx, skus); err != nil {
+		return nil, err
+	}
+	levels, err := queryStockLevels(ctx, tx, skus, true)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := allocate(lines, levels)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, allocation := range allocations {
+		err := insertStockMovement(ctx, tx, &models.StockMovement{
+			WarehouseID: allocation.WarehouseID,
+			SKU:         allocation.SKU,
+			Type:        models.StockReservation,
+			Quantity:    allocation.Quantity,
+			OrderID:     orderID,
+			Reason:      "order " + orderID,
+			ActorID:     actorID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// ReleaseOrder снимает весь ещё не отгруженный резерв заказа; возвращает записанные движения
+func (r *InventoryRepository) ReleaseOrder(ctx context.Context, orderID, reason, actorID string) ([]models.StockMovement, error) {
+	return r.settleReservations(ctx, orderID, models.StockRelease, reason, actorID)
+}
+
+// ShipOrder списывает со складов весь ещё не отгруженный резерв заказа; возвращает записанные движения
+func (r *InventoryRepository) ShipOrder(ctx context.Context, orderID, reason, actorID string) ([]models.StockMovement, error) {
+	return r.settleReservations(ctx, orderID, models.StockShipment, reason, actorID)
+}
+
+// settleReservations закрывает остаток резерва заказа движениями типа movementType.
+// Повторный вызов ничего не записывает: резерва уже нет.
+func (r *InventoryRepository) settleReservations(ctx context.Context, orderID, movementType, reason, actorID string) ([]models.StockMovement, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var skus []string
+	err = tx.QueryRow(ctx, `SELECT COALESCE(array_agg(DISTINCT sku), '{}') FROM stock_movements WHERE order_id = $1`, orderID).Scan(&skus)
+	if err != nil {
+		logQueryError(ctx, "error getting order stock movements", err)
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+	if len(skus) == 0 {
+		return []models.StockMovement{}, nil
+	}
+	if err := lockSKUs(ctx, tx, skus); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT warehouse_id, sku, SUM(CASE type
+			WHEN 'reservation' THEN quantity
+			WHEN 'release' THEN -quantity
+			WHEN 'shipment' THEN -quantity
+			ELSE 0 END)::INT AS reserved
+		FROM stock_movements
+		WHERE order_id = $1
+		GROUP BY warehouse_id, sku
+		ORDER BY sku, warehouse_id`, orderID)
+	if err != nil {
+		logQueryError(ctx, "error getting order reservations", err)
+		return nil, err
+	}
+	movements, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.StockMovement, error) {
+		m := models.StockMovement{Type: movementType, OrderID: orderID, Reason: reason, ActorID: actorID}
+		err := row.Scan(&m.WarehouseID, &m.SKU, &m.Quantity)
+		return m, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := []models.StockMovement{}
+	for _, m := range movements {
+		if m.Quantity <= 0 {
+			continue
+		}
+		if err := insertStockMovement(ctx, tx, &m); err != nil {
+			return nil, err
+		}
+		recorded = append(recorded, m)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
+
+// Restock приходует каждую возвращённую позицию целиком на склад, с которого по заказу отгружено
+// больше всего единиц её SKU (при равенстве — с меньшим ID); если отгрузок SKU не было — на fallbackWarehouseID
+func (r *InventoryRepository) Restock(ctx context.Context, orderID string, lines []models.StockLine, fallbackWarehouseID, reason, actorID string) ([]models.StockMovement, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	movements := make([]models.StockMovement, 0, len(lines))
+	for _, line := range lines {
+		m := models.StockMovement{
+			WarehouseID: fallbackWarehouseID,
+			SKU:         line.SKU,
+			Type:        models.StockReceipt,
+			Quantity:    line.Quantity,
+			OrderID:     orderID,
+			Reason:      reason,
+			ActorID:     actorID,
+		}
+		err := tx.QueryRow(ctx, `
+			SELECT warehouse_id FROM stock_movements
+			WHERE order_id = $1 AND sku = $2 AND type = 'shipment'
+			GROUP BY warehouse_id
+			ORDER BY SUM(quantity) DESC, warehouse_id
+			LIMIT 1`, orderID, line.SKU).Scan(&m.WarehouseID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			logQueryError(ctx, "error getting shipping warehouse", err)
+			return nil, err
+		}
+		if err := insertStockMovement(ctx, tx, &m); err != nil {
+			return nil, foreignKeyViolation(err, ErrWarehouseNotFound)
+		}
+		movements = append(movements, m)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+// ImportStock приходует на склад warehouseID остатки stock тех SKU, по которым ещё нет движений.
+// Повторный запуск ничего не меняет.
+func (r *InventoryRepository) ImportStock(ctx context.Context, warehouseID string, stock map[string]int, reason, actorID string) (*StockImportResult, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	skus := make([]string, 0, len(stock))
+	for sku := range stock {
+		skus = append(skus, sku)
+	}
+	if err := lockSKUs(ctx, tx, skus); err != nil {
+		return nil, err
+	}
+
+	result := &StockImportResult{}
+	for _, sku := range slices.Sorted(slices.Values(skus)) {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM stock_movements WHERE sku = $1)`, sku).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists || stock[sku] <= 0 {
+			result.Skipped++
+			continue
+		}
+		err := insertStockMovement(ctx, tx, &models.StockMovement{
+			WarehouseID: warehouseID,
+			SKU:         sku,
+			Type:        models.StockReceipt,
+			Quantity:    stock[sku],
+			Reason:      reason,
+			ActorID:     actorID,
+		})
+		if err != nil {
+			return nil, foreignKeyViolation(err, ErrWarehouseNotFound)
+		}
+		result.SKUs++
+		result.Units += stock[sku]
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// lockSKUs блокирует остатки SKU до конца транзакции. Блокировки берутся в одном порядке,
+// чтобы транзакции с пересекающимися SKU не ждали друг друга по кругу.
+func lockSKUs(ctx context.Context, tx pgx.Tx, skus []string) error {
+	sorted := slices.Compact(slices.Sorted(slices.Values(skus)))
+	for _, sku := range sorted {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended('stock:' || $1::text, 0))`, sku); err != nil {
+			logQueryError(ctx, "error locking stock", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// queryStockLevels читает остатки SKU по складам; activeOnly — только активные склады
+func queryStockLevels(ctx context.Context, q querier, skus []string, activeOnly bool) ([]models.StockLevel, error) {
+	query := `
+		SELECT l.warehouse_id, w.code, l.sku, l.on_hand, l.reserved, l.available, w.priority, w.active
+		FROM stock_levels l
+		JOIN warehouses w ON w.id = l.warehouse_id
+		WHERE l.sku = ANY($1)`
+	if activeOnly {
+		query += ` AND w.active`
+	}
+	rows, err := q.Query(ctx, query+` ORDER BY w.priority, w.code, l.sku`, skus)
+	if err != nil {
+		logQueryError(ctx, "error getting stock levels", err)
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.StockLevel, error) {
+		var l models.StockLevel
+		err := row.Scan(&l.WarehouseID, &l.WarehouseCode, &l.SKU, &l.OnHand, &l.Reserved, &l.Available, &l.Priority, &l.Active)
+		return l, err
+	})
+}
+
+func insertStockMovement(ctx context.Context, tx pgx.Tx, m *models.StockMovement) error {
+	var orderID, actorID *string
+	if m.OrderID != "" {
+		orderID = &m.OrderID
+	}
+	if m.ActorID != "" {
+		actorID = &m.ActorID
+	}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO stock_movements (warehouse_id, sku, type, quantity, order_id, reason, actor_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		m.WarehouseID, m.SKU, m.Type, m.Quantity, orderID, m.Reason, actorID).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		logQueryError(ctx, "error inserting stock movement", err)
+	}
+	return err
+}
+
+func scanWarehouse(row pgx.Row) (*models.Warehouse, error) {
+	var w models.Warehouse
+	err := row.Scan(&w.ID, &w.Code, &w.Name, &w.Priority, &w.Active, &w.Version, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// movementFields — адреса полей движения в порядке movementColumns
+func movementFields(m *models.StockMovement) []any {
+	return []any{&m.ID, &m.WarehouseID, &m.SKU, &m.Type, &m.Quantity, &m.OrderID, &m.Reason, &m.ActorID, &m.CreatedAt}
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrInvoiceExists — документ для заказа или возврата уже выпущен параллельным запросом
+var ErrInvoiceExists = models.NewConflict("document_exists", "document has already been issued")
+
+// Префиксы номеров документов
+var invoiceNumberPrefixes = map[string]string{
+	models.DocTypeInvoice:    "INV",
+	models.DocTypeCreditNote: "CN",
+}
+
+type InvoiceRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewInvoiceRepository(db *pgxpool.Pool) *InvoiceRepository {
+	return &InvoiceRepository{DB: db}
+}
+
+const invoiceColumns = `id, doc_type, sequence, number, order_id, refund_id, data, sha256, issued_at`
+
+// IssueInvoice присваивает документу следующий номер, отрисовывает его через render
+// и сохраняет вместе с PDF и его хэшем в одной транзакции. Счётчик увеличивается под блокировкой
+// строки и откатывается вместе с документом, поэтому нумерация идёт без пропусков.
+func (r *InvoiceRepository) IssueInvoice(ctx context.Context, invoice *models.Invoice, render func(*models.Invoice) ([]byte, error)) ([]byte, error) {
+	prefix, ok := invoiceNumberPrefixes[invoice.DocType]
+	if !ok {
+		return nil, fmt.Errorf("unknown document type %q", invoice.DocType)
+	}
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE document_sequences
+		SET last_number = last_number + 1
+		WHERE doc_type = $1
+		RETURNING last_number`, invoice.DocType).Scan(&invoice.Sequence)
+	if err != nil {
+		logQueryError(ctx, "error allocating document number", err)
+		return nil, err
+	}
+	invoice.Number = fmt.Sprintf("%s-%06d", prefix, invoice.Sequence)
+
+	pdf, err := render(invoice)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(pdf)
+	invoice.SHA256 = hex.EncodeToString(sum[:])
+
+	data, err := json.Marshal(invoice.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO invoices (doc_type, sequence, number, order_id, refund_id, data, sha256, issued_at, pdf)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`,
+		invoice.DocType, invoice.Sequence, invoice.Number, invoice.OrderID, invoice.RefundID,
+		data, invoice.SHA256, invoice.IssuedAt, pdf).Scan(&invoice.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrInvoiceExists
+		}
+		logQueryError(ctx, "error inserting invoice", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
+
+// GetPDF возвращает сохранённый PDF документа; nil — документ выпущен до того, как PDF стали храниться
+func (r *InvoiceRepository) GetPDF(ctx context.Context, id string) ([]byte, error) {
+	var pdf []byte
+	if err := r.DB.QueryRow(ctx, `SELECT pdf FROM invoices WHERE id = $1`, id).Scan(&pdf); err != nil {
+		logQueryError(ctx, "error querying document pdf", err)
+		return nil, err
+	}
+	return pdf, nil
+}
+
+// SavePDF сохраняет PDF документа, выпущенного до того, как PDF стали храниться
+func (r *InvoiceRepository) SavePDF(ctx context.Context, id string, pdf []byte) error {
+	_, err := r.DB.Exec(ctx, `UPDATE invoices SET pdf = $2 WHERE id = $1 AND pdf IS NULL`, id, pdf)
+	if err != nil {
+		logQueryError(ctx, "error saving document pdf", err)
+	}
+	return err
+}
+
+// GetInvoiceByOrderID возвращает счёт по заказу или nil, если он ещё не выпущен
+func (r *InvoiceRepository) GetInvoiceByOrderID(ctx context.Context, orderID string) (*models.Invoice, error) {
+	return r.getInvoice(ctx, "SELECT "+invoiceColumns+" FROM invoices WHERE order_id = $1 AND doc_type = $2",
+		orderID, models.DocTypeInvoice)
+}
+
+// GetCreditNoteByRefundID возвращает кредит-ноту по возврату средств или nil
+func (r *InvoiceRepository) GetCreditNoteByRefundID(ctx context.Context, refundID string) (*models.Invoice, error) {
+	return r.getInvoice(ctx, "SELECT "+invoiceColumns+" FROM invoices WHERE refund_id = $1 AND doc_type = $2",
+		refundID, models.DocTypeCreditNote)
+}
+
+func (r *InvoiceRepository) getInvoice(ctx context.Context, query string, args ...any) (*models.Invoice, error) {
+	var invoice models.Invoice
+	var data []byte
+
+	err := r.DB.QueryRow(ctx, query, args...).Scan(
+		&invoice.ID, &invoice.DocType, &invoice.Sequence, &invoice.Number, &invoice.OrderID,
+		&invoice.RefundID, &data, &invoice.SHA256, &invoice.IssuedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &invoice.Data); err != nil {
+		return nil, fmt.Errorf("invalid data of document %s: %w", invoice.Number, err)
+	}
+	return &invoice, nil
+}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
-	_ "time"
+	"order-service/config"
+	"order-service/metrics"
+	"order-service/tracing"
 )
 
 type MongoDBRepository struct {
@@ -14,19 +15,22 @@ type MongoDBRepository struct {
 	DB     *mongo.Database
 }
 
-func NewMongoDBRepository(uri string, dbName string) (*MongoDBRepository, error) {
-	clientOptions := options.Client().ApplyURI(uri)
+func NewMongoDBRepository(cfg config.MongoConfig) (*MongoDBRepository, error) {
+	clientOptions := options.Client().
+		ApplyURI(cfg.URI).
+		SetConnectTimeout(cfg.Timeout).
+		SetServerSelectionTimeout(cfg.Timeout).
+		SetMonitor(tracing.MongoCommandMonitor(metrics.MongoCommandMonitor()))
 
 	client, err := mongo.Connect(context.Background(), clientOptions)
 	if err != nil {
-		log.Fatalf("Error connecting to MongoDB: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
 	if err := client.Ping(context.Background(), nil); err != nil {
 		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
-	db := client.Database(dbName)
-	log.Println("Successfully connected to MongoDB!")
+	db := client.Database(cfg.Database)
+	logger.Info("Successfully connected to MongoDB", "database", cfg.Database)
 
 	return &MongoDBRepository{Client: client, DB: db}, nil
 }
@@ -0,0 +1,404 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"order-service/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// OrderSnapshotInterval — через сколько событий после предыдущего снимка сохраняется новый;
+// задаётся из настроек при запуске
+var OrderSnapshotInterval = 20
+
+// querier — пул соединений или открытая транзакция
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// orderProjection поддерживает модель чтения, построенную по журналу событий заказов.
+// Project вызывается в транзакции, добавившей событие; before — состояние заказа до события (nil для первого).
+type orderProjection interface {
+	Project(ctx context.Context, tx pgx.Tx, event *models.OrderEvent, before, after *models.Order) error
+	// Reset очищает модель перед пересборкой из журнала
+	Reset(ctx context.Context, tx pgx.Tx) error
+}
+
+// orderProjections обновляются при каждом событии в указанном порядке
+var orderProjections = []orderProjection{ordersProjection{}, userSummaryProjection{}}
+
+// loadOrder восстанавливает заказ из последнего снимка и событий после него.
+// pending — число событий после снимка; ErrOrderNotFound — у заказа нет событий.
+func loadOrder(ctx context.Context, q querier, id string) (order *models.Order, pending int, err error) {
+	var after int64
+	var state []byte
+	err = q.QueryRow(ctx, "SELECT version, state FROM order_snapshots WHERE order_id = $1", id).Scan(&after, &state)
+	switch {
+	case err == nil:
+		order = &models.Order{}
+		if err := json.Unmarshal(state, order); err != nil {
+			return nil, 0, err
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		logQueryError(ctx, "error getting order snapshot", err)
+		return nil, 0, notFound(err, ErrOrderNotFound)
+	}
+
+	events, err := queryOrderEvents(ctx, q, id, after)
+	if err != nil {
+		return nil, 0, notFound(err, ErrOrderNotFound)
+	}
+	if order == nil && len(events) == 0 {
+		return nil, 0, ErrOrderNotFound
+	}
+	if order == nil {
+		order = &models.Order{}
+	}
+	for i := range events {
+		if err := order.Apply(&events[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return order, len(events), nil
+}
+
+// queryOrderEvents возвращает события заказа с версией больше after по порядку
+func queryOrderEvents(ctx context.Context, q querier, id string, after int64) ([]models.OrderEvent, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, order_id, version, type, data, COALESCE(actor_id::text, ''), occurred_at
+		FROM order_events
+		WHERE order_id = $1 AND version > $2
+		ORDER BY version`, id, after)
+	if err != nil {
+		logQueryError(ctx, "error getting order events", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.OrderEvent{}
+	for rows.Next() {
+		var event models.OrderEvent
+		if err := rows.Scan(&event.ID, &event.OrderID, &event.Version, &event.Type, &event.Data, &event.ActorID, &event.OccurredAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// appendOrderEvent добавляет событие следующей после current версии, обновляет проекции
+// и при необходимости сохраняет снимок. Возвращает состояние заказа после события.
+// Если поток успел получить событие этой версии, возвращает ErrVersionMismatch.
+func appendOrderEvent(ctx context.Context, tx pgx.Tx, current *models.Order, pending int, event *models.OrderEvent) (*models.Order, error) {
+	after := &models.Order{}
+	event.Version = 1
+	if current != nil {
+		copied := *current
+		after = &copied
+		event.Version = current.Version + 1
+	}
+	event.OccurredAt = time.Now()
+	if err := after.Apply(event); err != nil {
+		return nil, err
+	}
+
+	var actor *string
+	if event.ActorID != "" {
+		actor = &event.ActorID
+	}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO order_events (order_id, version, type, data, actor_id, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		event.OrderID, event.Version, event.Type, event.Data, actor, event.OccurredAt).Scan(&event.ID)
+	if err != nil {
+		logQueryError(ctx, "error appending order event", err)
+		return nil, uniqueViolation(err, ErrVersionMismatch)
+	}
+
+	for _, projection := range orderProjections {
+		if err := projection.Project(ctx, tx, event, current, after); err != nil {
+			return nil, err
+		}
+	}
+
+	if pending+1 >= OrderSnapshotInterval {
+		if err := saveOrderSnapshot(ctx, tx, after); err != nil {
+			return nil, err
+		}
+	}
+	return after, nil
+}
+
+// saveOrderSnapshot сохраняет состояние заказа; более старый снимок новый не заменяет
+func saveOrderSnapshot(ctx context.Context, tx pgx.Tx, order *models.Order) error {
+	state, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO order_snapshots (order_id, version, state)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (order_id) DO UPDATE
+		SET version = EXCLUDED.version, state = EXCLUDED.state, created_at = NOW()
+		WHERE order_snapshots.version < EXCLUDED.version`,
+		order.ID, order.Version, state)
+	if err != nil {
+		logQueryError(ctx, "error saving order snapshot", err)
+	}
+	return err
+}
+
+// ordersProjection поддерживает таблицы orders и order_items
+type ordersProjection struct{}
+
+func (ordersProjection) Project(ctx context.Context, tx pgx.Tx, event *models.OrderEvent, before, after *models.Order) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO orders (id, user_id, total_price, status, version, created_at, updated_at, deleted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE
+		SET user_id = EXCLUDED.user_id, total_price = EXCLUDED.total_price, status = EXCLUDED.status,
+			version = EXCLUDED.version, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at,
+			deleted_at = EXCLUDED.deleted_at`,
+		after.ID, after.UserID, after.TotalPrice, after.Status, after.Version, after.CreatedAt, after.UpdatedAt, after.DeletedAt)
+	if err != nil {
+		logQueryError(ctx, "error projecting order", err)
+		return foreignKeyViolation(err, ErrOrderUserNotFound)
+	}
+
+	// Позиции задаются только при создании заказа
+	if event.Type != models.OrderEventCreated && event.Type != models.OrderEventImported {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM order_items WHERE order_id = $1", after.ID); err != nil {
+		logQueryError(ctx, "error clearing order items", err)
+		return err
+	}
+	for _, item := range after.Items {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO order_items (order_id, product_id, sku, quantity, price)
+			VALUES ($1, $2, $3, $4, $5)`,
+			after.ID, item.ProductID, item.SKU, item.Quantity, item.Price)
+		if err != nil {
+			logQueryError(ctx, "error inserting order item", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset очищает orders и order_items. Ссылки на заказы из платежей, возвратов и счетов
+// проверяются при фиксации транзакции пересборки, когда заказы уже восстановлены из журнала.
+func (ordersProjection) Reset(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, "SET CONSTRAINTS ALL DEFERRED"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM order_items"); err != nil {
+		logQueryError(ctx, "error clearing order items", err)
+		return err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM orders"); err != nil {
+		logQueryError(ctx, "error clearing orders", err)
+		return err
+	}
+	return nil
+}
+
+// userSummaryProjection поддерживает order_user_summaries: вычитает вклад заказа до события
+// и добавляет вклад после, поэтому смена владельца переносит заказ между сводками
+type userSummaryProjection struct{}
+
+// summaryContribution — вклад заказа в сводку его владельца
+type summaryContribution struct {
+	orders, cancelled int
+	spent             float64
+}
+
+func contribution(order *models.Order) summaryContribution {
+	if order == nil || order.DeletedAt != nil || order.UserID == "" {
+		return summaryContribution{}
+	}
+	if order.Status == models.OrderStatusCancelled {
+		return summaryContribution{orders: 1, cancelled: 1}
+	}
+	return summaryContribution{orders: 1, spent: order.TotalPrice}
+}
+
+func (c summaryContribution) minus(other summaryContribution) summaryContribution {
+	return summaryContribution{c.orders - other.orders, c.cancelled - other.cancelled, c.spent - other.spent}
+}
+
+func (userSummaryProjection) Project(ctx context.Context, tx pgx.Tx, event *models.OrderEvent, before, after *models.Order) error {
+	old, current := contribution(before), contribution(after)
+	if before != nil && before.UserID == after.UserID {
+		if old == current {
+			return nil
+		}
+		return addToSummary(ctx, tx, after, current.minus(old))
+	}
+	if old != (summaryContribution{}) {
+		if err := addToSummary(ctx, tx, before, summaryContribution{}.minus(old)); err != nil {
+			return err
+		}
+	}
+	if current == (summaryContribution{}) {
+		return nil
+	}
+	return addToSummary(ctx, tx, after, current)
+}
+
+func (userSummaryProjection) Reset(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, "DELETE FROM order_user_summaries")
+	return err
+}
+
+// addToSummary прибавляет delta к сводке владельца заказа; учтённый заказ сдвигает время последнего заказа вперёд
+func addToSummary(ctx context.Context, tx pgx.Tx, order *models.Order, delta summaryContribution) error {
+	if order.UserID == "" {
+		return nil
+	}
+	var orderedAt *time.Time
+	if contribution(order).orders > 0 {
+		orderedAt = &order.CreatedAt
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO order_user_summaries (user_id, orders_count, cancelled_count, total_spent, last_order_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET orders_count = order_user_summaries.orders_count + EXCLUDED.orders_count,
+			cancelled_count = order_user_summaries.cancelled_count + EXCLUDED.cancelled_count,
+			total_spent = order_user_summaries.total_spent + EXCLUDED.total_spent,
+			last_order_at = GREATEST(order_user_summaries.last_order_at, EXCLUDED.last_order_at),
+			updated_at = NOW()`,
+		order.UserID, delta.orders, delta.cancelled, delta.spent, orderedAt)
+	if err != nil {
+		logQueryError(ctx, "error updating order user summary", err)
+	}
+	return err
+}
+
+// GetOrderEvents возвращает журнал событий заказа, в том числе удалённого
+func (r *OrderRepository) GetOrderEvents(ctx context.Context, orderID string) ([]models.OrderEvent, error) {
+	events, err := queryOrderEvents(ctx, r.DB, orderID, 0)
+	if err != nil {
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+	if len(events) == 0 {
+		return nil, ErrOrderNotFound
+	}
+	return events, nil
+}
+
+// GetUserSummary возвращает сводку по заказам пользователя; без заказов — нулевую
+func (r *OrderRepository) GetUserSummary(ctx context.Context, userID string) (*models.OrderUserSummary, error) {
+	summary := &models.OrderUserSummary{UserID: userID}
+	err := r.DB.QueryRow(ctx, `
+		SELECT orders_count, cancelled_count, total_spent, last_order_at, updated_at
+		FROM order_user_summaries
+		WHERE user_id = $1`, userID).
+		Scan(&summary.OrdersCount, &summary.CancelledCount, &summary.TotalSpent, &summary.LastOrderAt, &summary.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return summary, nil
+	}
+	if err != nil {
+		logQueryError(ctx, "error getting order user summary", err)
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return summary, nil
+}
+
+// OrderReplayResult — итог пересборки проекций заказов
+type OrderReplayResult struct {
+	Orders    int // восстановлено заказов
+	Events    int // применено событий
+	Snapshots int // сохранено снимков
+}
+
+// ReplayOrders пересобирает проекции и снимки заказов из журнала событий с нуля.
+// Всё выполняется в одной транзакции: при ошибке проекции остаются прежними. Если на заказ
+// без событий ссылаются другие таблицы, фиксация завершается ошибкой внешнего ключа.
+func (r *OrderRepository) ReplayOrders(ctx context.Context) (*OrderReplayResult, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, projection := range orderProjections {
+		if err := projection.Reset(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+	// Снимки пересобираются вместе с проекциями, а не используются при пересборке
+	if _, err := tx.Exec(ctx, "DELETE FROM order_snapshots"); err != nil {
+		return nil, err
+	}
+
+	ids, err := orderStreamIDs(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OrderReplayResult{}
+	for _, id := range ids {
+		events, err := queryOrderEvents(ctx, tx, id, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		var before *models.Order
+		for i := range events {
+			after := &models.Order{}
+			if before != nil {
+				copied := *before
+				after = &copied
+			}
+			if err := after.Apply(&events[i]); err != nil {
+				return nil, err
+			}
+			for _, projection := range orderProjections {
+				if err := projection.Project(ctx, tx, &events[i], before, after); err != nil {
+					return nil, err
+				}
+			}
+			before = after
+		}
+
+		if len(events) >= OrderSnapshotInterval {
+			if err := saveOrderSnapshot(ctx, tx, before); err != nil {
+				return nil, err
+			}
+			result.Snapshots++
+		}
+		result.Orders++
+		result.Events += len(events)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// orderStreamIDs возвращает ID всех заказов, у которых есть события
+func orderStreamIDs(ctx context.Context, q querier) ([]string, error) {
+	rows, err := q.Query(ctx, "SELECT DISTINCT order_id::text FROM order_events ORDER BY 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -2,126 +2,312 @@ package repositories
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"errors"
 	"order-service/models"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrOrderStatusConflict — статус заказа изменился с момента чтения
+var ErrOrderStatusConflict = models.NewConflict("order_status_conflict", "order status has changed")
+
+// ErrOrderUserNotFound — заказ ссылается на несуществующего пользователя
+var ErrOrderUserNotFound = models.NewFieldErrors(models.FieldError{Field: "user_id", Message: "user does not exist"})
+
+const orderColumns = `id, user_id, total_price, status, version, created_at, updated_at, deleted_at`
+
 type OrderRepository struct {
-	DB *pgx.Conn
+	DB *pgxpool.Pool
 }
 
-func NewOrderRepository(db *pgx.Conn) *OrderRepository {
+func NewOrderRepository(db *pgxpool.Pool) *OrderRepository {
 	return &OrderRepository{DB: db}
 }
 
-func (r *OrderRepository) CreateOrder(order *models.Order) error {
-	currentTime := time.Now()
-	query := `
-		INSERT INTO orders (id, user_id, total_price, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`
+// CreateOrder начинает поток событий заказа событием order.created; order получает сохранённое состояние
+func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order, actorID string) error {
+	event, err := models.NewOrderEvent(order.ID, models.OrderEventCreated, models.OrderCreated{
+		UserID:     order.UserID,
+		Items:      order.Items,
+		TotalPrice: order.TotalPrice,
+		Status:     order.Status,
+	}, actorID)
+	if err != nil {
+		return err
+	}
 
-	_, err := r.DB.Exec(context.Background(), query,
-		order.ID,
-		order.UserID, // Теперь это UUID
-		order.TotalPrice,
-		order.Status,
-		currentTime,
-		currentTime,
-	)
+	// Событие, заказ и его позиции сохраняем в одной транзакции
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		log.Printf("error inserting order: %v", err)
+		logQueryError(ctx, "error starting transaction", err)
 		return err
 	}
+	defer tx.Rollback(ctx)
+
+	created, err := appendOrderEvent(ctx, tx, nil, 0, event)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	*order = *created
 	return nil
 }
 
-func (r *OrderRepository) UpdateOrder(id string, updatedOrder *models.Order) (*models.Order, error) {
-	// Проверяем, является ли ID валидным UUID
-	orderID, err := uuid.Parse(id)
+// UpdateOrder меняет владельца и статус заказа, если его версия равна updatedOrder.Version.
+// Смена владельца — событие order.updated, смена статуса — order.status_changed с записью
+// в историю статусов; всё в одной транзакции.
+func (r *OrderRepository) UpdateOrder(ctx context.Context, id string, updatedOrder *models.Order, actorID string) (*models.Order, error) {
+	tx, err := r.DB.Begin(ctx)
 	if err != nil {
-		log.Printf("invalid order ID: %v", err)
+		logQueryError(ctx, "error starting transaction", err)
 		return nil, err
 	}
+	defer tx.Rollback(ctx)
 
-	updatedOrder.UpdatedAt = time.Now()
-
-	query := `
-		UPDATE orders 
-		SET user_id = $1, total_price = $2, status = $3, updated_at = $4 
-		WHERE id = $5 
-		RETURNING id, user_id, total_price, status, created_at, updated_at`
+	saved, err := changeOrderTx(ctx, tx, id, func(current *models.Order) (*models.OrderEvent, error) {
+		if current.Version != updatedOrder.Version {
+			return nil, ErrVersionMismatch
+		}
+		if current.UserID == updatedOrder.UserID {
+			return nil, nil
+		}
+		return models.NewOrderEvent(id, models.OrderEventUpdated, models.OrderChanges{UserID: &updatedOrder.UserID}, actorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if saved.Status != updatedOrder.Status {
+		if saved, err = updateOrderStatusTx(ctx, tx, id, saved.Status, updatedOrder.Status, "", actorID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
 
-	// Создаём структуру для хранения обновленных данных
-	var newOrder models.Order
+// changeOrder загружает заказ из журнала и добавляет событие, которое строит change.
+// Всё выполняется в одной транзакции вместе с обновлением проекций.
+func (r *OrderRepository) changeOrder(ctx context.Context, id string, change func(current *models.Order) (*models.OrderEvent, error)) (*models.Order, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		logQueryError(ctx, "error starting transaction", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
 
-	err = r.DB.QueryRow(context.Background(), query,
-		updatedOrder.UserID, updatedOrder.TotalPrice, updatedOrder.Status, updatedOrder.UpdatedAt, orderID).
-		Scan(&newOrder.ID, &newOrder.UserID, &newOrder.TotalPrice, &newOrder.Status, &newOrder.CreatedAt, &newOrder.UpdatedAt)
+	saved, err := changeOrderTx(ctx, tx, id, change)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
 
+// changeOrderTx — changeOrder внутри уже открытой транзакции. Если change не вернул событие,
+// заказ не меняется.
+func changeOrderTx(ctx context.Context, tx pgx.Tx, id string, change func(current *models.Order) (*models.OrderEvent, error)) (*models.Order, error) {
+	current, pending, err := loadOrder(ctx, tx, id)
 	if err != nil {
-		log.Printf("error updating order: %v", err)
 		return nil, err
 	}
+	event, err := change(current)
+	if err != nil || event == nil {
+		return current, err
+	}
+	return appendOrderEvent(ctx, tx, current, pending, event)
+}
 
-	return &newOrder, nil
+// GetOrderById возвращает заказ с позициями; удалённые заказы не находятся
+func (r *OrderRepository) GetOrderById(ctx context.Context, id string) (*models.Order, error) {
+	return r.FindOrderById(ctx, id, false)
 }
-func (r *OrderRepository) GetOrderById(id string) (*models.Order, error) {
-	var order models.Order
-	query := `
-SELECT id, user_id, total_price, status, created_at, updated_at From orders WHERE id = $1`
-	err := r.DB.QueryRow(context.Background(), query, id).Scan(&order.ID, &order.UserID, &order.TotalPrice, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+
+// FindOrderById ищет заказ по ID, в том числе удалённый, если includeDeleted
+func (r *OrderRepository) FindOrderById(ctx context.Context, id string, includeDeleted bool) (*models.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = $1 AND ` + notDeleted(includeDeleted)
+	order, err := scanOrder(r.DB.QueryRow(ctx, query, id))
+	if err != nil {
+		logQueryError(ctx, "error getting order", err)
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+
+	order.Items, err = r.GetOrderItems(ctx, id)
 	if err != nil {
-		log.Printf("error getting order: %v", err)
 		return nil, err
 	}
-	return &order, nil
+	return order, nil
 }
 
-func (r *OrderRepository) GetAllOrders() ([]models.Order, error) {
-	var orders []models.Order
-	rows, err := r.DB.Query(context.Background(), "SELECT id, user_id, total_price, status, created_at, updated_at FROM orders")
+// SoftDeleteOrder помечает заказ удалённым; позиции, история и документы остаются
+func (r *OrderRepository) SoftDeleteOrder(ctx context.Context, order *models.Order, actorID string) error {
+	saved, err := r.changeOrder(ctx, order.ID, func(current *models.Order) (*models.OrderEvent, error) {
+		if current.DeletedAt != nil {
+			return nil, ErrOrderNotFound
+		}
+		return models.NewOrderEvent(order.ID, models.OrderEventDeleted, nil, actorID)
+	})
 	if err != nil {
-		fmt.Printf("error getting all orders: %v", err)
+		return err
+	}
+	*order = *saved
+	return nil
+}
+
+// RestoreOrder снимает с заказа отметку об удалении
+func (r *OrderRepository) RestoreOrder(ctx context.Context, order *models.Order, actorID string) error {
+	saved, err := r.changeOrder(ctx, order.ID, func(current *models.Order) (*models.OrderEvent, error) {
+		if current.DeletedAt == nil {
+			return nil, ErrOrderNotFound
+		}
+		return models.NewOrderEvent(order.ID, models.OrderEventRestored, nil, actorID)
+	})
+	if err != nil {
+		return err
+	}
+	*order = *saved
+	return nil
+}
+
+// GetOrderItems возвращает позиции заказа
+func (r *OrderRepository) GetOrderItems(ctx context.Context, orderID string) ([]models.CartItem, error) {
+	rows, err := r.DB.Query(ctx,
+		"SELECT product_id, sku, quantity, price FROM order_items WHERE order_id = $1 ORDER BY sku", orderID)
+	if err != nil {
+		logQueryError(ctx, "error getting order items", err)
 		return nil, err
 	}
 	defer rows.Close()
+
+	items := []models.CartItem{}
 	for rows.Next() {
-		var order models.Order
-		if err := rows.Scan(&order.ID, &order.UserID, &order.TotalPrice, &order.Status, &order.CreatedAt, &order.UpdatedAt); err != nil {
-			log.Printf("error scanning order: %v", err)
+		var item models.CartItem
+		if err := rows.Scan(&item.ProductID, &item.SKU, &item.Quantity, &item.Price); err != nil {
 			return nil, err
 		}
-		orders = append(orders, order)
+		items = append(items, item)
 	}
-	if err := rows.Err(); err != nil {
-		log.Printf("error iterating over rows: %v", err)
+	return items, rows.Err()
+}
+
+// CancelOrder переводит заказ из статуса from в cancelled. Если заказ оплачен, остаток оплаты
+// в той же транзакции записывается возвратом средств; он и возвращается (иначе nil).
+func (r *OrderRepository) CancelOrder(ctx context.Context, id, from, reason, changedBy string) (*models.Refund, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return orders, nil
+	defer tx.Rollback(ctx)
+
+	refundable, err := lockRefundable(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	var refund *models.Refund
+	if from == models.OrderStatusPaid && refundable > 0 {
+		refund = &models.Refund{OrderID: id, Amount: refundable, Status: models.RefundStatusCompleted, Reason: "order cancelled"}
+		if err := insertRefund(ctx, tx, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	// Статус проверяется под блокировкой: параллельная отмена получит ErrOrderStatusConflict
+	if _, err := updateOrderStatusTx(ctx, tx, id, from, models.OrderStatusCancelled, reason, changedBy); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return refund, nil
 }
-func (r *OrderRepository) DeleteOrder(id string) error {
-	_, err := r.DB.Exec(context.Background(), "DELETE FROM orders WHERE id = $1", id)
+
+// GetStatusHistory возвращает историю статусов заказа в хронологическом порядке
+func (r *OrderRepository) GetStatusHistory(ctx context.Context, orderID string) ([]models.OrderStatusChange, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT from_status, to_status, COALESCE(reason, ''), changed_by, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY id`, orderID)
 	if err != nil {
-		log.Printf("error deleting order: %v", err)
-		return err
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+
+	history := []models.OrderStatusChange{}
+	for rows.Next() {
+		var change models.OrderStatusChange
+		if err := rows.Scan(&change.FromStatus, &change.ToStatus, &change.Reason, &change.ChangedBy, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// updateOrderStatusTx меняет статус заказа внутри уже открытой транзакции (событие order.status_changed)
+// и возвращает заказ после изменения
+func updateOrderStatusTx(ctx context.Context, tx pgx.Tx, id, from, to, reason, changedBy string) (*models.Order, error) {
+	saved, err := changeOrderTx(ctx, tx, id, func(current *models.Order) (*models.OrderEvent, error) {
+		if current.Status != from {
+			return nil, ErrOrderStatusConflict
+		}
+		return models.NewOrderEvent(id, models.OrderEventStatusChanged, models.OrderStatusChanged{From: from, To: to, Reason: reason}, changedBy)
+	})
+	if errors.Is(err, ErrVersionMismatch) {
+		return nil, ErrOrderStatusConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var actor *string
+	if changedBy != "" {
+		actor = &changedBy
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO order_status_history (order_id, from_status, to_status, reason, changed_by)
+		VALUES ($1, $2, $3, $4, $5)`,
+		id, from, to, reason, actor)
+	if err != nil {
+		logQueryError(ctx, "error inserting order status history", err)
+		return nil, err
+	}
+	return saved, nil
 }
 
-func (r *OrderRepository) GetOrdersByUserID(userID string) ([]models.Order, error) {
-	ctx := context.Background()
+// GetAllOrders возвращает все заказы без позиций; удалённые — только если includeDeleted
+func (r *OrderRepository) GetAllOrders(ctx context.Context, includeDeleted bool) ([]models.Order, error) {
+	var orders []models.Order
+	rows, err := r.DB.Query(ctx, "SELECT "+orderColumns+" FROM orders WHERE "+notDeleted(includeDeleted))
+	if err != nil {
+		logQueryError(ctx, "error getting all orders", err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			logQueryError(ctx, "error scanning order", err)
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	if err := rows.Err(); err != nil {
+		logQueryError(ctx, "error iterating over rows", err)
+	}
+	return orders, nil
+}
 
+// GetOrdersByUserID возвращает заказы пользователя; удалённые — только если includeDeleted
+func (r *OrderRepository) GetOrdersByUserID(ctx context.Context, userID string, includeDeleted bool) ([]models.Order, error) {
 	// Создаем SQL запрос для получения заказов пользователя
-	query := `
-		SELECT o.id, o.user_id, o.total_price, o.status, o.created_at, o.updated_at
-		FROM orders o
-		JOIN users u ON o.user_id = u.id
-		WHERE u.id = $1`
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE user_id = $1 AND ` + notDeleted(includeDeleted)
 
 	// Выполняем запрос
 	rows, err := r.DB.Query(ctx, query, userID)
@@ -135,18 +321,11 @@ func (r *OrderRepository) GetOrdersByUserID(userID string) ([]models.Order, erro
 
 	// Получаем все заказы
 	for rows.Next() {
-		var order models.Order
-		if err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.TotalPrice,
-			&order.Status,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-		); err != nil {
+		order, err := scanOrder(rows)
+		if err != nil {
 			return nil, err
 		}
-		orders = append(orders, order)
+		orders = append(orders, *order)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -155,3 +334,13 @@ func (r *OrderRepository) GetOrdersByUserID(userID string) ([]models.Order, erro
 
 	return orders, nil
 }
+
+func scanOrder(row pgx.Row) (*models.Order, error) {
+	var order models.Order
+	err := row.Scan(&order.ID, &order.UserID, &order.TotalPrice, &order.Status, &order.Version,
+		&order.CreatedAt, &order.UpdatedAt, &order.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// patchAssignments строит список "колонка = $n" для полей, изменённых PATCH-запросом.
+// values сопоставляет имя поля (оно же имя колонки) с новым значением; поле вне values
+// не может быть изменено. Значения дописываются в args.
+func patchAssignments(fields []string, values map[string]interface{}, args []interface{}) (string, []interface{}, error) {
+	assignments := make([]string, 0, len(fields))
+	for _, field := range fields {
+		value, ok := values[field]
+		if !ok {
+			return "", nil, fmt.Errorf("field %q cannot be patched", field)
+		}
+		args = append(args, value)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", pgx.Identifier{field}.Sanitize(), len(args)))
+	}
+	return strings.Join(assignments, ", "), args, nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"order-service/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrRefundExceedsPayment — сумма возврата больше оплаченной и ещё не возвращённой суммы
+var ErrRefundExceedsPayment = models.NewConflict("refund_exceeds_payment", "refund amount exceeds refundable amount")
+
+type PaymentRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPaymentRepository(db *pgxpool.Pool) *PaymentRepository {
+	return &PaymentRepository{DB: db}
+}
+
+// GetRefundByID возвращает возврат средств по ID
+func (r *PaymentRepository) GetRefundByID(ctx context.Context, id string) (*models.Refund, error) {
+	var refund models.Refund
+	query := `
+		SELECT id, order_id, return_id, amount, status, COALESCE(reason, ''), created_at
+		FROM refunds
+		WHERE id = $1`
+
+	err := r.DB.QueryRow(ctx, query, id).Scan(
+		&refund.ID, &refund.OrderID, &refund.ReturnID, &refund.Amount, &refund.Status, &refund.Reason, &refund.CreatedAt)
+	if err != nil {
+		return nil, notFound(err, ErrRefundNotFound)
+	}
+	return &refund, nil
+}
+
+// GetRefundsByOrderID возвращает все возвраты средств по заказу
+func (r *PaymentRepository) GetRefundsByOrderID(ctx context.Context, orderID string) ([]models.Refund, error) {
+	query := `
+		SELECT id, order_id, return_id, amount, status, COALESCE(reason, ''), created_at
+		FROM refunds
+		WHERE order_id = $1
+		ORDER BY created_at`
+
+	rows, err := r.DB.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []models.Refund{}
+	for rows.Next() {
+		var refund models.Refund
+		if err := rows.Scan(&refund.ID, &refund.OrderID, &refund.ReturnID, &refund.Amount, &refund.Status, &refund.Reason, &refund.CreatedAt); err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
+
+// lockRefundable блокирует строку заказа до конца транзакции и возвращает сумму, которую
+// по нему ещё можно вернуть. Под этой блокировкой возвраты средств по заказу идут по очереди:
+// отмена и одобрение заявок не вернут больше оплаченного.
+func lockRefundable(ctx context.Context, tx pgx.Tx, orderID string) (float64, error) {
+	var id string
+	if err := tx.QueryRow(ctx, `SELECT id FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&id); err != nil {
+		return 0, notFound(err, ErrOrderNotFound)
+	}
+
+	// Отдельный запрос: после ожидания блокировки он видит возвраты, сохранённые предыдущей транзакцией
+	var refundable float64
+	err := tx.QueryRow(ctx, `
+		SELECT o.total_price - COALESCE(SUM(r.amount), 0)
+		FROM orders o
+		LEFT JOIN refunds r ON r.order_id = o.id AND r.status = $2
+		WHERE o.id = $1
+		GROUP BY o.total_price`, orderID, models.RefundStatusCompleted).Scan(&refundable)
+	if err != nil {
+		logQueryError(ctx, "error getting refundable amount", err)
+		return 0, err
+	}
+	return refundable, nil
+}
+
+// insertRefund сохраняет возврат средств внутри транзакции, держащей lockRefundable.
+// Второй возврат по той же заявке — ErrReturnStatusConflict.
+func insertRefund(ctx context.Context, tx pgx.Tx, refund *models.Refund) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO refunds (order_id, return_id, amount, status, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		refund.OrderID, refund.ReturnID, refund.Amount, refund.Status, refund.Reason).
+		Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		logQueryError(ctx, "error inserting refund", err)
+		return uniqueViolation(err, ErrReturnStatusConflict)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// productCacheStream — запись коллекции resume_tokens с позицией потока изменений продуктов
+const productCacheStream = "product_cache"
+
+var (
+	// ErrChangeStreamsUnsupported — MongoDB запущен без replica set, потоки изменений недоступны
+	ErrChangeStreamsUnsupported = errors.New("change streams require a MongoDB replica set")
+	// ErrProductChangesLost — сохранённая позиция потока больше не в oplog; часть изменений пропущена
+	ErrProductChangesLost = errors.New("product change stream history lost")
+)
+
+// ProductChange — изменение в коллекции products
+type ProductChange struct {
+	Operation string // insert, update, replace, delete, drop, invalidate...
+	// Канонический и прежние ID продукта; пусто, если документ недоступен (удалён или коллекция удалена)
+	IDs []string
+}
+
+// WatchChanges передаёт handle изменения коллекции products, пока не отменён ctx или не произошла ошибка.
+// Позиция потока сохраняется после каждого обработанного изменения, поэтому после перезапуска
+// чтение продолжается с места остановки; изменение, на котором handle вернул ошибку, будет прочитано снова.
+// Если позиция устарела, она удаляется и возвращается ErrProductChangesLost.
+func (r *ProductRepository) WatchChanges(ctx context.Context, handle func(ctx context.Context, change ProductChange) error) error {
+	tokens := r.db.Collection("resume_tokens")
+
+	var saved struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := tokens.FindOne(ctx, bson.M{"_id": productCacheStream}).Decode(&saved)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	// Без сохранённой позиции поток начинается с текущего момента.
+	// StartAfter, в отличие от ResumeAfter, продолжает поток и после события invalidate.
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if saved.Token != nil {
+		opts.SetStartAfter(saved.Token)
+	}
+	stream, err := r.db.Collection("products").Watch(ctx, mongo.Pipeline{}, opts)
+	if err != nil {
+		return r.changeStreamError(ctx, err)
+	}
+	defer stream.Close(context.WithoutCancel(ctx))
+
+	for stream.Next(ctx) {
+		var event struct {
+			OperationType string `bson:"operationType"`
+			FullDocument  *struct {
+				IDString  string   `bson:"idString"`
+				LegacyIDs []string `bson:"legacy_ids"`
+			} `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			return err
+		}
+
+		change := ProductChange{Operation: event.OperationType}
+		if doc := event.FullDocument; doc != nil && doc.IDString != "" {
+			change.IDs = append([]string{doc.IDString}, doc.LegacyIDs...)
+		}
+		if err := handle(ctx, change); err != nil {
+			return err
+		}
+
+		_, err := tokens.UpdateOne(ctx,
+			bson.M{"_id": productCacheStream},
+			bson.M{"$set": bson.M{"token": stream.ResumeToken(), "updated_at": time.Now()}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+	return r.changeStreamError(ctx, stream.Err())
+}
+
+// changeStreamError распознаёт ошибки сервера, после которых повтор с той же позицией бесполезен
+func (r *ProductRepository) changeStreamError(ctx context.Context, err error) error {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return err
+	}
+	switch {
+	case serverErr.HasErrorCode(40573): // $changeStream is only supported on replica sets
+		return ErrChangeStreamsUnsupported
+	case serverErr.HasErrorCode(286), // ChangeStreamHistoryLost
+		serverErr.HasErrorCode(280), // ChangeStreamFatalError
+		serverErr.HasErrorCode(260): // InvalidResumeToken
+		if _, err := r.db.Collection("resume_tokens").DeleteOne(ctx, bson.M{"_id": productCacheStream}); err != nil {
+			return err
+		}
+		return ErrProductChangesLost
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// productIDFilter находит продукт по каноническому ID или по одному из прежних ID.
+// Формат id не анализируется: оба поля проиндексированы, и прежние ID хранятся явно.
+func productIDFilter(id string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"idString": id},
+		bson.M{"legacy_ids": id},
+	}}
+}
+
+// ProductIDBackfillResult — итог нормализации идентификаторов продуктов
+type ProductIDBackfillResult struct {
+	Scanned    int // просмотрено документов
+	Updated    int // документов с изменёнными ID
+	Reinserted int // документов, пересозданных из-за _id не типа ObjectID
+}
+
+// BackfillProductIDs приводит идентификаторы всех продуктов к каноническому виду:
+//   - idString становится UUID в нижнем регистре (новым, если прежний не UUID или уже занят);
+//   - прежний idString и hex/строковое значение _id сохраняются в legacy_ids;
+//   - документы с _id не типа ObjectID пересоздаются с новым ObjectID.
+//
+// Операция идемпотентна: повторный запуск ничего не меняет.
+func (r *ProductRepository) BackfillProductIDs(ctx context.Context) (*ProductIDBackfillResult, error) {
+	products := r.db.Collection("products")
+
+	cursor, err := products.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := &ProductIDBackfillResult{}
+	// Канонический ID -> _id документа, которому он достался
+	takenBy := make(map[string]string)
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		result.Scanned++
+
+		oldID := doc["_id"]
+		currentID, _ := doc["idString"].(string)
+
+		legacy := []string{}
+		if existing, ok := doc["legacy_ids"].(bson.A); ok {
+			for _, value := range existing {
+				if s, ok := value.(string); ok {
+					legacy = append(legacy, s)
+				}
+			}
+		}
+		if oid, ok := oldID.(primitive.ObjectID); ok {
+			legacy = appendUnique(legacy, oid.Hex())
+		} else {
+			legacy = appendUnique(legacy, fmt.Sprint(oldID))
+		}
+
+		canonical := currentID
+		if owner, taken := takenBy[canonical]; !models.IsCanonicalProductID(canonical) || (taken && owner != fmt.Sprint(oldID)) {
+			if currentID != "" {
+				legacy = appendUnique(legacy, currentID)
+			}
+			canonical = models.NewProductID()
+		}
+		legacy = removeValue(legacy, canonical)
+
+		if _, isObjectID := oldID.(primitive.ObjectID); !isObjectID {
+			newID := primitive.NewObjectID()
+			takenBy[canonical] = fmt.Sprint(newID)
+			doc["_id"] = newID
+			doc["idString"] = canonical
+			doc["legacy_ids"] = legacy
+			if err := r.reinsertProduct(ctx, oldID, doc); err != nil {
+				return nil, err
+			}
+			logger.InfoContext(ctx, "Product re-inserted", "old_id", fmt.Sprint(oldID), "id", canonical)
+			result.Reinserted++
+			continue
+		}
+		takenBy[canonical] = fmt.Sprint(oldID)
+
+		if canonical == currentID && sameValues(legacy, doc["legacy_ids"]) {
+			continue
+		}
+		_, err := products.UpdateOne(ctx, bson.M{"_id": oldID}, bson.M{"$set": bson.M{
+			"idString":   canonical,
+			"legacy_ids": legacy,
+		}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update product %v: %w", oldID, err)
+		}
+		if canonical != currentID {
+			logger.InfoContext(ctx, "Product id replaced with canonical", "old_id", fmt.Sprint(oldID), "previous_id", currentID, "id", canonical)
+		}
+		result.Updated++
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reinsertProduct заменяет документ с нестандартным _id копией с новым ObjectID.
+// MongoDB не позволяет изменить _id, а транзакции недоступны на одиночном сервере,
+// поэтому копия вставляется первой: если удаление не удалось, повторный запуск
+// найдёт копию по legacy_ids и только удалит старый документ.
+func (r *ProductRepository) reinsertProduct(ctx context.Context, oldID interface{}, doc bson.M) error {
+	products := r.db.Collection("products")
+
+	copies, err := products.CountDocuments(ctx, bson.M{
+		"legacy_ids": fmt.Sprint(oldID),
+		"_id":        bson.M{"$type": "objectId"},
+	})
+	if err != nil {
+		return err
+	}
+	if copies == 0 {
+		if _, err := products.InsertOne(ctx, doc); err != nil {
+			return fmt.Errorf("failed to re-insert product %v: %w", oldID, err)
+		}
+	}
+	if _, err := products.DeleteOne(ctx, bson.M{"_id": oldID}); err != nil {
+		return fmt.Errorf("failed to delete product %v after re-insert: %w", oldID, err)
+	}
+	return nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func removeValue(values []string, value string) []string {
+	result := values[:0]
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// sameValues сравнивает список ID с сохранённым в документе массивом legacy_ids
+func sameValues(values []string, stored interface{}) bool {
+	array, _ := stored.(bson.A)
+	if len(array) != len(values) {
+		return false
+	}
+	for i, value := range array {
+		if s, _ := value.(string); s != values[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -4,13 +4,20 @@ import (
 	"context"
 	"fmt"
 	"order-service/models"
-	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrProductNotFound — продукт не найден ни по каноническому, ни по прежнему ID
+var ErrProductNotFound = models.NewNotFound("product_not_found", "product not found")
+
+// ErrVariantNotFound — вариант продукта с таким SKU не существует
+var ErrVariantNotFound = models.NewNotFound("variant_not_found", "product variant not found")
+
 type ProductRepository struct {
 	db *mongo.Database
 }
@@ -19,67 +26,75 @@ func NewProductRepository(db *mongo.Database) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) CreateProduct(product *models.Product) error {
+func (r *ProductRepository) CreateProduct(ctx context.Context, product *models.Product) error {
 	// Генерируем новый ObjectID
 	product.ID = primitive.NewObjectID()
 
-	// Если IDString не задан, используем ObjectID в виде строки
+	// Если публичный ID не задан, генерируем канонический UUID
 	if product.IDString == "" {
-		product.IDString = product.ID.Hex()
+		product.IDString = models.NewProductID()
+	}
+
+	// Продукт без вариантов получает один вариант по умолчанию с SKU, равным ID продукта
+	if len(product.Variants) == 0 {
+		product.Variants = []models.ProductVariant{{
+			SKU:   product.IDString,
+			Price: product.Price,
+			Stock: product.Stock,
+		}}
 	}
+	product.SyncTotals()
+	product.Version = 1
 
-	_, err := r.db.Collection("products").InsertOne(context.Background(), product)
+	_, err := r.db.Collection("products").InsertOne(ctx, product)
 	return err
 }
 
-func (r *ProductRepository) GetProductById(id string) (*models.Product, error) {
-	var product models.Product
-
-	// Проверяем, является ли ID UUID (содержит дефисы)
-	if strings.Contains(id, "-") {
-		// Если это UUID, ищем продукт по IDString
-		err := r.db.Collection("products").FindOne(context.Background(), bson.M{"idString": id}).Decode(&product)
-		if err != nil {
-			return nil, fmt.Errorf("product not found: %v", err)
-		}
-		return &product, nil
-	}
+// GetProductById ищет продукт по каноническому ID или по одному из его прежних ID.
+// Признак legacy-идентификатора можно проверить, сравнив id с product.IDString.
+// Удалённые продукты не находятся.
+func (r *ProductRepository) GetProductById(ctx context.Context, id string) (*models.Product, error) {
+	return r.FindProductById(ctx, id, false)
+}
 
-	// Если это не UUID, пробуем преобразовать в ObjectID
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return nil, fmt.Errorf("invalid product ID format: %v", err)
+// FindProductById ищет продукт так же, как GetProductById, но с includeDeleted находит и удалённые
+func (r *ProductRepository) FindProductById(ctx context.Context, id string, includeDeleted bool) (*models.Product, error) {
+	filter := productIDFilter(id)
+	if !includeDeleted {
+		filter["deleted_at"] = nil
 	}
-
-	err = r.db.Collection("products").FindOne(context.Background(), bson.M{"_id": objID}).Decode(&product)
+	var product models.Product
+	err := r.db.Collection("products").FindOne(ctx, filter).Decode(&product)
 	if err != nil {
-		return nil, fmt.Errorf("product not found: %v", err)
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
 	}
 	return &product, nil
 }
 
-func (r *ProductRepository) GetAllProducts() ([]models.ProductResponse, error) {
-	var products []models.ProductResponse
+// GetAllProducts возвращает каталог; удалённые продукты — только если includeDeleted
+func (r *ProductRepository) GetAllProducts(ctx context.Context, includeDeleted bool) ([]models.ProductResponse, error) {
+	products := []models.ProductResponse{}
 
-	cursor, err := r.db.Collection("products").Find(context.Background(), bson.M{})
+	filter := bson.M{}
+	if !includeDeleted {
+		filter["deleted_at"] = nil
+	}
+	cursor, err := r.db.Collection("products").Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
-	for cursor.Next(context.Background()) {
+	for cursor.Next(ctx) {
 		var product models.Product
 		if err := cursor.Decode(&product); err != nil {
-			// Пропускаем документы с некорректным _id
-			continue
+			// Документы старого формата исправляет команда -backfill-product-ids
+			return nil, fmt.Errorf("failed to decode product %v (run -backfill-product-ids): %w", cursor.Current.Lookup("_id"), err)
 		}
-		products = append(products, models.ProductResponse{
-			ID:          product.IDString, // Используем IDString вместо ID.Hex()
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       product.Price,
-			Stock:       product.Stock,
-		})
+		products = append(products, product.ToResponse())
 	}
 
 	if err := cursor.Err(); err != nil {
@@ -89,55 +104,279 @@ func (r *ProductRepository) GetAllProducts() ([]models.ProductResponse, error) {
 	return products, nil
 }
 
-func (r *ProductRepository) UpdateProduct(id primitive.ObjectID, updatedProduct *models.Product) error {
-	filter := bson.M{"_id": id}
-	update := bson.M{
-		"$set": bson.M{
-			"name":        updatedProduct.Name,
-			"description": updatedProduct.Description,
-			"price":       updatedProduct.Price,
-			"stock":       updatedProduct.Stock,
-		},
-	}
+// UpdateProduct сохраняет продукт, если его версия всё ещё равна updatedProduct.Version,
+// и увеличивает версию на единицу. Остатки вариантов не перезаписываются: их ведёт журнал склада.
+func (r *ProductRepository) UpdateProduct(ctx context.Context, id primitive.ObjectID, updatedProduct *models.Product) error {
+	filter := bson.M{"_id": id, "version": updatedProduct.Version}
+	updatedProduct.SyncTotals()
+	update := productUpdate(bson.M{
+		"name":          updatedProduct.Name,
+		"description":   updatedProduct.Description,
+		"price":         updatedProduct.Price,
+		"category":      updatedProduct.Category,
+		"category_path": updatedProduct.CategoryPath,
+		"variants":      updatedProduct.Variants,
+		"attributes":    updatedProduct.Attributes,
+		"images":        updatedProduct.Images,
+	})
 
-	_, err := r.db.Collection("products").UpdateOne(context.Background(), filter, update)
-	return err
+	result, err := r.db.Collection("products").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrVersionMismatch
+	}
+	updatedProduct.Version++
+	return nil
 }
 
-func (r *ProductRepository) DeleteProduct(id primitive.ObjectID) error {
-	_, err := r.db.Collection("products").DeleteOne(context.Background(), bson.M{"_id": id})
-	return err
+// productUpdate строит обновление полей set с увеличением версии. Значения передаются как $literal,
+// чтобы строки вида "$…" не читались как пути. Новые варианты получают остаток одноимённого SKU
+// из сохранённого документа (остатки ведёт журнал склада), суммарный остаток пересчитывается.
+func productUpdate(set bson.M) mongo.Pipeline {
+	fields := bson.M{"version": bson.M{"$add": bson.A{"$version", 1}}}
+	for name, value := range set {
+		fields[name] = bson.M{"$literal": value}
+	}
+	if variants, ok := set["variants"]; ok {
+		fields["variants"] = bson.M{"$map": bson.M{
+			"input": bson.M{"$literal": variants},
+			"as":    "n",
+			"in": bson.M{"$mergeObjects": bson.A{"$$n", bson.M{"stock": bson.M{"$ifNull": bson.A{
+				bson.M{"$first": bson.M{"$map": bson.M{
+					"input": bson.M{"$filter": bson.M{
+						"input": bson.M{"$ifNull": bson.A{"$variants", bson.A{}}},
+						"as":    "o",
+						"cond":  bson.M{"$eq": bson.A{"$$o.sku", "$$n.sku"}},
+					}},
+					"as": "o",
+					"in": "$$o.stock",
+				}}},
+				0,
+			}}}}},
+		}}
+	}
+	pipeline := mongo.Pipeline{{{Key: "$set", Value: fields}}}
+	if _, ok := set["variants"]; ok {
+		pipeline = append(pipeline, bson.D{{Key: "$set", Value: bson.M{"stock": bson.M{"$sum": "$variants.stock"}}}})
+	}
+	return pipeline
 }
 
-func (repo *ProductRepository) GetProductPrice(productID string) (float64, error) {
-	// Проверяем, является ли ID UUID (содержит дефисы)
-	if strings.Contains(productID, "-") {
-		// Если это UUID, ищем продукт по IDString
-		var product models.Product
-		err := repo.db.Collection("products").FindOne(context.Background(), bson.M{"idString": productID}).Decode(&product)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				return 0, fmt.Errorf("product not found")
-			}
-			return 0, err
+// PatchProduct изменяет только перечисленные поля продукта, если его версия равна product.Version.
+// Цена продукта вычисляется по вариантам, поэтому сохраняется вместе с ними; остатки — как в UpdateProduct.
+func (r *ProductRepository) PatchProduct(ctx context.Context, product *models.Product, fields []string) error {
+	product.SyncTotals()
+	set := bson.M{}
+	for _, field := range fields {
+		switch field {
+		case "name":
+			set["name"] = product.Name
+		case "description":
+			set["description"] = product.Description
+		case "price", "stock", "variants":
+			set["variants"] = product.Variants
+			set["price"] = product.Price
+		case "category":
+			set["category"] = product.Category
+			set["category_path"] = product.CategoryPath
+		case "attributes":
+			set["attributes"] = product.Attributes
+		case "images":
+			set["images"] = product.Images
+		default:
+			return fmt.Errorf("field %q cannot be patched", field)
 		}
-		return product.Price, nil
 	}
 
-	// Если это не UUID, пробуем преобразовать в ObjectID
-	objID, err := primitive.ObjectIDFromHex(productID)
+	result, err := r.db.Collection("products").UpdateOne(ctx,
+		bson.M{"_id": product.ID, "version": product.Version},
+		productUpdate(set))
 	if err != nil {
-		return 0, fmt.Errorf("invalid product ID format: %v", err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrVersionMismatch
 	}
+	product.Version++
+	return nil
+}
+
+// SoftDeleteProduct помечает продукт удалённым и увеличивает версию. Документ остаётся:
+// по его SKU корзины и документы по-прежнему находят название и цену.
+func (r *ProductRepository) SoftDeleteProduct(ctx context.Context, product *models.Product) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	result, err := r.db.Collection("products").UpdateOne(ctx,
+		bson.M{"_id": product.ID, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": now}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrProductNotFound
+	}
+	product.DeletedAt = &now
+	product.Version++
+	return nil
+}
 
+// RestoreProduct снимает с продукта отметку об удалении
+func (r *ProductRepository) RestoreProduct(ctx context.Context, product *models.Product) error {
+	result, err := r.db.Collection("products").UpdateOne(ctx,
+		bson.M{"_id": product.ID, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"deleted_at": ""}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrProductNotFound
+	}
+	product.DeletedAt = nil
+	product.Version++
+	return nil
+}
+
+// GetVariantBySKU возвращает продукт и его вариант по SKU, в том числе вариант удалённого продукта:
+// вызывающий проверяет product.DeletedAt
+func (r *ProductRepository) GetVariantBySKU(ctx context.Context, sku string) (*models.Product, *models.ProductVariant, error) {
 	var product models.Product
-	err = repo.db.Collection("products").FindOne(context.Background(), bson.M{"_id": objID}).Decode(&product)
+	err := r.db.Collection("products").FindOne(ctx, bson.M{"variants.sku": sku}).Decode(&product)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return 0, fmt.Errorf("product not found")
+			return nil, nil, fmt.Errorf("%w: %s", ErrVariantNotFound, sku)
 		}
-		return 0, err
+		return nil, nil, err
 	}
 
-	return product.Price, nil
+	variant, _ := product.Variant(sku)
+	return &product, variant, nil
+}
+
+// GetProductsBySKUs одним запросом находит продукты с вариантами из skus, в том числе удалённые.
+// Результат — продукт по каждому найденному SKU; ненайденных SKU в нём нет.
+func (r *ProductRepository) GetProductsBySKUs(ctx context.Context, skus []string) (map[string]*models.Product, error) {
+	products, err := r.findProducts(ctx, bson.M{"variants.sku": bson.M{"$in": skus}})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*models.Product, len(skus))
+	for _, product := range products {
+		for _, variant := range product.Variants {
+			result[variant.SKU] = product
+		}
+	}
+	return result, nil
+}
+
+// GetProductsByIDs одним запросом находит продукты по каноническим или прежним ID, в том числе удалённые.
+// Результат — продукт по каждому найденному ID из ids; ненайденных ID в нём нет.
+func (r *ProductRepository) GetProductsByIDs(ctx context.Context, ids []string) (map[string]*models.Product, error) {
+	products, err := r.findProducts(ctx, bson.M{"$or": bson.A{
+		bson.M{"idString": bson.M{"$in": ids}},
+		bson.M{"legacy_ids": bson.M{"$in": ids}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*models.Product, len(ids))
+	for _, product := range products {
+		result[product.IDString] = product
+		for _, id := range product.LegacyIDs {
+			result[id] = product
+		}
+	}
+	return result, nil
+}
+
+func (r *ProductRepository) findProducts(ctx context.Context, filter bson.M) ([]*models.Product, error) {
+	cursor, err := r.db.Collection("products").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []*models.Product
+	for cursor.Next(ctx) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return nil, fmt.Errorf("failed to decode product %v (run -backfill-product-ids): %w", cursor.Current.Lookup("_id"), err)
+		}
+		products = append(products, &product)
+	}
+	return products, cursor.Err()
+}
+
+// SetVariantStock записывает в вариант остаток, доступный по журналу склада, и пересчитывает
+// суммарный остаток продукта. Остаток не редактируется через API, поэтому версия (ETag) не меняется.
+// Возвращает ID продукта, если остаток изменился, иначе пустую строку.
+func (r *ProductRepository) SetVariantStock(ctx context.Context, sku string, stock int) (string, error) {
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"variants": bson.M{"$map": bson.M{
+			"input": "$variants",
+			"as":    "v",
+			"in": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$$v.sku", sku}},
+				bson.M{"$mergeObjects": bson.A{"$$v", bson.M{"stock": stock}}},
+				"$$v",
+			}},
+		}}}}},
+		{{Key: "$set", Value: bson.M{"stock": bson.M{"$sum": "$variants.stock"}}}},
+	}
+	var product struct {
+		IDString string `bson:"idString"`
+	}
+	err := r.db.Collection("products").FindOneAndUpdate(ctx,
+		bson.M{"variants": bson.M{"$elemMatch": bson.M{"sku": sku, "stock": bson.M{"$ne": stock}}}},
+		update,
+		options.FindOneAndUpdate().SetProjection(bson.M{"idString": 1})).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		// SKU нет в каталоге или остаток уже совпадает
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return product.IDString, nil
+}
+
+// GetVariantStocks возвращает остатки вариантов каталога, в том числе удалённых продуктов, по SKU
+func (r *ProductRepository) GetVariantStocks(ctx context.Context) (map[string]int, error) {
+	cursor, err := r.db.Collection("products").Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"variants.sku": 1, "variants.stock": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	stock := map[string]int{}
+	for cursor.Next(ctx) {
+		var product struct {
+			Variants []struct {
+				SKU   string `bson:"sku"`
+				Stock int    `bson:"stock"`
+			} `bson:"variants"`
+		}
+		if err := cursor.Decode(&product); err != nil {
+			return nil, err
+		}
+		for _, variant := range product.Variants {
+			stock[variant.SKU] = variant.Stock
+		}
+	}
+	return stock, cursor.Err()
+}
+
+// CountByCategory возвращает количество продуктов в категории и её подкатегориях.
+// Удалённые продукты тоже учитываются: после восстановления их категория должна существовать.
+func (r *ProductRepository) CountByCategory(ctx context.Context, slug string) (int64, error) {
+	return r.db.Collection("products").CountDocuments(ctx, bson.M{"category_path": slug})
+}
+
+// UpdateCategoryPath обновляет путь категории у всех её продуктов после переноса в дереве
+func (r *ProductRepository) UpdateCategoryPath(ctx context.Context, slug string, path []string) error {
+	_, err := r.db.Collection("products").UpdateMany(ctx,
+		bson.M{"category": slug},
+		bson.M{"$set": bson.M{"category_path": path}, "$inc": bson.M{"version": 1}})
+	return err
 }
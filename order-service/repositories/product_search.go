@@ -0,0 +1,225 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"order-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// priceBucketBoundaries — границы ценовых диапазонов фасета price
+var priceBucketBoundaries = []float64{0, 50, 100, 250, 500, 1000}
+
+// priceBucketOverflow — диапазон для цен выше последней границы. Продукты без цены
+// в фасет price не попадают, иначе $bucket отнёс бы их к этому диапазону.
+const priceBucketOverflow = "1000+"
+
+// EnsureIndexes создаёт индексы коллекции products, в том числе текстовый индекс для поиска
+func (r *ProductRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("products").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().
+				SetName("products_text").
+				SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "description", Value: 2}}).
+				// Каталог смешанный по языкам, поэтому без стемминга и стоп-слов: слова совпадают целиком.
+				// Подсветка в services/product_search.go следует тому же правилу.
+				SetDefaultLanguage("none"),
+		},
+		{
+			Keys: bson.D{{Key: "idString", Value: 1}},
+			Options: options.Index().
+				SetName("idString_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"idString": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "legacy_ids", Value: 1}}},
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "price", Value: 1}}},
+		{Keys: bson.D{{Key: "category_path", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "variants.sku", Value: 1}},
+			Options: options.Index().
+				SetName("variants_sku_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"variants.sku": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		// Дубликаты idString в старых данных устраняет команда -backfill-product-ids
+		return fmt.Errorf("failed to create product indexes (run -backfill-product-ids if ids are duplicated): %w", err)
+	}
+	return nil
+}
+
+// SearchProducts выполняет полнотекстовый поиск с фильтрами. Результаты упорядочены
+// по релевантности, фасеты считаются одним агрегационным запросом по всей выборке.
+func (r *ProductRepository) SearchProducts(ctx context.Context, params models.ProductSearchParams) (*models.ProductSearchResult, error) {
+	// Удалённые продукты в поиск не попадают
+	match := bson.M{"deleted_at": nil}
+	if params.Query != "" {
+		match["$text"] = bson.M{"$search": params.Query}
+	}
+	price := bson.M{}
+	if params.MinPrice != nil {
+		price["$gte"] = *params.MinPrice
+	}
+	if params.MaxPrice != nil {
+		price["$lte"] = *params.MaxPrice
+	}
+	if len(price) > 0 {
+		match["price"] = price
+	}
+	if params.InStock != nil {
+		if *params.InStock {
+			match["stock"] = bson.M{"$gt": 0}
+		} else {
+			match["stock"] = bson.M{"$lte": 0}
+		}
+	}
+	// Категория включает все свои подкатегории
+	if params.Category != "" {
+		match["category_path"] = params.Category
+	}
+
+	// Без поискового запроса сортируем по имени
+	sort := bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	if params.Query != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}})
+		sort = bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}
+	}
+
+	boundaries := make(bson.A, 0, len(priceBucketBoundaries))
+	for _, b := range priceBucketBoundaries {
+		boundaries = append(boundaries, b)
+	}
+
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.M{
+		"results": bson.A{
+			bson.M{"$sort": sort},
+			bson.M{"$skip": int64(params.Page-1) * int64(params.PageSize)},
+			bson.M{"$limit": int64(params.PageSize)},
+		},
+		"total": bson.A{
+			bson.M{"$count": "count"},
+		},
+		"price": bson.A{
+			bson.M{"$match": bson.M{"price": bson.M{"$type": "number"}}},
+			bson.M{"$bucket": bson.M{
+				"groupBy":    "$price",
+				"boundaries": boundaries,
+				"default":    priceBucketOverflow,
+				"output":     bson.M{"count": bson.M{"$sum": 1}},
+			}},
+		},
+		"category": bson.A{
+			bson.M{"$group": bson.M{"_id": bson.M{"$ifNull": bson.A{"$category", ""}}, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		},
+		"availability": bson.A{
+			bson.M{"$group": bson.M{"_id": bson.M{"$gt": bson.A{"$stock", 0}}, "count": bson.M{"$sum": 1}}},
+		},
+	}}})
+
+	cursor, err := r.db.Collection("products").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var facets []struct {
+		Results []struct {
+			models.Product `bson:",inline"`
+			Score          float64 `bson:"score"`
+		} `bson:"results"`
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Price []struct {
+			ID    interface{} `bson:"_id"`
+			Count int64       `bson:"count"`
+		} `bson:"price"`
+		Category []struct {
+			ID    string `bson:"_id"`
+			Count int64  `bson:"count"`
+		} `bson:"category"`
+		Availability []struct {
+			ID    bool  `bson:"_id"`
+			Count int64 `bson:"count"`
+		} `bson:"availability"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+
+	result := &models.ProductSearchResult{
+		Items:    []models.ProductResponse{},
+		Page:     params.Page,
+		PageSize: params.PageSize,
+		Facets: models.ProductFacets{
+			Price:        []models.FacetBucket{},
+			Category:     []models.FacetBucket{},
+			Availability: []models.FacetBucket{},
+		},
+	}
+	if len(facets) == 0 {
+		return result, nil
+	}
+	f := facets[0]
+
+	for _, doc := range f.Results {
+		product := doc.ToResponse()
+		product.Score = doc.Score
+		result.Items = append(result.Items, product)
+	}
+	if len(f.Total) > 0 {
+		result.Total = f.Total[0].Count
+	}
+	for _, bucket := range f.Price {
+		result.Facets.Price = append(result.Facets.Price, models.FacetBucket{
+			Value: priceBucketLabel(bucket.ID),
+			Count: bucket.Count,
+		})
+	}
+	for _, bucket := range f.Category {
+		// Продукты без категории в фасет не попадают
+		if bucket.ID == "" {
+			continue
+		}
+		result.Facets.Category = append(result.Facets.Category, models.FacetBucket{Value: bucket.ID, Count: bucket.Count})
+	}
+	for _, bucket := range f.Availability {
+		value := "out_of_stock"
+		if bucket.ID {
+			value = "in_stock"
+		}
+		result.Facets.Availability = append(result.Facets.Availability, models.FacetBucket{Value: value, Count: bucket.Count})
+	}
+
+	return result, nil
+}
+
+// priceBucketLabel превращает нижнюю границу из $bucket в подпись диапазона "50-100"
+func priceBucketLabel(id interface{}) string {
+	var lower float64
+	switch v := id.(type) {
+	case float64:
+		lower = v
+	case int32:
+		lower = float64(v)
+	case int64:
+		lower = float64(v)
+	default:
+		return fmt.Sprint(v)
+	}
+
+	for i, b := range priceBucketBoundaries[:len(priceBucketBoundaries)-1] {
+		if b == lower {
+			return fmt.Sprintf("%g-%g", b, priceBucketBoundaries[i+1])
+		}
+	}
+	return fmt.Sprintf("%g", lower)
+}
@@ -0,0 +1,250 @@
+package repositories
+
+import (
+	"context"
+	"order-service/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrReturnStatusConflict — заявка на возврат уже обработана
+var ErrReturnStatusConflict = models.NewConflict("return_status_conflict", "return has already been resolved")
+
+type ReturnRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewReturnRepository(db *pgxpool.Pool) *ReturnRepository {
+	return &ReturnRepository{DB: db}
+}
+
+const returnColumns = `id, order_id, user_id, status, reason, COALESCE(admin_comment, ''), refund_amount,
+	resolved_by, resolved_at, created_at, updated_at`
+
+// CreateReturn сохраняет заявку на возврат вместе с позициями
+func (r *ReturnRepository) CreateReturn(ctx context.Context, ret *models.Return) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	err = tx.QueryRow(ctx, `
+		INSERT INTO order_returns (order_id, user_id, status, reason, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		ret.OrderID, ret.UserID, ret.Status, ret.Reason, now, now).Scan(&ret.ID)
+	if err != nil {
+		logQueryError(ctx, "error inserting return", err)
+		return err
+	}
+	ret.CreatedAt = now
+	ret.UpdatedAt = now
+
+	for _, item := range ret.Items {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO order_return_items (return_id, product_id, sku, quantity, price, reason)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			ret.ID, item.ProductID, item.SKU, item.Quantity, item.Price, item.Reason)
+		if err != nil {
+			logQueryError(ctx, "error inserting return item", err)
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetReturnByID возвращает заявку на возврат с позициями
+func (r *ReturnRepository) GetReturnByID(ctx context.Context, id string) (*models.Return, error) {
+	ret, err := scanReturn(r.DB.QueryRow(ctx,
+		"SELECT "+returnColumns+" FROM order_returns WHERE id = $1", id))
+	if err != nil {
+		return nil, notFound(err, ErrReturnNotFound)
+	}
+
+	ret.Items, err = r.getReturnItems(ctx, ret.ID)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetReturnsByOrderID возвращает все заявки по заказу
+func (r *ReturnRepository) GetReturnsByOrderID(ctx context.Context, orderID string) ([]models.Return, error) {
+	return r.queryReturns(ctx, "SELECT "+returnColumns+" FROM order_returns WHERE order_id = $1 ORDER BY created_at", orderID)
+}
+
+// GetReturnsByUserID возвращает все заявки пользователя
+func (r *ReturnRepository) GetReturnsByUserID(ctx context.Context, userID string) ([]models.Return, error) {
+	return r.queryReturns(ctx, "SELECT "+returnColumns+" FROM order_returns WHERE user_id = $1 ORDER BY created_at", userID)
+}
+
+// GetReturnsByStatus возвращает заявки в указанном статусе; пустой статус — все заявки
+func (r *ReturnRepository) GetReturnsByStatus(ctx context.Context, status string) ([]models.Return, error) {
+	if status == "" {
+		return r.queryReturns(ctx, "SELECT "+returnColumns+" FROM order_returns ORDER BY created_at")
+	}
+	return r.queryReturns(ctx, "SELECT "+returnColumns+" FROM order_returns WHERE status = $1 ORDER BY created_at", status)
+}
+
+// GetReturnedQuantities возвращает количество товаров по SKU в заказе,
+// заявленных к возврату в заявках с указанными статусами
+func (r *ReturnRepository) GetReturnedQuantities(ctx context.Context, orderID string, statuses ...string) (map[string]int, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT ri.sku, SUM(ri.quantity)
+		FROM order_return_items ri
+		JOIN order_returns rt ON rt.id = ri.return_id
+		WHERE rt.order_id = $1 AND rt.status = ANY($2)
+		GROUP BY ri.sku`, orderID, statuses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quantities := make(map[string]int)
+	for rows.Next() {
+		var sku string
+		var quantity int
+		if err := rows.Scan(&sku, &quantity); err != nil {
+			return nil, err
+		}
+		quantities[sku] = quantity
+	}
+	return quantities, rows.Err()
+}
+
+// RejectReturn отклоняет заявку, если она ещё не обработана
+func (r *ReturnRepository) RejectReturn(ctx context.Context, id, adminID, comment string) error {
+	now := time.Now()
+	tag, err := r.DB.Exec(ctx, `
+		UPDATE order_returns
+		SET status = $1, admin_comment = $2, resolved_by = $3, resolved_at = $4, updated_at = $4
+		WHERE id = $5 AND status = $6`,
+		models.ReturnStatusRejected, comment, adminID, now, id, models.ReturnStatusRequested)
+	if err != nil {
+		logQueryError(ctx, "error rejecting return", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrReturnStatusConflict
+	}
+	return nil
+}
+
+// CompleteReturn отмечает заявку возмещённой и в той же транзакции возвращает ret.RefundAmount
+// (под блокировкой заказа, см. lockRefundable) и переводит заказ в новый статус.
+// Возвращает возврат средств или nil, если возвращать нечего.
+func (r *ReturnRepository) CompleteReturn(ctx context.Context, ret *models.Return, adminID, comment, orderFrom, orderTo string) (*models.Refund, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	refundable, err := lockRefundable(ctx, tx, ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tag, err := tx.Exec(ctx, `
+		UPDATE order_returns
+		SET status = $1, admin_comment = $2, refund_amount = $3, resolved_by = $4, resolved_at = $5, updated_at = $5
+		WHERE id = $6 AND status = $7`,
+		models.ReturnStatusRefunded, comment, ret.RefundAmount, adminID, now, ret.ID, models.ReturnStatusRequested)
+	if err != nil {
+		logQueryError(ctx, "error completing return", err)
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrReturnStatusConflict
+	}
+
+	var refund *models.Refund
+	if ret.RefundAmount > 0 {
+		if ret.RefundAmount > refundable {
+			return nil, ErrRefundExceedsPayment
+		}
+		refund = &models.Refund{OrderID: ret.OrderID, ReturnID: &ret.ID, Amount: ret.RefundAmount, Status: models.RefundStatusCompleted, Reason: "return " + ret.ID}
+		if err := insertRefund(ctx, tx, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	if orderFrom != orderTo {
+		if _, err := updateOrderStatusTx(ctx, tx, ret.OrderID, orderFrom, orderTo, "return "+ret.ID, adminID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+func (r *ReturnRepository) queryReturns(ctx context.Context, query string, args ...any) ([]models.Return, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	returns := []models.Return{}
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		returns = append(returns, *ret)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Позиции читаем после закрытия курсора: на одном соединении нельзя выполнять запросы параллельно
+	for i := range returns {
+		returns[i].Items, err = r.getReturnItems(ctx, returns[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return returns, nil
+}
+
+func (r *ReturnRepository) getReturnItems(ctx context.Context, returnID string) ([]models.ReturnItem, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT product_id, sku, quantity, price, COALESCE(reason, '')
+		FROM order_return_items
+		WHERE return_id = $1
+		ORDER BY sku`, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ReturnItem{}
+	for rows.Next() {
+		var item models.ReturnItem
+		if err := rows.Scan(&item.ProductID, &item.SKU, &item.Quantity, &item.Price, &item.Reason); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func scanReturn(row pgx.Row) (*models.Return, error) {
+	var ret models.Return
+	err := row.Scan(&ret.ID, &ret.OrderID, &ret.UserID, &ret.Status, &ret.Reason, &ret.AdminComment,
+		&ret.RefundAmount, &ret.ResolvedBy, &ret.ResolvedAt, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// notDeleted — условие WHERE, скрывающее мягко удалённые строки; TRUE, если нужны и они
+func notDeleted(includeDeleted bool) string {
+	if includeDeleted {
+		return "TRUE"
+	}
+	return "deleted_at IS NULL"
+}
+
+// setDeletedAt помечает строку таблицы table удалённой (deletedAt задан) или восстанавливает её (nil)
+// и увеличивает версию. Если строка не найдена или уже в нужном состоянии, возвращает pgx.ErrNoRows.
+func setDeletedAt(ctx context.Context, db *pgxpool.Pool, table, id string, deletedAt *time.Time) (int64, time.Time, error) {
+	condition := "deleted_at IS NULL"
+	if deletedAt == nil {
+		condition = "deleted_at IS NOT NULL"
+	}
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = $1, updated_at = $2, version = version + 1
+		WHERE id = $3 AND %s
+		RETURNING version, updated_at`, table, condition)
+
+	var version int64
+	var updatedAt time.Time
+	err := db.QueryRow(ctx, query, deletedAt, time.Now(), id).Scan(&version, &updatedAt)
+	return version, updatedAt, err
+}
@@ -2,118 +2,114 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"order-service/models"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const userColumns = `id, username, email, password, role, version, created_at, updated_at, last_login_at, erased_at, deleted_at`
+
 type UserRepository struct {
-	DB *pgx.Conn
+	DB *pgxpool.Pool
 }
 
-func NewUserRepository(db *pgx.Conn) *UserRepository {
+func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{DB: db}
 }
 
 // Создание нового пользователя
-func (r *UserRepository) CreateUser(user *models.User) error {
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
 	user.ID = uuid.New().String()
+	user.Version = 1
+	if user.Role == "" {
+		user.Role = models.RoleCustomer
+	}
 	query := `
-		INSERT INTO users (id, username, email, password, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO users (id, username, email, password, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 	now := time.Now()
-	_, err := r.DB.Exec(context.Background(), query,
+	_, err := r.DB.Exec(ctx, query,
 		user.ID,
 		user.Username,
 		user.Email,
 		user.Password,
+		user.Role,
 		now,
 		now,
 	)
-	return err
+	return uniqueViolation(err, ErrUserExists)
 }
 
-// Получение пользователя по email
-func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
-	var user models.User
-	query := `
-		SELECT id, username, email, password, created_at, updated_at
-		FROM users
-		WHERE email = $1
-	`
-	err := r.DB.QueryRow(context.Background(), query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+// Получение пользователя по email; удалённые пользователи не находятся и войти не могут
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1 AND deleted_at IS NULL`
+	user, err := scanUser(r.DB.QueryRow(ctx, query, email))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &user, nil
+	return user, nil
 }
 
-// Получение пользователя по ID
-func (r *UserRepository) GetUserByID(id string) (*models.User, error) {
-	var user models.User
-	query := `
-		SELECT id, username, email, password, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
-	err := r.DB.QueryRow(context.Background(), query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+// Получение пользователя по ID; удалённые пользователи не находятся
+func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	return r.FindUserByID(ctx, id, false)
+}
+
+// FindUserByID ищет пользователя по ID, в том числе удалённого, если includeDeleted
+func (r *UserRepository) FindUserByID(ctx context.Context, id string, includeDeleted bool) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND ` + notDeleted(includeDeleted)
+	user, err := scanUser(r.DB.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return user, nil
+}
+
+// GetUsersByIDs одним запросом находит пользователей по ID, в том числе удалённых.
+// Результат — пользователь по ID; ненайденных ID в нём нет.
+func (r *UserRepository) GetUsersByIDs(ctx context.Context, ids []string) (map[string]*models.User, error) {
+	rows, err := r.DB.Query(ctx, `SELECT `+userColumns+` FROM users WHERE id = ANY($1)`, ids)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
 		return nil, err
 	}
-	return &user, nil
+	defer rows.Close()
+
+	users := make(map[string]*models.User, len(ids))
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users[user.ID] = user
+	}
+	return users, rows.Err()
 }
 
-// Получение всех пользователей
-func (r *UserRepository) GetAllUsers() ([]models.User, error) {
+// Получение всех пользователей; удалённые — только если includeDeleted
+func (r *UserRepository) GetAllUsers(ctx context.Context, includeDeleted bool) ([]models.User, error) {
 	var users []models.User
-	query := `
-		SELECT id, username, email, password, created_at, updated_at
-		FROM users
-	`
-	rows, err := r.DB.Query(context.Background(), query)
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + notDeleted(includeDeleted)
+	rows, err := r.DB.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var user models.User
-		err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.Email,
-			&user.Password,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, user)
+		users = append(users, *user)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -123,36 +119,157 @@ func (r *UserRepository) GetAllUsers() ([]models.User, error) {
 	return users, nil
 }
 
-// Обновление пользователя
-func (r *UserRepository) UpdateUser(user *models.User) error {
+// Обновление пользователя. Строка изменяется, только если её версия равна user.Version;
+// при успехе версия увеличивается. Иначе возвращается ErrVersionMismatch.
+func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, updated_at = $3
-		WHERE id = $4
+		SET username = $1, email = $2, updated_at = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version, updated_at
 	`
-	_, err := r.DB.Exec(context.Background(), query,
+	err := r.DB.QueryRow(ctx, query,
 		user.Username,
 		user.Email,
 		time.Now(),
 		user.ID,
-	)
-	return err
+		user.Version,
+	).Scan(&user.Version, &user.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVersionMismatch
+	}
+	return uniqueViolation(err, ErrUserExists)
 }
 
-// Удаление пользователя
-func (r *UserRepository) DeleteUser(id string) error {
-	query := `
-		DELETE FROM users
-		WHERE id = $1
-	`
-	result, err := r.DB.Exec(context.Background(), query, id)
+// PatchUser изменяет только перечисленные поля пользователя; версия проверяется так же, как в UpdateUser
+func (r *UserRepository) PatchUser(ctx context.Context, user *models.User, fields []string) error {
+	set, args, err := patchAssignments(fields, map[string]interface{}{
+		"username": user.Username,
+		"email":    user.Email,
+	}, nil)
 	if err != nil {
 		return err
 	}
+	args = append(args, time.Now(), user.ID, user.Version)
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("user not found")
+	query := fmt.Sprintf(`
+		UPDATE users
+		SET %s, updated_at = $%d, version = version + 1
+		WHERE id = $%d AND version = $%d
+		RETURNING version, updated_at
+	`, set, len(args)-2, len(args)-1, len(args))
+	err = r.DB.QueryRow(ctx, query, args...).Scan(&user.Version, &user.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrVersionMismatch
 	}
+	return uniqueViolation(err, ErrUserExists)
+}
 
+// SoftDeleteUser помечает пользователя удалённым; данные и заказы остаются
+func (r *UserRepository) SoftDeleteUser(ctx context.Context, user *models.User) error {
+	now := time.Now()
+	version, updatedAt, err := setDeletedAt(ctx, r.DB, "users", user.ID, &now)
+	if err != nil {
+		logQueryError(ctx, "error deleting user", err)
+		return notFound(err, ErrUserNotFound)
+	}
+	user.Version, user.UpdatedAt, user.DeletedAt = version, updatedAt, &now
 	return nil
 }
+
+// RestoreUser снимает с пользователя отметку об удалении
+func (r *UserRepository) RestoreUser(ctx context.Context, user *models.User) error {
+	version, updatedAt, err := setDeletedAt(ctx, r.DB, "users", user.ID, nil)
+	if err != nil {
+		logQueryError(ctx, "error restoring user", err)
+		return notFound(err, ErrUserNotFound)
+	}
+	user.Version, user.UpdatedAt, user.DeletedAt = version, updatedAt, nil
+	return nil
+}
+
+// TouchLastLogin запоминает время успешного входа
+func (r *UserRepository) TouchLastLogin(ctx context.Context, id string) error {
+	_, err := r.DB.Exec(ctx, `UPDATE users SET last_login_at = $1 WHERE id = $2`, time.Now(), id)
+	return err
+}
+
+// EraseUser обезличивает пользователя: имя, email и хэш пароля заменяются, свободный текст
+// в его заявках на возврат и в причинах смены статусов заказов очищается. Заказы, возвраты
+// средств и документы остаются. Возвращает false, если пользователь уже обезличен.
+func (r *UserRepository) EraseUser(ctx context.Context, id string) (bool, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Пустой хэш пароля не совпадает ни с одним паролем, так что войти под записью нельзя
+	result, err := tx.Exec(ctx, `
+		UPDATE users
+		SET username = 'erased-' || id, email = id || '@erased.invalid', password = '',
+			last_login_at = NULL, erased_at = $1, updated_at = $1, version = version + 1
+		WHERE id = $2 AND erased_at IS NULL`, time.Now(), id)
+	if err != nil {
+		return false, notFound(err, ErrUserNotFound)
+	}
+	if result.RowsAffected() == 0 {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, ErrUserNotFound
+		}
+		return false, nil
+	}
+
+	statements := []string{
+		`UPDATE order_returns SET reason = '', admin_comment = NULL WHERE user_id = $1`,
+		`UPDATE order_return_items SET reason = NULL
+			WHERE return_id IN (SELECT id FROM order_returns WHERE user_id = $1)`,
+		`UPDATE order_status_history SET reason = NULL WHERE changed_by = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement, id); err != nil {
+			logQueryError(ctx, "error erasing user data", err)
+			return false, err
+		}
+	}
+	return true, tx.Commit(ctx)
+}
+
+// GetInactiveUserIDs возвращает до limit покупателей без входа с момента before
+// (или зарегистрированных до before и ни разу не входивших); обезличенные не учитываются
+func (r *UserRepository) GetInactiveUserIDs(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	rows, err := r.DB.Query(ctx, `
+		SELECT id FROM users
+		WHERE erased_at IS NULL AND role = $1 AND COALESCE(last_login_at, created_at) < $2
+		ORDER BY COALESCE(last_login_at, created_at)
+		LIMIT $3`, models.RoleCustomer, before, limit)
+	if err != nil {
+		logQueryError(ctx, "error querying inactive users", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Version,
+		&user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt, &user.ErasedAt, &user.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
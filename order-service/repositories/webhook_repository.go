@@ -0,0 +1,252 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"order-service/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Ошибки поиска подписок и доставок
+var (
+	ErrWebhookNotFound  = models.NewNotFound("webhook_not_found", "webhook subscription not found")
+	ErrDeliveryNotFound = models.NewNotFound("delivery_not_found", "webhook delivery not found")
+)
+
+const webhookColumns = `id, url, secret, events, active, description, version, created_at, updated_at`
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_attempt_at, d.response_status, d.response_body, d.last_error, d.redelivery_of,
+	d.created_at, d.delivered_at`
+
+type WebhookRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewWebhookRepository(db *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{DB: db}
+}
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	sub.ID = uuid.New().String()
+	sub.Version = 1
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+	err := r.DB.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (id, url, secret, events, active, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at`,
+		sub.ID, sub.URL, sub.Secret, sub.Events, sub.Active, sub.Description).Scan(&sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		logQueryError(ctx, "error inserting webhook subscription", err)
+	}
+	return err
+}
+
+func (r *WebhookRepository) GetSubscription(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	sub, err := scanWebhook(r.DB.QueryRow(ctx, `SELECT `+webhookColumns+` FROM webhook_subscriptions WHERE id = $1`, id))
+	if err != nil {
+		logQueryError(ctx, "error getting webhook subscription", err)
+		return nil, notFound(err, ErrWebhookNotFound)
+	}
+	return sub, nil
+}
+
+// ListSubscriptions возвращает подписки; activeOnly — только включённые
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context, activeOnly bool) ([]models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhook_subscriptions`
+	if activeOnly {
+		query += ` WHERE active`
+	}
+	rows, err := r.DB.Query(ctx, query+` ORDER BY created_at`)
+	if err != nil {
+		logQueryError(ctx, "error listing webhook subscriptions", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+// UpdateSubscription сохраняет подписку, если её версия равна sub.Version, и увеличивает версию
+func (r *WebhookRepository) UpdateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	err := r.DB.QueryRow(ctx, `
+		UPDATE webhook_subscriptions
+		SET url = $1, secret = $2, events = $3, active = $4, description = $5, updated_at = $6, version = version + 1
+		WHERE id = $7 AND version = $8
+		RETURNING version, updated_at`,
+		sub.URL, sub.Secret, sub.Events, sub.Active, sub.Description, time.Now(), sub.ID, sub.Version).
+		Scan(&sub.Version, &sub.UpdatedAt)
+	if err != nil {
+		logQueryError(ctx, "error updating webhook subscription", err)
+		return notFound(err, ErrVersionMismatch)
+	}
+	return nil
+}
+
+// DeleteSubscription удаляет подписку вместе с журналом её доставок
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	result, err := r.DB.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		logQueryError(ctx, "error deleting webhook subscription", err)
+		return notFound(err, ErrWebhookNotFound)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// EnqueueDeliveries ставит доставки в очередь одной транзакцией; ID проставляются
+func (r *WebhookRepository) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for i := range deliveries {
+		d := &deliveries[i]
+		d.Status = models.WebhookDeliveryPending
+		err := tx.QueryRow(ctx, `
+			INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, redelivery_of)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at, next_attempt_at`,
+			d.SubscriptionID, d.EventID, d.EventType, string(d.Payload), d.RedeliveryOf).
+			Scan(&d.ID, &d.CreatedAt, &d.NextAttemptAt)
+		if err != nil {
+			logQueryError(ctx, "error enqueuing webhook delivery", err)
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// ClaimDueDeliveries берёт в отправку до limit доставок, время которых наступило. Взятые доставки
+// откладываются на lease: если экземпляр упадёт, не записав результат, их возьмёт другой.
+// SKIP LOCKED позволяет нескольким экземплярам сервиса разбирать очередь параллельно.
+// Доставки выключенных подписок ждут, пока подписку не включат снова.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	rows, err := r.DB.Query(ctx, `
+		WITH due AS (
+			SELECT q.id FROM webhook_deliveries q
+			JOIN webhook_subscriptions sub ON sub.id = q.subscription_id
+			WHERE q.status = 'pending' AND q.next_attempt_at <= NOW() AND sub.active
+			ORDER BY q.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF q SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id AND s.id = d.subscription_id
+		RETURNING `+deliveryColumns+`, s.url, s.secret`, limit, lease.Seconds())
+	if err != nil {
+		logQueryError(ctx, "error claiming webhook deliveries", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(append(deliveryFields(&d), &d.URL, &d.Secret)...); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordAttempt сохраняет результат попытки доставки: статус, ответ получателя и время следующей попытки
+// (nil, если доставка завершена)
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, d *models.WebhookDelivery) error {
+	_, err := r.DB.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_attempt_at = $4,
+			response_status = $5, response_body = $6, last_error = $7, delivered_at = $8
+		WHERE id = $9`,
+		d.Status, d.Attempts, d.NextAttemptAt, d.LastAttemptAt, d.ResponseStatus, d.ResponseBody, d.LastError,
+		d.DeliveredAt, d.ID)
+	if err != nil {
+		logQueryError(ctx, "error recording webhook attempt", err)
+	}
+	return err
+}
+
+// GetDelivery возвращает доставку подписки subscriptionID
+func (r *WebhookRepository) GetDelivery(ctx context.Context, subscriptionID string, id int64) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := r.DB.QueryRow(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries d
+		WHERE d.subscription_id = $1 AND d.id = $2`, subscriptionID, id).Scan(deliveryFields(&d)...)
+	if err != nil {
+		logQueryError(ctx, "error getting webhook delivery", err)
+		return nil, notFound(err, ErrDeliveryNotFound)
+	}
+	return &d, nil
+}
+
+// ListDeliveries возвращает журнал доставок подписки от новых к старым
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	conditions := []string{"d.subscription_id = $1"}
+	args := []any{subscriptionID}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("d.status = $%d", len(args)))
+	}
+	if filter.BeforeID > 0 {
+		args = append(args, filter.BeforeID)
+		conditions = append(conditions, fmt.Sprintf("d.id < $%d", len(args)))
+	}
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`SELECT %s FROM webhook_deliveries d WHERE %s ORDER BY d.id DESC LIMIT $%d`,
+		deliveryColumns, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		logQueryError(ctx, "error listing webhook deliveries", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(deliveryFields(&d)...); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func scanWebhook(row pgx.Row) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &sub.Events, &sub.Active, &sub.Description, &sub.Version,
+		&sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// deliveryFields — адреса полей доставки в порядке deliveryColumns
+func deliveryFields(d *models.WebhookDelivery) []any {
+	return []any{&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastAttemptAt, &d.ResponseStatus, &d.ResponseBody, &d.LastError, &d.RedeliveryOf,
+		&d.CreatedAt, &d.DeliveredAt}
+}
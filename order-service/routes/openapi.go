@@ -0,0 +1,256 @@
+package routes
+
+import (
+	"net/http"
+	"order-service/handlers"
+	"order-service/middleware"
+	"order-service/models"
+	"order-service/openapi"
+
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// OpenAPI возвращает спецификацию маршрутов RegisterRoutes. Описание маршрута добавляется сюда
+// вместе с регистрацией: TestOpenAPICoversRoutes не пропустит неописанный маршрут.
+func OpenAPI() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "Order Service API",
+		Version:     "1.0.0",
+		Description: "Пользователи, каталог, корзины, заказы, возвраты и документы. Ошибки — application/problem+json (RFC 7807).",
+	}, middleware.Problem{},
+		openapi.Tag{Name: "health", Description: "Проверки состояния и метрики"},
+		openapi.Tag{Name: "users", Description: "Регистрация, вход и профили"},
+		openapi.Tag{Name: "privacy", Description: "Выгрузка и удаление персональных данных"},
+		openapi.Tag{Name: "products", Description: "Каталог продуктов"},
+		openapi.Tag{Name: "categories", Description: "Дерево категорий"},
+		openapi.Tag{Name: "cart", Description: "Корзина и оформление заказа"},
+		openapi.Tag{Name: "orders", Description: "Заказы"},
+		openapi.Tag{Name: "returns", Description: "Возвраты (RMA) и возвраты средств"},
+		openapi.Tag{Name: "documents", Description: "Счета и кредит-ноты в PDF"},
+		openapi.Tag{Name: "audit", Description: "Журнал аудита"},
+		openapi.Tag{Name: "webhooks", Description: "Подписки на события заказов"},
+		openapi.Tag{Name: "inventory", Description: "Склады, остатки и журнал движений"},
+		openapi.Tag{Name: "graphql", Description: "GraphQL API"},
+		openapi.Tag{Name: "docs", Description: "Спецификация и Swagger UI"},
+	)
+
+	// Собственные правила валидатора из middleware.RegisterValidators
+	doc.Rule("password", func(s *openapi.Schema) {
+		s.Format = "password"
+		s.MinLength, s.MaxLength = intPtr(8), intPtr(72)
+		s.Description = "Хотя бы одна буква и одна цифра"
+	})
+	doc.Rule("sku", func(s *openapi.Schema) {
+		s.Pattern = `^[A-Za-z0-9._-]{1,64}$`
+	})
+	doc.Name(graphql.Response{}, "GraphQLResponse")
+	doc.Name(gqlerrors.QueryError{}, "GraphQLError")
+	doc.Name(gqlerrors.Location{}, "GraphQLErrorLocation")
+
+	includeDeleted := openapi.QueryParam("include_deleted", "boolean", "Показать удалённые записи; только с токеном администратора")
+	limit := openapi.QueryParam("limit", "integer", "Размер страницы")
+	beforeID := openapi.QueryParam("before_id", "integer", "Курсор: next_before_id предыдущей страницы")
+	notFound := []int{http.StatusNotFound}
+	orderStatusRule := "Статус меняется только вперёд: pending → paid → shipped → delivered, иначе 409. Отмена и возвраты — через свои операции."
+
+	doc.Add(
+		openapi.Route{Method: http.MethodGet, Path: "/healthz", Tag: "health", Summary: "Живость процесса", Response: models.Liveness{}},
+		openapi.Route{Method: http.MethodGet, Path: "/readyz", Tag: "health", Summary: "Готовность: доступны Postgres, MongoDB и Redis",
+			Description: "503 с тем же телом, если одна из зависимостей недоступна или сервис останавливается.", Response: models.Readiness{}},
+		openapi.Route{Method: http.MethodGet, Path: "/metrics", Tag: "health", Summary: "Метрики Prometheus", ResponseType: "text/plain"},
+
+		openapi.Route{Method: http.MethodGet, Path: "/openapi.json", Tag: "docs", Summary: "Эта спецификация", Response: map[string]any{}},
+		openapi.Route{Method: http.MethodGet, Path: "/docs", Tag: "docs", Summary: "Swagger UI", ResponseType: "text/html"},
+
+		openapi.Route{Method: http.MethodPost, Path: "/register", Tag: "users", Summary: "Регистрация пользователя",
+			Body: models.RegisterRequest{}, Response: models.UserResponse{}, Errors: []int{http.StatusConflict}},
+		openapi.Route{Method: http.MethodPost, Path: "/login", Tag: "users", Summary: "Вход: выдаёт JWT",
+			Body: models.LoginRequest{}, Response: models.TokenResponse{}, Errors: []int{http.StatusUnauthorized}},
+		openapi.Route{Method: http.MethodGet, Path: "/users/:id", Tag: "users", Summary: "Пользователь по ID: сам пользователь или администратор", Auth: openapi.AuthBearer,
+			Query: []openapi.Param{includeDeleted}, Response: models.UserResponse{}, ETag: true, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		openapi.Route{Method: http.MethodPut, Path: "/users/:id", Tag: "users", Summary: "Обновление профиля: сам пользователь или администратор",
+			Auth: openapi.AuthBearer, IfMatch: true, Body: models.UpdateUserRequest{}, Response: models.UserResponse{}, ETag: true,
+			Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodPatch, Path: "/users/:id", Tag: "users", Summary: "Частичное обновление профиля: сам пользователь или администратор",
+			Auth: openapi.AuthBearer, IfMatch: true, Patch: models.UpdateUserRequest{}, PatchFields: models.UserPatchFields, Response: models.UserResponse{}, ETag: true,
+			Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+
+		openapi.Route{Method: http.MethodPost, Path: "/orders", Tag: "orders", Summary: "Создание заказа без корзины: сам покупатель или администратор",
+			Auth: openapi.AuthBearer, Status: http.StatusCreated,
+			Body: models.CreateOrderRequest{}, Response: models.OrderResponse{}, ETag: true, Errors: []int{http.StatusForbidden}},
+		openapi.Route{Method: http.MethodGet, Path: "/orders/:id", Tag: "orders", Summary: "Заказ по ID: владелец или администратор", Auth: openapi.AuthBearer,
+			Query: []openapi.Param{includeDeleted}, Response: models.OrderResponse{}, ETag: true, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		openapi.Route{Method: http.MethodGet, Path: "/admin/orders", Tag: "orders", Summary: "Все заказы", Auth: openapi.AuthAdmin,
+			Query: []openapi.Param{includeDeleted}, Response: []models.OrderResponse{}},
+		openapi.Route{Method: http.MethodPost, Path: "/orders/:id/cancel", Tag: "orders", Summary: "Отмена заказа до отгрузки", Auth: openapi.AuthBearer,
+			Body: models.CancelOrderRequest{}, BodyOptional: true, Response: models.OrderResponse{},
+			Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodGet, Path: "/orders/:id/history", Tag: "orders", Summary: "История статусов заказа", Auth: openapi.AuthBearer,
+			Response: []models.OrderStatusChange{}, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/users/:id/export", Tag: "privacy", Summary: "Выгрузка персональных данных (zip)", Auth: openapi.AuthBearer,
+			ResponseType: "application/zip", Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		openapi.Route{Method: http.MethodDelete, Path: "/users/:id", Tag: "privacy", Summary: "Удаление пользователя и обезличивание его данных", Auth: openapi.AuthBearer,
+			Response: models.MessageResponse{}, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		openapi.Route{Method: http.MethodGet, Path: "/users/:id/order-summary", Tag: "orders", Summary: "Сводка по заказам пользователя", Auth: openapi.AuthBearer,
+			Response: models.OrderUserSummary{}, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+
+		openapi.Route{Method: http.MethodPost, Path: "/orders/:id/returns", Tag: "returns", Summary: "Заявка на возврат", Auth: openapi.AuthBearer, Status: http.StatusCreated,
+			Body: handlers.CreateReturnRequest{}, Response: models.Return{}, Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodGet, Path: "/orders/:id/returns", Tag: "returns", Summary: "Заявки на возврат по заказу", Auth: openapi.AuthBearer,
+			Response: []models.Return{}, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		openapi.Route{Method: http.MethodGet, Path: "/returns/:id", Tag: "returns", Summary: "Заявка на возврат по ID", Auth: openapi.AuthBearer,
+			Response: models.Return{}, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		openapi.Route{Method: http.MethodGet, Path: "/orders/:id/refunds", Tag: "returns", Summary: "Возвраты средств по заказу", Auth: openapi.AuthBearer,
+			Response: []models.Refund{}, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/orders/:id/invoice.pdf", Tag: "documents", Summary: "Счёт по заказу", Auth: openapi.AuthBearer,
+			Description:  "Новый счёт выпускается только по оплаченному заказу, иначе 409 order_not_invoiceable.",
+			ResponseType: "application/pdf", ETag: true, Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodGet, Path: "/refunds/:id/credit-note.pdf", Tag: "documents", Summary: "Кредит-нота по возврату средств", Auth: openapi.AuthBearer,
+			ResponseType: "application/pdf", ETag: true, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/admin/users", Tag: "users", Summary: "Все пользователи", Auth: openapi.AuthAdmin,
+			Query: []openapi.Param{includeDeleted}, Response: []models.UserResponse{}},
+		openapi.Route{Method: http.MethodDelete, Path: "/admin/users/:id", Tag: "users", Summary: "Деактивация (мягкое удаление) пользователя", Auth: openapi.AuthAdmin,
+			Response: models.MessageResponse{}, Errors: notFound},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/users/:id/restore", Tag: "users", Summary: "Восстановление пользователя", Auth: openapi.AuthAdmin,
+			Response: models.UserResponse{}, ETag: true, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodPut, Path: "/admin/orders/:id", Tag: "orders", Summary: "Смена владельца или статуса заказа", Auth: openapi.AuthAdmin, IfMatch: true,
+			Description: orderStatusRule, Body: models.UpdateOrderRequest{}, Response: models.OrderResponse{}, ETag: true,
+			Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodPatch, Path: "/admin/orders/:id", Tag: "orders", Summary: "Частичное обновление заказа", Auth: openapi.AuthAdmin, IfMatch: true,
+			Description: orderStatusRule, Patch: models.UpdateOrderRequest{}, PatchFields: models.OrderPatchFields, Response: models.OrderResponse{}, ETag: true,
+			Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodDelete, Path: "/admin/orders/:id", Tag: "orders", Summary: "Мягкое удаление заказа", Auth: openapi.AuthAdmin,
+			Response: models.MessageResponse{}, Errors: notFound},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/orders/:id/restore", Tag: "orders", Summary: "Восстановление заказа", Auth: openapi.AuthAdmin,
+			Response: models.OrderResponse{}, ETag: true, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodGet, Path: "/admin/orders/:id/events", Tag: "orders", Summary: "Журнал событий заказа",
+			Description: "События в порядке версий; версия события совпадает с версией заказа после него.", Auth: openapi.AuthAdmin,
+			Response: []models.OrderEvent{}, Errors: notFound},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/products", Tag: "products", Summary: "Создание продукта", Auth: openapi.AuthAdmin,
+			Body: models.ProductRequest{}, Response: models.ProductResponse{}, ETag: true, Errors: []int{http.StatusConflict}},
+		openapi.Route{Method: http.MethodPut, Path: "/admin/products/:id", Tag: "products", Summary: "Обновление продукта", Auth: openapi.AuthAdmin, IfMatch: true,
+			Body: models.ProductRequest{}, Response: models.ProductResponse{}, ETag: true, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodPatch, Path: "/admin/products/:id", Tag: "products", Summary: "Частичное обновление продукта", Auth: openapi.AuthAdmin, IfMatch: true,
+			Patch: models.ProductRequest{}, PatchFields: models.ProductPatchFields, Response: models.ProductResponse{}, ETag: true,
+			Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodDelete, Path: "/admin/products/:id", Tag: "products", Summary: "Мягкое удаление продукта", Auth: openapi.AuthAdmin,
+			Response: models.MessageResponse{}, Errors: notFound},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/products/:id/restore", Tag: "products", Summary: "Восстановление продукта", Auth: openapi.AuthAdmin,
+			Response: models.ProductResponse{}, ETag: true, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/admin/returns", Tag: "returns", Summary: "Заявки на возврат", Auth: openapi.AuthAdmin,
+			Query: []openapi.Param{openapi.QueryParam("status", "string", "requested, rejected или refunded")}, Response: []models.Return{}},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/returns/:id/approve", Tag: "returns", Summary: "Одобрение заявки и возврат средств", Auth: openapi.AuthAdmin,
+			Body: handlers.ResolveReturnRequest{}, BodyOptional: true, Response: models.Return{}, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/returns/:id/reject", Tag: "returns", Summary: "Отклонение заявки", Auth: openapi.AuthAdmin,
+			Body: handlers.ResolveReturnRequest{}, BodyOptional: true, Response: models.Return{}, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/admin/audit", Tag: "audit", Summary: "Записи журнала аудита, от новых к старым", Auth: openapi.AuthAdmin,
+			Query: []openapi.Param{
+				openapi.QueryParam("actor_id", "string", "Автор изменения"),
+				openapi.QueryParam("action", "string", "Действие: product.update"),
+				openapi.QueryParam("entity_type", "string", "Тип сущности"),
+				openapi.QueryParam("entity_id", "string", "ID сущности"),
+				openapi.QueryParam("request_id", "string", "X-Request-ID запроса"),
+				{Name: "from", Description: "Не раньше (RFC 3339)", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+				{Name: "to", Description: "Не позже (RFC 3339)", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+				limit, beforeID,
+			}, Response: models.AuditPage{}},
+		openapi.Route{Method: http.MethodGet, Path: "/admin/audit/verify", Tag: "audit", Summary: "Проверка цепочки хэшей журнала", Auth: openapi.AuthAdmin,
+			Response: models.AuditVerification{}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/admin/webhooks", Tag: "webhooks", Summary: "Подписки", Auth: openapi.AuthAdmin,
+			Response: []models.WebhookSubscription{}},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/webhooks", Tag: "webhooks", Summary: "Создание подписки; секрет возвращается только здесь", Auth: openapi.AuthAdmin,
+			Status: http.StatusCreated, Body: models.WebhookSubscriptionRequest{}, Response: models.WebhookSubscriptionResponse{}, ETag: true},
+		openapi.Route{Method: http.MethodGet, Path: "/admin/webhooks/:id", Tag: "webhooks", Summary: "Подписка по ID", Auth: openapi.AuthAdmin,
+			Response: models.WebhookSubscription{}, ETag: true, Errors: notFound},
+		openapi.Route{Method: http.MethodPut, Path: "/admin/webhooks/:id", Tag: "webhooks", Summary: "Обновление подписки", Auth: openapi.AuthAdmin, IfMatch: true,
+			Body: models.WebhookSubscriptionRequest{}, Response: models.WebhookSubscription{}, ETag: true, Errors: notFound},
+		openapi.Route{Method: http.MethodDelete, Path: "/admin/webhooks/:id", Tag: "webhooks", Summary: "Удаление подписки", Auth: openapi.AuthAdmin,
+			Response: models.MessageResponse{}, Errors: notFound},
+		openapi.Route{Method: http.MethodGet, Path: "/admin/webhooks/:id/deliveries", Tag: "webhooks", Summary: "Журнал доставок, от новых к старым", Auth: openapi.AuthAdmin,
+			Query:    []openapi.Param{openapi.QueryParam("status", "string", "pending, delivered или failed"), limit, beforeID},
+			Response: models.WebhookDeliveryPage{}, Errors: notFound},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/webhooks/:id/deliveries/:deliveryID/redeliver", Tag: "webhooks", Summary: "Повторная отправка события",
+			Auth: openapi.AuthAdmin, Status: http.StatusAccepted, Response: models.WebhookDelivery{}, Errors: notFound},
+
+		openapi.Route{Method: http.MethodGet, Path: "/admin/warehouses", Tag: "inventory", Summary: "Склады в порядке приоритета", Auth: openapi.AuthAdmin,
+			Response: []models.Warehouse{}},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/warehouses", Tag: "inventory", Summary: "Создание склада", Auth: openapi.AuthAdmin,
+			Status: http.StatusCreated, Body: models.WarehouseRequest{}, Response: models.Warehouse{}, ETag: true, Errors: []int{http.StatusConflict}},
+		openapi.Route{Method: http.MethodGet, Path: "/admin/warehouses/:id", Tag: "inventory", Summary: "Склад по ID", Auth: openapi.AuthAdmin,
+			Response: models.Warehouse{}, ETag: true, Errors: notFound},
+		openapi.Route{Method: http.MethodPut, Path: "/admin/warehouses/:id", Tag: "inventory", Summary: "Обновление склада", Auth: openapi.AuthAdmin, IfMatch: true,
+			Description: "Выключенный склад не участвует в резервировании; уже зарезервированное с него отгружается.",
+			Body:        models.WarehouseRequest{}, Response: models.Warehouse{}, ETag: true, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodGet, Path: "/admin/inventory/:sku", Tag: "inventory", Summary: "Остатки SKU по складам, вычисленные по журналу",
+			Auth: openapi.AuthAdmin, Response: models.SKUAvailability{}},
+		openapi.Route{Method: http.MethodGet, Path: "/admin/stock-movements", Tag: "inventory", Summary: "Журнал движений остатков, от новых к старым", Auth: openapi.AuthAdmin,
+			Query: []openapi.Param{
+				openapi.QueryParam("sku", "string", "SKU"),
+				openapi.QueryParam("warehouse_id", "string", "ID склада"),
+				openapi.QueryParam("order_id", "string", "ID заказа"),
+				openapi.QueryParam("type", "string", "receipt, reservation, release, shipment или adjustment"),
+				limit, beforeID,
+			}, Response: models.StockMovementPage{}},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/stock-movements", Tag: "inventory", Summary: "Поступление или корректировка остатка", Auth: openapi.AuthAdmin,
+			Description: "Корректировка со знаком минус не может опустить остаток склада ниже зарезервированного (409).",
+			Status:      http.StatusCreated, Body: models.StockMovementRequest{}, Response: models.StockMovement{}, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/categories", Tag: "categories", Summary: "Дерево категорий", Response: []models.Category{}},
+		openapi.Route{Method: http.MethodGet, Path: "/categories/:slug", Tag: "categories", Summary: "Категория по slug", Response: models.Category{}, Errors: notFound},
+		openapi.Route{Method: http.MethodPost, Path: "/admin/categories", Tag: "categories", Summary: "Создание категории", Auth: openapi.AuthAdmin, Status: http.StatusCreated,
+			Body: handlers.CategoryRequest{}, Response: models.Category{}, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodPut, Path: "/admin/categories/:slug", Tag: "categories", Summary: "Переименование или перенос категории", Auth: openapi.AuthAdmin,
+			Body: handlers.CategoryRequest{}, Response: models.Category{}, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodDelete, Path: "/admin/categories/:slug", Tag: "categories", Summary: "Удаление категории без подкатегорий и продуктов", Auth: openapi.AuthAdmin,
+			Response: models.MessageResponse{}, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/products", Tag: "products", Summary: "Все продукты", Auth: openapi.AuthOptional,
+			Query: []openapi.Param{includeDeleted}, Response: []models.ProductResponse{}, Errors: []int{http.StatusForbidden}},
+		openapi.Route{Method: http.MethodGet, Path: "/products/search", Tag: "products", Summary: "Полнотекстовый поиск с фасетами",
+			Query: []openapi.Param{
+				openapi.QueryParam("q", "string", "Поисковый запрос"),
+				openapi.QueryParam("category", "string", "Slug категории, включая подкатегории"),
+				openapi.QueryParam("min_price", "number", "Минимальная цена"),
+				openapi.QueryParam("max_price", "number", "Максимальная цена"),
+				openapi.QueryParam("in_stock", "boolean", "Только в наличии"),
+				openapi.QueryParam("page", "integer", "Номер страницы, с 1, не больше 1000"),
+				openapi.QueryParam("page_size", "integer", "Размер страницы, до 100"),
+			}, Response: models.ProductSearchResult{}},
+		openapi.Route{Method: http.MethodGet, Path: "/products/:id", Tag: "products", Summary: "Продукт по ID", Auth: openapi.AuthOptional,
+			Query: []openapi.Param{includeDeleted}, Response: models.ProductResponse{}, ETag: true, Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		openapi.Route{Method: http.MethodGet, Path: "/products/:id/resolve", Tag: "products", Summary: "Канонический ID продукта по устаревшему",
+			Response: models.ProductIDResolution{}, Errors: notFound},
+
+		openapi.Route{Method: http.MethodPost, Path: "/cart/:userID", Tag: "cart", Summary: "Добавление товара в корзину", Auth: openapi.AuthBearer,
+			Body: handlers.AddToCartRequest{}, Response: models.MessageResponse{}, Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		openapi.Route{Method: http.MethodDelete, Path: "/cart/:userID/:sku", Tag: "cart", Summary: "Удаление товара из корзины", Auth: openapi.AuthBearer,
+			Response: models.MessageResponse{}, Errors: []int{http.StatusForbidden}},
+		openapi.Route{Method: http.MethodGet, Path: "/cart/:userID", Tag: "cart", Summary: "Содержимое корзины с текущими ценами", Auth: openapi.AuthBearer,
+			Response: models.CartResponse{}, Errors: []int{http.StatusForbidden}},
+		openapi.Route{Method: http.MethodPost, Path: "/cart/:userID/checkout", Tag: "cart", Summary: "Оформление заказа из корзины", Auth: openapi.AuthBearer,
+			Description: "Позиции удалённых продуктов не оформляются и остаются в корзине — они перечислены в skipped. Остальные резервируются на складах; если не хватает — 409.",
+			Response:    models.CheckoutResponse{}, Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+
+		openapi.Route{Method: http.MethodGet, Path: "/graphql", Tag: "graphql", Summary: "GraphQL-запрос в параметрах строки", Auth: openapi.AuthOptional,
+			Query: []openapi.Param{
+				{Name: "query", Description: "Текст запроса", Required: true, Schema: &openapi.Schema{Type: "string"}},
+				openapi.QueryParam("operationName", "string", "Операция из запроса"),
+				openapi.QueryParam("variables", "string", "Переменные — объект JSON"),
+			}, Response: graphql.Response{}, Errors: []int{http.StatusUnauthorized}},
+		openapi.Route{Method: http.MethodPost, Path: "/graphql", Tag: "graphql", Summary: "GraphQL-запрос", Auth: openapi.AuthOptional,
+			Description: "Ошибки запроса и резолверов возвращаются в errors со статусом 200.",
+			Body:        models.GraphQLRequest{}, Response: graphql.Response{}, Errors: []int{http.StatusUnauthorized}},
+	)
+	return doc
+}
+
+func intPtr(v int) *int {
+	return &v
+}
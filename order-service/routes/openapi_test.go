@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestOpenAPICoversRoutes сверяет маршруты RegisterRoutes со спецификацией. Обработчики не нужны:
+// маршруты регистрируются на отдельном движке без сервисов и баз данных.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	RegisterRoutes(engine, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	for _, problem := range OpenAPI().Diff(engine.Routes()) {
+		t.Error(problem)
+	}
+}
@@ -1,37 +1,126 @@
 package routes
 
 import (
+	"net/http"
 	"order-service/handlers"
+	"order-service/metrics"
+	"order-service/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(r *gin.Engine, userHandler *handlers.UserHandler, orderHandler *handlers.OrderHandler, productHandler *handlers.ProductHandler, cartHandler *handlers.CartHandler) {
+func RegisterRoutes(r *gin.Engine, userHandler *handlers.UserHandler, orderHandler *handlers.OrderHandler, productHandler *handlers.ProductHandler, cartHandler *handlers.CartHandler, returnHandler *handlers.ReturnHandler, invoiceHandler *handlers.InvoiceHandler, categoryHandler *handlers.CategoryHandler, healthHandler *handlers.HealthHandler, auditHandler *handlers.AuditHandler, privacyHandler *handlers.PrivacyHandler, webhookHandler *handlers.WebhookHandler, inventoryHandler *handlers.InventoryHandler, graphqlHandler *handlers.GraphQLHandler, docsHandler *handlers.DocsHandler) {
+	// Неизвестные маршруты тоже отвечают в формате problem+json
+	r.NoRoute(func(c *gin.Context) {
+		middleware.RespondProblem(c, http.StatusNotFound, "route_not_found", "Route not found")
+	})
+
+	// Проверки живости и готовности для оркестратора
+	r.GET("/healthz", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Спецификация OpenAPI (см. OpenAPI) и Swagger UI
+	r.GET("/openapi.json", docsHandler.OpenAPI)
+	r.GET("/docs", docsHandler.SwaggerUI)
+
 	// Регистрация маршрутов для пользователей
 	r.POST("/register", userHandler.Register)
 	r.POST("/login", userHandler.Login)
-	r.GET("/users", userHandler.GetAllUsers)
-	r.GET("/users/:id", userHandler.GetUserByID)
-	r.PUT("/users/:id", userHandler.UpdateUser)
-	r.DELETE("/users/:id", userHandler.DeleteUser)
-
-	// Регистрация маршрутов для заказов
-	r.POST("/orders", orderHandler.CreateOrder)
-	r.GET("/orders/:id", orderHandler.GetOrderById)
-	r.GET("/orders/", orderHandler.GetAllOrders)
-	r.DELETE("/orders/:id", orderHandler.DeleteOrder)
-	r.PUT("/orders/:id", orderHandler.UpdateOrder)
+
+	// Заказы, возвраты (RMA) и документы требуют авторизации: доступны владельцу заказа и администратору
+	authorized := r.Group("/", middleware.AuthRequired())
+	authorized.POST("/orders", orderHandler.CreateOrder)
+	authorized.GET("/orders/:id", orderHandler.GetOrderById)
+	authorized.POST("/orders/:id/cancel", orderHandler.CancelOrder)
+
+	// Профиль, выгрузка и удаление персональных данных — сам пользователь или администратор
+	authorized.GET("/users/:id", middleware.SelfOrAdmin("id"), userHandler.GetUserByID)
+	authorized.PUT("/users/:id", userHandler.UpdateUser)
+	authorized.PATCH("/users/:id", userHandler.PatchUser)
+	authorized.GET("/users/:id/export", privacyHandler.ExportUser)
+	authorized.DELETE("/users/:id", privacyHandler.EraseUser)
+	authorized.GET("/users/:id/order-summary", orderHandler.GetUserOrderSummary)
+
+	authorized.GET("/orders/:id/history", orderHandler.GetOrderHistory)
+	authorized.POST("/orders/:id/returns", returnHandler.CreateReturn)
+	authorized.GET("/orders/:id/returns", returnHandler.GetOrderReturns)
+	authorized.GET("/returns/:id", returnHandler.GetReturn)
+
+	// Счета и кредит-ноты
+	authorized.GET("/orders/:id/invoice.pdf", invoiceHandler.GetInvoice)
+	authorized.GET("/orders/:id/refunds", orderHandler.GetOrderRefunds)
+	authorized.GET("/refunds/:id/credit-note.pdf", invoiceHandler.GetCreditNote)
+
+	admin := r.Group("/admin", middleware.AuthRequired(), middleware.AdminOnly())
+	admin.GET("/orders", orderHandler.GetAllOrders)
+	// Владельца и статус заказа меняет администратор; статус — только вперёд до delivered
+	admin.PUT("/orders/:id", orderHandler.UpdateOrder)
+	admin.PATCH("/orders/:id", orderHandler.PatchOrder)
+	// Список пользователей с их email — персональные данные, поэтому только для администратора
+	admin.GET("/users", userHandler.GetAllUsers)
+	// Мягкое удаление и восстановление; удалённые записи видны администратору с ?include_deleted
+	admin.DELETE("/users/:id", userHandler.DeleteUser)
+	admin.POST("/users/:id/restore", userHandler.RestoreUser)
+	admin.DELETE("/orders/:id", orderHandler.DeleteOrder)
+	admin.POST("/orders/:id/restore", orderHandler.RestoreOrder)
+	// Журнал событий заказа, из которого строятся его проекции
+	admin.GET("/orders/:id/events", orderHandler.GetOrderEvents)
+	admin.DELETE("/products/:id", productHandler.DeleteProduct)
+	admin.POST("/products/:id/restore", productHandler.RestoreProduct)
+	// Каталог изменяет только администратор: в аудите цен и остатков всегда есть автор
+	admin.POST("/products", productHandler.CreateProduct)
+	admin.PUT("/products/:id", productHandler.UpdateProduct)
+	admin.PATCH("/products/:id", productHandler.PatchProduct)
+
+	admin.GET("/returns", returnHandler.ListReturns)
+	admin.POST("/returns/:id/approve", returnHandler.ApproveReturn)
+	admin.POST("/returns/:id/reject", returnHandler.RejectReturn)
+
+	// Журнал аудита и проверка его целостности
+	admin.GET("/audit", auditHandler.ListAudit)
+	admin.GET("/audit/verify", auditHandler.VerifyAudit)
+
+	// Подписки на события заказов и журнал их доставок
+	admin.GET("/webhooks", webhookHandler.ListWebhooks)
+	admin.POST("/webhooks", webhookHandler.CreateWebhook)
+	admin.GET("/webhooks/:id", webhookHandler.GetWebhook)
+	admin.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+	admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+	admin.GET("/webhooks/:id/deliveries", webhookHandler.ListWebhookDeliveries)
+	admin.POST("/webhooks/:id/deliveries/:deliveryID/redeliver", webhookHandler.RedeliverWebhook)
+
+	// Склады, остатки по складам и журнал движений остатков
+	admin.GET("/warehouses", inventoryHandler.ListWarehouses)
+	admin.POST("/warehouses", inventoryHandler.CreateWarehouse)
+	admin.GET("/warehouses/:id", inventoryHandler.GetWarehouse)
+	admin.PUT("/warehouses/:id", inventoryHandler.UpdateWarehouse)
+	admin.GET("/inventory/:sku", inventoryHandler.GetAvailability)
+	admin.GET("/stock-movements", inventoryHandler.ListStockMovements)
+	admin.POST("/stock-movements", inventoryHandler.RecordStockMovement)
+
+	// Дерево категорий изменяет только администратор
+	r.GET("/categories", categoryHandler.GetCategoryTree)
+	r.GET("/categories/:slug", categoryHandler.GetCategory)
+	admin.POST("/categories", categoryHandler.CreateCategory)
+	admin.PUT("/categories/:slug", categoryHandler.UpdateCategory)
+	admin.DELETE("/categories/:slug", categoryHandler.DeleteCategory)
 
 	// Регистрация маршрутов для продуктов
-	r.POST("/products", productHandler.CreateProduct)
 	r.GET("/products", productHandler.GetAllProducts)
+	r.GET("/products/search", productHandler.SearchProducts)
 	r.GET("/products/:id", productHandler.GetProductById)
-	r.PUT("/products/:id", productHandler.UpdateProduct)
-	r.DELETE("/products/:id", productHandler.DeleteProduct)
-
-	// Регистрация маршрутов для корзины
-	r.POST("/cart/:userID", cartHandler.AddToCart)
-	r.DELETE("/cart/:userID/:productID", cartHandler.RemoveFromCart)
-	r.GET("/cart/:userID", cartHandler.GetCart)
-	r.POST("/cart/:userID/checkout", cartHandler.CheckoutCart)
+	r.GET("/products/:id/resolve", productHandler.ResolveProductID)
+
+	// Корзиной пользуется её владелец или администратор
+	cart := authorized.Group("/cart/:userID", middleware.SelfOrAdmin("userID"))
+	cart.POST("", cartHandler.AddToCart)
+	cart.DELETE("/:sku", cartHandler.RemoveFromCart)
+	cart.GET("", cartHandler.GetCart)
+	cart.POST("/checkout", cartHandler.CheckoutCart)
+
+	// GraphQL: каталог доступен без токена; пользователи, корзины и заказы — владельцу или администратору,
+	// списки — администратору, как в REST
+	r.GET("/graphql", middleware.OptionalAuth(), graphqlHandler.Query)
+	r.POST("/graphql", middleware.OptionalAuth(), graphqlHandler.Query)
 }
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"order-service/models"
+	"order-service/repositories"
+	"slices"
+)
+
+// allocationStrategies — стратегии выбора склада по имени из настройки inventory.allocation
+var allocationStrategies = map[string]repositories.StockAllocator{
+	models.AllocationPriority:        allocateByPriority,
+	models.AllocationSingleWarehouse: allocateSingleWarehouse,
+	models.AllocationMostAvailable:   allocateMostAvailable,
+}
+
+// allocateByPriority берёт каждую позицию с самого приоритетного склада;
+// то, чего там не хватает, добирается со следующих
+func allocateByPriority(lines []models.StockLine, levels []models.StockLevel) ([]models.StockAllocation, error) {
+	return allocateInOrder(lines, levels, nil)
+}
+
+// allocateMostAvailable берёт каждую позицию со склада, где её доступно больше всего,
+// при равенстве — с более приоритетного
+func allocateMostAvailable(lines []models.StockLine, levels []models.StockLevel) ([]models.StockAllocation, error) {
+	return allocateInOrder(lines, levels, func(a, b models.StockLevel) int {
+		return cmp.Compare(b.Available, a.Available)
+	})
+}
+
+// allocateSingleWarehouse резервирует весь заказ на самом приоритетном складе, где хватает всех позиций,
+// чтобы заказ ушёл одной отгрузкой; если такого склада нет — как allocateByPriority
+func allocateSingleWarehouse(lines []models.StockLine, levels []models.StockLevel) ([]models.StockAllocation, error) {
+	var warehouses []string
+	available := map[string]map[string]int{}
+	for _, level := range levels {
+		if available[level.WarehouseID] == nil {
+			available[level.WarehouseID] = map[string]int{}
+			warehouses = append(warehouses, level.WarehouseID)
+		}
+		available[level.WarehouseID][level.SKU] = level.Available
+	}
+
+	for _, warehouse := range warehouses {
+		fits := true
+		for _, line := range lines {
+			if available[warehouse][line.SKU] < line.Quantity {
+				fits = false
+				break
+			}
+		}
+		if !fits {
+			continue
+		}
+		allocations := make([]models.StockAllocation, 0, len(lines))
+		for _, line := range lines {
+			allocations = append(allocations, models.StockAllocation{WarehouseID: warehouse, SKU: line.SKU, Quantity: line.Quantity})
+		}
+		return allocations, nil
+	}
+	return allocateByPriority(lines, levels)
+}
+
+// allocateInOrder набирает каждую позицию со складов в порядке levels (по приоритету),
+// предварительно переупорядоченных compare, если он задан. SKU в lines не повторяются.
+func allocateInOrder(lines []models.StockLine, levels []models.StockLevel, compare func(a, b models.StockLevel) int) ([]models.StockAllocation, error) {
+	bySKU := map[string][]models.StockLevel{}
+	for _, level := range levels {
+		bySKU[level.SKU] = append(bySKU[level.SKU], level)
+	}
+
+	var allocations []models.StockAllocation
+	for _, line := range lines {
+		candidates := bySKU[line.SKU]
+		if compare != nil {
+			slices.SortStableFunc(candidates, compare)
+		}
+		remaining := line.Quantity
+		for _, level := range candidates {
+			take := min(remaining, level.Available)
+			if take <= 0 {
+				continue
+			}
+			allocations = append(allocations, models.StockAllocation{WarehouseID: level.WarehouseID, SKU: line.SKU, Quantity: take})
+			remaining -= take
+		}
+		if remaining > 0 {
+			return nil, fmt.Errorf("%w: %s", repositories.ErrInsufficientStock, line.SKU)
+		}
+	}
+	return allocations, nil
+}
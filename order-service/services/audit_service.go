@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"order-service/logging"
+	"order-service/models"
+	"order-service/repositories"
+	"reflect"
+)
+
+// Размер страницы журнала аудита
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// ActorRoleSystem — роль автора изменений, сделанных фоновыми задачами сервиса
+const ActorRoleSystem = "system"
+
+// Actor — кто выполняет запрос; middleware кладёт его в контекст запроса
+type Actor struct {
+	UserID string
+	Role   string
+	IP     string
+}
+
+type actorKey struct{}
+
+// WithActor сохраняет в контексте автора запроса для журнала аудита
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext возвращает автора запроса; для фоновых задач — пустой Actor
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// auditIgnoredFields меняются при каждом изменении и в журнал не пишутся
+var auditIgnoredFields = map[string]bool{"updated_at": true}
+
+// auditPersonalFields — персональные данные: журнал только дополняется и не может забыть их
+// при удалении пользователя, поэтому фиксируется лишь факт изменения, без значений.
+// Свободный текст (причина возврата, комментарий) может содержать что угодно о покупателе.
+var auditPersonalFields = map[string]bool{"username": true, "email": true, "reason": true, "admin_comment": true}
+
+type AuditService struct {
+	Repo *repositories.AuditRepository
+}
+
+func NewAuditService(repo *repositories.AuditRepository) *AuditService {
+	return &AuditService{Repo: repo}
+}
+
+// Record записывает в журнал изменение сущности: before — состояние до изменения (nil при создании),
+// after — после (nil при удалении). Изменение к этому моменту уже сохранено, поэтому ошибка записи
+// журнала не отменяет операцию, а логируется.
+func (s *AuditService) Record(ctx context.Context, action, entityType, entityID string, before, after any) {
+	if s == nil {
+		return
+	}
+	ctx, span := tracer.Start(ctx, "AuditService.Record")
+	defer span.End()
+
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to build audit changes", "action", action, "entity_id", entityID, "error", err)
+		return
+	}
+
+	actor := ActorFromContext(ctx)
+	entry := &models.AuditEntry{
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    changes,
+		RequestID:  logging.RequestID(ctx),
+		IP:         actor.IP,
+	}
+	// Клиент мог уже отключиться, но запись об изменении должна сохраниться
+	if err := s.Repo.Append(context.WithoutCancel(ctx), entry); err != nil {
+		logger.ErrorContext(ctx, "failed to write audit entry", "action", action, "entity_id", entityID, "error", err)
+	}
+}
+
+// List возвращает страницу журнала по фильтру
+func (s *AuditService) List(ctx context.Context, filter models.AuditFilter) (*models.AuditPage, error) {
+	ctx, span := tracer.Start(ctx, "AuditService.List")
+	defer span.End()
+
+	var fields models.FieldErrors
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = defaultAuditLimit
+	case filter.Limit < 0 || filter.Limit > maxAuditLimit:
+		fields.Add("limit", "must be between 1 and 500")
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		fields.Add("to", "must be after from")
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.Repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.AuditPage{Items: entries}
+	if len(entries) == filter.Limit {
+		page.NextBeforeID = &entries[len(entries)-1].ID
+	}
+	return page, nil
+}
+
+// Verify проверяет целостность цепочки хэшей журнала
+func (s *AuditService) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	ctx, span := tracer.Start(ctx, "AuditService.Verify")
+	defer span.End()
+
+	return s.Repo.Verify(ctx)
+}
+
+// auditChanges возвращает изменённые поля сущности: {"поле": {"before": ..., "after": ...}}.
+// Поля берутся из JSON-представления сущности, поэтому скрытые из API поля (хэш пароля) в журнал не попадают.
+// Значения персональных полей, в том числе вложенных, заменяются на [REDACTED].
+func auditChanges(before, after any) (json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]map[string]any{}
+	for name, value := range beforeFields {
+		if auditIgnoredFields[name] {
+			continue
+		}
+		if afterValue, ok := afterFields[name]; !ok || !reflect.DeepEqual(value, afterValue) {
+			changes[name] = map[string]any{"before": value}
+		}
+	}
+	for name, value := range afterFields {
+		if auditIgnoredFields[name] {
+			continue
+		}
+		if beforeValue, ok := beforeFields[name]; !ok || !reflect.DeepEqual(beforeValue, value) {
+			if changes[name] == nil {
+				changes[name] = map[string]any{}
+			}
+			changes[name]["after"] = value
+		}
+	}
+	for name, change := range changes {
+		for side, value := range change {
+			if auditPersonalFields[name] {
+				change[side] = logging.Redacted
+			} else {
+				change[side] = redactPersonal(value)
+			}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+// redactPersonal заменяет на [REDACTED] персональные поля во вложенных объектах и списках (позиции возврата)
+func redactPersonal(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for name, nested := range value {
+			if auditPersonalFields[name] {
+				value[name] = logging.Redacted
+			} else {
+				value[name] = redactPersonal(nested)
+			}
+		}
+	case []any:
+		for i, nested := range value {
+			value[i] = redactPersonal(nested)
+		}
+	}
+	return value
+}
+
+// auditFields переводит сущность в набор полей её JSON-представления; nil — пустой набор
+func auditFields(entity any) (map[string]any, error) {
+	if entity == nil || reflect.ValueOf(entity).Kind() == reflect.Pointer && reflect.ValueOf(entity).IsNil() {
+		return nil, nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
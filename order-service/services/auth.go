@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"order-service/models"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrForbidden — у пользователя нет доступа к ресурсу
+var ErrForbidden = models.NewForbidden("forbidden", "access denied")
+
+// Ошибки проверки токена; одинаковы для REST и gRPC
+var (
+	ErrTokenRequired = models.NewUnauthorized("token_required", "Authorization token required")
+	ErrInvalidToken  = models.NewUnauthorized("invalid_token", "Invalid token")
+	ErrAdminRequired = models.NewForbidden("admin_required", "Admin access required")
+	// ErrDeletedAdminOnly — удалённые записи запрошены не администратором
+	ErrDeletedAdminOnly = models.NewForbidden("admin_required", "only admins can view deleted records")
+)
+
+// JWTSecret — ключ подписи токенов и TokenTTL — срок их действия; задаются из настроек при запуске
+var (
+	JWTSecret = []byte("secret_key")
+	TokenTTL  = 24 * time.Hour
+)
+
+// TokenClaims — данные пользователя, извлечённые из JWT
+type TokenClaims struct {
+	UserID string
+	Role   string
+}
+
+// IsAdmin сообщает, является ли владелец токена администратором
+func (c *TokenClaims) IsAdmin() bool {
+	return c.Role == models.RoleAdmin
+}
+
+// CanAccess сообщает, может ли владелец токена работать с данными пользователя ownerID
+func (c *TokenClaims) CanAccess(ownerID string) bool {
+	return c.IsAdmin() || c.UserID == ownerID
+}
+
+// GenerateToken выпускает JWT для пользователя
+func GenerateToken(user *models.User) (string, error) {
+	role := user.Role
+	if role == "" {
+		role = models.RoleCustomer
+	}
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"role":    role,
+		"exp":     time.Now().Add(TokenTTL).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(JWTSecret)
+}
+
+// Authenticate проверяет значение заголовка Authorization: "Bearer {token}"
+func Authenticate(header string) (*TokenClaims, error) {
+	tokenString, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || tokenString == "" {
+		return nil, ErrTokenRequired
+	}
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseToken проверяет подпись и срок действия JWT
+func ParseToken(tokenString string) (*TokenClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return JWTSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	userID, _ := claims["user_id"].(string)
+	if userID == "" {
+		return nil, errors.New("token has no user_id")
+	}
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = models.RoleCustomer
+	}
+
+	return &TokenClaims{UserID: userID, Role: role}, nil
+}
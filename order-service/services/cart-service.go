@@ -3,66 +3,102 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"order-service/metrics"
 	"order-service/models"
 	"order-service/repositories"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrInvalidCart — некорректная позиция или пустая корзина
+var ErrInvalidCart = models.NewValidation("invalid_cart", "invalid cart")
+
 type CartService struct {
 	RedisClient *redis.Client
 	ProductRepo *repositories.ProductRepository
 	OrderRepo   *repositories.OrderRepository
 	UserRepo    *repositories.UserRepository
+	Inventory   *InventoryService
+	Audit       *AuditService
+	Webhooks    *WebhookService
 }
 
-func NewCartService(redisClient *redis.Client, productRepo *repositories.ProductRepository, orderRepo *repositories.OrderRepository, userRepo *repositories.UserRepository) *CartService {
+func NewCartService(redisClient *redis.Client, productRepo *repositories.ProductRepository, orderRepo *repositories.OrderRepository, userRepo *repositories.UserRepository, inventory *InventoryService, audit *AuditService, webhooks *WebhookService) *CartService {
 	return &CartService{
 		RedisClient: redisClient,
 		ProductRepo: productRepo,
 		OrderRepo:   orderRepo,
 		UserRepo:    userRepo,
+		Inventory:   inventory,
+		Audit:       audit,
+		Webhooks:    webhooks,
 	}
 }
 
-func (s *CartService) AddToCart(userID, productID string, quantity int) error {
-	ctx := context.Background()
+// AddToCart добавляет в корзину вариант продукта по SKU. Если SKU не передан,
+// используется единственный вариант продукта productID.
+func (s *CartService) AddToCart(ctx context.Context, userID, sku, productID string, quantity int) error {
+	ctx, span := tracer.Start(ctx, "CartService.AddToCart")
+	defer span.End()
+
 	key := fmt.Sprintf("cart:%s", userID)
 
 	// Проверяем существование пользователя
-	_, err := s.UserRepo.GetUserByID(userID)
+	_, err := s.UserRepo.GetUserByID(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("user not found: %v", err)
+		return err
 	}
 
-	// Проверяем существование продукта
-	_, err = s.ProductRepo.GetProductById(productID)
-	if err != nil {
-		return fmt.Errorf("product not found: %v", err)
+	// Проверяем существование варианта продукта
+	if sku == "" {
+		product, err := s.ProductRepo.GetProductById(ctx, productID)
+		if errors.Is(err, repositories.ErrProductNotFound) {
+			return models.NewFieldErrors(models.FieldError{Field: "productId", Message: "product does not exist"})
+		}
+		if err != nil {
+			return err
+		}
+		sku = product.DefaultSKU()
+		if sku == "" {
+			return models.NewFieldErrors(models.FieldError{Field: "sku", Message: "is required for a product with several variants"})
+		}
+	} else if product, _, err := s.ProductRepo.GetVariantBySKU(ctx, sku); err != nil {
+		if errors.Is(err, repositories.ErrVariantNotFound) {
+			return models.NewFieldErrors(models.FieldError{Field: "sku", Message: "product variant does not exist"})
+		}
+		return err
+	} else if product.DeletedAt != nil {
+		return models.NewFieldErrors(models.FieldError{Field: "sku", Message: "product has been deleted"})
 	}
 
 	// Проверяем, есть ли уже этот товар в корзине
-	existingQuantity, err := s.RedisClient.HGet(ctx, key, productID).Int()
+	existingQuantity, err := s.RedisClient.HGet(ctx, key, sku).Int()
 	if err != nil && err != redis.Nil {
 		return err
 	}
 
 	// Обновляем количество товара
 	totalQuantity := existingQuantity + quantity
-	return s.RedisClient.HSet(ctx, key, productID, totalQuantity).Err()
+	return s.RedisClient.HSet(ctx, key, sku, totalQuantity).Err()
 }
 
-func (s *CartService) RemoveFromCart(userID, productID string) error {
-	ctx := context.Background()
+func (s *CartService) RemoveFromCart(ctx context.Context, userID, sku string) error {
+	ctx, span := tracer.Start(ctx, "CartService.RemoveFromCart")
+	defer span.End()
+
 	key := fmt.Sprintf("cart:%s", userID)
-	return s.RedisClient.HDel(ctx, key, productID).Err()
+	return s.RedisClient.HDel(ctx, key, sku).Err()
 }
 
-func (s *CartService) GetCart(userID string) (map[string]int, error) {
-	ctx := context.Background()
+func (s *CartService) GetCart(ctx context.Context, userID string) (map[string]int, error) {
+	ctx, span := tracer.Start(ctx, "CartService.GetCart")
+	defer span.End()
+
 	key := fmt.Sprintf("cart:%s", userID)
 
 	cartItems, err := s.RedisClient.HGetAll(ctx, key).Result()
@@ -79,8 +115,58 @@ func (s *CartService) GetCart(userID string) (map[string]int, error) {
 	return cart, nil
 }
 
-func (s *CartService) ClearCart(userID string) error {
-	ctx := context.Background()
+// GetCartLines возвращает позиции корзины с текущими данными продуктов. Позиции удалённых
+// продуктов и исчезнувших вариантов не отбрасываются, а помечаются полем Unavailable.
+func (s *CartService) GetCartLines(ctx context.Context, userID string) ([]models.CartLine, error) {
+	ctx, span := tracer.Start(ctx, "CartService.GetCartLines")
+	defer span.End()
+
+	cart, err := s.GetCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.cartLines(ctx, cart)
+}
+
+// cartLines дополняет позиции корзины данными продуктов; порядок — по SKU
+func (s *CartService) cartLines(ctx context.Context, cart map[string]int) ([]models.CartLine, error) {
+	skus := make([]string, 0, len(cart))
+	for sku := range cart {
+		skus = append(skus, sku)
+	}
+	sort.Strings(skus)
+
+	// Продукты всех позиций — одним запросом
+	products, err := s.ProductRepo.GetProductsBySKUs(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]models.CartLine, 0, len(skus))
+	for _, sku := range skus {
+		line := models.CartLine{SKU: sku, Quantity: cart[sku]}
+		product, ok := products[sku]
+		if !ok {
+			line.Unavailable = models.CartLineVariantNotFound
+			lines = append(lines, line)
+			continue
+		}
+		variant, _ := product.Variant(sku)
+		line.ProductID = product.IDString
+		line.Name = product.Name
+		line.Price = variant.Price
+		if product.DeletedAt != nil {
+			line.Unavailable = models.CartLineProductDeleted
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+func (s *CartService) ClearCart(ctx context.Context, userID string) error {
+	ctx, span := tracer.Start(ctx, "CartService.ClearCart")
+	defer span.End()
+
 	key := fmt.Sprintf("cart:%s", userID)
 	return s.RedisClient.Del(ctx, key).Err()
 }
@@ -89,25 +175,67 @@ func generateOrderID() string {
 	return uuid.New().String() // Генерируем новый UUID и преобразуем его в строку
 }
 
-func (s *CartService) CheckoutCart(userID string) (*models.Order, error) {
+// CheckoutCart оформляет заказ из корзины; неудачные попытки учитываются в метриках по причине.
+// Позиции удалённых продуктов в заказ не попадают, остаются в корзине и возвращаются вторым значением.
+// Остальные позиции резервируются на складах; если какой-то не хватает — ErrInsufficientStock.
+func (s *CartService) CheckoutCart(ctx context.Context, userID string) (*models.Order, []models.CartLine, error) {
+	ctx, span := tracer.Start(ctx, "CartService.CheckoutCart")
+	defer span.End()
+
+	order, skipped, err := s.checkout(ctx, userID)
+	if err != nil {
+		reason := metrics.CheckoutInternal
+		switch {
+		case errors.Is(err, ErrInvalidCart):
+			reason = metrics.CheckoutEmptyCart
+		case errors.Is(err, repositories.ErrVariantNotFound):
+			reason = metrics.CheckoutUnknownSKU
+		case errors.Is(err, repositories.ErrInsufficientStock):
+			reason = metrics.CheckoutOutOfStock
+		}
+		metrics.CheckoutFailed(reason)
+		return nil, nil, err
+	}
+	metrics.OrderCreated(metrics.OrderSourceCheckout, order.TotalPrice)
+	s.Audit.Record(ctx, "order.checkout", models.AuditEntityOrder, order.ID, nil, order)
+	s.Webhooks.OrderChanged(ctx, models.WebhookOrderCreated, nil, order)
+	return order, skipped, nil
+}
+
+func (s *CartService) checkout(ctx context.Context, userID string) (*models.Order, []models.CartLine, error) {
 	// Получаем корзину из Redis
-	cart, err := s.GetCart(userID)
+	cart, err := s.GetCart(ctx, userID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if len(cart) == 0 {
+		return nil, nil, fmt.Errorf("%w: cart is empty", ErrInvalidCart)
+	}
+	lines, err := s.cartLines(ctx, cart)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Преобразуем cart (map[string]int) в []models.CartItem
+	// Преобразуем позиции корзины в []models.CartItem, пропуская удалённые продукты
 	cartItems := []models.CartItem{}
-	for productID, quantity := range cart {
-		price, err := s.ProductRepo.GetProductPrice(productID)
-		if err != nil {
-			return nil, err
+	skipped := []models.CartLine{}
+	for _, line := range lines {
+		switch line.Unavailable {
+		case "":
+			cartItems = append(cartItems, models.CartItem{
+				ProductID: line.ProductID,
+				SKU:       line.SKU,
+				Quantity:  line.Quantity,
+				Price:     line.Price,
+			})
+		case models.CartLineProductDeleted:
+			skipped = append(skipped, line)
+		default:
+			return nil, nil, fmt.Errorf("%w: %s", repositories.ErrVariantNotFound, line.SKU)
 		}
-		cartItems = append(cartItems, models.CartItem{
-			ProductID: productID,
-			Quantity:  quantity,
-			Price:     price,
-		})
+	}
+	if len(cartItems) == 0 {
+		return nil, nil, fmt.Errorf("%w: all products in the cart have been deleted", ErrInvalidCart)
 	}
 
 	// Создаем заказ
@@ -121,19 +249,31 @@ func (s *CartService) CheckoutCart(userID string) (*models.Order, error) {
 		UpdatedAt:  time.Now(),
 	}
 
+	// Резервируем позиции на складах до создания заказа: если чего-то не хватает, заказа не будет
+	if _, err := s.Inventory.ReserveOrder(ctx, order.ID, cartItems); err != nil {
+		return nil, nil, err
+	}
+
 	// Сохраняем заказ в БД
-	err = s.OrderRepo.CreateOrder(&order)
+	err = s.OrderRepo.CreateOrder(ctx, &order, ActorFromContext(ctx).UserID)
 	if err != nil {
-		return nil, err
+		// Заказ не создан — резерв под него больше не нужен
+		if releaseErr := s.Inventory.ReleaseOrder(context.WithoutCancel(ctx), order.ID, "order creation failed"); releaseErr != nil {
+			logger.ErrorContext(ctx, "failed to release stock of failed order", "order_id", order.ID, "error", releaseErr)
+		}
+		return nil, nil, err
 	}
 
-	// Очищаем корзину после оформления заказа
-	err = s.ClearCart(userID)
-	if err != nil {
-		return nil, err
+	// Убираем из корзины оформленные позиции; пропущенные остаются, чтобы покупатель их увидел
+	ordered := make([]string, 0, len(cartItems))
+	for _, item := range cartItems {
+		ordered = append(ordered, item.SKU)
+	}
+	if err := s.RedisClient.HDel(ctx, fmt.Sprintf("cart:%s", userID), ordered...).Err(); err != nil {
+		return nil, nil, err
 	}
 
-	return &order, nil
+	return &order, skipped, nil
 }
 func (s *CartService) calculateTotalPrice(cartItems []models.CartItem) float64 {
 	var totalPrice float64
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"order-service/repositories"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrCategoryNotEmpty — у категории есть подкатегории или продукты
+var ErrCategoryNotEmpty = models.NewConflict("category_not_empty", "category has subcategories or products")
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type CategoryService struct {
+	Repo        *repositories.CategoryRepository
+	ProductRepo *repositories.ProductRepository
+	Products    *ProductCache
+	Audit       *AuditService
+}
+
+func NewCategoryService(repo *repositories.CategoryRepository, productRepo *repositories.ProductRepository, products *ProductCache, audit *AuditService) *CategoryService {
+	return &CategoryService{
+		Repo:        repo,
+		ProductRepo: productRepo,
+		Products:    products,
+		Audit:       audit,
+	}
+}
+
+// CreateCategory создаёт категорию; если slug не задан, он строится из имени
+func (s *CategoryService) CreateCategory(ctx context.Context, name, slug, parent string) (*models.Category, error) {
+	ctx, span := tracer.Start(ctx, "CategoryService.CreateCategory")
+	defer span.End()
+
+	var fields models.FieldErrors
+	name = strings.TrimSpace(name)
+	if name == "" {
+		fields.Add("name", "is required")
+	}
+	if slug == "" {
+		slug = Slugify(name)
+	}
+	if !slugPattern.MatchString(slug) {
+		fields.Add("slug", "must contain only lowercase latin letters, digits and hyphens")
+	}
+
+	category := &models.Category{Slug: slug, Name: name, Path: []string{slug}}
+	if parent != "" {
+		parentCategory, err := s.parent(ctx, parent, &fields)
+		if err != nil {
+			return nil, err
+		}
+		if parentCategory != nil {
+			category.Parent = parent
+			category.Path = append(append([]string{}, parentCategory.Path...), slug)
+		}
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.CreateCategory(ctx, category); err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, "category.create", models.AuditEntityCategory, category.Slug, nil, category)
+	return category, nil
+}
+
+func (s *CategoryService) GetCategory(ctx context.Context, slug string) (*models.Category, error) {
+	ctx, span := tracer.Start(ctx, "CategoryService.GetCategory")
+	defer span.End()
+
+	return s.Repo.GetCategoryBySlug(ctx, slug)
+}
+
+// GetCategoryTree возвращает корневые категории с вложенными подкатегориями
+func (s *CategoryService) GetCategoryTree(ctx context.Context) ([]*models.Category, error) {
+	ctx, span := tracer.Start(ctx, "CategoryService.GetCategoryTree")
+	defer span.End()
+
+	categories, err := s.Repo.GetAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*models.Category, len(categories))
+	for i := range categories {
+		nodes[categories[i].Slug] = &categories[i]
+	}
+
+	roots := []*models.Category{}
+	for i := range categories {
+		node := &categories[i]
+		if parent, ok := nodes[node.Parent]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots, nil
+}
+
+// UpdateCategory переименовывает категорию и при необходимости переносит её
+// вместе с подкатегориями под другого родителя
+func (s *CategoryService) UpdateCategory(ctx context.Context, slug, name, parent string) (*models.Category, error) {
+	ctx, span := tracer.Start(ctx, "CategoryService.UpdateCategory")
+	defer span.End()
+
+	category, err := s.Repo.GetCategoryBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *category
+	if name = strings.TrimSpace(name); name != "" {
+		category.Name = name
+	}
+
+	if parent == category.Parent {
+		if err := s.Repo.UpdateCategory(ctx, category); err != nil {
+			return nil, err
+		}
+		s.Audit.Record(ctx, "category.update", models.AuditEntityCategory, slug, &before, category)
+		return category, nil
+	}
+
+	newPath := []string{slug}
+	if parent != "" {
+		var fields models.FieldErrors
+		parentCategory, err := s.parent(ctx, parent, &fields)
+		if err != nil {
+			return nil, err
+		}
+		if parentCategory != nil {
+			for _, ancestor := range parentCategory.Path {
+				if ancestor == slug {
+					fields.Add("parent", "category cannot be moved under itself")
+				}
+			}
+		}
+		if err := fields.Err(); err != nil {
+			return nil, err
+		}
+		newPath = append(append([]string{}, parentCategory.Path...), slug)
+	}
+
+	descendants, err := s.Repo.GetDescendants(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	oldDepth := len(category.Path)
+	category.Parent = parent
+	category.Path = newPath
+	if err := s.Repo.UpdateCategory(ctx, category); err != nil {
+		return nil, err
+	}
+	if err := s.ProductRepo.UpdateCategoryPath(ctx, slug, newPath); err != nil {
+		return nil, err
+	}
+
+	// Пути подкатегорий: новый путь переносимой категории плюс их собственный хвост
+	for i := range descendants {
+		descendant := &descendants[i]
+		descendant.Path = append(append([]string{}, newPath...), descendant.Path[oldDepth:]...)
+		if err := s.Repo.UpdateCategory(ctx, descendant); err != nil {
+			return nil, err
+		}
+		if err := s.ProductRepo.UpdateCategoryPath(ctx, descendant.Slug, descendant.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	s.Products.Invalidate(ctx, productsAllKey)
+	s.Audit.Record(ctx, "category.update", models.AuditEntityCategory, slug, &before, category)
+	return category, nil
+}
+
+// DeleteCategory удаляет пустую категорию без подкатегорий
+func (s *CategoryService) DeleteCategory(ctx context.Context, slug string) error {
+	ctx, span := tracer.Start(ctx, "CategoryService.DeleteCategory")
+	defer span.End()
+
+	descendants, err := s.Repo.GetDescendants(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if len(descendants) > 0 {
+		return fmt.Errorf("%w: %d subcategories", ErrCategoryNotEmpty, len(descendants))
+	}
+
+	count, err := s.ProductRepo.CountByCategory(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d products", ErrCategoryNotEmpty, count)
+	}
+
+	category, err := s.Repo.GetCategoryBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if err := s.Repo.DeleteCategory(ctx, slug); err != nil {
+		return err
+	}
+	s.Audit.Record(ctx, "category.delete", models.AuditEntityCategory, slug, category, nil)
+	return nil
+}
+
+// parent возвращает родительскую категорию; отсутствие родителя — ошибка поля parent
+func (s *CategoryService) parent(ctx context.Context, slug string, fields *models.FieldErrors) (*models.Category, error) {
+	category, err := s.Repo.GetCategoryBySlug(ctx, slug)
+	if errors.Is(err, repositories.ErrCategoryNotFound) {
+		fields.Add("parent", "category does not exist")
+		return nil, nil
+	}
+	return category, err
+}
+
+// Slugify строит slug из названия: латиница и цифры в нижнем регистре, разделённые дефисами
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			dash = false
+		case b.Len() > 0 && !dash:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"order-service/models"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// dependencyTimeout — время ожидания ответа одной зависимости при проверке готовности
+const dependencyTimeout = 2 * time.Second
+
+// HealthService проверяет, готов ли сервис принимать запросы
+type HealthService struct {
+	checks   map[string]func(ctx context.Context) error
+	draining atomic.Bool
+}
+
+func NewHealthService(db *pgxpool.Pool, mongoClient *mongo.Client, redisClient *redis.Client) *HealthService {
+	return &HealthService{
+		checks: map[string]func(ctx context.Context) error{
+			"postgres": db.Ping,
+			"mongo": func(ctx context.Context) error {
+				return mongoClient.Ping(ctx, nil)
+			},
+			"redis": func(ctx context.Context) error {
+				return redisClient.Ping(ctx).Err()
+			},
+		},
+	}
+}
+
+// Drain помечает сервис как останавливающийся: с этого момента он не готов принимать новые запросы
+func (s *HealthService) Drain() {
+	s.draining.Store(true)
+}
+
+// Readiness параллельно опрашивает все зависимости; сервис готов, если все они доступны
+func (s *HealthService) Readiness(ctx context.Context) models.Readiness {
+	readiness := models.Readiness{
+		Status:       models.ReadinessReady,
+		Dependencies: make(map[string]models.DependencyHealth, len(s.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range s.checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			health := probe(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			readiness.Dependencies[name] = health
+			if health.Status != models.HealthStatusUp {
+				readiness.Status = models.ReadinessNotReady
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	if s.draining.Load() {
+		readiness.Status = models.ReadinessNotReady
+	}
+	return readiness
+}
+
+func probe(ctx context.Context, check func(ctx context.Context) error) models.DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, dependencyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	health := models.DependencyHealth{
+		Status:    models.HealthStatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		health.Status = models.HealthStatusDown
+		health.Error = err.Error()
+	}
+	return health
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"order-service/models"
+	"order-service/repositories"
+	"slices"
+)
+
+// InventorySettings — параметры складского учёта
+type InventorySettings struct {
+	Allocation       string // стратегия выбора склада при резервировании
+	DefaultWarehouse string // код склада для начальных остатков каталога и возвратов
+}
+
+// InventoryService — склады и журнал движений остатков. Остатки складов вычисляются по журналу;
+// остаток вариантов в каталоге — копия доступного по всем активным складам, которая обновляется
+// после каждого движения.
+type InventoryService struct {
+	Repo        *repositories.InventoryRepository
+	ProductRepo *repositories.ProductRepository
+	Products    *ProductCache
+	Audit       *AuditService
+	Settings    InventorySettings
+
+	allocate repositories.StockAllocator
+}
+
+func NewInventoryService(repo *repositories.InventoryRepository, productRepo *repositories.ProductRepository, products *ProductCache, audit *AuditService, settings InventorySettings) (*InventoryService, error) {
+	allocate, ok := allocationStrategies[settings.Allocation]
+	if !ok {
+		return nil, fmt.Errorf("unknown allocation strategy %q", settings.Allocation)
+	}
+	return &InventoryService{
+		Repo:        repo,
+		ProductRepo: productRepo,
+		Products:    products,
+		Audit:       audit,
+		Settings:    settings,
+		allocate:    allocate,
+	}, nil
+}
+
+func (s *InventoryService) CreateWarehouse(ctx context.Context, request *models.WarehouseRequest) (*models.Warehouse, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.CreateWarehouse")
+	defer span.End()
+
+	warehouse := &models.Warehouse{
+		Code:     request.Code,
+		Name:     request.Name,
+		Priority: request.Priority,
+		Active:   request.Active == nil || *request.Active,
+	}
+	if err := s.Repo.CreateWarehouse(ctx, warehouse); err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, "warehouse.create", models.AuditEntityWarehouse, warehouse.ID, nil, warehouse)
+	return warehouse, nil
+}
+
+func (s *InventoryService) GetWarehouse(ctx context.Context, id string) (*models.Warehouse, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.GetWarehouse")
+	defer span.End()
+
+	return s.Repo.GetWarehouse(ctx, id)
+}
+
+func (s *InventoryService) ListWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.ListWarehouses")
+	defer span.End()
+
+	return s.Repo.ListWarehouses(ctx)
+}
+
+// UpdateWarehouse заменяет склад, если его текущая версия равна expectedVersion (0 — любая версия).
+// Выключенный склад не участвует в резервировании, но уже зарезервированное с него отгружается.
+func (s *InventoryService) UpdateWarehouse(ctx context.Context, id string, request *models.WarehouseRequest, expectedVersion int64) (*models.Warehouse, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.UpdateWarehouse")
+	defer span.End()
+
+	warehouse, err := s.Repo.GetWarehouse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(warehouse.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	before := *warehouse
+	warehouse.Code = request.Code
+	warehouse.Name = request.Name
+	warehouse.Priority = request.Priority
+	warehouse.Active = request.Active == nil || *request.Active
+	if err := s.Repo.UpdateWarehouse(ctx, warehouse); err != nil {
+		return nil, err
+	}
+	// Доступный остаток в каталоге считается только по активным складам
+	if before.Active != warehouse.Active {
+		if err := s.syncWarehouseCatalog(ctx, warehouse.ID); err != nil {
+			logger.ErrorContext(ctx, "failed to sync catalog stock", "warehouse_id", warehouse.ID, "error", err)
+		}
+	}
+	s.Audit.Record(ctx, "warehouse.update", models.AuditEntityWarehouse, id, &before, warehouse)
+	return warehouse, nil
+}
+
+// GetAvailability возвращает остатки SKU по складам и доступный к продаже остаток
+func (s *InventoryService) GetAvailability(ctx context.Context, sku string) (*models.SKUAvailability, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.GetAvailability")
+	defer span.End()
+
+	levels, err := s.Repo.GetStockLevels(ctx, []string{sku})
+	if err != nil {
+		return nil, err
+	}
+	availability := &models.SKUAvailability{SKU: sku, Warehouses: levels}
+	for _, level := range levels {
+		availability.OnHand += level.OnHand
+		availability.Reserved += level.Reserved
+		if level.Active {
+			availability.Available += level.Available
+		}
+	}
+	return availability, nil
+}
+
+// ListMovements возвращает страницу журнала движений
+func (s *InventoryService) ListMovements(ctx context.Context, filter models.StockMovementFilter) (*models.StockMovementPage, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.ListMovements")
+	defer span.End()
+
+	var fields models.FieldErrors
+	switch filter.Type {
+	case "", models.StockReceipt, models.StockReservation, models.StockRelease, models.StockShipment, models.StockAdjustment:
+	default:
+		fields.Add("type", "must be one of: receipt reservation release shipment adjustment")
+	}
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = defaultAuditLimit
+	case filter.Limit < 0 || filter.Limit > maxAuditLimit:
+		fields.Add("limit", "must be between 1 and 500")
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+
+	movements, err := s.Repo.ListMovements(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.StockMovementPage{Items: movements}
+	if len(movements) == filter.Limit {
+		next := movements[len(movements)-1].ID
+		page.NextBeforeID = &next
+	}
+	return page, nil
+}
+
+// RecordMovement записывает поступление или корректировку от имени текущего пользователя
+func (s *InventoryService) RecordMovement(ctx context.Context, request *models.StockMovementRequest) (*models.StockMovement, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.RecordMovement")
+	defer span.End()
+
+	if request.Type == models.StockReceipt && request.Quantity < 0 {
+		return nil, models.NewFieldErrors(models.FieldError{Field: "quantity", Message: "must be positive for a receipt; use an adjustment to write stock off"})
+	}
+	if _, err := s.Repo.GetWarehouse(ctx, request.WarehouseID); err != nil {
+		return nil, err
+	}
+	if _, _, err := s.ProductRepo.GetVariantBySKU(ctx, request.SKU); err != nil {
+		return nil, err
+	}
+
+	movement := &models.StockMovement{
+		WarehouseID: request.WarehouseID,
+		SKU:         request.SKU,
+		Type:        request.Type,
+		Quantity:    request.Quantity,
+		Reason:      request.Reason,
+		ActorID:     ActorFromContext(ctx).UserID,
+	}
+	if err := s.Repo.RecordMovement(ctx, movement); err != nil {
+		return nil, err
+	}
+	s.syncCatalog(ctx, movement.SKU)
+	return movement, nil
+}
+
+// ReserveOrder резервирует позиции заказа на складах по стратегии из настроек.
+// Если какой-то позиции не хватает, ничего не резервируется и возвращается ErrInsufficientStock.
+func (s *InventoryService) ReserveOrder(ctx context.Context, orderID string, items []models.CartItem) ([]models.StockAllocation, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.ReserveOrder")
+	defer span.End()
+
+	lines := stockLines(items)
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	allocations, err := s.Repo.Reserve(ctx, orderID, lines, ActorFromContext(ctx).UserID, s.allocate)
+	if err != nil {
+		return nil, err
+	}
+	s.syncCatalog(ctx, skusOf(lines)...)
+	return allocations, nil
+}
+
+// ReleaseOrder снимает резерв заказа, ещё не отгруженный со склада
+func (s *InventoryService) ReleaseOrder(ctx context.Context, orderID, reason string) error {
+	ctx, span := tracer.Start(ctx, "InventoryService.ReleaseOrder")
+	defer span.End()
+
+	movements, err := s.Repo.ReleaseOrder(ctx, orderID, reason, ActorFromContext(ctx).UserID)
+	if err != nil {
+		return err
+	}
+	s.syncMovements(ctx, movements)
+	return nil
+}
+
+// ShipOrder списывает со складов резерв отгруженного заказа
+func (s *InventoryService) ShipOrder(ctx context.Context, orderID string) error {
+	ctx, span := tracer.Start(ctx, "InventoryService.ShipOrder")
+	defer span.End()
+
+	movements, err := s.Repo.ShipOrder(ctx, orderID, "order "+orderID+" shipped", ActorFromContext(ctx).UserID)
+	if err != nil {
+		return err
+	}
+	s.syncMovements(ctx, movements)
+	return nil
+}
+
+// RestockReturn приходует товары одобренного возврата. Каждая позиция целиком поступает на склад,
+// с которого по этому заказу отгружено больше всего единиц её SKU (при равенстве — с меньшим ID);
+// если отгрузок SKU по заказу не было — на склад по умолчанию.
+func (s *InventoryService) RestockReturn(ctx context.Context, ret *models.Return) error {
+	ctx, span := tracer.Start(ctx, "InventoryService.RestockReturn")
+	defer span.End()
+
+	warehouse, err := s.Repo.GetWarehouseByCode(ctx, s.Settings.DefaultWarehouse)
+	if err != nil {
+		return err
+	}
+	lines := make([]models.StockLine, 0, len(ret.Items))
+	for _, item := range ret.Items {
+		lines = append(lines, models.StockLine{SKU: item.SKU, Quantity: item.Quantity})
+	}
+	movements, err := s.Repo.Restock(ctx, ret.OrderID, lines, warehouse.ID, "return "+ret.ID, ActorFromContext(ctx).UserID)
+	if err != nil {
+		return err
+	}
+	s.syncMovements(ctx, movements)
+	return nil
+}
+
+// ReceiveProductStock приходует на склад по умолчанию начальные остатки вариантов нового продукта
+func (s *InventoryService) ReceiveProductStock(ctx context.Context, product *models.Product) error {
+	ctx, span := tracer.Start(ctx, "InventoryService.ReceiveProductStock")
+	defer span.End()
+
+	stock := map[string]int{}
+	for _, variant := range product.Variants {
+		if variant.Stock > 0 {
+			stock[variant.SKU] = variant.Stock
+		}
+	}
+	if len(stock) == 0 {
+		return nil
+	}
+	warehouse, err := s.Repo.GetWarehouseByCode(ctx, s.Settings.DefaultWarehouse)
+	if err != nil {
+		return err
+	}
+	_, err = s.Repo.ImportStock(ctx, warehouse.ID, stock, "initial stock of product "+product.IDString, ActorFromContext(ctx).UserID)
+	return err
+}
+
+// ImportCatalogStock переносит остатки вариантов каталога в журнал поступлением на склад по умолчанию.
+// SKU, по которым журнал уже ведётся, пропускаются.
+func (s *InventoryService) ImportCatalogStock(ctx context.Context) (*repositories.StockImportResult, error) {
+	ctx, span := tracer.Start(ctx, "InventoryService.ImportCatalogStock")
+	defer span.End()
+
+	warehouse, err := s.Repo.GetWarehouseByCode(ctx, s.Settings.DefaultWarehouse)
+	if err != nil {
+		return nil, err
+	}
+	stock, err := s.ProductRepo.GetVariantStocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ImportStock(ctx, warehouse.ID, stock, "catalog stock import", "")
+}
+
+// syncMovements обновляет остаток в каталоге по SKU записанных движений
+func (s *InventoryService) syncMovements(ctx context.Context, movements []models.StockMovement) {
+	skus := make([]string, 0, len(movements))
+	for _, m := range movements {
+		skus = append(skus, m.SKU)
+	}
+	s.syncCatalog(ctx, slices.Compact(slices.Sorted(slices.Values(skus)))...)
+}
+
+// syncWarehouseCatalog обновляет остаток в каталоге по всем SKU, которые были на складе
+func (s *InventoryService) syncWarehouseCatalog(ctx context.Context, warehouseID string) error {
+	skus, err := s.Repo.GetWarehouseSKUs(ctx, warehouseID)
+	if err != nil {
+		return err
+	}
+	s.syncCatalog(ctx, skus...)
+	return nil
+}
+
+// syncCatalog записывает в варианты каталога доступный по журналу остаток и сбрасывает кэш
+// изменившихся продуктов. Журнал уже сохранён, поэтому ошибки только логируются:
+// копия в каталоге исправится при следующем движении по SKU.
+func (s *InventoryService) syncCatalog(ctx context.Context, skus ...string) {
+	if len(skus) == 0 {
+		return
+	}
+	available, err := s.Repo.GetAvailable(ctx, skus)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get available stock", "error", err)
+		return
+	}
+	keys := []string{}
+	for _, sku := range skus {
+		productID, err := s.ProductRepo.SetVariantStock(ctx, sku, available[sku])
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to update catalog stock", "sku", sku, "error", err)
+			continue
+		}
+		if productID != "" {
+			keys = append(keys, productCacheKey(productID))
+		}
+	}
+	if len(keys) > 0 {
+		s.Products.Invalidate(ctx, append(keys, productsAllKey)...)
+	}
+}
+
+// stockLines объединяет позиции заказа с одинаковым SKU
+func stockLines(items []models.CartItem) []models.StockLine {
+	index := map[string]int{}
+	var lines []models.StockLine
+	for _, item := range items {
+		if i, ok := index[item.SKU]; ok {
+			lines[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.SKU] = len(lines)
+		lines = append(lines, models.StockLine{SKU: item.SKU, Quantity: item.Quantity})
+	}
+	return lines
+}
+
+func skusOf(lines []models.StockLine) []string {
+	skus := make([]string, 0, len(lines))
+	for _, line := range lines {
+		skus = append(skus, line.SKU)
+	}
+	return skus
+}
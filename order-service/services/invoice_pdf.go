@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"order-service/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// renderInvoicePDF отрисовывает счёт или кредит-ноту. Результат детерминирован:
+// даты документа берутся из IssuedAt, каталоги PDF сортируются, поэтому
+// одинаковый снимок данных той же версией отрисовки даёт одинаковые байты.
+func renderInvoicePDF(invoice *models.Invoice) ([]byte, error) {
+	data := invoice.Data
+	issuedAt := invoice.IssuedAt.UTC()
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCatalogSort(true)
+	pdf.SetCreationDate(issuedAt)
+	pdf.SetModificationDate(issuedAt)
+	pdf.SetCompression(true)
+	pdf.SetProducer("order-service", false)
+
+	// Стандартные шрифты PDF поддерживают только cp1252
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	title := "INVOICE"
+	if invoice.DocType == models.DocTypeCreditNote {
+		title = "CREDIT NOTE"
+	}
+	pdf.SetTitle(title+" "+invoice.Number, false)
+	pdf.SetAuthor(tr(data.Seller.Name), false)
+
+	pdf.AddPage()
+
+	// Заголовок
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, title, "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, "Number: "+invoice.Number, "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, "Date: "+issuedAt.Format("2006-01-02"), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, "Order: "+data.OrderID, "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, "Order date: "+data.OrderDate.UTC().Format("2006-01-02"), "", 1, "L", false, 0, "")
+	if data.RelatedNumber != "" {
+		pdf.CellFormat(0, 5, "Corrects invoice: "+data.RelatedNumber, "", 1, "L", false, 0, "")
+	}
+	if data.Reason != "" {
+		pdf.CellFormat(0, 5, tr("Reason: "+data.Reason), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	// Реквизиты сторон
+	partyTop := pdf.GetY()
+	writeParty(pdf, tr, "Seller", data.Seller, 10)
+	sellerBottom := pdf.GetY()
+	pdf.SetY(partyTop)
+	writeParty(pdf, tr, "Buyer", data.Buyer, 110)
+	if sellerBottom > pdf.GetY() {
+		pdf.SetY(sellerBottom)
+	}
+	pdf.Ln(8)
+
+	// Позиции
+	widths := []float64{70, 15, 25, 25, 25, 30}
+	headers := []string{"Description", "Qty", "Unit price", "Net", "Tax", "Total"}
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for i, header := range headers {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 7, header, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range data.Lines {
+		pdf.CellFormat(widths[0], 6, tr(truncate(line.Description, 45)), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 6, fmt.Sprintf("%d", line.Quantity), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 6, formatMoney(line.UnitPrice), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 6, formatMoney(line.NetAmount), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 6, formatMoney(line.TaxAmount), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 6, formatMoney(line.GrossAmount), "1", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	// Итоги и расшифровка налога
+	labelWidth := widths[0] + widths[1] + widths[2] + widths[3] + widths[4]
+	totals := []struct {
+		label  string
+		amount float64
+	}{
+		{"Net total", data.NetTotal},
+		{fmt.Sprintf("Tax (%s%%)", formatRate(data.TaxRate)), data.TaxTotal},
+		{"Total " + data.Currency, data.GrossTotal},
+	}
+	for i, total := range totals {
+		if i == len(totals)-1 {
+			pdf.SetFont("Helvetica", "B", 10)
+		}
+		pdf.CellFormat(labelWidth, 6, total.label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 6, formatMoney(total.amount), "", 1, "R", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", invoice.Number, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeParty(pdf *gofpdf.Fpdf, tr func(string) string, label string, party models.InvoiceParty, x float64) {
+	pdf.SetX(x)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(90, 5, label, "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range []string{party.Name, party.Address, party.Email} {
+		if line != "" {
+			pdf.CellFormat(90, 5, tr(line), "", 2, "L", false, 0, "")
+		}
+	}
+	if party.TaxID != "" {
+		pdf.CellFormat(90, 5, tr("Tax ID: "+party.TaxID), "", 2, "L", false, 0, "")
+	}
+}
+
+func formatMoney(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%g", roundMoney(rate*100))
+}
+
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"order-service/repositories"
+	"time"
+)
+
+// ErrOrderNotInvoiceable — счёт выпускается только по оплаченному заказу: номер без пропусков нельзя освободить
+var ErrOrderNotInvoiceable = models.NewConflict("order_not_invoiceable", "invoice can only be issued for a paid, shipped or delivered order")
+
+// InvoiceSettings — реквизиты продавца и налоговые параметры документов
+type InvoiceSettings struct {
+	Seller   models.InvoiceParty
+	TaxRate  float64 // цены включают налог
+	Currency string
+}
+
+type InvoiceService struct {
+	Repo        *repositories.InvoiceRepository
+	OrderRepo   *repositories.OrderRepository
+	UserRepo    *repositories.UserRepository
+	ProductRepo *repositories.ProductRepository
+	PaymentRepo *repositories.PaymentRepository
+	ReturnRepo  *repositories.ReturnRepository
+	Settings    InvoiceSettings
+	Audit       *AuditService
+}
+
+func NewInvoiceService(repo *repositories.InvoiceRepository, orderRepo *repositories.OrderRepository, userRepo *repositories.UserRepository, productRepo *repositories.ProductRepository, paymentRepo *repositories.PaymentRepository, returnRepo *repositories.ReturnRepository, settings InvoiceSettings, audit *AuditService) *InvoiceService {
+	if settings.Currency == "" {
+		settings.Currency = "USD"
+	}
+	return &InvoiceService{
+		Repo:        repo,
+		OrderRepo:   orderRepo,
+		UserRepo:    userRepo,
+		ProductRepo: productRepo,
+		PaymentRepo: paymentRepo,
+		ReturnRepo:  returnRepo,
+		Settings:    settings,
+		Audit:       audit,
+	}
+}
+
+// GetInvoicePDF возвращает PDF счёта по заказу, выпуская его при первом обращении.
+// Новый счёт выпускается только по оплаченному заказу; выпущенный отдаётся при любом статусе.
+func (s *InvoiceService) GetInvoicePDF(ctx context.Context, orderID string, claims *TokenClaims) (*models.Invoice, []byte, error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.GetInvoicePDF")
+	defer span.End()
+
+	order, err := s.OrderRepo.GetOrderById(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, nil, ErrForbidden
+	}
+
+	invoice, err := s.Repo.GetInvoiceByOrderID(ctx, order.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var pdf []byte
+	if invoice == nil {
+		if !order.IsInvoiceable() {
+			return nil, nil, ErrOrderNotInvoiceable
+		}
+		if invoice, pdf, err = s.ensureInvoice(ctx, order); err != nil {
+			return nil, nil, err
+		}
+	}
+	if pdf == nil {
+		pdf, err = s.issuedPDF(ctx, invoice)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return invoice, pdf, nil
+}
+
+// GetCreditNotePDF возвращает PDF кредит-ноты по возврату средств, выпуская её при первом обращении
+func (s *InvoiceService) GetCreditNotePDF(ctx context.Context, refundID string, claims *TokenClaims) (*models.Invoice, []byte, error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.GetCreditNotePDF")
+	defer span.End()
+
+	refund, err := s.PaymentRepo.GetRefundByID(ctx, refundID)
+	if err != nil {
+		return nil, nil, err
+	}
+	order, err := s.OrderRepo.GetOrderById(ctx, refund.OrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, nil, ErrForbidden
+	}
+
+	creditNote, err := s.Repo.GetCreditNoteByRefundID(ctx, refund.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if creditNote != nil {
+		pdf, err := s.issuedPDF(ctx, creditNote)
+		if err != nil {
+			return nil, nil, err
+		}
+		return creditNote, pdf, nil
+	}
+
+	// Кредит-нота всегда ссылается на исходный счёт. Возврат средств подтверждает оплату,
+	// поэтому счёт выпускается и по заказу, отменённому после оплаты.
+	invoice, _, err := s.ensureInvoice(ctx, order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := s.creditNoteData(ctx, order, refund, invoice)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creditNote = &models.Invoice{
+		DocType:  models.DocTypeCreditNote,
+		OrderID:  order.ID,
+		RefundID: &refund.ID,
+		Data:     *data,
+		IssuedAt: issueTime(),
+	}
+	pdf, err := s.Repo.IssueInvoice(ctx, creditNote, renderInvoicePDF)
+	if errors.Is(err, repositories.ErrInvoiceExists) {
+		// Параллельный запрос уже выпустил кредит-ноту
+		return s.GetCreditNotePDF(ctx, refundID, claims)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	s.auditIssue(ctx, creditNote)
+	return creditNote, pdf, nil
+}
+
+// ensureInvoice возвращает счёт по заказу; если счёт выпущен только что, возвращает и его PDF
+func (s *InvoiceService) ensureInvoice(ctx context.Context, order *models.Order) (*models.Invoice, []byte, error) {
+	invoice, err := s.Repo.GetInvoiceByOrderID(ctx, order.ID)
+	if err != nil || invoice != nil {
+		return invoice, nil, err
+	}
+
+	data, err := s.invoiceData(ctx, order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	invoice = &models.Invoice{
+		DocType:  models.DocTypeInvoice,
+		OrderID:  order.ID,
+		Data:     *data,
+		IssuedAt: issueTime(),
+	}
+	pdf, err := s.Repo.IssueInvoice(ctx, invoice, renderInvoicePDF)
+	if errors.Is(err, repositories.ErrInvoiceExists) {
+		invoice, err = s.Repo.GetInvoiceByOrderID(ctx, order.ID)
+		return invoice, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	s.auditIssue(ctx, invoice)
+	return invoice, pdf, nil
+}
+
+// auditIssue записывает выпуск документа в журнал аудита; строки документа остаются в его снимке
+func (s *InvoiceService) auditIssue(ctx context.Context, invoice *models.Invoice) {
+	s.Audit.Record(ctx, invoice.DocType+".issue", models.AuditEntityInvoice, invoice.ID, nil, map[string]any{
+		"number":      invoice.Number,
+		"doc_type":    invoice.DocType,
+		"order_id":    invoice.OrderID,
+		"refund_id":   invoice.RefundID,
+		"currency":    invoice.Data.Currency,
+		"gross_total": invoice.Data.GrossTotal,
+		"sha256":      invoice.SHA256,
+	})
+}
+
+// issuedPDF возвращает PDF в том виде, в каком документ был выпущен. Документы, выпущенные
+// до того, как PDF стали храниться, отрисовываются по сохранённому снимку данных: если файл
+// совпал с хэшем, он сохраняется; иначе изменилась отрисовка, а не данные, и отдаётся новый файл.
+func (s *InvoiceService) issuedPDF(ctx context.Context, invoice *models.Invoice) ([]byte, error) {
+	pdf, err := s.Repo.GetPDF(ctx, invoice.ID)
+	if err != nil || pdf != nil {
+		return pdf, err
+	}
+
+	pdf, err = renderInvoicePDF(invoice)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(pdf)
+	if hash := hex.EncodeToString(sum[:]); hash != invoice.SHA256 {
+		logger.WarnContext(ctx, "document rendering changed since issue", "number", invoice.Number, "stored_sha256", invoice.SHA256)
+		invoice.SHA256 = hash
+		return pdf, nil
+	}
+	if err := s.Repo.SavePDF(ctx, invoice.ID, pdf); err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
+
+// invoiceData собирает снимок данных счёта по позициям заказа
+func (s *InvoiceService) invoiceData(ctx context.Context, order *models.Order) (*models.InvoiceData, error) {
+	buyer, err := s.buyer(ctx, order.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]models.InvoiceLine, 0, len(order.Items))
+	for _, item := range order.Items {
+		lines = append(lines, s.line(ctx, item.ProductID, item.SKU, item.Quantity, item.Price))
+	}
+
+	// Заказы, созданные без корзины, не имеют позиций
+	if len(lines) == 0 {
+		lines = append(lines, s.amountLine("Order "+order.ID, order.TotalPrice))
+	}
+
+	data := &models.InvoiceData{
+		Seller:    s.Settings.Seller,
+		Buyer:     *buyer,
+		OrderID:   order.ID,
+		OrderDate: order.CreatedAt,
+		Currency:  s.Settings.Currency,
+		TaxRate:   s.Settings.TaxRate,
+		Lines:     lines,
+	}
+	calculateTotals(data)
+	return data, nil
+}
+
+// creditNoteData собирает снимок кредит-ноты: позиции возврата или одну строку на сумму возврата
+func (s *InvoiceService) creditNoteData(ctx context.Context, order *models.Order, refund *models.Refund, invoice *models.Invoice) (*models.InvoiceData, error) {
+	var lines []models.InvoiceLine
+	if refund.ReturnID != nil {
+		ret, err := s.ReturnRepo.GetReturnByID(ctx, *refund.ReturnID)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range ret.Items {
+			lines = append(lines, s.line(ctx, item.ProductID, item.SKU, item.Quantity, item.Price))
+		}
+	} else {
+		lines = append(lines, s.amountLine("Refund for order "+order.ID, refund.Amount))
+	}
+
+	data := &models.InvoiceData{
+		Seller:        invoice.Data.Seller,
+		Buyer:         invoice.Data.Buyer,
+		OrderID:       order.ID,
+		OrderDate:     order.CreatedAt,
+		RelatedNumber: invoice.Number,
+		Reason:        refund.Reason,
+		Currency:      invoice.Data.Currency,
+		TaxRate:       invoice.Data.TaxRate,
+		Lines:         lines,
+	}
+	calculateTotals(data)
+	return data, nil
+}
+
+func (s *InvoiceService) buyer(ctx context.Context, userID string) (*models.InvoiceParty, error) {
+	// Документ выпускается и для заказа удалённого пользователя
+	user, err := s.UserRepo.FindUserByID(ctx, userID, true)
+	if err != nil {
+		return nil, fmt.Errorf("buyer not found: %w", err)
+	}
+	// Данные обезличенного покупателя в новые документы не попадают
+	if user.ErasedAt != nil {
+		return &models.InvoiceParty{Name: "Erased customer"}, nil
+	}
+	return &models.InvoiceParty{Name: user.Username, Email: user.Email}, nil
+}
+
+// line рассчитывает строку документа; цены включают налог
+func (s *InvoiceService) line(ctx context.Context, productID, sku string, quantity int, unitPrice float64) models.InvoiceLine {
+	description := sku
+	if product, variant, err := s.ProductRepo.GetVariantBySKU(ctx, sku); err == nil {
+		description = product.Name
+		if variant.Name != "" {
+			description += " / " + variant.Name
+		}
+		description += " (" + sku + ")"
+	}
+
+	gross := roundMoney(unitPrice * float64(quantity))
+	net := roundMoney(gross / (1 + s.Settings.TaxRate))
+	return models.InvoiceLine{
+		ProductID:   productID,
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   unitPrice,
+		NetAmount:   net,
+		TaxAmount:   roundMoney(gross - net),
+		GrossAmount: gross,
+	}
+}
+
+func (s *InvoiceService) amountLine(description string, amount float64) models.InvoiceLine {
+	gross := roundMoney(amount)
+	net := roundMoney(gross / (1 + s.Settings.TaxRate))
+	return models.InvoiceLine{
+		Description: description,
+		Quantity:    1,
+		UnitPrice:   gross,
+		NetAmount:   net,
+		TaxAmount:   roundMoney(gross - net),
+		GrossAmount: gross,
+	}
+}
+
+func calculateTotals(data *models.InvoiceData) {
+	for _, line := range data.Lines {
+		data.NetTotal += line.NetAmount
+		data.TaxTotal += line.TaxAmount
+		data.GrossTotal += line.GrossAmount
+	}
+	data.NetTotal = roundMoney(data.NetTotal)
+	data.TaxTotal = roundMoney(data.TaxTotal)
+	data.GrossTotal = roundMoney(data.GrossTotal)
+}
+
+// issueTime — время выпуска с точностью до секунды, чтобы оно одинаково
+// сохранялось в Postgres и попадало в метаданные PDF
+func issueTime() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
@@ -0,0 +1,12 @@
+package services
+
+import (
+	"order-service/logging"
+
+	"go.opentelemetry.io/otel"
+)
+
+// tracer создаёт спаны методов сервисов; запросы к базам и Redis становятся их дочерними спанами
+var tracer = otel.Tracer("order-service/services")
+
+var logger = logging.For("services")
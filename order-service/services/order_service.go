@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"order-service/metrics"
 	"order-service/models"
 	"order-service/repositories"
 	"time"
@@ -14,9 +14,20 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrOrderNotCancellable — заказ уже отгружен или закрыт
+var ErrOrderNotCancellable = models.NewConflict("order_not_cancellable", "order can only be cancelled before shipment")
+
+// ErrOrderStatusTransition — статус нельзя так сменить через PUT и PATCH
+var ErrOrderStatusTransition = models.NewConflict("order_status_transition", "order status can only advance from pending to paid, shipped and delivered; use cancel or returns for other changes")
+
 type OrderService struct {
 	Repo        *repositories.OrderRepository
+	Payments    *PaymentService
+	Inventory   *InventoryService
 	RedisClient *redis.Client
+	Audit       *AuditService
+	Webhooks    *WebhookService
+	Updates     *OrderUpdates
 }
 
 // OrderStats представляет статистику заказов
@@ -27,17 +38,24 @@ type OrderStats struct {
 	OrdersPerMonth int64   `json:"orders_per_month"`
 }
 
-func NewOrderService(repo *repositories.OrderRepository, redisClient *redis.Client) *OrderService {
+func NewOrderService(repo *repositories.OrderRepository, payments *PaymentService, inventory *InventoryService, redisClient *redis.Client, audit *AuditService, webhooks *WebhookService, updates *OrderUpdates) *OrderService {
 	if repo == nil {
-		log.Fatal("NewOrderService: received nil repository")
+		panic("NewOrderService: received nil repository")
 	}
 	return &OrderService{
 		Repo:        repo,
+		Payments:    payments,
+		Inventory:   inventory,
 		RedisClient: redisClient,
+		Audit:       audit,
+		Webhooks:    webhooks,
+		Updates:     updates,
 	}
 }
 
-func (s *OrderService) CreateOrder(userID string, totalPrice float64) (*models.Order, error) {
+func (s *OrderService) CreateOrder(ctx context.Context, userID string, totalPrice float64) (*models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreateOrder")
+	defer span.End()
 
 	if s.Repo == nil {
 		return nil, errors.New("order repository is not initialized")
@@ -50,82 +68,311 @@ func (s *OrderService) CreateOrder(userID string, totalPrice float64) (*models.O
 		Status:     "pending",
 		CreatedAt:  time.Now(),
 	}
-	err := s.Repo.CreateOrder(order)
+	err := s.Repo.CreateOrder(ctx, order, ActorFromContext(ctx).UserID)
 	if err != nil {
 		return nil, err
 	}
+	metrics.OrderCreated(metrics.OrderSourceAPI, order.TotalPrice)
+	s.Audit.Record(ctx, "order.create", models.AuditEntityOrder, order.ID, nil, order)
+	s.Webhooks.OrderChanged(ctx, models.WebhookOrderCreated, nil, order)
 	return order, nil
 }
 
-func (s *OrderService) GetOrderById(id string) (*models.Order, error) {
+// GetOrderById возвращает заказ; удалённый — только если includeDeleted (мимо кэша)
+func (s *OrderService) GetOrderById(ctx context.Context, id string, includeDeleted bool) (*models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrderById")
+	defer span.End()
+
+	if includeDeleted {
+		return s.Repo.FindOrderById(ctx, id, true)
+	}
+
 	// Проверяем кэш
 	cacheKey := fmt.Sprintf("order:%s", id)
-	if cached, err := s.RedisClient.Get(context.Background(), cacheKey).Result(); err == nil {
+	if cached, err := s.RedisClient.Get(ctx, cacheKey).Result(); err == nil {
 		var order models.Order
 		if err := json.Unmarshal([]byte(cached), &order); err == nil {
+			metrics.ObserveCache(cacheKey, true)
 			return &order, nil
 		}
 	}
+	metrics.ObserveCache(cacheKey, false)
 
 	// Если нет в кэше, получаем из БД
-	order, err := s.Repo.GetOrderById(id)
+	order, err := s.Repo.GetOrderById(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	// Сохраняем в кэш
 	if orderJSON, err := json.Marshal(order); err == nil {
-		s.RedisClient.Set(context.Background(), cacheKey, orderJSON, 1*time.Hour)
+		s.RedisClient.Set(ctx, cacheKey, orderJSON, 1*time.Hour)
 	}
 
 	return order, nil
 }
 
-func (s *OrderService) GetAllOrders() ([]models.Order, error) {
-	return s.Repo.GetAllOrders()
+func (s *OrderService) GetAllOrders(ctx context.Context, includeDeleted bool) ([]models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetAllOrders")
+	defer span.End()
+
+	return s.Repo.GetAllOrders(ctx, includeDeleted)
 }
 
-func (s *OrderService) DeleteOrder(id string) error {
-	return s.Repo.DeleteOrder(id)
+// DeleteOrder мягко удаляет заказ: он исчезает из выборок и статистики, но остаётся в базе
+// вместе с позициями, историей статусов и документами
+func (s *OrderService) DeleteOrder(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "OrderService.DeleteOrder")
+	defer span.End()
+
+	order, err := s.Repo.GetOrderById(ctx, id)
+	if err != nil {
+		return err
+	}
+	before := *order
+	if err := s.Repo.SoftDeleteOrder(ctx, order, ActorFromContext(ctx).UserID); err != nil {
+		return err
+	}
+	s.invalidateOrderCache(ctx, order)
+	s.Audit.Record(ctx, "order.delete", models.AuditEntityOrder, id, &before, order)
+	s.Webhooks.OrderChanged(ctx, models.WebhookOrderDeleted, nil, order)
+	s.Updates.Publish(ctx, &before, order)
+	return nil
 }
 
-func (s *OrderService) UpdateOrder(id string, updatedOrder *models.Order) (*models.Order, error) {
-	return s.Repo.UpdateOrder(id, updatedOrder)
+// RestoreOrder восстанавливает мягко удалённый заказ; неудалённый возвращается как есть
+func (s *OrderService) RestoreOrder(ctx context.Context, id string) (*models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.RestoreOrder")
+	defer span.End()
+
+	order, err := s.Repo.FindOrderById(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if order.DeletedAt == nil {
+		return order, nil
+	}
+	before := *order
+	if err := s.Repo.RestoreOrder(ctx, order, ActorFromContext(ctx).UserID); err != nil {
+		return nil, err
+	}
+	s.invalidateOrderCache(ctx, order)
+	s.Audit.Record(ctx, "order.restore", models.AuditEntityOrder, id, &before, order)
+	s.Webhooks.OrderChanged(ctx, models.WebhookOrderRestored, nil, order)
+	s.Updates.Publish(ctx, &before, order)
+	return order, nil
+}
+
+// CancelOrder отменяет заказ до отгрузки. Оплаченный заказ возвращается полностью.
+func (s *OrderService) CancelOrder(ctx context.Context, id string, claims *TokenClaims, reason string) (*models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CancelOrder")
+	defer span.End()
+
+	order, err := s.Repo.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, ErrForbidden
+	}
+	if !order.IsCancellable() {
+		return nil, ErrOrderNotCancellable
+	}
+
+	refund, err := s.Repo.CancelOrder(ctx, order.ID, order.Status, reason, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	s.Payments.RecordRefund(ctx, refund)
+	s.invalidateOrderCache(ctx, order)
+
+	before := *order
+	order.Status = models.OrderStatusCancelled
+	s.settleStock(ctx, &before, order)
+	s.Audit.Record(ctx, "order.cancel", models.AuditEntityOrder, order.ID, &before, order)
+	s.Webhooks.OrderChanged(ctx, models.WebhookOrderCancelled, &before, order)
+	s.Updates.Publish(ctx, &before, order)
+	return order, nil
+}
+
+// GetOrderHistory возвращает историю статусов заказа
+func (s *OrderService) GetOrderHistory(ctx context.Context, id string, claims *TokenClaims) ([]models.OrderStatusChange, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrderHistory")
+	defer span.End()
+
+	order, err := s.Repo.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, ErrForbidden
+	}
+	return s.Repo.GetStatusHistory(ctx, id)
+}
+
+// GetOrderEvents возвращает журнал событий заказа, в том числе удалённого (администратор)
+func (s *OrderService) GetOrderEvents(ctx context.Context, id string) ([]models.OrderEvent, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrderEvents")
+	defer span.End()
+
+	return s.Repo.GetOrderEvents(ctx, id)
+}
+
+// GetUserOrderSummary возвращает сводку по заказам пользователя — ему самому или администратору
+func (s *OrderService) GetUserOrderSummary(ctx context.Context, userID string, claims *TokenClaims) (*models.OrderUserSummary, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetUserOrderSummary")
+	defer span.End()
+
+	if !claims.CanAccess(userID) {
+		return nil, ErrForbidden
+	}
+	return s.Repo.GetUserSummary(ctx, userID)
+}
+
+// GetOrderRefunds возвращает возвраты средств по заказу
+func (s *OrderService) GetOrderRefunds(ctx context.Context, id string, claims *TokenClaims) ([]models.Refund, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrderRefunds")
+	defer span.End()
+
+	order, err := s.Repo.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, ErrForbidden
+	}
+	return s.Payments.GetOrderRefunds(ctx, id)
+}
+
+// invalidateOrderCache удаляет из кэша заказ и зависящие от него данные
+func (s *OrderService) invalidateOrderCache(ctx context.Context, order *models.Order) {
+	s.RedisClient.Del(ctx, fmt.Sprintf("order:%s", order.ID))
+	s.RedisClient.Del(ctx, fmt.Sprintf("user_orders:%s", order.UserID))
+	s.RedisClient.Del(ctx, "order:statistics")
+}
+
+// settleStock закрывает резерв заказа на складах, когда заказ отменён или отгружен.
+// Статус уже сохранён, поэтому ошибку склада только логируем.
+func (s *OrderService) settleStock(ctx context.Context, before, after *models.Order) {
+	if before.Status == after.Status {
+		return
+	}
+	var err error
+	switch after.Status {
+	case models.OrderStatusCancelled:
+		err = s.Inventory.ReleaseOrder(ctx, after.ID, "order "+after.ID+" cancelled")
+	case models.OrderStatusShipped:
+		err = s.Inventory.ShipOrder(ctx, after.ID)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to settle order stock", "order_id", after.ID, "status", after.Status, "error", err)
+	}
+}
+
+// UpdateOrder заменяет заказ, если его текущая версия равна expectedVersion (0 — любая версия)
+func (s *OrderService) UpdateOrder(ctx context.Context, id string, updatedOrder *models.Order, expectedVersion int64) (*models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.UpdateOrder")
+	defer span.End()
+
+	order, err := s.Repo.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(order.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	return s.saveOrder(ctx, "order.update", order, updatedOrder)
+}
+
+// PatchOrder сохраняет только поля fields из request — результата применения PATCH к заказу
+func (s *OrderService) PatchOrder(ctx context.Context, id string, request *models.UpdateOrderRequest, fields []string, expectedVersion int64) (*models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.PatchOrder")
+	defer span.End()
+
+	order, err := s.Repo.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(order.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return order, nil
+	}
+
+	// request заполнен текущими значениями заказа, поэтому поля вне fields не меняются
+	patched := *order
+	patched.UserID = request.UserID
+	patched.Status = request.Status
+	return s.saveOrder(ctx, "order.patch", order, &patched)
+}
+
+// saveOrder сохраняет владельца и статус заказа из updated. Статус меняется только вперёд
+// по orderTransitions: отмена и возвраты идут через CancelOrder и заявки на возврат.
+func (s *OrderService) saveOrder(ctx context.Context, action string, order, updated *models.Order) (*models.Order, error) {
+	if updated.Status != order.Status && !order.CanTransitionTo(updated.Status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrOrderStatusTransition, order.Status, updated.Status)
+	}
+
+	updated.Version = order.Version
+	saved, err := s.Repo.UpdateOrder(ctx, order.ID, updated, ActorFromContext(ctx).UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Заказ мог сменить владельца: сбрасываем кэш и старого, и нового
+	s.invalidateOrderCache(ctx, order)
+	s.invalidateOrderCache(ctx, saved)
+	s.settleStock(ctx, order, saved)
+	s.Audit.Record(ctx, action, models.AuditEntityOrder, order.ID, order, saved)
+	s.Webhooks.OrderChanged(ctx, models.WebhookOrderUpdated, order, saved)
+	s.Updates.Publish(ctx, order, saved)
+	return saved, nil
 }
 
 // Кэширование последних заказов пользователя
-func (s *OrderService) GetUserOrders(userID string) ([]models.Order, error) {
+func (s *OrderService) GetUserOrders(ctx context.Context, userID string) ([]models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetUserOrders")
+	defer span.End()
+
 	cacheKey := fmt.Sprintf("user_orders:%s", userID)
-	if cached, err := s.RedisClient.Get(context.Background(), cacheKey).Result(); err == nil {
+	if cached, err := s.RedisClient.Get(ctx, cacheKey).Result(); err == nil {
 		var orders []models.Order
 		if err := json.Unmarshal([]byte(cached), &orders); err == nil {
+			metrics.ObserveCache(cacheKey, true)
 			return orders, nil
 		}
 	}
+	metrics.ObserveCache(cacheKey, false)
 
-	orders, err := s.Repo.GetOrdersByUserID(userID)
+	orders, err := s.Repo.GetOrdersByUserID(ctx, userID, false)
 	if err != nil {
 		return nil, err
 	}
 
 	if ordersJSON, err := json.Marshal(orders); err == nil {
-		s.RedisClient.Set(context.Background(), cacheKey, ordersJSON, 30*time.Minute)
+		s.RedisClient.Set(ctx, cacheKey, ordersJSON, 30*time.Minute)
 	}
 
 	return orders, nil
 }
 
-func (s *OrderService) GetOrderStatistics() (*OrderStats, error) {
+func (s *OrderService) GetOrderStatistics(ctx context.Context) (*OrderStats, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrderStatistics")
+	defer span.End()
+
 	cacheKey := "order:statistics"
-	if cached, err := s.RedisClient.Get(context.Background(), cacheKey).Result(); err == nil {
+	if cached, err := s.RedisClient.Get(ctx, cacheKey).Result(); err == nil {
 		var stats OrderStats
 		if err := json.Unmarshal([]byte(cached), &stats); err == nil {
+			metrics.ObserveCache(cacheKey, true)
 			return &stats, nil
 		}
 	}
+	metrics.ObserveCache(cacheKey, false)
 
 	// Получаем статистику из БД
-	orders, err := s.Repo.GetAllOrders()
+	orders, err := s.Repo.GetAllOrders(ctx, false)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +394,7 @@ func (s *OrderService) GetOrderStatistics() (*OrderStats, error) {
 
 	// Сохраняем в кэш
 	if statsJSON, err := json.Marshal(stats); err == nil {
-		s.RedisClient.Set(context.Background(), cacheKey, statsJSON, 1*time.Hour)
+		s.RedisClient.Set(ctx, cacheKey, statsJSON, 1*time.Hour)
 	}
 
 	return stats, nil
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"order-service/models"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// orderUpdatesPrefix — префикс каналов Redis с изменениями заказов: order_updates:<id>
+const orderUpdatesPrefix = "order_updates:"
+
+// OrderUpdates рассылает изменения заказов через Redis Pub/Sub. Подписчик получает
+// изменения, сделанные любым экземпляром сервиса; пропущенные во время отключения
+// изменения не повторяются — после переподключения нужно перечитать заказ.
+type OrderUpdates struct {
+	RedisClient *redis.Client
+}
+
+func NewOrderUpdates(redisClient *redis.Client) *OrderUpdates {
+	return &OrderUpdates{RedisClient: redisClient}
+}
+
+// Publish сообщает подписчикам заказа о его новом состоянии; before — состояние до изменения
+// или nil. Изменение к этому моменту уже сохранено, поэтому ошибки только логируются.
+func (u *OrderUpdates) Publish(ctx context.Context, before, order *models.Order) {
+	if u == nil {
+		return
+	}
+	ctx, span := tracer.Start(ctx, "OrderUpdates.Publish")
+	defer span.End()
+
+	update := models.OrderUpdate{Order: *order}
+	if before != nil && before.Status != order.Status {
+		update.PreviousStatus = before.Status
+	}
+	payload, err := json.Marshal(update)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to encode order update", "order_id", order.ID, "error", err)
+		return
+	}
+	if err := u.RedisClient.Publish(context.WithoutCancel(ctx), orderUpdatesPrefix+order.ID, payload).Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to publish order update", "order_id", order.ID, "error", err)
+	}
+}
+
+// OrderSubscription — подписка на изменения одного заказа
+type OrderSubscription struct {
+	pubsub  *redis.PubSub
+	updates chan models.OrderUpdate
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Subscribe подписывается на изменения заказа id. Подписка активна к моменту возврата,
+// поэтому заказ, прочитанный после Subscribe, не пропустит изменений между чтением и подпиской.
+func (u *OrderUpdates) Subscribe(ctx context.Context, id string) (*OrderSubscription, error) {
+	pubsub := u.RedisClient.Subscribe(ctx, orderUpdatesPrefix+id)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	sub := &OrderSubscription{pubsub: pubsub, updates: make(chan models.OrderUpdate), done: make(chan struct{})}
+	go sub.forward()
+	return sub, nil
+}
+
+// Updates возвращает канал изменений; он закрывается после Close
+func (s *OrderSubscription) Updates() <-chan models.OrderUpdate {
+	return s.updates
+}
+
+// Close отменяет подписку
+func (s *OrderSubscription) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.pubsub.Close()
+}
+
+func (s *OrderSubscription) forward() {
+	defer close(s.updates)
+	for msg := range s.pubsub.Channel() {
+		var update models.OrderUpdate
+		if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+			logger.Error("failed to decode order update", "channel", msg.Channel, "error", err)
+			continue
+		}
+		select {
+		case s.updates <- update:
+		case <-s.done:
+			return
+		}
+	}
+}
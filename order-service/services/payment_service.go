@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"math"
+	"order-service/models"
+	"order-service/repositories"
+)
+
+// PaymentService — платёжный слой. Внешнего платёжного провайдера пока нет, поэтому возврат
+// средств фиксируется сразу как выполненный — в той же транзакции, что отмена заказа
+// (OrderRepository.CancelOrder) или одобрение заявки на возврат (ReturnRepository.CompleteReturn).
+type PaymentService struct {
+	Repo  *repositories.PaymentRepository
+	Audit *AuditService
+}
+
+func NewPaymentService(repo *repositories.PaymentRepository, audit *AuditService) *PaymentService {
+	return &PaymentService{Repo: repo, Audit: audit}
+}
+
+// RecordRefund записывает в журнал аудита сохранённый возврат средств; nil пропускается
+func (s *PaymentService) RecordRefund(ctx context.Context, refund *models.Refund) {
+	if refund == nil {
+		return
+	}
+	s.Audit.Record(ctx, "refund.create", models.AuditEntityRefund, refund.ID, nil, refund)
+}
+
+// GetOrderRefunds возвращает возвраты средств по заказу
+func (s *PaymentService) GetOrderRefunds(ctx context.Context, orderID string) ([]models.Refund, error) {
+	ctx, span := tracer.Start(ctx, "PaymentService.GetOrderRefunds")
+	defer span.End()
+
+	return s.Repo.GetRefundsByOrderID(ctx, orderID)
+}
+
+// roundMoney округляет сумму до копеек
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
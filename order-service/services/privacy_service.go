@@ -0,0 +1,256 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"order-service/models"
+	"order-service/repositories"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// retentionBatchSize — сколько учётных записей обезличивается за один проход задачи хранения
+const retentionBatchSize = 100
+
+// RetentionSettings — сроки хранения персональных данных
+type RetentionSettings struct {
+	// Учётные записи покупателей без входа дольше этого срока обезличиваются; 0 — не удалять
+	InactiveAccounts time.Duration
+	// IP клиентов в журнале аудита старше этого срока удаляются; 0 — хранить
+	AuditIP time.Duration
+}
+
+// PrivacyService — выгрузка персональных данных пользователя и их удаление (GDPR).
+// Заказы, возвраты средств и счета хранятся для бухгалтерии и при удалении остаются,
+// обезличивается только то, что связывает их с человеком.
+type PrivacyService struct {
+	UserRepo    *repositories.UserRepository
+	OrderRepo   *repositories.OrderRepository
+	ReturnRepo  *repositories.ReturnRepository
+	PaymentRepo *repositories.PaymentRepository
+	InvoiceRepo *repositories.InvoiceRepository
+	AuditRepo   *repositories.AuditRepository
+	RedisClient *redis.Client
+	Audit       *AuditService
+	Retention   RetentionSettings
+}
+
+func NewPrivacyService(userRepo *repositories.UserRepository, orderRepo *repositories.OrderRepository, returnRepo *repositories.ReturnRepository, paymentRepo *repositories.PaymentRepository, invoiceRepo *repositories.InvoiceRepository, auditRepo *repositories.AuditRepository, redisClient *redis.Client, audit *AuditService, retention RetentionSettings) *PrivacyService {
+	return &PrivacyService{
+		UserRepo:    userRepo,
+		OrderRepo:   orderRepo,
+		ReturnRepo:  returnRepo,
+		PaymentRepo: paymentRepo,
+		InvoiceRepo: invoiceRepo,
+		AuditRepo:   auditRepo,
+		RedisClient: redisClient,
+		Audit:       audit,
+		Retention:   retention,
+	}
+}
+
+// exportAddress — адрес и реквизиты покупателя из выпущенного документа
+type exportAddress struct {
+	Document string              `json:"document"`
+	IssuedAt time.Time           `json:"issued_at"`
+	Buyer    models.InvoiceParty `json:"buyer"`
+}
+
+// ExportUser собирает ZIP-архив с данными пользователя: профиль, заказы, заявки на возврат,
+// возвраты средств, адреса из счетов, корзина и записи журнала аудита — по JSON-файлу на раздел
+func (s *PrivacyService) ExportUser(ctx context.Context, id string, claims *TokenClaims) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "PrivacyService.ExportUser")
+	defer span.End()
+
+	if !claims.CanAccess(id) {
+		return nil, ErrForbidden
+	}
+	user, err := s.UserRepo.FindUserByID(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.OrderRepo.GetOrdersByUserID(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	refunds := []models.Refund{}
+	addresses := []exportAddress{}
+	for i := range orders {
+		order := &orders[i]
+		if order.Items, err = s.OrderRepo.GetOrderItems(ctx, order.ID); err != nil {
+			return nil, err
+		}
+		orderRefunds, err := s.PaymentRepo.GetRefundsByOrderID(ctx, order.ID)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, orderRefunds...)
+
+		// Адреса в профиле не хранятся: единственный источник — реквизиты покупателя в счетах
+		invoice, err := s.InvoiceRepo.GetInvoiceByOrderID(ctx, order.ID)
+		if err != nil {
+			return nil, err
+		}
+		if invoice != nil {
+			addresses = append(addresses, exportAddress{Document: invoice.Number, IssuedAt: invoice.IssuedAt, Buyer: invoice.Data.Buyer})
+		}
+	}
+
+	returns, err := s.ReturnRepo.GetReturnsByUserID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cartItems, err := s.RedisClient.HGetAll(ctx, fmt.Sprintf("cart:%s", id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	cart := make(map[string]int, len(cartItems))
+	for sku, quantity := range cartItems {
+		cart[sku], _ = strconv.Atoi(quantity)
+	}
+
+	auditEntries, err := s.AuditRepo.ListBySubject(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", user.ToResponse()},
+		{"orders.json", orders},
+		{"returns.json", returns},
+		{"refunds.json", refunds},
+		{"addresses.json", addresses},
+		{"cart.json", cart},
+		{"audit.json", auditEntries},
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EraseUser обезличивает пользователя по его запросу или по запросу администратора
+func (s *PrivacyService) EraseUser(ctx context.Context, id string, claims *TokenClaims) error {
+	ctx, span := tracer.Start(ctx, "PrivacyService.EraseUser")
+	defer span.End()
+
+	if !claims.CanAccess(id) {
+		return ErrForbidden
+	}
+	return s.erase(ctx, id)
+}
+
+// erase обезличивает пользователя в Postgres и удаляет его данные из Redis: корзину и кэши.
+// В MongoDB хранится только каталог, персональных данных там нет.
+// Повторный вызов для уже обезличенного пользователя ничего не меняет.
+func (s *PrivacyService) erase(ctx context.Context, id string) error {
+	before, err := s.UserRepo.FindUserByID(ctx, id, true)
+	if err != nil {
+		return err
+	}
+
+	erased, err := s.UserRepo.EraseUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	s.RedisClient.Del(ctx, fmt.Sprintf("cart:%s", id), fmt.Sprintf("user:%s", id), fmt.Sprintf("user_orders:%s", id))
+	// IP из записей аудита, сделанных пользователем, хранятся вне хэш-цепочки и удаляются вместе с его данными
+	if err := s.AuditRepo.DeleteActorIPs(ctx, id); err != nil {
+		return err
+	}
+	if !erased {
+		return nil
+	}
+
+	after, err := s.UserRepo.FindUserByID(ctx, id, true)
+	if err != nil {
+		return err
+	}
+	s.Audit.Record(ctx, "user.erase", models.AuditEntityUser, id, before, after)
+	return nil
+}
+
+// ApplyRetention удаляет устаревшие IP из журнала аудита и обезличивает учётные записи
+// покупателей, неактивные дольше срока хранения. Возвращает число обезличенных записей.
+func (s *PrivacyService) ApplyRetention(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "PrivacyService.ApplyRetention")
+	defer span.End()
+
+	if s.Retention.AuditIP > 0 {
+		removed, err := s.AuditRepo.DeleteIPsBefore(ctx, time.Now().Add(-s.Retention.AuditIP))
+		if err != nil {
+			return 0, err
+		}
+		if removed > 0 {
+			logger.InfoContext(ctx, "audit log IPs expired", "count", removed)
+		}
+	}
+	if s.Retention.InactiveAccounts <= 0 {
+		return 0, nil
+	}
+	ctx = WithActor(ctx, Actor{Role: ActorRoleSystem})
+
+	cutoff := time.Now().Add(-s.Retention.InactiveAccounts)
+	erased := 0
+	for {
+		ids, err := s.UserRepo.GetInactiveUserIDs(ctx, cutoff, retentionBatchSize)
+		if err != nil {
+			return erased, err
+		}
+		for _, id := range ids {
+			if err := s.erase(ctx, id); err != nil {
+				return erased, err
+			}
+			erased++
+		}
+		if len(ids) < retentionBatchSize || ctx.Err() != nil {
+			return erased, ctx.Err()
+		}
+	}
+}
+
+// RunRetention применяет политику хранения сразу и затем каждые interval, пока не отменён ctx
+func (s *PrivacyService) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		erased, err := s.ApplyRetention(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "retention run failed", "erased", erased, "error", err)
+		} else if erased > 0 {
+			logger.InfoContext(ctx, "inactive accounts erased", "count", erased)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"order-service/metrics"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Ключи кэша продуктов
+const (
+	productCachePrefix = "product:"
+	productsAllKey     = "products:all"
+)
+
+// productCacheChannel — канал Redis Pub/Sub, по которому экземпляры сообщают друг другу о сброшенных ключах
+const productCacheChannel = "cache_invalidations:products"
+
+// productCacheKey возвращает ключ кэша продукта по его ID
+func productCacheKey(id string) string {
+	return productCachePrefix + id
+}
+
+// productCacheInvalidation — сообщение о сброшенных ключах; All — сброшен весь кэш продуктов
+type productCacheInvalidation struct {
+	Instance string   `json:"instance"`
+	Keys     []string `json:"keys,omitempty"`
+	All      bool     `json:"all,omitempty"`
+}
+
+type localCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// ProductCache — кэш продуктов в Redis, общий для экземпляров, и копия в памяти экземпляра
+// не дольше LocalTTL (0 — без копии). Сброс ключа удаляет его из Redis и памяти и рассылается
+// остальным экземплярам через Pub/Sub, чтобы они удалили свои копии.
+type ProductCache struct {
+	RedisClient *redis.Client
+	LocalTTL    time.Duration
+
+	instance string // отличает свои сообщения от сообщений других экземпляров
+	mu       sync.Mutex
+	local    map[string]localCacheEntry
+}
+
+func NewProductCache(redisClient *redis.Client, localTTL time.Duration) *ProductCache {
+	return &ProductCache{
+		RedisClient: redisClient,
+		LocalTTL:    localTTL,
+		instance:    uuid.NewString(),
+		local:       map[string]localCacheEntry{},
+	}
+}
+
+// Get читает значение ключа в dst: сначала из памяти, затем из Redis
+func (c *ProductCache) Get(ctx context.Context, key string, dst any) bool {
+	if value, ok := c.getLocal(key); ok && json.Unmarshal(value, dst) == nil {
+		metrics.ObserveCache(key, true)
+		return true
+	}
+	cached, err := c.RedisClient.Get(ctx, key).Bytes()
+	if err == nil && json.Unmarshal(cached, dst) == nil {
+		c.setLocal(key, cached)
+		metrics.ObserveCache(key, true)
+		return true
+	}
+	metrics.ObserveCache(key, false)
+	return false
+}
+
+// Set сохраняет значение в Redis на ttl и в памяти на LocalTTL
+func (c *ProductCache) Set(ctx context.Context, key string, value any, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	c.RedisClient.Set(ctx, key, data, ttl)
+	c.setLocal(key, data)
+}
+
+// Invalidate удаляет ключи из Redis и памяти и сообщает о них остальным экземплярам
+func (c *ProductCache) Invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	c.dropLocal(keys...)
+	if err := c.RedisClient.Del(ctx, keys...).Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to invalidate product cache", "keys", keys, "error", err)
+		return err
+	}
+	return c.publish(ctx, productCacheInvalidation{Instance: c.instance, Keys: keys})
+}
+
+// InvalidateAll удаляет весь кэш продуктов — когда неизвестно, какие продукты изменились
+func (c *ProductCache) InvalidateAll(ctx context.Context) error {
+	c.mu.Lock()
+	clear(c.local)
+	c.mu.Unlock()
+
+	keys := []string{productsAllKey}
+	iter := c.RedisClient.Scan(ctx, 0, productCachePrefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to list product cache keys", "error", err)
+		return err
+	}
+	for start := 0; start < len(keys); start += 500 {
+		batch := keys[start:min(start+500, len(keys))]
+		if err := c.RedisClient.Del(ctx, batch...).Err(); err != nil {
+			logger.ErrorContext(ctx, "failed to invalidate product cache", "error", err)
+			return err
+		}
+	}
+	return c.publish(ctx, productCacheInvalidation{Instance: c.instance, All: true})
+}
+
+// RunInvalidations удаляет из памяти ключи, сброшенные другими экземплярами, до отмены ctx.
+// Сообщения, пропущенные во время отключения от Redis, не повторяются: после переподключения
+// память очищается целиком.
+func (c *ProductCache) RunInvalidations(ctx context.Context) {
+	pubsub := c.RedisClient.Subscribe(ctx, productCacheChannel)
+	defer pubsub.Close()
+
+	// Channel сам переподключается; подтверждения подписки приходят только через ChannelWithSubscriptions
+	messages := pubsub.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			switch msg := msg.(type) {
+			case *redis.Subscription:
+				// Повторная подписка после разрыва: что-то могло быть пропущено
+				c.mu.Lock()
+				clear(c.local)
+				c.mu.Unlock()
+			case *redis.Message:
+				c.receive(ctx, msg.Payload)
+			}
+		}
+	}
+}
+
+func (c *ProductCache) receive(ctx context.Context, payload string) {
+	var invalidation productCacheInvalidation
+	if err := json.Unmarshal([]byte(payload), &invalidation); err != nil {
+		logger.ErrorContext(ctx, "failed to decode product cache invalidation", "error", err)
+		return
+	}
+	if invalidation.Instance == c.instance {
+		return
+	}
+	metrics.CacheInvalidated(metrics.CacheInvalidationPeer)
+	if invalidation.All {
+		c.mu.Lock()
+		clear(c.local)
+		c.mu.Unlock()
+		return
+	}
+	c.dropLocal(invalidation.Keys...)
+}
+
+func (c *ProductCache) publish(ctx context.Context, invalidation productCacheInvalidation) error {
+	payload, err := json.Marshal(invalidation)
+	if err != nil {
+		return err
+	}
+	if err := c.RedisClient.Publish(context.WithoutCancel(ctx), productCacheChannel, payload).Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to publish product cache invalidation", "error", err)
+		return fmt.Errorf("publish product cache invalidation: %w", err)
+	}
+	return nil
+}
+
+func (c *ProductCache) getLocal(key string) ([]byte, bool) {
+	if c.LocalTTL == 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.local[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.local, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *ProductCache) setLocal(key string, value []byte) {
+	if c.LocalTTL == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local[key] = localCacheEntry{value: value, expiresAt: time.Now().Add(c.LocalTTL)}
+}
+
+func (c *ProductCache) dropLocal(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.local, key)
+	}
+}
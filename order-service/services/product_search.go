@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"html"
+	"order-service/models"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+// SearchProducts ищет продукты по тексту и фильтрам и подсвечивает совпадения
+func (s *ProductService) SearchProducts(ctx context.Context, params models.ProductSearchParams) (*models.ProductSearchResult, error) {
+	ctx, span := tracer.Start(ctx, "ProductService.SearchProducts")
+	defer span.End()
+
+	params.Query = strings.TrimSpace(params.Query)
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = defaultSearchPageSize
+	}
+	if params.PageSize > maxSearchPageSize {
+		params.PageSize = maxSearchPageSize
+	}
+
+	result, err := s.Repo.SearchProducts(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if terms := highlightTerms(params.Query); len(terms) > 0 {
+		for i := range result.Items {
+			item := &result.Items[i]
+			highlights := make(map[string]string)
+			if text, ok := highlight(item.Name, terms); ok {
+				highlights["name"] = text
+			}
+			if text, ok := highlight(item.Description, terms); ok {
+				highlights["description"] = text
+			}
+			if len(highlights) > 0 {
+				item.Highlights = highlights
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// searchWord — слово так, как его выделяет текстовый индекс: буквы и цифры между разделителями
+var searchWord = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// highlightTerms возвращает слова запроса в нижнем регистре. Слова с минусом исключены
+// из поиска и не подсвечиваются. Индекс построен без стемминга (язык "none"), поэтому
+// подсвечиваются только слова, совпадающие с запросом целиком, — как их и находит Mongo.
+func highlightTerms(query string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, term := range strings.Fields(query) {
+		if strings.HasPrefix(term, "-") {
+			continue
+		}
+		for _, word := range searchWord.FindAllString(term, -1) {
+			terms[strings.ToLower(word)] = true
+		}
+	}
+	return terms
+}
+
+// highlight оборачивает слова запроса в <em>; остальной текст экранируется как HTML
+func highlight(text string, terms map[string]bool) (string, bool) {
+	var b strings.Builder
+	last, found := 0, false
+	for _, m := range searchWord.FindAllStringIndex(text, -1) {
+		if !terms[strings.ToLower(text[m[0]:m[1]])] {
+			continue
+		}
+		found = true
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString("</em>")
+		last = m[1]
+	}
+	if !found {
+		return "", false
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), true
+}
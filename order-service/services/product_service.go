@@ -2,143 +2,382 @@ package services
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"order-service/models"
 	"order-service/repositories"
-	"strings"
 	"time"
 
-	"github.com/redis/go-redis/v9"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ErrSKUTaken — ID или SKU уже используется другим продуктом
+var ErrSKUTaken = models.NewConflict("sku_taken", "product id or sku is already used by another product")
+
+// ErrStockManaged — остаток существующего продукта ведёт журнал движений склада, PUT и PATCH его не меняют
+var ErrStockManaged = models.NewFieldErrors(models.FieldError{Field: "stock", Message: "is managed by inventory; record a stock movement instead"})
+
 type ProductService struct {
-	Repo        *repositories.ProductRepository
-	RedisClient *redis.Client
+	Repo         *repositories.ProductRepository
+	CategoryRepo *repositories.CategoryRepository
+	Inventory    *InventoryService
+	Cache        *ProductCache
+	Audit        *AuditService
 }
 
-func NewProductService(repo *repositories.ProductRepository, redisClient *redis.Client) *ProductService {
+func NewProductService(repo *repositories.ProductRepository, categoryRepo *repositories.CategoryRepository, inventory *InventoryService, cache *ProductCache, audit *AuditService) *ProductService {
 	return &ProductService{
-		Repo:        repo,
-		RedisClient: redisClient,
+		Repo:         repo,
+		CategoryRepo: categoryRepo,
+		Inventory:    inventory,
+		Cache:        cache,
+		Audit:        audit,
 	}
 }
 
-func (s *ProductService) CreateProduct(product *models.Product) error {
-	return s.Repo.CreateProduct(product)
+// CreateProduct создаёт продукт; остатки вариантов приходуются на склад по умолчанию
+func (s *ProductService) CreateProduct(ctx context.Context, product *models.Product) error {
+	ctx, span := tracer.Start(ctx, "ProductService.CreateProduct")
+	defer span.End()
+
+	if err := s.prepareProduct(ctx, product, nil); err != nil {
+		return err
+	}
+	err := s.Repo.CreateProduct(ctx, product)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSKUTaken
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.Inventory.ReceiveProductStock(ctx, product); err != nil {
+		return err
+	}
+	s.Audit.Record(ctx, "product.create", models.AuditEntityProduct, product.IDString, nil, product.ToResponse())
+	return nil
 }
 
-func (s *ProductService) GetProductById(id string) (*models.Product, error) {
+// GetProductById возвращает продукт по каноническому или прежнему ID.
+// В кэш продукт попадает только под каноническим ID. Удалённый продукт
+// возвращается только если includeDeleted, в обход кэша.
+func (s *ProductService) GetProductById(ctx context.Context, id string, includeDeleted bool) (*models.Product, error) {
+	ctx, span := tracer.Start(ctx, "ProductService.GetProductById")
+	defer span.End()
+
+	if includeDeleted {
+		return s.Repo.FindProductById(ctx, id, true)
+	}
+
 	// Проверяем кэш
-	cacheKey := fmt.Sprintf("product:%s", id)
-	if cached, err := s.RedisClient.Get(context.Background(), cacheKey).Result(); err == nil {
-		var product models.Product
-		if err := json.Unmarshal([]byte(cached), &product); err == nil {
-			return &product, nil
-		}
+	var cached models.Product
+	if s.Cache.Get(ctx, productCacheKey(id), &cached) {
+		return &cached, nil
 	}
 
 	// Если нет в кэше, получаем из БД
-	product, err := s.Repo.GetProductById(id)
+	product, err := s.Repo.GetProductById(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	// Сохраняем в кэш
-	if productJSON, err := json.Marshal(product); err == nil {
-		s.RedisClient.Set(context.Background(), cacheKey, productJSON, 24*time.Hour)
-	}
+	s.Cache.Set(ctx, productCacheKey(product.IDString), product, 24*time.Hour)
 
 	return product, nil
 }
 
-func (s *ProductService) GetAllProducts() ([]models.ProductResponse, error) {
+// ResolveProductID возвращает канонический ID продукта по любому его ID
+func (s *ProductService) ResolveProductID(ctx context.Context, id string) (*models.ProductIDResolution, error) {
+	ctx, span := tracer.Start(ctx, "ProductService.ResolveProductID")
+	defer span.End()
+
+	product, err := s.Repo.GetProductById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ProductIDResolution{
+		ID:          id,
+		CanonicalID: product.IDString,
+		Legacy:      id != product.IDString,
+	}, nil
+}
+
+// GetAllProducts возвращает каталог; с includeDeleted — вместе с удалёнными продуктами, в обход кэша
+func (s *ProductService) GetAllProducts(ctx context.Context, includeDeleted bool) ([]models.ProductResponse, error) {
+	ctx, span := tracer.Start(ctx, "ProductService.GetAllProducts")
+	defer span.End()
+
+	if includeDeleted {
+		return s.Repo.GetAllProducts(ctx, true)
+	}
+
 	// Проверяем кэш
-	cacheKey := "products:all"
-	if cached, err := s.RedisClient.Get(context.Background(), cacheKey).Result(); err == nil {
-		var products []models.ProductResponse
-		if err := json.Unmarshal([]byte(cached), &products); err == nil {
-			return products, nil
-		}
+	var cached []models.ProductResponse
+	if s.Cache.Get(ctx, productsAllKey, &cached) {
+		return cached, nil
 	}
 
 	// Если нет в кэше, получаем из БД
-	products, err := s.Repo.GetAllProducts()
+	products, err := s.Repo.GetAllProducts(ctx, false)
 	if err != nil {
 		return nil, err
 	}
 
 	// Сохраняем в кэш
-	if productsJSON, err := json.Marshal(products); err == nil {
-		s.RedisClient.Set(context.Background(), cacheKey, productsJSON, 1*time.Hour)
-	}
+	s.Cache.Set(ctx, productsAllKey, products, 1*time.Hour)
 
 	return products, nil
 }
 
-func (s *ProductService) UpdateProduct(id string, updatedProduct *models.Product) (*models.Product, error) {
-	// Проверяем, является ли ID UUID (содержит дефисы)
-	if strings.Contains(id, "-") {
-		// Если это UUID, получаем продукт по IDString
-		product, err := s.Repo.GetProductById(id)
-		if err != nil {
-			return nil, err
-		}
+// GetProductsByIDs одним запросом возвращает продукты по каноническим или прежним ID, в том числе
+// удалённые, мимо кэша: так связанные продукты заказов и корзин загружаются пакетом
+func (s *ProductService) GetProductsByIDs(ctx context.Context, ids []string) (map[string]*models.Product, error) {
+	ctx, span := tracer.Start(ctx, "ProductService.GetProductsByIDs")
+	defer span.End()
 
-		// Обновляем в БД
-		err = s.Repo.UpdateProduct(product.ID, updatedProduct)
-		if err != nil {
-			return nil, err
-		}
+	return s.Repo.GetProductsByIDs(ctx, ids)
+}
 
-		// Инвалидируем кэш
-		cacheKey := fmt.Sprintf("product:%s", id)
-		s.RedisClient.Del(context.Background(), cacheKey)
-		s.RedisClient.Del(context.Background(), "products:all")
+// UpdateProduct заменяет продукт, если его текущая версия равна expectedVersion (0 — любая версия).
+// Остатки вариантов не меняются: их ведёт журнал движений склада.
+func (s *ProductService) UpdateProduct(ctx context.Context, id string, updatedProduct *models.Product, expectedVersion int64) (*models.Product, error) {
+	ctx, span := tracer.Start(ctx, "ProductService.UpdateProduct")
+	defer span.End()
 
-		return updatedProduct, nil
+	product, err := s.Repo.GetProductById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(product.Version, expectedVersion); err != nil {
+		return nil, err
 	}
 
-	// Если это не UUID, пробуем преобразовать в ObjectID
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return nil, errors.New("invalid product ID format")
+	// Идентификаторы продукта при обновлении не меняются
+	updatedProduct.IDString = ""
+	if err := s.prepareProduct(ctx, updatedProduct, product); err != nil {
+		return nil, err
 	}
+	updatedProduct.ID = product.ID
+	updatedProduct.IDString = product.IDString
+	updatedProduct.Version = product.Version
 
 	// Обновляем в БД
-	err = s.Repo.UpdateProduct(objID, updatedProduct)
+	err = s.Repo.UpdateProduct(ctx, product.ID, updatedProduct)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrSKUTaken
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Инвалидируем кэш
-	cacheKey := fmt.Sprintf("product:%s", id)
-	s.RedisClient.Del(context.Background(), cacheKey)
-	s.RedisClient.Del(context.Background(), "products:all")
-
+	s.invalidateCache(ctx, product)
+	s.Audit.Record(ctx, "product.update", models.AuditEntityProduct, product.IDString, product.ToResponse(), updatedProduct.ToResponse())
 	return updatedProduct, nil
 }
 
-func (s *ProductService) DeleteProduct(id string) error {
-	// Проверяем, является ли ID UUID (содержит дефисы)
-	if strings.Contains(id, "-") {
-		// Если это UUID, получаем продукт по IDString
-		product, err := s.Repo.GetProductById(id)
-		if err != nil {
-			return err
+// PatchProduct сохраняет только поля fields из request — результата применения PATCH к продукту
+func (s *ProductService) PatchProduct(ctx context.Context, id string, request *models.ProductRequest, fields []string, expectedVersion int64) (*models.Product, error) {
+	ctx, span := tracer.Start(ctx, "ProductService.PatchProduct")
+	defer span.End()
+
+	product, err := s.Repo.GetProductById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(product.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return product, nil
+	}
+
+	// Остатки ведёт журнал движений склада
+	if contains(fields, "stock") {
+		return nil, ErrStockManaged
+	}
+
+	patched := request.ToProduct()
+	if !contains(fields, "variants") && contains(fields, "price") {
+		// Цена продукта с одним вариантом относится к этому варианту;
+		// у продукта с несколькими вариантами она вычисляется и напрямую не меняется
+		if len(product.Variants) != 1 {
+			return nil, models.NewFieldErrors(models.FieldError{Field: "price", Message: "is derived from variants; patch variants instead"})
 		}
+		variant := product.Variants[0]
+		variant.Price = patched.Price
+		patched.Variants = []models.ProductVariant{variant}
+	}
+	if err := s.prepareProduct(ctx, patched, product); err != nil {
+		return nil, err
+	}
+	patched.ID = product.ID
+	patched.IDString = product.IDString
+	patched.Version = product.Version
+
+	err = s.Repo.PatchProduct(ctx, patched, fields)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrSKUTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateCache(ctx, product)
+	s.Audit.Record(ctx, "product.patch", models.AuditEntityProduct, product.IDString, product.ToResponse(), patched.ToResponse())
+	return patched, nil
+}
 
-		// Удаляем из БД
-		return s.Repo.DeleteProduct(product.ID)
+// DeleteProduct мягко удаляет продукт: он исчезает из каталога и поиска, но корзины
+// и документы по-прежнему видят его варианты
+func (s *ProductService) DeleteProduct(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "ProductService.DeleteProduct")
+	defer span.End()
+
+	product, err := s.Repo.GetProductById(ctx, id)
+	if err != nil {
+		return err
 	}
+	before := product.ToResponse()
 
-	// Если это не UUID, пробуем преобразовать в ObjectID
-	objID, err := primitive.ObjectIDFromHex(id)
+	if err := s.Repo.SoftDeleteProduct(ctx, product); err != nil {
+		return err
+	}
+
+	s.invalidateCache(ctx, product)
+	s.Audit.Record(ctx, "product.delete", models.AuditEntityProduct, product.IDString, before, product.ToResponse())
+	return nil
+}
+
+// RestoreProduct возвращает мягко удалённый продукт в каталог; неудалённый возвращается как есть
+func (s *ProductService) RestoreProduct(ctx context.Context, id string) (*models.Product, error) {
+	ctx, span := tracer.Start(ctx, "ProductService.RestoreProduct")
+	defer span.End()
+
+	product, err := s.Repo.FindProductById(ctx, id, true)
 	if err != nil {
-		return errors.New("invalid product ID format")
+		return nil, err
+	}
+	if product.DeletedAt == nil {
+		return product, nil
+	}
+	before := product.ToResponse()
+
+	if err := s.Repo.RestoreProduct(ctx, product); err != nil {
+		return nil, err
+	}
+
+	s.invalidateCache(ctx, product)
+	s.Audit.Record(ctx, "product.restore", models.AuditEntityProduct, product.IDString, before, product.ToResponse())
+	return product, nil
+}
+
+func (s *ProductService) invalidateCache(ctx context.Context, product *models.Product) {
+	s.Cache.Invalidate(ctx, productCacheKey(product.IDString), productsAllKey)
+}
+
+// prepareProduct проверяет варианты, атрибуты и изображения и проставляет путь категории.
+// existing — текущая версия продукта при обновлении или nil при создании.
+// Ошибки возвращаются по полям запроса.
+func (s *ProductService) prepareProduct(ctx context.Context, product *models.Product, existing *models.Product) error {
+	var fields models.FieldErrors
+
+	// Клиент может задать ID нового продукта, но только в каноническом виде
+	if product.IDString != "" && !models.IsCanonicalProductID(product.IDString) {
+		fields.Add("id", "must be a lowercase UUID")
+	}
+
+	// Без вариантов в запросе: у продукта с одним вариантом цена
+	// относится к этому варианту, у продукта с несколькими вариантами варианты не меняются
+	if len(product.Variants) == 0 && existing != nil {
+		if len(existing.Variants) == 1 {
+			variant := existing.Variants[0]
+			variant.Price = product.Price
+			product.Variants = []models.ProductVariant{variant}
+		} else {
+			product.Variants = existing.Variants
+		}
+	}
+
+	// Остаток существующего продукта — копия журнала склада: из запроса он не берётся,
+	// новый вариант получает остаток поступлением на склад
+	if existing != nil {
+		for i := range product.Variants {
+			product.Variants[i].Stock = 0
+			if variant, ok := existing.Variant(product.Variants[i].SKU); ok {
+				product.Variants[i].Stock = variant.Stock
+			}
+		}
+	}
+
+	skus := make(map[string]bool, len(product.Variants))
+	for i, variant := range product.Variants {
+		switch {
+		case variant.SKU == "":
+			fields.Add(fmt.Sprintf("variants[%d].sku", i), "is required")
+		case skus[variant.SKU]:
+			fields.Add(fmt.Sprintf("variants[%d].sku", i), "duplicates another variant")
+		}
+		skus[variant.SKU] = true
+		if variant.Price < 0 {
+			fields.Add(fmt.Sprintf("variants[%d].price", i), "must be greater than or equal to 0")
+		}
+		if variant.Stock < 0 {
+			fields.Add(fmt.Sprintf("variants[%d].stock", i), "must be greater than or equal to 0")
+		}
+	}
+
+	for i, attribute := range product.Attributes {
+		if message := validateAttribute(attribute); message != "" {
+			fields.Add(fmt.Sprintf("attributes[%d].value", i), message)
+		}
+	}
+
+	for i, image := range product.Images {
+		if image.VariantSKU != "" && !skus[image.VariantSKU] && len(product.Variants) > 0 {
+			fields.Add(fmt.Sprintf("images[%d].variant_sku", i), "refers to an unknown variant")
+		}
 	}
 
-	return s.Repo.DeleteProduct(objID)
+	product.CategoryPath = nil
+	if product.Category != "" {
+		category, err := s.CategoryRepo.GetCategoryBySlug(ctx, product.Category)
+		switch {
+		case errors.Is(err, repositories.ErrCategoryNotFound):
+			fields.Add("category", "category does not exist")
+		case err != nil:
+			return err
+		default:
+			product.CategoryPath = category.Path
+		}
+	}
+	return fields.Err()
+}
+
+// validateAttribute проверяет, что значение атрибута соответствует его типу;
+// возвращает текст ошибки или пустую строку
+func validateAttribute(attribute models.ProductAttribute) string {
+	var ok bool
+	switch attribute.Type {
+	case models.AttributeTypeString:
+		_, ok = attribute.Value.(string)
+	case models.AttributeTypeNumber:
+		_, ok = attribute.Value.(float64)
+	case models.AttributeTypeBoolean:
+		_, ok = attribute.Value.(bool)
+	default:
+		return fmt.Sprintf("has unknown type %q", attribute.Type)
+	}
+	if !ok {
+		return "must be a " + attribute.Type
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
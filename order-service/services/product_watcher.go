@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"order-service/metrics"
+	"order-service/repositories"
+	"time"
+)
+
+// WatchChanges сбрасывает кэш продуктов по потоку изменений коллекции products, пока не отменён ctx.
+// Так продукты, изменённые в MongoDB в обход API (например, инструментами каталога), не остаются
+// в кэше. После ошибки поток переоткрывается через retry с сохранённой позиции.
+func (s *ProductService) WatchChanges(ctx context.Context, retry time.Duration) {
+	// flush — позиция потока потеряна, и кэш нужно сбросить целиком до его переоткрытия
+	flush := false
+	for {
+		var err error
+		if flush {
+			if err = s.Cache.InvalidateAll(ctx); err == nil {
+				flush = false
+			}
+		}
+		if !flush {
+			err = s.Repo.WatchChanges(ctx, s.applyChange)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case errors.Is(err, repositories.ErrChangeStreamsUnsupported):
+			logger.WarnContext(ctx, "product change stream is unavailable; cache is invalidated only by API changes", "error", err)
+			return
+		case errors.Is(err, repositories.ErrProductChangesLost):
+			// Неизвестно, какие продукты менялись, пока поток был недоступен
+			logger.WarnContext(ctx, "product change stream position is lost; invalidating the whole product cache", "error", err)
+			flush = true
+			continue
+		case err != nil:
+			logger.ErrorContext(ctx, "product change stream failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// applyChange сбрасывает кэш изменённого продукта и каталога; если продукт неизвестен — весь кэш продуктов
+func (s *ProductService) applyChange(ctx context.Context, change repositories.ProductChange) error {
+	metrics.CacheInvalidated(metrics.CacheInvalidationChangeStream)
+	if len(change.IDs) == 0 {
+		logger.DebugContext(ctx, "product changed without a document; invalidating the whole product cache", "operation", change.Operation)
+		return s.Cache.InvalidateAll(ctx)
+	}
+
+	keys := []string{productsAllKey}
+	for _, id := range change.IDs {
+		keys = append(keys, productCacheKey(id))
+	}
+	logger.DebugContext(ctx, "product changed in MongoDB; invalidating cache", "operation", change.Operation, "product_id", change.IDs[0])
+	return s.Cache.Invalidate(ctx, keys...)
+}
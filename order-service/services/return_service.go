@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"order-service/repositories"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrOrderNotReturnable — возврат возможен только после доставки
+	ErrOrderNotReturnable = models.NewConflict("order_not_returnable", "order can only be returned after delivery")
+	// ErrReturnResolved — заявка уже одобрена или отклонена
+	ErrReturnResolved = models.NewConflict("return_resolved", "return has already been resolved")
+)
+
+type ReturnService struct {
+	Repo        *repositories.ReturnRepository
+	OrderRepo   *repositories.OrderRepository
+	Inventory   *InventoryService
+	Payments    *PaymentService
+	RedisClient *redis.Client
+	Audit       *AuditService
+	Webhooks    *WebhookService
+	Updates     *OrderUpdates
+}
+
+func NewReturnService(repo *repositories.ReturnRepository, orderRepo *repositories.OrderRepository, inventory *InventoryService, payments *PaymentService, redisClient *redis.Client, audit *AuditService, webhooks *WebhookService, updates *OrderUpdates) *ReturnService {
+	return &ReturnService{
+		Repo:        repo,
+		OrderRepo:   orderRepo,
+		Inventory:   inventory,
+		Payments:    payments,
+		RedisClient: redisClient,
+		Audit:       audit,
+		Webhooks:    webhooks,
+		Updates:     updates,
+	}
+}
+
+// RequestReturn создаёт заявку на возврат позиций доставленного заказа
+func (s *ReturnService) RequestReturn(ctx context.Context, orderID string, claims *TokenClaims, reason string, items []models.ReturnItem) (*models.Return, error) {
+	ctx, span := tracer.Start(ctx, "ReturnService.RequestReturn")
+	defer span.End()
+
+	order, err := s.OrderRepo.GetOrderById(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, ErrForbidden
+	}
+	if !order.IsReturnable() {
+		return nil, ErrOrderNotReturnable
+	}
+
+	var fields models.FieldErrors
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		fields.Add("reason", "is required")
+	}
+	if len(items) == 0 {
+		fields.Add("items", "must contain at least one item")
+	}
+
+	// Нельзя вернуть больше, чем заказано, с учётом уже поданных заявок
+	alreadyReturned, err := s.Repo.GetReturnedQuantities(ctx, order.ID, models.ReturnStatusRequested, models.ReturnStatusRefunded)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make(map[string]models.CartItem, len(order.Items))
+	for _, item := range order.Items {
+		ordered[item.SKU] = item
+	}
+
+	seen := make(map[string]bool, len(items))
+	returnItems := make([]models.ReturnItem, 0, len(items))
+	for i, item := range items {
+		field := fmt.Sprintf("items[%d]", i)
+		sku := item.SKU
+		if sku == "" {
+			// Старые клиенты передают только product_id: подходит, если у продукта одна позиция в заказе
+			sku = skuByProductID(order.Items, item.ProductID)
+		}
+
+		orderItem, ok := ordered[sku]
+		if !ok || sku == "" {
+			if item.SKU != "" {
+				fields.Add(field+".sku", "is not in the order")
+			} else {
+				fields.Add(field+".product_id", "is not in the order or matches several order lines")
+			}
+			continue
+		}
+		if seen[sku] {
+			fields.Add(field+".sku", "is listed twice")
+			continue
+		}
+		seen[sku] = true
+
+		if item.Quantity <= 0 {
+			fields.Add(field+".quantity", "must be greater than or equal to 1")
+			continue
+		}
+		if available := orderItem.Quantity - alreadyReturned[sku]; item.Quantity > available {
+			fields.Add(field+".quantity", fmt.Sprintf("only %d can be returned", available))
+			continue
+		}
+
+		returnItems = append(returnItems, models.ReturnItem{
+			ProductID: orderItem.ProductID,
+			SKU:       sku,
+			Quantity:  item.Quantity,
+			Price:     orderItem.Price,
+			Reason:    strings.TrimSpace(item.Reason),
+		})
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+
+	ret := &models.Return{
+		OrderID: order.ID,
+		UserID:  order.UserID,
+		Status:  models.ReturnStatusRequested,
+		Reason:  reason,
+		Items:   returnItems,
+	}
+	if err := s.Repo.CreateReturn(ctx, ret); err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, "return.request", models.AuditEntityReturn, ret.ID, nil, ret)
+	return ret, nil
+}
+
+// GetReturn возвращает заявку, если она принадлежит пользователю или он администратор
+func (s *ReturnService) GetReturn(ctx context.Context, id string, claims *TokenClaims) (*models.Return, error) {
+	ctx, span := tracer.Start(ctx, "ReturnService.GetReturn")
+	defer span.End()
+
+	ret, err := s.Repo.GetReturnByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(ret.UserID) {
+		return nil, ErrForbidden
+	}
+	return ret, nil
+}
+
+// GetOrderReturns возвращает все заявки на возврат по заказу
+func (s *ReturnService) GetOrderReturns(ctx context.Context, orderID string, claims *TokenClaims) ([]models.Return, error) {
+	ctx, span := tracer.Start(ctx, "ReturnService.GetOrderReturns")
+	defer span.End()
+
+	order, err := s.OrderRepo.GetOrderById(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.CanAccess(order.UserID) {
+		return nil, ErrForbidden
+	}
+	return s.Repo.GetReturnsByOrderID(ctx, orderID)
+}
+
+// ListReturns возвращает заявки в указанном статусе (для администратора)
+func (s *ReturnService) ListReturns(ctx context.Context, status string) ([]models.Return, error) {
+	ctx, span := tracer.Start(ctx, "ReturnService.ListReturns")
+	defer span.End()
+
+	return s.Repo.GetReturnsByStatus(ctx, status)
+}
+
+// ApproveReturn одобряет заявку: возвращает деньги (в одной транзакции с заявкой и статусом заказа)
+// и возвращает товары на склад
+func (s *ReturnService) ApproveReturn(ctx context.Context, id, adminID, comment string) (*models.Return, error) {
+	ctx, span := tracer.Start(ctx, "ReturnService.ApproveReturn")
+	defer span.End()
+
+	ret, err := s.Repo.GetReturnByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Status != models.ReturnStatusRequested {
+		return nil, ErrReturnResolved
+	}
+
+	order, err := s.OrderRepo.GetOrderById(ctx, ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var amount float64
+	for _, item := range ret.Items {
+		amount += item.Price * float64(item.Quantity)
+	}
+	before := *ret
+	ret.RefundAmount = roundMoney(amount)
+
+	orderStatus, err := s.orderStatusAfterReturn(ctx, order, ret)
+	if err != nil {
+		return nil, err
+	}
+
+	// Заявка, возврат средств и статус заказа сохраняются одной транзакцией
+	refund, err := s.Repo.CompleteReturn(ctx, ret, adminID, comment, order.Status, orderStatus)
+	if err != nil {
+		if errors.Is(err, repositories.ErrReturnStatusConflict) {
+			return nil, ErrReturnResolved
+		}
+		return nil, err
+	}
+	s.Payments.RecordRefund(ctx, refund)
+
+	// Деньги уже возвращены, поэтому ошибку пополнения склада только логируем
+	if err := s.Inventory.RestockReturn(ctx, ret); err != nil {
+		logger.ErrorContext(ctx, "failed to restock return", "return_id", ret.ID, "error", err)
+	}
+	s.invalidateCache(ctx, order)
+
+	ret.Status = models.ReturnStatusRefunded
+	ret.AdminComment = comment
+	ret.ResolvedBy = &adminID
+	s.Audit.Record(ctx, "return.approve", models.AuditEntityReturn, ret.ID, &before, ret)
+	if orderStatus != order.Status {
+		after := *order
+		after.Status = orderStatus
+		s.Webhooks.OrderChanged(ctx, "", order, &after)
+		s.Updates.Publish(ctx, order, &after)
+	}
+	return ret, nil
+}
+
+// RejectReturn отклоняет заявку на возврат
+func (s *ReturnService) RejectReturn(ctx context.Context, id, adminID, comment string) (*models.Return, error) {
+	ctx, span := tracer.Start(ctx, "ReturnService.RejectReturn")
+	defer span.End()
+
+	before, err := s.Repo.GetReturnByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.RejectReturn(ctx, id, adminID, comment); err != nil {
+		if errors.Is(err, repositories.ErrReturnStatusConflict) {
+			return nil, ErrReturnResolved
+		}
+		return nil, err
+	}
+	ret, err := s.Repo.GetReturnByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, "return.reject", models.AuditEntityReturn, ret.ID, before, ret)
+	return ret, nil
+}
+
+// orderStatusAfterReturn определяет статус заказа после одобрения заявки ret
+func (s *ReturnService) orderStatusAfterReturn(ctx context.Context, order *models.Order, ret *models.Return) (string, error) {
+	returned, err := s.Repo.GetReturnedQuantities(ctx, order.ID, models.ReturnStatusRefunded)
+	if err != nil {
+		return "", err
+	}
+	for _, item := range ret.Items {
+		returned[item.SKU] += item.Quantity
+	}
+
+	for _, item := range order.Items {
+		if returned[item.SKU] < item.Quantity {
+			return models.OrderStatusPartiallyReturned, nil
+		}
+	}
+	return models.OrderStatusReturned, nil
+}
+
+func (s *ReturnService) invalidateCache(ctx context.Context, order *models.Order) {
+	s.RedisClient.Del(ctx, fmt.Sprintf("order:%s", order.ID))
+	s.RedisClient.Del(ctx, fmt.Sprintf("user_orders:%s", order.UserID))
+	s.RedisClient.Del(ctx, "order:statistics")
+}
+
+// skuByProductID возвращает SKU позиции заказа с данным продуктом, если такая позиция одна
+func skuByProductID(items []models.CartItem, productID string) string {
+	sku := ""
+	for _, item := range items {
+		if item.ProductID != productID {
+			continue
+		}
+		if sku != "" {
+			return ""
+		}
+		sku = item.SKU
+	}
+	return sku
+}
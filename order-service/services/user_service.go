@@ -4,37 +4,45 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"order-service/metrics"
 	"order-service/models"
 	"order-service/repositories"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidCredentials — неверный email или пароль; причина намеренно не уточняется
+var ErrInvalidCredentials = models.NewUnauthorized("invalid_credentials", "invalid credentials")
+
 type UserService struct {
 	Repo        *repositories.UserRepository
 	RedisClient *redis.Client
+	Audit       *AuditService
 }
 
-func NewUserService(repo *repositories.UserRepository, redisClient *redis.Client) *UserService {
+func NewUserService(repo *repositories.UserRepository, redisClient *redis.Client, audit *AuditService) *UserService {
 	return &UserService{
 		Repo:        repo,
 		RedisClient: redisClient,
+		Audit:       audit,
 	}
 }
 
 // Регистрация пользователя
-func (s *UserService) Register(username, email, password string) (*models.User, error) {
+func (s *UserService) Register(ctx context.Context, username, email, password string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Register")
+	defer span.End()
+
 	// Проверка на наличие пользователя с таким email
-	existingUser, err := s.Repo.GetUserByEmail(email)
+	existingUser, err := s.Repo.GetUserByEmail(ctx, email)
 	if err != nil {
 		// Если ошибка не связана с отсутствием пользователя, вернем ее
 		return nil, fmt.Errorf("error checking for existing user: %v", err)
 	}
 	if existingUser != nil {
-		return nil, fmt.Errorf("user with email %s already exists", email)
+		return nil, repositories.ErrUserExists
 	}
 
 	// Хеширование пароля
@@ -50,26 +58,33 @@ func (s *UserService) Register(username, email, password string) (*models.User,
 		Password: string(hashedPassword),
 	}
 
-	err = s.Repo.CreateUser(user)
+	err = s.Repo.CreateUser(ctx, user)
 	if err != nil {
-		return nil, fmt.Errorf("error saving user to database: %v", err)
+		return nil, err
 	}
+	s.Audit.Record(ctx, "user.register", models.AuditEntityUser, user.ID, nil, user)
 
 	return user, nil
 }
 
 // Вход в систему
-func (s *UserService) Login(email, password string) (string, error) {
+func (s *UserService) Login(ctx context.Context, email, password string) (string, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Login")
+	defer span.End()
+
 	// Получение пользователя по email
-	user, err := s.Repo.GetUserByEmail(email)
+	user, err := s.Repo.GetUserByEmail(ctx, email)
 	if err != nil {
-		return "", fmt.Errorf("user not found")
+		return "", err
+	}
+	if user == nil {
+		return "", ErrInvalidCredentials
 	}
 
 	// Проверка пароля
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return "", fmt.Errorf("invalid credentials")
+		return "", ErrInvalidCredentials
 	}
 
 	// Генерация JWT токена
@@ -77,79 +92,169 @@ func (s *UserService) Login(email, password string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	// По времени входа считается срок хранения неактивных учётных записей; вход из-за ошибки не отклоняем
+	if err := s.Repo.TouchLastLogin(ctx, user.ID); err != nil {
+		logger.WarnContext(ctx, "failed to record last login", "user_id", user.ID, "error", err)
+	}
 
 	return token, nil
 }
 
 // Генерация JWT токена
 func (s *UserService) generateJWT(user *models.User) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte("secret_key"))
+	return GenerateToken(user)
 }
 
-func (s *UserService) GetUserByID(id string) (*models.User, error) {
+// GetUserByID возвращает пользователя; удалённого — только если includeDeleted (мимо кэша)
+func (s *UserService) GetUserByID(ctx context.Context, id string, includeDeleted bool) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByID")
+	defer span.End()
+
+	if includeDeleted {
+		return s.Repo.FindUserByID(ctx, id, true)
+	}
+
 	// Проверяем кэш
 	cacheKey := fmt.Sprintf("user:%s", id)
-	if cached, err := s.RedisClient.Get(context.Background(), cacheKey).Result(); err == nil {
+	if cached, err := s.RedisClient.Get(ctx, cacheKey).Result(); err == nil {
 		var user models.User
 		if err := json.Unmarshal([]byte(cached), &user); err == nil {
+			metrics.ObserveCache(cacheKey, true)
 			return &user, nil
 		}
 	}
+	metrics.ObserveCache(cacheKey, false)
 
 	// Если нет в кэше, получаем из БД
-	user, err := s.Repo.GetUserByID(id)
+	user, err := s.Repo.GetUserByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, err
 	}
 
-	// Сохраняем в кэш
+	// Сохраняем в кэш (хэш пароля в JSON не попадает)
 	if userJSON, err := json.Marshal(user); err == nil {
-		s.RedisClient.Set(context.Background(), cacheKey, userJSON, 12*time.Hour)
+		s.RedisClient.Set(ctx, cacheKey, userJSON, 12*time.Hour)
 	}
 
 	return user, nil
 }
 
-func (s *UserService) GetAllUsers() ([]models.User, error) {
-	return s.Repo.GetAllUsers()
+func (s *UserService) GetAllUsers(ctx context.Context, includeDeleted bool) ([]models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetAllUsers")
+	defer span.End()
+
+	return s.Repo.GetAllUsers(ctx, includeDeleted)
+}
+
+// GetUsersByIDs одним запросом возвращает пользователей по ID, в том числе удалённых, мимо кэша
+func (s *UserService) GetUsersByIDs(ctx context.Context, ids []string) (map[string]*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUsersByIDs")
+	defer span.End()
+
+	return s.Repo.GetUsersByIDs(ctx, ids)
+}
+
+// DeleteUser мягко удаляет пользователя: он исчезает из выборок и не может войти,
+// но данные и заказы остаются, и администратор может его восстановить
+func (s *UserService) DeleteUser(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	user, err := s.Repo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	before := *user
+	if err := s.Repo.SoftDeleteUser(ctx, user); err != nil {
+		return err
+	}
+	s.RedisClient.Del(ctx, fmt.Sprintf("user:%s", id))
+	s.Audit.Record(ctx, "user.delete", models.AuditEntityUser, id, &before, user)
+	return nil
+}
+
+// RestoreUser восстанавливает мягко удалённого пользователя; неудалённый возвращается как есть
+func (s *UserService) RestoreUser(ctx context.Context, id string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.RestoreUser")
+	defer span.End()
+
+	user, err := s.Repo.FindUserByID(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt == nil {
+		return user, nil
+	}
+	before := *user
+	if err := s.Repo.RestoreUser(ctx, user); err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, "user.restore", models.AuditEntityUser, id, &before, user)
+	return user, nil
 }
 
-func (s *UserService) UpdateUser(id string, updatedUser *models.User) (*models.User, error) {
+// UpdateUser обновляет пользователя, если его текущая версия равна expectedVersion (0 — любая версия).
+// Изменять профиль может сам пользователь или администратор.
+func (s *UserService) UpdateUser(ctx context.Context, id string, claims *TokenClaims, request *models.UpdateUserRequest, expectedVersion int64) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateUser")
+	defer span.End()
+
+	if !claims.CanAccess(id) {
+		return nil, ErrForbidden
+	}
+
 	// Проверка на наличие пользователя
-	user, err := s.Repo.GetUserByID(id)
+	user, err := s.Repo.GetUserByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, err
+	}
+	if err := checkVersion(user.Version, expectedVersion); err != nil {
+		return nil, err
 	}
 
 	// Обновляем информацию о пользователе
-	user.Username = updatedUser.Username
-	user.Email = updatedUser.Email
-	// Можно обновить другие поля при необходимости
+	before := *user
+	user.Username = request.Username
+	user.Email = request.Email
 
-	err = s.Repo.UpdateUser(user)
+	err = s.Repo.UpdateUser(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	s.RedisClient.Del(ctx, fmt.Sprintf("user:%s", id))
+	s.Audit.Record(ctx, "user.update", models.AuditEntityUser, user.ID, &before, user)
 
 	return user, nil
 }
 
-func (s *UserService) DeleteUser(id string) error {
-	// Проверяем кэш
-	cacheKey := fmt.Sprintf("user:%s", id)
-	s.RedisClient.Del(context.Background(), cacheKey)
+// PatchUser сохраняет только поля fields из request — результата применения PATCH к пользователю.
+// Изменять профиль может сам пользователь или администратор.
+func (s *UserService) PatchUser(ctx context.Context, id string, claims *TokenClaims, request *models.UpdateUserRequest, fields []string, expectedVersion int64) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.PatchUser")
+	defer span.End()
 
-	// Удаляем пользователя
-	err := s.Repo.DeleteUser(id)
+	if !claims.CanAccess(id) {
+		return nil, ErrForbidden
+	}
+	user, err := s.Repo.GetUserByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("user not found")
+		return nil, err
+	}
+	if err := checkVersion(user.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return user, nil
 	}
 
-	return nil
+	before := *user
+	user.Username = request.Username
+	user.Email = request.Email
+	if err := s.Repo.PatchUser(ctx, user, fields); err != nil {
+		return nil, err
+	}
+	s.RedisClient.Del(ctx, fmt.Sprintf("user:%s", id))
+	s.Audit.Record(ctx, "user.patch", models.AuditEntityUser, user.ID, &before, user)
+
+	return user, nil
 }
@@ -0,0 +1,11 @@
+package services
+
+import "order-service/repositories"
+
+// checkVersion сверяет текущую версию ресурса с ожидаемой клиентом; 0 — любая версия
+func checkVersion(current, expected int64) error {
+	if expected != 0 && expected != current {
+		return repositories.ErrVersionMismatch
+	}
+	return nil
+}
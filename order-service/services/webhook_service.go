@@ -0,0 +1,465 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"order-service/metrics"
+	"order-service/models"
+	"order-service/repositories"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Заголовки доставки вебхука
+const (
+	WebhookHeaderEventID   = "X-Webhook-Id"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// webhookResponseLimit — сколько байт ответа получателя сохраняется в журнале доставок
+const webhookResponseLimit = 1024
+
+// webhookLeaseMargin — запас сверх таймаута запроса, на который взятая в отправку доставка скрывается от других экземпляров
+const webhookLeaseMargin = time.Minute
+
+// WebhookSettings — параметры доставки вебхуков
+type WebhookSettings struct {
+	PollInterval time.Duration // как часто проверять очередь
+	Timeout      time.Duration // таймаут одного запроса к получателю
+	MaxAttempts  int           // после стольких неудачных попыток доставка помечается failed
+	BackoffBase  time.Duration // пауза после первой неудачи; дальше удваивается
+	BackoffMax   time.Duration // предел паузы между попытками
+	BatchSize    int           // сколько доставок отправляется параллельно
+}
+
+// webhookStore — хранилище подписок и очереди доставок; в работе это repositories.WebhookRepository
+type webhookStore interface {
+	CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id string) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context, activeOnly bool) ([]models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+	EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, d *models.WebhookDelivery) error
+	GetDelivery(ctx context.Context, subscriptionID string, id int64) (*models.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, subscriptionID string, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+}
+
+// WebhookService — подписки на события заказов и их доставка. События ставятся в очередь
+// в Postgres, фоновая задача отправляет их с подписью HMAC-SHA256 и повторяет неудачные
+// с экспоненциальной паузой.
+type WebhookService struct {
+	Repo     webhookStore
+	Audit    *AuditService
+	Client   *http.Client
+	Settings WebhookSettings
+}
+
+func NewWebhookService(repo *repositories.WebhookRepository, audit *AuditService, settings WebhookSettings) *WebhookService {
+	return &WebhookService{
+		Repo:  repo,
+		Audit: audit,
+		Client: &http.Client{
+			Timeout: settings.Timeout,
+			// Перенаправление считается ошибкой: подписанное тело уходит только на указанный адрес
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		Settings: settings,
+	}
+}
+
+// CreateSubscription создаёт подписку. Если секрет не задан, он генерируется;
+// секрет возвращается только в ответе на создание.
+func (s *WebhookService) CreateSubscription(ctx context.Context, request *models.WebhookSubscriptionRequest) (*models.WebhookSubscriptionResponse, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.CreateSubscription")
+	defer span.End()
+
+	if err := validateWebhookURL(request.URL); err != nil {
+		return nil, err
+	}
+	sub := &models.WebhookSubscription{
+		URL:         request.URL,
+		Secret:      request.Secret,
+		Events:      request.Events,
+		Active:      request.Active == nil || *request.Active,
+		Description: request.Description,
+	}
+	if sub.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		sub.Secret = secret
+	}
+	if err := s.Repo.CreateSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, "webhook.create", models.AuditEntityWebhook, sub.ID, nil, sub)
+	return &models.WebhookSubscriptionResponse{WebhookSubscription: *sub, Secret: sub.Secret}, nil
+}
+
+func (s *WebhookService) GetSubscription(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetSubscription")
+	defer span.End()
+
+	return s.Repo.GetSubscription(ctx, id)
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListSubscriptions")
+	defer span.End()
+
+	return s.Repo.ListSubscriptions(ctx, false)
+}
+
+// UpdateSubscription заменяет подписку, если её текущая версия равна expectedVersion (0 — любая версия).
+// Пустой секрет в запросе оставляет прежний.
+func (s *WebhookService) UpdateSubscription(ctx context.Context, id string, request *models.WebhookSubscriptionRequest, expectedVersion int64) (*models.WebhookSubscription, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.UpdateSubscription")
+	defer span.End()
+
+	if err := validateWebhookURL(request.URL); err != nil {
+		return nil, err
+	}
+	sub, err := s.Repo.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(sub.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	before := *sub
+	sub.URL = request.URL
+	if request.Secret != "" {
+		sub.Secret = request.Secret
+	}
+	sub.Events = request.Events
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+	sub.Active = request.Active == nil || *request.Active
+	sub.Description = request.Description
+	if err := s.Repo.UpdateSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	s.Audit.Record(ctx, "webhook.update", models.AuditEntityWebhook, id, &before, sub)
+	return sub, nil
+}
+
+// DeleteSubscription удаляет подписку и журнал её доставок
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.DeleteSubscription")
+	defer span.End()
+
+	sub, err := s.Repo.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.Repo.DeleteSubscription(ctx, id); err != nil {
+		return err
+	}
+	s.Audit.Record(ctx, "webhook.delete", models.AuditEntityWebhook, id, sub, nil)
+	return nil
+}
+
+// ListDeliveries возвращает страницу журнала доставок подписки
+func (s *WebhookService) ListDeliveries(ctx context.Context, id string, filter models.WebhookDeliveryFilter) (*models.WebhookDeliveryPage, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListDeliveries")
+	defer span.End()
+
+	var fields models.FieldErrors
+	switch filter.Status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed:
+	default:
+		fields.Add("status", "must be one of: pending succeeded failed")
+	}
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = defaultAuditLimit
+	case filter.Limit < 0 || filter.Limit > maxAuditLimit:
+		fields.Add("limit", "must be between 1 and 500")
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Repo.GetSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.Repo.ListDeliveries(ctx, id, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.WebhookDeliveryPage{Items: deliveries}
+	if len(deliveries) == filter.Limit {
+		page.NextBeforeID = &deliveries[len(deliveries)-1].ID
+	}
+	return page, nil
+}
+
+// Redeliver ставит событие доставки deliveryID в очередь ещё раз, отдельной доставкой
+// с тем же ID события — получатель может отбросить дубликат
+func (s *WebhookService) Redeliver(ctx context.Context, id string, deliveryID int64) (*models.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Redeliver")
+	defer span.End()
+
+	original, err := s.Repo.GetDelivery(ctx, id, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []models.WebhookDelivery{{
+		SubscriptionID: id,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		RedeliveryOf:   &original.ID,
+	}}
+	if err := s.Repo.EnqueueDeliveries(ctx, deliveries); err != nil {
+		return nil, err
+	}
+	delivery := &deliveries[0]
+	s.Audit.Record(ctx, "webhook.redeliver", models.AuditEntityWebhook, id, nil,
+		map[string]any{"delivery_id": delivery.ID, "redelivery_of": original.ID})
+	return delivery, nil
+}
+
+// OrderChanged ставит в очередь событие event по заказу order для подписанных на него подписок.
+// Если статус заказа отличается от статуса before, дополнительно публикуется order.status_changed;
+// пустой event — только проверка статуса. Изменение заказа к этому моменту уже сохранено,
+// поэтому ошибки не возвращаются, а логируются.
+func (s *WebhookService) OrderChanged(ctx context.Context, event string, before, order *models.Order) {
+	if s == nil {
+		return
+	}
+	ctx, span := tracer.Start(ctx, "WebhookService.OrderChanged")
+	defer span.End()
+
+	// Клиент мог уже отключиться, но событие должно попасть в очередь
+	ctx = context.WithoutCancel(ctx)
+	if event != "" {
+		s.publish(ctx, event, models.WebhookOrderData{Order: order.ToResponse()})
+	}
+	if before != nil && before.Status != order.Status {
+		s.publish(ctx, models.WebhookOrderStatusChanged, models.WebhookOrderData{Order: order.ToResponse(), PreviousStatus: before.Status})
+	}
+}
+
+// publish ставит событие в очередь каждой включённой подписке на него
+func (s *WebhookService) publish(ctx context.Context, eventType string, data any) {
+	subs, err := s.Repo.ListSubscriptions(ctx, true)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load webhook subscriptions", "event", eventType, "error", err)
+		return
+	}
+
+	event := models.WebhookEvent{ID: uuid.New().String(), Type: eventType, CreatedAt: time.Now().UTC()}
+	var deliveries []models.WebhookDelivery
+	for i := range subs {
+		if !subs[i].Subscribed(eventType) {
+			continue
+		}
+		if event.Data == nil {
+			if event.Data, err = json.Marshal(data); err != nil {
+				logger.ErrorContext(ctx, "failed to encode webhook event", "event", eventType, "error", err)
+				return
+			}
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{SubscriptionID: subs[i].ID, EventID: event.ID, EventType: eventType})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to encode webhook event", "event", eventType, "error", err)
+		return
+	}
+	for i := range deliveries {
+		deliveries[i].Payload = payload
+	}
+	if err := s.Repo.EnqueueDeliveries(ctx, deliveries); err != nil {
+		logger.ErrorContext(ctx, "failed to enqueue webhook deliveries", "event", eventType, "event_id", event.ID, "error", err)
+	}
+}
+
+// RunDeliveries разбирает очередь доставок каждые PollInterval, пока не отменён ctx.
+// Пока в очереди есть готовые доставки, следующая пачка берётся без паузы.
+func (s *WebhookService) RunDeliveries(ctx context.Context) {
+	ticker := time.NewTicker(s.Settings.PollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			sent, err := s.DeliverDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.ErrorContext(ctx, "webhook delivery run failed", "error", err)
+				}
+				break
+			}
+			if sent < s.Settings.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue отправляет параллельно одну пачку доставок, время которых наступило; возвращает их число
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.Repo.ClaimDueDeliveries(ctx, s.Settings.BatchSize, s.Settings.Timeout+webhookLeaseMargin)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			s.deliver(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// deliver выполняет одну попытку доставки и сохраняет её результат
+func (s *WebhookService) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	// Начатая попытка завершается и при остановке сервиса: её ограничивает таймаут клиента
+	ctx, span := tracer.Start(context.WithoutCancel(ctx), "WebhookService.deliver")
+	defer span.End()
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = nil
+
+	status, body, err := s.send(ctx, delivery)
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+	delivery.ResponseBody = body
+
+	result := metrics.WebhookSuccess
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+	case delivery.Attempts >= s.Settings.MaxAttempts:
+		result = metrics.WebhookFailed
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.LastError = err.Error()
+		logger.WarnContext(ctx, "webhook delivery failed permanently", "delivery_id", delivery.ID,
+			"subscription_id", delivery.SubscriptionID, "attempts", delivery.Attempts, "error", err)
+	default:
+		result = metrics.WebhookRetry
+		next := now.Add(webhookBackoff(delivery.Attempts, s.Settings.BackoffBase, s.Settings.BackoffMax))
+		delivery.NextAttemptAt = &next
+		delivery.LastError = err.Error()
+	}
+	metrics.WebhookDelivered(delivery.EventType, result)
+
+	if err := s.Repo.RecordAttempt(ctx, delivery); err != nil {
+		logger.ErrorContext(ctx, "failed to record webhook attempt", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send отправляет тело доставки получателю. Ошибка — сбой соединения или ответ не из 2xx;
+// статус и начало тела ответа возвращаются в обоих случаях, если ответ получен.
+func (s *WebhookService) send(ctx context.Context, delivery *models.WebhookDelivery) (int, string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "order-service-webhooks/1")
+	req.Header.Set(WebhookHeaderEventID, delivery.EventID)
+	req.Header.Set(WebhookHeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	req.Header.Set(WebhookHeaderSignature, "sha256="+SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	// Ответ сохраняется в TEXT: нулевые байты и неверный UTF-8 Postgres не примет
+	body := strings.ToValidUTF8(strings.ReplaceAll(string(data), "\x00", ""), "�")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, body, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// SignWebhook возвращает подпись доставки: hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Получатель пересчитывает её по заголовку X-Webhook-Timestamp и телу запроса.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff — пауза перед следующей попыткой после attempts неудачных: base, 2·base, 4·base…
+// не больше max, со случайной добавкой до 10%, чтобы повторы к одному получателю не шли разом
+func webhookBackoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay + mathrand.N(delay/10+1)
+}
+
+// validateWebhookURL допускает только абсолютные адреса http и https
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return models.NewFieldErrors(models.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+	return nil
+}
+
+// newWebhookSecret генерирует секрет подписки: 32 случайных байта в hex с префиксом whsec_
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
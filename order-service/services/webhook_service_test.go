@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"order-service/models"
+	"order-service/repositories"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryWebhookStore — очередь доставок в памяти для одной подписки. Методы подписок,
+// которые доставке не нужны, не реализованы: вызов встроенного nil-интерфейса паникует.
+type memoryWebhookStore struct {
+	webhookStore
+
+	mu         sync.Mutex
+	sub        models.WebhookSubscription
+	deliveries []*models.WebhookDelivery
+}
+
+func (m *memoryWebhookStore) EnqueueDeliveries(_ context.Context, deliveries []models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for i := range deliveries {
+		d := &deliveries[i]
+		d.ID = int64(len(m.deliveries) + 1)
+		d.Status = models.WebhookDeliveryPending
+		d.CreatedAt = now
+		d.NextAttemptAt = &now
+		stored := *d
+		m.deliveries = append(m.deliveries, &stored)
+	}
+	return nil
+}
+
+func (m *memoryWebhookStore) ClaimDueDeliveries(_ context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	deliveries := []models.WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		if d.Status != models.WebhookDeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		leased := now.Add(lease)
+		d.NextAttemptAt = &leased
+		claimed := *d
+		claimed.URL, claimed.Secret = m.sub.URL, m.sub.Secret
+		deliveries = append(deliveries, claimed)
+	}
+	return deliveries, nil
+}
+
+func (m *memoryWebhookStore) RecordAttempt(_ context.Context, d *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *d
+	stored.URL, stored.Secret = "", ""
+	m.deliveries[d.ID-1] = &stored
+	return nil
+}
+
+func (m *memoryWebhookStore) GetDelivery(_ context.Context, subscriptionID string, id int64) (*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if subscriptionID != m.sub.ID || id < 1 || id > int64(len(m.deliveries)) {
+		return nil, repositories.ErrDeliveryNotFound
+	}
+	d := *m.deliveries[id-1]
+	return &d, nil
+}
+
+// delivery возвращает сохранённое состояние доставки id
+func (m *memoryWebhookStore) delivery(id int64) models.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.deliveries[id-1]
+}
+
+// makeDue переносит следующую попытку доставки id в прошлое, не дожидаясь паузы
+func (m *memoryWebhookStore) makeDue(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	past := time.Now().Add(-time.Second)
+	m.deliveries[id-1].NextAttemptAt = &past
+}
+
+// webhookReceiver — получатель вебхуков, отвечающий status и запоминающий полученные запросы
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, status int) *webhookReceiver {
+	r := &webhookReceiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, receivedWebhook{header: req.Header.Clone(), body: body})
+		status := r.status
+		r.mu.Unlock()
+		w.WriteHeader(status)
+		io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *webhookReceiver) received() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.requests...)
+}
+
+var testWebhookSettings = WebhookSettings{
+	Timeout:     5 * time.Second,
+	MaxAttempts: 3,
+	BackoffBase: time.Minute,
+	BackoffMax:  time.Hour,
+	BatchSize:   10,
+}
+
+// newTestWebhookService возвращает сервис с подпиской на receiver и одной доставкой в очереди
+func newTestWebhookService(t *testing.T, receiver *webhookReceiver) (*WebhookService, *memoryWebhookStore) {
+	t.Helper()
+	store := &memoryWebhookStore{sub: models.WebhookSubscription{ID: "sub-1", URL: receiver.URL, Secret: "whsec_test", Active: true}}
+	service := &WebhookService{Repo: store, Client: receiver.Client(), Settings: testWebhookSettings}
+	err := store.EnqueueDeliveries(context.Background(), []models.WebhookDelivery{{
+		SubscriptionID: "sub-1",
+		EventID:        "evt-1",
+		EventType:      models.WebhookOrderStatusChanged,
+		Payload:        []byte(`{"id":"evt-1","type":"order.status_changed"}`),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, store
+}
+
+func deliverDue(t *testing.T, service *WebhookService, want int) {
+	t.Helper()
+	sent, err := service.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if sent != want {
+		t.Fatalf("DeliverDue sent %d deliveries, want %d", sent, want)
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"id":"evt-1"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000.{\"id\":\"evt-1\"}"))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignWebhook("secret", "1700000000", body); got != want {
+		t.Errorf("SignWebhook = %s, want %s", got, want)
+	}
+	if SignWebhook("secret", "1700000001", body) == want {
+		t.Error("signature does not depend on the timestamp")
+	}
+	if SignWebhook("other", "1700000000", body) == want {
+		t.Error("signature does not depend on the secret")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			got := webhookBackoff(tt.attempts, time.Minute, time.Hour)
+			if got < tt.want || got > tt.want+tt.want/10 {
+				t.Fatalf("webhookBackoff(%d) = %s, want %s plus up to 10%% jitter", tt.attempts, got, tt.want)
+			}
+		}
+	}
+}
+
+// TestDeliverDueSignsRequest проверяет, что получатель может проверить подпись по заголовку
+// X-Webhook-Timestamp и телу запроса
+func TestDeliverDueSignsRequest(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusNoContent)
+	service, store := newTestWebhookService(t, receiver)
+
+	deliverDue(t, service, 1)
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(req.header.Get(WebhookHeaderTimestamp) + "." + string(req.body)))
+	if got, want := req.header.Get(WebhookHeaderSignature), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("%s = %q, want %q", WebhookHeaderSignature, got, want)
+	}
+	if got := req.header.Get(WebhookHeaderEventID); got != "evt-1" {
+		t.Errorf("%s = %q, want evt-1", WebhookHeaderEventID, got)
+	}
+	if got := req.header.Get(WebhookHeaderDelivery); got != "1" {
+		t.Errorf("%s = %q, want 1", WebhookHeaderDelivery, got)
+	}
+
+	d := store.delivery(1)
+	if d.Status != models.WebhookDeliverySucceeded || d.Attempts != 1 || d.DeliveredAt == nil || d.NextAttemptAt != nil {
+		t.Errorf("delivery = status %s, attempts %d, delivered_at %v, next_attempt_at %v; want succeeded after 1 attempt",
+			d.Status, d.Attempts, d.DeliveredAt, d.NextAttemptAt)
+	}
+}
+
+// TestDeliverDueRetriesWithBackoff проверяет, что ответ не из 2xx откладывает доставку
+// на паузу webhookBackoff, а после MaxAttempts попыток доставка помечается failed
+func TestDeliverDueRetriesWithBackoff(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError)
+	service, store := newTestWebhookService(t, receiver)
+
+	for attempt := 1; attempt < testWebhookSettings.MaxAttempts; attempt++ {
+		before := time.Now()
+		deliverDue(t, service, 1)
+
+		d := store.delivery(1)
+		if d.Status != models.WebhookDeliveryPending || d.Attempts != attempt {
+			t.Fatalf("after attempt %d: status %s, attempts %d; want pending", attempt, d.Status, d.Attempts)
+		}
+		if d.ResponseStatus == nil || *d.ResponseStatus != http.StatusInternalServerError || d.LastError == "" {
+			t.Errorf("after attempt %d: response status %v, last error %q; want 500 and an error", attempt, d.ResponseStatus, d.LastError)
+		}
+		backoff := testWebhookSettings.BackoffBase << (attempt - 1)
+		if d.NextAttemptAt == nil || d.NextAttemptAt.Before(before.Add(backoff)) || d.NextAttemptAt.After(time.Now().Add(backoff+backoff/10)) {
+			t.Fatalf("after attempt %d: next attempt at %v, want about %s from now", attempt, d.NextAttemptAt, backoff)
+		}
+
+		// До конца паузы доставка не отправляется повторно
+		deliverDue(t, service, 0)
+		store.makeDue(1)
+	}
+
+	deliverDue(t, service, 1)
+	d := store.delivery(1)
+	if d.Status != models.WebhookDeliveryFailed || d.Attempts != testWebhookSettings.MaxAttempts || d.NextAttemptAt != nil {
+		t.Fatalf("after %d attempts: status %s, attempts %d, next attempt at %v; want failed",
+			testWebhookSettings.MaxAttempts, d.Status, d.Attempts, d.NextAttemptAt)
+	}
+	if got := len(receiver.received()); got != testWebhookSettings.MaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", got, testWebhookSettings.MaxAttempts)
+	}
+	deliverDue(t, service, 0)
+}
+
+// TestRedeliver проверяет, что повторная отправка ставит в очередь новую доставку
+// того же события, которую фоновая задача затем отправляет
+func TestRedeliver(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusBadGateway)
+	service, store := newTestWebhookService(t, receiver)
+	service.Settings.MaxAttempts = 1
+	deliverDue(t, service, 1)
+	if d := store.delivery(1); d.Status != models.WebhookDeliveryFailed {
+		t.Fatalf("original delivery status %s, want failed", d.Status)
+	}
+
+	receiver.setStatus(http.StatusOK)
+	redelivery, err := service.Redeliver(context.Background(), "sub-1", 1)
+	if err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	if redelivery.ID != 2 || redelivery.Status != models.WebhookDeliveryPending || redelivery.RedeliveryOf == nil || *redelivery.RedeliveryOf != 1 {
+		t.Fatalf("redelivery = id %d, status %s, redelivery_of %v; want a new pending delivery of 1",
+			redelivery.ID, redelivery.Status, redelivery.RedeliveryOf)
+	}
+	if redelivery.EventID != "evt-1" || string(redelivery.Payload) != string(store.delivery(1).Payload) {
+		t.Errorf("redelivery event %s, payload %s; want the original event", redelivery.EventID, redelivery.Payload)
+	}
+
+	deliverDue(t, service, 1)
+	if d := store.delivery(2); d.Status != models.WebhookDeliverySucceeded {
+		t.Errorf("redelivery status %s, want succeeded", d.Status)
+	}
+	if d := store.delivery(1); d.Status != models.WebhookDeliveryFailed || d.Attempts != 1 {
+		t.Errorf("original delivery changed: status %s, attempts %d", d.Status, d.Attempts)
+	}
+	requests := receiver.received()
+	if got := requests[len(requests)-1].header; got.Get(WebhookHeaderEventID) != "evt-1" || got.Get(WebhookHeaderDelivery) != "2" {
+		t.Errorf("redelivery headers: event %q, delivery %q; want evt-1 and 2", got.Get(WebhookHeaderEventID), got.Get(WebhookHeaderDelivery))
+	}
+
+	if _, err := service.Redeliver(context.Background(), "sub-1", 99); err != repositories.ErrDeliveryNotFound {
+		t.Errorf("Redeliver of unknown delivery: %v, want ErrDeliveryNotFound", err)
+	}
+}
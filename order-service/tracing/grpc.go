@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// grpcMetadata адаптирует метаданные gRPC к propagation.TextMapCarrier
+type grpcMetadata metadata.MD
+
+func (md grpcMetadata) Get(key string) string {
+	values := metadata.MD(md).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (md grpcMetadata) Set(key, value string) {
+	metadata.MD(md).Set(key, value)
+}
+
+func (md grpcMetadata) Keys() []string {
+	keys := make([]string, 0, len(md))
+	for key := range md {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+var _ propagation.TextMapCarrier = grpcMetadata{}
+
+// StartGRPCSpan начинает серверный спан вызова gRPC с контекстом трассы клиента
+// из входящих метаданных. fullMethod — "/orderservice.v1.OrderService/GetOrder".
+func StartGRPCSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, grpcMetadata(md))
+	}
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return otel.Tracer("order-service/grpc").Start(ctx, service+"/"+method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			semconv.RPCService(service),
+			semconv.RPCMethod(method),
+		))
+}
+
+// EndGRPCSpan записывает код статуса вызова и завершает спан. Ошибкой сервера
+// считаются только коды, которые не объясняются запросом клиента.
+func EndGRPCSpan(span trace.Span, code grpccodes.Code, err error) {
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if IsGRPCServerError(code) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// IsGRPCServerError сообщает, соответствует ли код ответу 5xx в HTTP
+func IsGRPCServerError(code grpccodes.Code) bool {
+	switch code {
+	case grpccodes.Unknown, grpccodes.Internal, grpccodes.Unavailable, grpccodes.DataLoss, grpccodes.Unimplemented, grpccodes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
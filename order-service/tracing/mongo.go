@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type mongoCommandKey struct {
+	connectionID string
+	requestID    int64
+}
+
+// MongoCommandMonitor создаёт спан на каждую команду MongoDB и передаёт события в next
+// (например, в монитор метрик). Тело команды в спан не попадает.
+func MongoCommandMonitor(next *event.CommandMonitor) *event.CommandMonitor {
+	if next == nil {
+		next = &event.CommandMonitor{}
+	}
+	tracer := otel.Tracer("order-service/mongodb")
+	var spans sync.Map
+
+	finish := func(key mongoCommandKey, failure string) {
+		value, ok := spans.LoadAndDelete(key)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		if failure != "" {
+			span.SetStatus(codes.Error, failure)
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemNameMongoDB,
+				semconv.DBNamespace(e.DatabaseName),
+				semconv.DBOperationName(e.CommandName),
+			}
+			// Первый элемент команды — имя коллекции ({"find": "products", ...})
+			if value, err := e.Command.IndexErr(0); err == nil {
+				if collection, ok := value.Value().StringValueOK(); ok {
+					attrs = append(attrs, semconv.DBCollectionName(collection))
+				}
+			}
+			_, span := tracer.Start(ctx, "mongodb "+e.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...))
+			spans.Store(mongoCommandKey{e.ConnectionID, e.RequestID}, span)
+
+			if next.Started != nil {
+				next.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finish(mongoCommandKey{e.ConnectionID, e.RequestID}, "")
+			if next.Succeeded != nil {
+				next.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finish(mongoCommandKey{e.ConnectionID, e.RequestID}, e.Failure)
+			if next.Failed != nil {
+				next.Failed(ctx, e)
+			}
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// ServiceName — имя сервиса в трассах
+const ServiceName = "order-service"
+
+// Экспортёры спанов
+const (
+	ExporterOTLP   = "otlp"   // OTLP/gRPC в коллектор
+	ExporterStdout = "stdout" // JSON в стандартный вывод
+	ExporterNone   = "none"   // спаны создаются, но никуда не отправляются
+)
+
+// Config — настройки трассировки
+type Config struct {
+	Exporter     string
+	OTLPEndpoint string // host:port коллектора; пусто — OTEL_EXPORTER_OTLP_ENDPOINT или localhost:4317
+	OTLPInsecure bool   // соединение с коллектором без TLS
+}
+
+// Init создаёт экспортёр по настройкам и регистрирует глобальный TracerProvider
+// и W3C-пропагатор (traceparent и baggage). Возвращает функцию, которая
+// отправляет оставшиеся спаны и останавливает провайдер.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracegrpc.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint))
+		}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		otlp, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		exporter = otlp
+	case ExporterStdout:
+		stdout, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		exporter = stdout
+	case ExporterNone, "":
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+
+	provider := NewTracerProvider(exporter)
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider регистрирует глобальный провайдер с данным экспортёром (nil — без экспорта).
+// Тесты передают сюда tracetest.NewInMemoryExporter() и проверяют записанные спаны.
+func NewTracerProvider(exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// TestTracedHandlerSpans проверяет по записанным в память спанам, что запрос к Gin продолжает
+// трассу из traceparent, а спан, начатый в обработчике, вложен в спан запроса
+func TestTracedHandlerSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewTracerProvider(exporter)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(otelgin.Middleware(ServiceName))
+	engine.GET("/orders/:id", func(c *gin.Context) {
+		_, span := otel.Tracer("order-service/services").Start(c.Request.Context(), "OrderService.GetOrderByID")
+		span.End()
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2: %v", len(spans), spans)
+	}
+	// Спаны записываются по завершении: вложенный раньше спана запроса
+	child, server := spans[0], spans[1]
+
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("request span trace ID %s, want the one from traceparent", got)
+	}
+	if got := server.Parent.SpanID().String(); got != "00f067aa0ba902b7" || !server.Parent.IsRemote() {
+		t.Errorf("request span parent %s (remote %t), want remote 00f067aa0ba902b7", got, server.Parent.IsRemote())
+	}
+	if child.Name != "OrderService.GetOrderByID" || child.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("span %q has parent %s, want a child of request span %s", child.Name, child.Parent.SpanID(), server.SpanContext.SpanID())
+	}
+	if got, _ := server.Resource.Set().Value(semconv.ServiceNameKey); got.AsString() != ServiceName {
+		t.Errorf("request span service.name %q, want %q", got.AsString(), ServiceName)
+	}
+	if server.Name != "GET /orders/:id" {
+		t.Errorf("request span name %q, want the route template", server.Name)
+	}
+}